@@ -26,9 +26,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/server/healthz"
@@ -61,6 +64,9 @@ func init() {
 
 var (
 	versionFlag bool
+	// logOptions are the options of the log format, nil when they are
+	// registered by the controller manager options
+	logOptions *logs.Options
 )
 
 func main() {
@@ -99,14 +105,36 @@ the cloud specific control loops shipped with Kubernetes.`,
 				version.PrintVersionAndExit()
 			}
 
+			if logOptions != nil {
+				if errs := logOptions.Validate(); len(errs) > 0 {
+					fmt.Fprintf(os.Stderr, "%v\n", utilerrors.NewAggregate(errs))
+					os.Exit(1)
+				}
+				logOptions.Apply()
+			}
+
 			flag.PrintFlags(cmd.Flags())
 
+			openstack.SetCloudConfigFile(s.KubeCloudShared.CloudProvider.CloudConfigFile)
+
 			c, err := s.Config(KnownControllers(), ControllersDisabledByDefault.List())
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				os.Exit(1)
 			}
 
+			go func() {
+				signals := make(chan os.Signal, 1)
+				signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+				<-signals
+				// A second signal terminates right away
+				signal.Stop(signals)
+
+				openstack.Shutdown()
+				logs.FlushLogs()
+				os.Exit(0)
+			}()
+
 			if err := app.Run(c.Complete(), wait.NeverStop); err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				os.Exit(1)
@@ -121,6 +149,12 @@ the cloud specific control loops shipped with Kubernetes.`,
 	}
 
 	fs.BoolVar(&versionFlag, "version", false, "Print version and exit")
+	if fs.Lookup("logging-format") == nil {
+		logOptions = logs.NewOptions()
+		logOptions.AddFlags(fs)
+	}
+
+	command.AddCommand(newPreflightCommand())
 
 	openstack.AddExtraFlags(pflag.CommandLine)
 
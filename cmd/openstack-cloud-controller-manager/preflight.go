@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack"
+)
+
+// newPreflightCommand creates the command validating the cloud config and
+// the access to the OpenStack services before the controllers are deployed
+func newPreflightCommand() *cobra.Command {
+	var cloudConfigFile string
+
+	command := &cobra.Command{
+		Use:   "preflight",
+		Short: "Validate the cloud config and the access to the OpenStack services",
+		Long: `Validate the cloud config, authenticate with it and check that the
+OpenStack services used by the controllers are reachable and readable with
+its credentials. The command exits with an error when a check fails.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			openstack.SetCloudConfigFile(cloudConfigFile)
+
+			failed := false
+			for _, result := range openstack.Preflight() {
+				switch {
+				case result.Skipped:
+					fmt.Printf("SKIP  %s: %s\n", result.Check, result.Hint)
+				case result.Err != nil:
+					failed = true
+					fmt.Printf("FAIL  %s: %v\n      %s\n", result.Check, result.Err, result.Hint)
+				default:
+					fmt.Printf("PASS  %s\n", result.Check)
+				}
+			}
+
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+
+	command.Flags().StringVar(&cloudConfigFile, "cloud-config", "", "The path to the cloud provider configuration file.")
+
+	return command
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package metrics
 
 import (
+	"strconv"
 	"sync"
 	"time"
 
@@ -68,17 +69,152 @@ var (
 	}
 )
 
+var (
+	serviceRequestDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name: "openstack_api_service_request_duration_seconds",
+			Help: "Latency of an OpenStack API call by OpenStack service",
+		}, []string{"service", "request"})
+
+	requestRetries = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "openstack_api_request_retries_total",
+			Help: "Total number of retries of OpenStack API calls failing with a transient error",
+		}, []string{"request"})
+
+	reauthentications = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "openstack_api_reauthentications_total",
+			Help: "Total number of re-authentications with an application credential by reason and result",
+		}, []string{"reason", "result"})
+
+	tokenExpiry = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Name: "openstack_api_token_expiry_timestamp_seconds",
+			Help: "Expiry of the earliest expiring token of the pooled OpenStack clients, in seconds since the epoch",
+		})
+
+	tokenRefreshes = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "openstack_api_token_refreshes_total",
+			Help: "Total number of tokens of the pooled OpenStack clients refreshed before they expired or once they were invalid, by reason and result",
+		}, []string{"reason", "result"})
+
+	rateLimiterWaiting = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "openstack_api_rate_limiter_waiting_requests",
+			Help: "Number of OpenStack API calls waiting for the rate limiter",
+		}, []string{"service"})
+
+	rateLimiterWaitDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name: "openstack_api_rate_limiter_wait_duration_seconds",
+			Help: "Time OpenStack API calls waited for the rate limiter",
+		}, []string{"service"})
+
+	openConnections = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "openstack_api_open_connections",
+			Help: "Number of open connections of the OpenStack HTTP client by endpoint",
+		}, []string{"endpoint"})
+
+	connectionDials = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "openstack_api_connection_dials_total",
+			Help: "Total number of connections dialed by the OpenStack HTTP client by endpoint and result",
+		}, []string{"endpoint", "result"})
+
+	connectionUses = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name: "openstack_api_connection_uses_total",
+			Help: "Total number of connections used by the OpenStack API calls by endpoint and whether an idle connection was reused",
+		}, []string{"endpoint", "reused"})
+
+	loadBalancerProvisioningDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:    "cloudprovider_openstack_loadbalancer_provisioning_duration_seconds",
+			Help:    "Time waited for the loadbalancers to be ACTIVE after they were created or updated, by provisioning status at the end of the wait",
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 180, 300, 600, 900, 1800},
+		}, []string{"operation", "status"})
+
+	instanceStatus = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cloudprovider_openstack_instances",
+			Help: "Number of instances backing the nodes by Nova status",
+		}, []string{"status"})
+
+	// instanceStatusLock guards instanceStatuses
+	instanceStatusLock sync.Mutex
+	// instanceStatuses is the last observed status of each instance
+	instanceStatuses = map[string]string{}
+
+	nodeZone = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name: "cloudprovider_openstack_nodes",
+			Help: "Number of nodes by the zone of their instance resolved by the cloud provider",
+		}, []string{"zone"})
+
+	// instanceZoneLock guards instanceZones
+	instanceZoneLock sync.Mutex
+	// instanceZones is the last resolved zone of each instance
+	instanceZones = map[string]string{}
+
+	metadataSyncsSkipped = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Name: "cloudprovider_openstack_instance_metadata_syncs_skipped_total",
+			Help: "Total number of node metadata syncs served from the metadata computed within the metadata sync interval",
+		})
+)
+
+// resourceServices maps the resources of the metric contexts to the type of
+// the OpenStack service serving them.
+var resourceServices = map[string]string{
+	"flavor":                     "compute",
+	"flavor_extra_specs":         "compute",
+	"server":                     "compute",
+	"server_group":               "compute",
+	"server_os_interface":        "compute",
+	"floating_ip":                "network",
+	"network":                    "network",
+	"network_extension":          "network",
+	"port":                       "network",
+	"port_tag":                   "network",
+	"router":                     "network",
+	"security_group":             "network",
+	"security_group_rule":        "network",
+	"subnet":                     "network",
+	"trunk":                      "network",
+	"loadbalancer":               "load-balancer",
+	"loadbalancer_flavor":        "load-balancer",
+	"loadbalancer_healthmonitor": "load-balancer",
+	"loadbalancer_listener":      "load-balancer",
+	"loadbalancer_member":        "load-balancer",
+	"loadbalancer_pool":          "load-balancer",
+	"loadbalancer_provider":      "load-balancer",
+	"version":                    "load-balancer",
+	"volume":                     "volumev3",
+	"container":                  "key-manager",
+	"secret":                     "key-manager",
+}
+
 // MetricContext indicates the context for OpenStack metrics.
 type MetricContext struct {
 	start      time.Time
 	attributes []string
+	service    string
 }
 
 // NewMetricContext creates a new MetricContext.
 func NewMetricContext(resource string, request string) *MetricContext {
+	service, ok := resourceServices[resource]
+	if !ok {
+		service = "unknown"
+	}
+
 	return &MetricContext{
 		start:      time.Now(),
 		attributes: []string{resource + "_" + request},
+		service:    service,
 	}
 }
 
@@ -96,8 +232,9 @@ func (mc *MetricContext) ObserveReconcile(err error) error {
 
 // ObserveRequest records the request latency and counts the errors.
 func (mc *MetricContext) ObserveRequest(err error) error {
-	requestMetrics.duration.WithLabelValues(mc.attributes...).Observe(
-		time.Since(mc.start).Seconds())
+	elapsed := time.Since(mc.start).Seconds()
+	requestMetrics.duration.WithLabelValues(mc.attributes...).Observe(elapsed)
+	serviceRequestDuration.WithLabelValues(append([]string{mc.service}, mc.attributes...)...).Observe(elapsed)
 	requestMetrics.total.WithLabelValues(mc.attributes...).Inc()
 	if err != nil {
 		requestMetrics.errors.WithLabelValues(mc.attributes...).Inc()
@@ -105,6 +242,134 @@ func (mc *MetricContext) ObserveRequest(err error) error {
 	return err
 }
 
+// ObserveRetry counts a retry of a request.
+func (mc *MetricContext) ObserveRetry() {
+	requestRetries.WithLabelValues(mc.attributes...).Inc()
+}
+
+// ObserveReauthentication counts a re-authentication.
+func ObserveReauthentication(reason string, err error) error {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	reauthentications.WithLabelValues(reason, result).Inc()
+	return err
+}
+
+// ObserveTokenExpiry records the expiry of the earliest expiring token.
+func ObserveTokenExpiry(expiresAt time.Time) {
+	tokenExpiry.Set(float64(expiresAt.Unix()))
+}
+
+// ObserveTokenRefresh counts a token refresh.
+func ObserveTokenRefresh(reason string, err error) error {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	tokenRefreshes.WithLabelValues(reason, result).Inc()
+	return err
+}
+
+// ObserveRateLimiterWait records the requests waiting for the rate limiter
+// of a service while wait runs.
+func ObserveRateLimiterWait(service string, wait func() error) error {
+	start := time.Now()
+	rateLimiterWaiting.WithLabelValues(service).Inc()
+	defer func() {
+		rateLimiterWaiting.WithLabelValues(service).Dec()
+		rateLimiterWaitDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+	}()
+	return wait()
+}
+
+// ObserveConnectionDial counts a connection dialed to an endpoint.
+func ObserveConnectionDial(endpoint string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	} else {
+		openConnections.WithLabelValues(endpoint).Inc()
+	}
+	connectionDials.WithLabelValues(endpoint, result).Inc()
+}
+
+// ObserveConnectionClose counts a connection to an endpoint closed.
+func ObserveConnectionClose(endpoint string) {
+	openConnections.WithLabelValues(endpoint).Dec()
+}
+
+// ObserveConnectionUse counts a connection used by a request to an endpoint.
+func ObserveConnectionUse(endpoint string, reused bool) {
+	connectionUses.WithLabelValues(endpoint, strconv.FormatBool(reused)).Inc()
+}
+
+// ObserveLoadBalancerProvisioning records the time waited since start for a
+// loadbalancer to be ACTIVE after the operation, with its last status.
+func ObserveLoadBalancerProvisioning(operation string, status string, start time.Time) {
+	if status == "" {
+		status = "unknown"
+	}
+	loadBalancerProvisioningDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+// ObserveInstanceStatus records the current status of an instance.
+func ObserveInstanceStatus(instanceID string, status string) {
+	instanceStatusLock.Lock()
+	defer instanceStatusLock.Unlock()
+
+	previous, found := instanceStatuses[instanceID]
+	if found && previous == status {
+		return
+	}
+	if found {
+		instanceStatus.WithLabelValues(previous).Dec()
+	}
+	instanceStatuses[instanceID] = status
+	instanceStatus.WithLabelValues(status).Inc()
+}
+
+// ObserveInstanceZone records the zone resolved for the instance of a node.
+func ObserveInstanceZone(instanceID string, zone string) {
+	instanceZoneLock.Lock()
+	defer instanceZoneLock.Unlock()
+
+	previous, found := instanceZones[instanceID]
+	if found && previous == zone {
+		return
+	}
+	if found {
+		nodeZone.WithLabelValues(previous).Dec()
+	}
+	instanceZones[instanceID] = zone
+	nodeZone.WithLabelValues(zone).Inc()
+}
+
+// ObserveMetadataSyncSkipped counts a node metadata sync served without
+// looking up the server of the node.
+func ObserveMetadataSyncSkipped() {
+	metadataSyncsSkipped.Inc()
+}
+
+// ForgetInstance stops recording the status and the zone of an instance which
+// no longer exists or no longer backs a node.
+func ForgetInstance(instanceID string) {
+	instanceStatusLock.Lock()
+	if previous, found := instanceStatuses[instanceID]; found {
+		instanceStatus.WithLabelValues(previous).Dec()
+		delete(instanceStatuses, instanceID)
+	}
+	instanceStatusLock.Unlock()
+
+	instanceZoneLock.Lock()
+	defer instanceZoneLock.Unlock()
+	if previous, found := instanceZones[instanceID]; found {
+		nodeZone.WithLabelValues(previous).Dec()
+		delete(instanceZones, instanceID)
+	}
+}
+
 var registerMetrics sync.Once
 
 // RegisterMetrics registers OpenStack metrics.
@@ -117,6 +382,20 @@ func RegisterMetrics() {
 			requestMetrics.duration,
 			requestMetrics.total,
 			requestMetrics.errors,
+			serviceRequestDuration,
+			requestRetries,
+			reauthentications,
+			tokenExpiry,
+			tokenRefreshes,
+			rateLimiterWaiting,
+			rateLimiterWaitDuration,
+			openConnections,
+			connectionDials,
+			connectionUses,
+			loadBalancerProvisioningDuration,
+			instanceStatus,
+			nodeZone,
+			metadataSyncsSkipped,
 		)
 	})
 }
@@ -25,7 +25,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net"
-	"net/http"
 	"os"
 	"regexp"
 	"runtime"
@@ -42,6 +41,9 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/extensions/trusts"
 	tokens3 "github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/trunks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/pagination"
 	"github.com/gophercloud/utils/client"
 	"github.com/gophercloud/utils/openstack/clientconfig"
@@ -50,8 +52,17 @@ import (
 	gcfg "gopkg.in/gcfg.v1"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
-	netutil "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	certutil "k8s.io/client-go/util/cert"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
@@ -64,10 +75,24 @@ const (
 	// ProviderName is the name of the openstack provider
 	ProviderName = "openstack"
 
+	// RegionalProviderIDEnv is the environment variable enabling the regional
+	// providerID format "openstack://${region}/${instance-id}"
+	RegionalProviderIDEnv = "OS_CCM_REGIONAL"
+
+	// ProviderIDSchemeEnv is the environment variable replacing the scheme
+	// "openstack" of the providerIDs set with the --provider-id flag of the
+	// kubelets, the controller manager always uses "openstack"
+	ProviderIDSchemeEnv = "OS_CCM_PROVIDER_ID_SCHEME"
+
 	// TypeHostName is the name type of openstack instance
 	TypeHostName     = "hostname"
 	availabilityZone = "availability_zone"
 	defaultTimeOut   = 60 * time.Second
+
+	// flavorCacheSize is the maximum number of flavors kept in the flavor cache
+	flavorCacheSize = 1000
+	// serverWarmupCacheSize is the maximum number of servers kept in the warmup cache
+	serverWarmupCacheSize = 10000
 )
 
 // ErrNotFound is used to inform that the object is missing
@@ -83,12 +108,36 @@ var ErrNoAddressFound = errors.New("no address found for host")
 // IPv6 support is disabled by config
 var ErrIPv6SupportDisabled = errors.New("IPv6 support is disabled")
 
+// ErrDryRun is used when the instances don't return the metadata of a node
+// because they run in dry-run mode
+var ErrDryRun = errors.New("instances are running in dry-run mode")
+
 // userAgentData is used to add extra information to the gophercloud user-agent
 var userAgentData []string
 
+// instancesDryRun makes the instances resolve the node metadata without applying it
+var instancesDryRun bool
+
+// cloudConfigFile is the path of the --cloud-config file, it is read again to
+// load a rotated application credential
+var cloudConfigFile string
+
+// configOverrides are the paths of the cloud config files read in order after
+// the --cloud-config file, their keys override the ones read before
+var configOverrides []string
+
 // AddExtraFlags is called by the main package to add component specific command line flags
 func AddExtraFlags(fs *pflag.FlagSet) {
 	fs.StringArrayVar(&userAgentData, "user-agent", nil, "Extra data to add to gophercloud user-agent. Use multiple times to add more than one component.")
+	fs.BoolVar(&instancesDryRun, "instances-dry-run", false, "Log the metadata, labels, shutdown taints and deletions the instances would apply to the nodes instead of applying them. Load balancers and routes are not affected.")
+	fs.StringArrayVar(&configOverrides, "cloud-config-override", nil, "Path to a cloud config file read after --cloud-config, whose keys override the ones of the previous files. Use multiple times to add more than one file.")
+	fs.DurationVar(&shutdownGracePeriod, "shutdown-grace-period", defaultShutdownGracePeriod, "How long the in-flight OpenStack operations may run on termination before their requests are canceled.")
+}
+
+// SetCloudConfigFile is called by the main package with the path of the
+// --cloud-config file
+func SetCloudConfigFile(path string) {
+	cloudConfigFile = path
 }
 
 // MyDuration is the encoding.TextUnmarshaler interface for time.Duration
@@ -111,7 +160,12 @@ type LoadBalancer struct {
 	network *gophercloud.ServiceClient
 	compute *gophercloud.ServiceClient
 	lb      *gophercloud.ServiceClient
-	opts    LoadBalancerOpts
+	// secret is the key-manager client, nil when Barbican is not available
+	secret        *gophercloud.ServiceClient
+	opts          LoadBalancerOpts
+	eventRecorder record.EventRecorder
+	// health is shared by the LoadBalancer instances to record the transitions of the load balancer statuses
+	health *loadBalancerHealth
 }
 
 // LoadBalancerOpts have the options to talk to Neutron LBaaSV2 or Octavia
@@ -135,6 +189,9 @@ type LoadBalancerOpts struct {
 	CascadeDelete        bool                `gcfg:"cascade-delete"` // applicable only if use-octavia is set to True
 	FlavorID             string              `gcfg:"flavor-id"`
 	AvailabilityZone     string              `gcfg:"availability-zone"`
+	CleanupOrphans       bool                `gcfg:"cleanup-orphaned-load-balancers"` // delete the load balancers of the deleted Services on startup
+	ClusterName          string              `gcfg:"cluster-name"`                    // must match the --cluster-name of the controller manager
+	AllowedNamespaces    []string            `gcfg:"allowed-namespace"`               // the namespaces whose Services may have a load balancer, all when empty
 }
 
 // LBClass defines the corresponding floating network, floating subnet or internal subnet ID
@@ -157,6 +214,169 @@ type NetworkingOpts struct {
 	IPv6SupportDisabled bool     `gcfg:"ipv6-support-disabled"`
 	PublicNetworkName   []string `gcfg:"public-network-name"`
 	InternalNetworkName []string `gcfg:"internal-network-name"`
+	IPVersionPreference string   `gcfg:"ip-version-preference"` // "ipv4" or "ipv6", lists the addresses of this IP family first
+	InternalNetworkIDs  []string // Do not specify, resolved from internal-network-name at startup
+	// ExternalIPv4Source and ExternalIPv6Source restrict the addresses of each IP family
+	// classified as ExternalIP to the floating IPs or to the fixed IPs
+	ExternalIPv4Source string `gcfg:"external-ipv4-source"`
+	ExternalIPv6Source string `gcfg:"external-ipv6-source"`
+	// ExternalIPAllowCIDR and ExternalIPDenyCIDR restrict the addresses
+	// classified as ExternalIP to the allowed CIDRs, when set, out of the
+	// denied CIDRs
+	ExternalIPAllowCIDR []string `gcfg:"external-ip-allow-cidr"`
+	ExternalIPDenyCIDR  []string `gcfg:"external-ip-deny-cidr"`
+	// ExcludeDeviceOwner lists the device owners of the ports whose fixed IPs
+	// are not node addresses, replacing defaultExcludedDeviceOwners when set
+	ExcludeDeviceOwner []string `gcfg:"exclude-device-owner"`
+	// AllowedAddressPairCIDR lists the CIDRs of the IPv6 allowed address pairs
+	// of the server ports listed as node addresses, e.g. for prefix delegation
+	AllowedAddressPairCIDR []string `gcfg:"allowed-address-pair-cidr"`
+	// AddressTranslation lists the "<from-cidr>-><to-cidr>" translations of
+	// the node addresses, e.g. when the fixed IPs are NAT-translated
+	AddressTranslation []string `gcfg:"address-translation"`
+	// DNSNodeAddresses lists the server name as the Hostname address and the
+	// DNS names of the server ports as InternalDNS addresses
+	DNSNodeAddresses bool `gcfg:"dns-node-addresses"`
+	// NetworkRole lists the "<network-name>:<role>" roles, internal or
+	// external, of the networks whose addresses are the node addresses, in
+	// order, replacing the classification by public-network-name and
+	// internal-network-name when set
+	NetworkRole []string `gcfg:"network-role"`
+	// NovaAddressesFallback reads the node addresses from the Nova server
+	// addresses only, without listing the server interfaces, in the regions
+	// where Neutron is not available
+	NovaAddressesFallback bool `gcfg:"nova-addresses-fallback"`
+	// CheckPortBindings warns about the server ports bound to another compute
+	// host than the server
+	CheckPortBindings bool `gcfg:"check-port-bindings"`
+}
+
+const (
+	networkRoleInternal = "internal"
+	networkRoleExternal = "external"
+)
+
+// networkRole is the node address type of the addresses of a network
+type networkRole struct {
+	network     string
+	addressType v1.NodeAddressType
+}
+
+// parseNetworkRoles parses the "<network-name>:<role>" network roles, each
+// network has a single role.
+func parseNetworkRoles(values []string) ([]networkRole, error) {
+	var roles []networkRole
+	networks := sets.NewString()
+	for _, value := range values {
+		sep := strings.LastIndex(value, ":")
+		if sep <= 0 {
+			return nil, fmt.Errorf("%q is not of the form <network-name>:<role>", value)
+		}
+		network, role := strings.TrimSpace(value[:sep]), strings.TrimSpace(value[sep+1:])
+		if networks.Has(network) {
+			return nil, fmt.Errorf("%q: network %s has several roles", value, network)
+		}
+		networks.Insert(network)
+
+		switch role {
+		case networkRoleInternal:
+			roles = append(roles, networkRole{network: network, addressType: v1.NodeInternalIP})
+		case networkRoleExternal:
+			roles = append(roles, networkRole{network: network, addressType: v1.NodeExternalIP})
+		default:
+			return nil, fmt.Errorf("%q: unsupported role %q, supported roles are %q and %q", value, role, networkRoleInternal, networkRoleExternal)
+		}
+	}
+	return roles, nil
+}
+
+// defaultExcludedDeviceOwners are the device owners of the infrastructure
+// ports excluded from the node addresses by default
+var defaultExcludedDeviceOwners = []string{
+	"network:dhcp",
+	"network:floatingip",
+	"network:ha_router_replicated_interface",
+	"network:router_gateway",
+	"network:router_ha_interface",
+	"network:router_interface",
+	"network:router_interface_distributed",
+}
+
+// excludedDeviceOwners returns the device owners of the ports excluded from
+// the node addresses
+func (opts NetworkingOpts) excludedDeviceOwners() []string {
+	if len(opts.ExcludeDeviceOwner) > 0 {
+		return opts.ExcludeDeviceOwner
+	}
+	return defaultExcludedDeviceOwners
+}
+
+const (
+	ipVersionPreferenceIPv4 = "ipv4"
+	ipVersionPreferenceIPv6 = "ipv6"
+
+	externalIPSourceAny      = "any"
+	externalIPSourceFloating = "floating"
+	externalIPSourceFixed    = "fixed"
+)
+
+// InstancesOpts is used for instances settings
+type InstancesOpts struct {
+	ExposeFaultReason bool       `gcfg:"expose-fault-reason"`
+	FlavorCacheTTL    MyDuration `gcfg:"flavor-cache-ttl"`
+	// NodeNameMetadataKey is the server metadata key holding the node name,
+	// used to find the server of a node without providerID.
+	NodeNameMetadataKey string `gcfg:"node-name-metadata-key"`
+	// ShutdownSuspended makes instances in PAUSED or SUSPENDED state
+	// reported as shut down.
+	ShutdownSuspended bool `gcfg:"shutdown-suspended"`
+	// APITimeout limits the duration of each OpenStack API request
+	APITimeout MyDuration `gcfg:"api-timeout"`
+	// APIMaxRetries is the number of retries of the requests failing with a
+	// transient error, each retry waits twice as long as the previous one
+	// starting from APIRetryDelay
+	APIMaxRetries uint       `gcfg:"api-max-retries"`
+	APIRetryDelay MyDuration `gcfg:"api-retry-delay"`
+	// LookupBackoff is the first backoff of the metadata lookups of a node
+	// failing with a transient error, doubling up to LookupBackoffMax
+	LookupBackoff    MyDuration `gcfg:"lookup-backoff"`
+	LookupBackoffMax MyDuration `gcfg:"lookup-backoff-max"`
+	// ZoneMetadataKey is the server metadata key overriding the availability
+	// zone reported as the zone of the node
+	ZoneMetadataKey string `gcfg:"zone-metadata-key"`
+	// TagLabels is the list of server tags exposed as node labels
+	TagLabels []string `gcfg:"tag-labels"`
+	// MetadataLabels is the list of server metadata keys exposed as node
+	// labels
+	MetadataLabels []string `gcfg:"metadata-labels"`
+	// FlavorLabels exposes the vCPUs, RAM and disk of the server flavor as
+	// node labels
+	FlavorLabels bool `gcfg:"flavor-labels"`
+	// ServerGroupLabel exposes the Nova server group of the server as a node
+	// label
+	ServerGroupLabel bool `gcfg:"server-group-label"`
+	// AdditionalRegions are the regions other than the cloud provider region
+	// where nodes can run, their nodes must have a regional providerID
+	AdditionalRegions []string `gcfg:"additional-region"`
+	// DisableNameLookup prevents looking up the servers of the nodes without
+	// providerID by their name
+	DisableNameLookup bool `gcfg:"disable-name-lookup"`
+	// FlavorClassExtraSpec is the flavor extra spec reported as the instance
+	// type instead of the flavor name when set
+	FlavorClassExtraSpec string `gcfg:"flavor-class-extra-spec"`
+	// WarmupCacheTTL makes the first lookup of a server by ID list all the
+	// servers of the project, the lookups are served from the list for this
+	// duration
+	WarmupCacheTTL MyDuration `gcfg:"warmup-cache-ttl"`
+	// ComputeHostLabel is the compute host attribute of the servers, "host-id"
+	// or "host", exposed as a node label when set
+	ComputeHostLabel string `gcfg:"compute-host-label"`
+	// NodeSelector is the label selector of the nodes backed by servers, the
+	// nodes not matching are externally managed and never looked up
+	NodeSelector string `gcfg:"node-selector"`
+	// MetadataSyncInterval is the minimum interval between the metadata
+	// lookups of a node, a negative interval disables it
+	MetadataSyncInterval MyDuration `gcfg:"metadata-sync-interval"`
 }
 
 // RouterOpts is used for Neutron routes
@@ -177,27 +397,39 @@ type ServerAttributesExt struct {
 
 // OpenStack is an implementation of cloud provider Interface for OpenStack.
 type OpenStack struct {
-	provider       *gophercloud.ProviderClient
-	region         string
-	lbOpts         LoadBalancerOpts
-	bsOpts         BlockStorageOpts
-	routeOpts      RouterOpts
-	metadataOpts   MetadataOpts
-	networkingOpts NetworkingOpts
+	provider         *gophercloud.ProviderClient
+	region           string
+	projectID        string
+	regionProviderID bool
+	lbOpts           LoadBalancerOpts
+	bsOpts           BlockStorageOpts
+	routeOpts        RouterOpts
+	metadataOpts     MetadataOpts
+	networkingOpts   NetworkingOpts
+	instancesOpts    InstancesOpts
+	kclient          kubernetes.Interface
+	eventRecorder    record.EventRecorder
+	nodes            toolscache.Indexer
+	flavorCache      *cache.LRUExpireCache
+	serverWarmup     *serverWarmup
+	serverGroups     *serverGroupMembers
+	lookupBackoff    *lookupBackoff
+	metadataSyncs    *metadataDebounce
+	lbHealth         *loadBalancerHealth
 	// InstanceID of the server where this OpenStack object is instantiated.
 	localInstanceID string
 }
 
 type AuthOpts struct {
-	AuthURL          string `gcfg:"auth-url" mapstructure:"auth-url" name:"os-authURL" dependsOn:"os-password|os-trustID|os-applicationCredentialSecret|os-clientCertPath"`
+	AuthURL          string `gcfg:"auth-url" mapstructure:"auth-url" name:"os-authURL" dependsOn:"os-password|os-trustID|os-applicationCredentialSecret|os-clientCertPath|os-identityProvider"`
 	UserID           string `gcfg:"user-id" mapstructure:"user-id" name:"os-userID" value:"optional" dependsOn:"os-password"`
 	Username         string `name:"os-userName" value:"optional" dependsOn:"os-password"`
 	Password         string `name:"os-password" value:"optional" dependsOn:"os-domainID|os-domainName,os-projectID|os-projectName,os-userID|os-userName"`
-	TenantID         string `gcfg:"tenant-id" mapstructure:"project-id" name:"os-projectID" value:"optional" dependsOn:"os-password|os-clientCertPath"`
-	TenantName       string `gcfg:"tenant-name" mapstructure:"project-name" name:"os-projectName" value:"optional" dependsOn:"os-password|os-clientCertPath"`
+	TenantID         string `gcfg:"tenant-id" mapstructure:"project-id" name:"os-projectID" value:"optional" dependsOn:"os-password|os-clientCertPath|os-identityProvider"`
+	TenantName       string `gcfg:"tenant-name" mapstructure:"project-name" name:"os-projectName" value:"optional" dependsOn:"os-password|os-clientCertPath|os-identityProvider"`
 	TrustID          string `gcfg:"trust-id" mapstructure:"trust-id" name:"os-trustID" value:"optional"`
-	DomainID         string `gcfg:"domain-id" mapstructure:"domain-id" name:"os-domainID" value:"optional" dependsOn:"os-password|os-clientCertPath"`
-	DomainName       string `gcfg:"domain-name" mapstructure:"domain-name" name:"os-domainName" value:"optional" dependsOn:"os-password|os-clientCertPath"`
+	DomainID         string `gcfg:"domain-id" mapstructure:"domain-id" name:"os-domainID" value:"optional" dependsOn:"os-password|os-clientCertPath|os-identityProvider"`
+	DomainName       string `gcfg:"domain-name" mapstructure:"domain-name" name:"os-domainName" value:"optional" dependsOn:"os-password|os-clientCertPath|os-identityProvider"`
 	TenantDomainID   string `gcfg:"tenant-domain-id" mapstructure:"project-domain-id" name:"os-projectDomainID" value:"optional"`
 	TenantDomainName string `gcfg:"tenant-domain-name" mapstructure:"project-domain-name" name:"os-projectDomainName" value:"optional"`
 	UserDomainID     string `gcfg:"user-domain-id" mapstructure:"user-domain-id" name:"os-userDomainID" value:"optional"`
@@ -212,9 +444,11 @@ type AuthOpts struct {
 	// Manila only options
 	TLSInsecure string `name:"os-TLSInsecure" value:"optional" matches:"^true|false$"`
 	// backward compatibility with the manila-csi-plugin
-	CAFileContents  string `name:"os-certAuthority" value:"optional"`
-	TrusteeID       string `name:"os-trusteeID" value:"optional" dependsOn:"os-trustID"`
-	TrusteePassword string `name:"os-trusteePassword" value:"optional" dependsOn:"os-trustID"`
+	CAFileContents string `name:"os-certAuthority" value:"optional"`
+
+	// Trustee credentials used to consume the trust, default to the user credentials
+	TrusteeID       string `gcfg:"trustee-id" mapstructure:"trustee-id" name:"os-trusteeID" value:"optional" dependsOn:"os-trustID"`
+	TrusteePassword string `gcfg:"trustee-password" mapstructure:"trustee-password" name:"os-trusteePassword" value:"optional" dependsOn:"os-trustID"`
 
 	UseClouds  bool   `gcfg:"use-clouds" mapstructure:"use-clouds" name:"os-useClouds" value:"optional"`
 	CloudsFile string `gcfg:"clouds-file,omitempty" mapstructure:"clouds-file,omitempty" name:"os-cloudsFile" value:"optional"`
@@ -223,6 +457,12 @@ type AuthOpts struct {
 	ApplicationCredentialID     string `gcfg:"application-credential-id" mapstructure:"application-credential-id" name:"os-applicationCredentialID" value:"optional"`
 	ApplicationCredentialName   string `gcfg:"application-credential-name" mapstructure:"application-credential-name" name:"os-applicationCredentialName" value:"optional"`
 	ApplicationCredentialSecret string `gcfg:"application-credential-secret" mapstructure:"application-credential-secret" name:"os-applicationCredentialSecret" value:"optional"`
+
+	// Keystone federation, the access token of the identity provider is exchanged for a token
+	IdentityProvider string `gcfg:"identity-provider" mapstructure:"identity-provider" name:"os-identityProvider" value:"optional" dependsOn:"os-protocol,os-accessToken|os-accessTokenFile"`
+	Protocol         string `gcfg:"protocol" mapstructure:"protocol" name:"os-protocol" value:"optional" dependsOn:"os-identityProvider"`
+	AccessToken      string `gcfg:"access-token" mapstructure:"access-token" name:"os-accessToken" value:"optional" dependsOn:"os-identityProvider"`
+	AccessTokenFile  string `gcfg:"access-token-file" mapstructure:"access-token-file" name:"os-accessTokenFile" value:"optional" dependsOn:"os-identityProvider"`
 }
 
 // Config is used to read and store information from the cloud configuration file
@@ -234,6 +474,10 @@ type Config struct {
 	Route             RouterOpts
 	Metadata          MetadataOpts
 	Networking        NetworkingOpts
+	Instances         InstancesOpts
+	RateLimit         RateLimitOpts
+	RateLimitService  map[string]*RateLimitOpts
+	Transport         TransportOpts
 }
 
 func LogCfg(cfg Config) {
@@ -243,6 +487,7 @@ func LogCfg(cfg Config) {
 	klog.V(5).Infof("TenantID: %s", cfg.Global.TenantID)
 	klog.V(5).Infof("TenantName: %s", cfg.Global.TenantName)
 	klog.V(5).Infof("TrustID: %s", cfg.Global.TrustID)
+	klog.V(5).Infof("TrusteeID: %s", cfg.Global.TrusteeID)
 	klog.V(5).Infof("DomainID: %s", cfg.Global.DomainID)
 	klog.V(5).Infof("DomainName: %s", cfg.Global.DomainName)
 	klog.V(5).Infof("TenantDomainID: %s", cfg.Global.TenantDomainID)
@@ -258,6 +503,9 @@ func LogCfg(cfg Config) {
 	klog.V(5).Infof("Cloud: %s", cfg.Global.Cloud)
 	klog.V(5).Infof("ApplicationCredentialID: %s", cfg.Global.ApplicationCredentialID)
 	klog.V(5).Infof("ApplicationCredentialName: %s", cfg.Global.ApplicationCredentialName)
+	klog.V(5).Infof("IdentityProvider: %s", cfg.Global.IdentityProvider)
+	klog.V(5).Infof("Protocol: %s", cfg.Global.Protocol)
+	klog.V(5).Infof("AccessTokenFile: %s", cfg.Global.AccessTokenFile)
 }
 
 type Logger struct{}
@@ -370,9 +618,10 @@ func (cfg AuthOpts) ToAuth3Options() tokens3.AuthOptions {
 	}
 }
 
-// ReadConfig reads values from the cloud.conf
+// ReadConfig reads values from the cloud.conf, then from the files given by
+// --cloud-config-override in order
 func ReadConfig(config io.Reader) (Config, error) {
-	if config == nil {
+	if config == nil && len(configOverrides) == 0 {
 		return Config{}, fmt.Errorf("no OpenStack cloud provider config file given")
 	}
 	var cfg Config
@@ -388,10 +637,26 @@ func ReadConfig(config io.Reader) (Config, error) {
 	cfg.LoadBalancer.MonitorTimeout = MyDuration{3 * time.Second}
 	cfg.LoadBalancer.MonitorMaxRetries = 1
 	cfg.LoadBalancer.CascadeDelete = true
-
-	err := gcfg.FatalOnly(gcfg.ReadInto(&cfg, config))
-	if err != nil {
-		return Config{}, err
+	cfg.LoadBalancer.ClusterName = "kubernetes"
+	cfg.Instances.FlavorCacheTTL = MyDuration{10 * time.Minute}
+	cfg.Instances.APITimeout = MyDuration{defaultAPITimeout}
+	cfg.Instances.APIMaxRetries = 3
+	cfg.Instances.APIRetryDelay = MyDuration{time.Second}
+
+	if config != nil {
+		err := gcfg.FatalOnly(gcfg.ReadInto(&cfg, config))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read the cloud config file: %v", err)
+		}
+	}
+	for _, path := range configOverrides {
+		err := gcfg.FatalOnly(gcfg.ReadFileInto(&cfg, path))
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read the cloud config override file %s: %v", path, err)
+		}
+	}
+	if err := ApplyAuthEnv(&cfg.Global); err != nil {
+		return Config{}, fmt.Errorf("failed to read the [Global] options from the environment: %v", err)
 	}
 
 	klog.V(5).Infof("Config, loaded from the config file:")
@@ -401,7 +666,7 @@ func ReadConfig(config io.Reader) (Config, error) {
 		if cfg.Global.CloudsFile != "" {
 			os.Setenv("OS_CLIENT_CONFIG_FILE", cfg.Global.CloudsFile)
 		}
-		err = ReadClouds(&cfg)
+		err := ReadClouds(&cfg)
 		if err != nil {
 			return Config{}, err
 		}
@@ -413,7 +678,40 @@ func ReadConfig(config io.Reader) (Config, error) {
 		cfg.Metadata.SearchOrder = fmt.Sprintf("%s,%s", metadata.ConfigDriveID, metadata.MetadataID)
 	}
 
-	return cfg, err
+	// Fail on the TLS files which can't be used before connecting to OpenStack
+	if _, err := TLSConfig(&cfg.Global); err != nil {
+		return Config{}, fmt.Errorf("invalid TLS configuration in section [Global]: %v", err)
+	}
+	if err := checkTransportOpts(cfg.Transport); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// readCloudConfig reads the --cloud-config file and its override files
+func readCloudConfig() (Config, error) {
+	var config io.Reader
+	if cloudConfigFile != "" {
+		f, err := os.Open(cloudConfigFile)
+		if err != nil {
+			return Config{}, err
+		}
+		defer f.Close()
+		config = f
+	}
+
+	return ReadConfig(config)
+}
+
+// reloadAuthOpts reads the authentication options from the cloud config files
+// again
+func reloadAuthOpts() (*AuthOpts, error) {
+	cfg, err := readCloudConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg.Global, nil
 }
 
 // replaceEmpty is a helper function to replace empty fields with another field
@@ -496,56 +794,147 @@ func readInstanceID(searchOrder string) (string, error) {
 
 // check opts for OpenStack
 func checkOpenStackOpts(openstackOpts *OpenStack) error {
+	if err := checkNetworkingOpts(openstackOpts.networkingOpts); err != nil {
+		return err
+	}
+	switch openstackOpts.instancesOpts.ComputeHostLabel {
+	case "", computeHostLabelHostID, computeHostLabelHost:
+	default:
+		return fmt.Errorf("invalid value %q in section [Instances] with key `compute-host-label`. Supported values are %q and %q",
+			openstackOpts.instancesOpts.ComputeHostLabel, computeHostLabelHostID, computeHostLabelHost)
+	}
+	if _, err := labels.Parse(openstackOpts.instancesOpts.NodeSelector); err != nil {
+		return fmt.Errorf("invalid value %q in section [Instances] with key `node-selector`: %v",
+			openstackOpts.instancesOpts.NodeSelector, err)
+	}
 	return checkMetadataSearchOrder(openstackOpts.metadataOpts.SearchOrder)
 }
 
-// NewOpenStackClient creates a new instance of the openstack client
-func NewOpenStackClient(cfg *AuthOpts, userAgent string, extraUserAgent ...string) (*gophercloud.ProviderClient, error) {
-	provider, err := openstack.NewClient(cfg.AuthURL)
-	if err != nil {
-		return nil, err
+func checkNetworkingOpts(opts NetworkingOpts) error {
+	switch opts.IPVersionPreference {
+	case "", ipVersionPreferenceIPv4:
+	case ipVersionPreferenceIPv6:
+		if opts.IPv6SupportDisabled {
+			return errors.New("invalid value in section [Networking] with key `ip-version-preference`. Value cannot be ipv6 when IPv6 support is disabled")
+		}
+	default:
+		return fmt.Errorf("invalid value %q in section [Networking] with key `ip-version-preference`. Supported values are %q and %q",
+			opts.IPVersionPreference, ipVersionPreferenceIPv4, ipVersionPreferenceIPv6)
 	}
 
-	ua := gophercloud.UserAgent{}
-	ua.Prepend(fmt.Sprintf("%s/%s", userAgent, version.Version))
-	for _, data := range extraUserAgent {
-		ua.Prepend(data)
+	for _, source := range []struct{ key, value string }{
+		{"external-ipv4-source", opts.ExternalIPv4Source},
+		{"external-ipv6-source", opts.ExternalIPv6Source},
+	} {
+		switch source.value {
+		case "", externalIPSourceAny, externalIPSourceFloating, externalIPSourceFixed:
+		default:
+			return fmt.Errorf("invalid value %q in section [Networking] with key `%s`. Supported values are %q, %q and %q",
+				source.value, source.key, externalIPSourceAny, externalIPSourceFloating, externalIPSourceFixed)
+		}
 	}
-	provider.UserAgent = ua
-	klog.V(4).Infof("Using user-agent %s", ua.Join())
 
-	var caPool *x509.CertPool
+	for _, cidrs := range []struct {
+		key    string
+		values []string
+	}{
+		{"allowed-address-pair-cidr", opts.AllowedAddressPairCIDR},
+		{"external-ip-allow-cidr", opts.ExternalIPAllowCIDR},
+		{"external-ip-deny-cidr", opts.ExternalIPDenyCIDR},
+	} {
+		for _, cidr := range cidrs.values {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("invalid value %q in section [Networking] with key `%s`: %v", cidr, cidrs.key, err)
+			}
+		}
+	}
+
+	if _, err := parseAddressTranslations(opts.AddressTranslation); err != nil {
+		return fmt.Errorf("invalid value in section [Networking] with key `address-translation`: %v", err)
+	}
+
+	if _, err := parseNetworkRoles(opts.NetworkRole); err != nil {
+		return fmt.Errorf("invalid value in section [Networking] with key `network-role`: %v", err)
+	}
+	if len(opts.NetworkRole) > 0 && (len(opts.PublicNetworkName) > 0 || len(opts.InternalNetworkName) > 0) {
+		return errors.New("invalid value in section [Networking] with key `network-role`. It cannot be set with `public-network-name` or `internal-network-name`")
+	}
+	return nil
+}
+
+// TLSConfig returns the TLS configuration of the connections to Keystone and
+// to all the OpenStack endpoints: the CA bundle of ca-file is trusted and the
+// client certificate of cert-file and key-file authenticates the client. The
+// errors name the faulty option, so that they can be reported at startup.
+func TLSConfig(cfg *AuthOpts) (*tls.Config, error) {
+	config := &tls.Config{}
+	config.InsecureSkipVerify = cfg.TLSInsecure == "true"
+
 	if cfg.CAFile != "" {
-		// read and parse CA certificate from file
-		caPool, err = certutil.NewPool(cfg.CAFile)
+		// read and parse the CA bundle from file
+		caPool, err := certutil.NewPool(cfg.CAFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read and parse %s certificate: %s", cfg.CAFile, err)
+			return nil, fmt.Errorf("failed to read the CA bundle %s of ca-file: %v", cfg.CAFile, err)
 		}
+		config.RootCAs = caPool
 	} else if cfg.CAFileContents != "" {
 		// parse CA certificate from the contents
-		caPool = x509.NewCertPool()
+		caPool := x509.NewCertPool()
 		if ok := caPool.AppendCertsFromPEM([]byte(cfg.CAFileContents)); !ok {
 			return nil, fmt.Errorf("failed to parse os-certAuthority certificate")
 		}
-	}
-
-	config := &tls.Config{}
-	config.InsecureSkipVerify = cfg.TLSInsecure == "true"
-
-	if caPool != nil {
 		config.RootCAs = caPool
 	}
 
 	// configure TLS client auth
-	if cfg.CertFile != "" && cfg.KeyFile != "" {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("cert-file and key-file must be set together for the TLS client authentication")
+	}
+	if cfg.CertFile != "" {
 		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
 		if err != nil {
-			return nil, fmt.Errorf("error loading TLS key pair: %s", err)
+			return nil, fmt.Errorf("failed to load the client certificate %s of cert-file and its key %s of key-file: %v", cfg.CertFile, cfg.KeyFile, err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the client certificate %s of cert-file: %v", cfg.CertFile, err)
+		}
+		if time.Now().After(leaf.NotAfter) {
+			return nil, fmt.Errorf("the client certificate %s of cert-file expired at %s", cfg.CertFile, leaf.NotAfter.UTC().Format(time.RFC3339))
 		}
 		config.Certificates = []tls.Certificate{cert}
 	}
 
-	provider.HTTPClient.Transport = netutil.SetOldTransportDefaults(&http.Transport{TLSClientConfig: config})
+	return config, nil
+}
+
+// NewOpenStackClient creates a new instance of the openstack client
+func NewOpenStackClient(cfg *AuthOpts, userAgent string, extraUserAgent ...string) (*gophercloud.ProviderClient, error) {
+	return NewOpenStackClientWithTransport(cfg, TransportOpts{}, userAgent, extraUserAgent...)
+}
+
+// NewOpenStackClientWithTransport creates a new instance of the openstack
+// client whose connections are tuned by transportOpts
+func NewOpenStackClientWithTransport(cfg *AuthOpts, transportOpts TransportOpts, userAgent string, extraUserAgent ...string) (*gophercloud.ProviderClient, error) {
+	provider, err := openstack.NewClient(cfg.AuthURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ua := gophercloud.UserAgent{}
+	ua.Prepend(fmt.Sprintf("%s/%s", userAgent, version.Version))
+	for _, data := range extraUserAgent {
+		ua.Prepend(data)
+	}
+	provider.UserAgent = ua
+	klog.V(4).Infof("Using user-agent %s", ua.Join())
+
+	config, err := TLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.HTTPClient.Transport = newTransport(config, transportOpts)
 
 	if klog.V(6).Enabled() {
 		provider.HTTPClient.Transport = &client.RoundTripper{
@@ -554,11 +943,19 @@ func NewOpenStackClient(cfg *AuthOpts, userAgent string, extraUserAgent ...strin
 		}
 	}
 
+	if usesFederation(cfg) {
+		err = AuthenticateFederated(provider, cfg)
+
+		return provider, err
+	}
+
 	if cfg.TrustID != "" {
 		opts := cfg.ToAuth3Options()
 
-		// support for the legacy manila auth
-		// if TrusteeID and TrusteePassword were defined, then use them
+		// if TrusteeID and TrusteePassword were defined, then use them,
+		// this also supports the legacy manila auth.
+		// The token is scoped to the trust and AllowReauth makes gophercloud
+		// consume the trust again when the token expires.
 		opts.UserID = replaceEmpty(cfg.TrusteeID, opts.UserID)
 		opts.Password = replaceEmpty(cfg.TrusteePassword, opts.Password)
 
@@ -579,25 +976,86 @@ func NewOpenStackClient(cfg *AuthOpts, userAgent string, extraUserAgent ...strin
 
 // NewOpenStack creates a new new instance of the openstack struct from a config struct
 func NewOpenStack(cfg Config) (*OpenStack, error) {
-	provider, err := NewOpenStackClient(&cfg.Global, "openstack-cloud-controller-manager", userAgentData...)
+	if cfg.Metadata.RequestTimeout == (MyDuration{}) {
+		cfg.Metadata.RequestTimeout.Duration = time.Duration(defaultTimeOut)
+	}
+
+	clientOpts := ClientOptionsKey("openstack-cloud-controller-manager", userAgentData, cfg.Transport, cfg.RateLimit, cfg.RateLimitService, cfg.Metadata.RequestTimeout)
+	provider, err := DefaultProviderClientPool.Get(&cfg.Global, clientOpts, func() (*gophercloud.ProviderClient, error) {
+		provider, err := NewOpenStackClientWithTransport(&cfg.Global, cfg.Transport, "openstack-cloud-controller-manager", userAgentData...)
+		if err != nil {
+			return nil, err
+		}
+
+		EnableApplicationCredentialReload(provider, &cfg.Global, reloadAuthOpts)
+
+		region := cfg.Global.Region
+		if region == "" {
+			region = authRegion(provider)
+		}
+
+		err = ApplyRateLimit(provider, region, cfg.RateLimit, cfg.RateLimitService)
+		if err != nil {
+			return nil, err
+		}
+
+		provider.HTTPClient.Timeout = cfg.Metadata.RequestTimeout.Duration
+		// The requests are canceled once the shutdown grace period is over
+		provider.Context = shutdown.requests
+		return provider, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if cfg.Metadata.RequestTimeout == (MyDuration{}) {
-		cfg.Metadata.RequestTimeout.Duration = time.Duration(defaultTimeOut)
+	if cfg.Global.Region == "" {
+		cfg.Global.Region = authRegion(provider)
+	}
+
+	regionProviderID := os.Getenv(RegionalProviderIDEnv) == "true"
+
+	if idScheme := os.Getenv(ProviderIDSchemeEnv); idScheme != "" {
+		if err := setProviderIDScheme(idScheme); err != nil {
+			return nil, err
+		}
 	}
-	provider.HTTPClient.Timeout = cfg.Metadata.RequestTimeout.Duration
 
 	os := OpenStack{
-		provider:       provider,
-		region:         cfg.Global.Region,
-		lbOpts:         cfg.LoadBalancer,
-		bsOpts:         cfg.BlockStorage,
-		routeOpts:      cfg.Route,
-		metadataOpts:   cfg.Metadata,
-		networkingOpts: cfg.Networking,
+		provider:         provider,
+		region:           cfg.Global.Region,
+		projectID:        replaceEmpty(cfg.Global.TenantID, authProjectID(provider)),
+		regionProviderID: regionProviderID,
+		lbOpts:           cfg.LoadBalancer,
+		bsOpts:           cfg.BlockStorage,
+		routeOpts:        cfg.Route,
+		metadataOpts:     cfg.Metadata,
+		networkingOpts:   cfg.Networking,
+		instancesOpts:    cfg.Instances,
+	}
+
+	if len(cfg.Networking.InternalNetworkName) > 0 {
+		network, err := os.NewNetworkV2()
+		if err != nil {
+			klog.Warningf("Unable to resolve the internal networks, failed to create an OpenStack Network client: %v", err)
+		} else {
+			os.networkingOpts.InternalNetworkIDs = resolveNetworkNames(network, cfg.Networking.InternalNetworkName)
+		}
+	}
+
+	if cfg.Instances.FlavorCacheTTL.Duration > 0 {
+		os.flavorCache = cache.NewLRUExpireCache(flavorCacheSize)
+	}
+	if cfg.Instances.WarmupCacheTTL.Duration > 0 {
+		os.serverWarmup = &serverWarmup{cache: cache.NewLRUExpireCache(serverWarmupCacheSize)}
+	}
+	if cfg.Instances.ServerGroupLabel {
+		os.serverGroups = newServerGroupMembers()
+	}
+	os.lookupBackoff = newLookupBackoff(durationOrDefault(cfg.Instances.LookupBackoff, lookupBackoffInitial), durationOrDefault(cfg.Instances.LookupBackoffMax, lookupBackoffMax))
+	if interval := durationOrDefault(cfg.Instances.MetadataSyncInterval, defaultMetadataSyncInterval); interval > 0 {
+		os.metadataSyncs = newMetadataDebounce(interval)
 	}
+	os.lbHealth = newLoadBalancerHealth()
 
 	// ini file doesn't support maps so we are reusing top level sub sections
 	// and copy the resulting map to corresponding loadbalancer section
@@ -611,8 +1069,138 @@ func NewOpenStack(cfg Config) (*OpenStack, error) {
 	return &os, nil
 }
 
+// authProjectID returns the ID of the project the provider client token is scoped to.
+func authProjectID(provider *gophercloud.ProviderClient) string {
+	if result, ok := provider.GetAuthResult().(tokens3.CreateResult); ok {
+		if project, err := result.ExtractProject(); err == nil && project != nil {
+			return project.ID
+		}
+	}
+	return ""
+}
+
+// authRegion returns the region of the compute endpoints of the provider
+// client service catalog when they are all in the same region. The Nova
+// metadata only has the availability zone of the server, so the region of a
+// single region cloud is found in the catalog instead. An empty region is
+// returned when the catalog has several regions, the endpoints of the first
+// region found are then used as before.
+func authRegion(provider *gophercloud.ProviderClient) string {
+	result, ok := provider.GetAuthResult().(tokens3.CreateResult)
+	if !ok {
+		klog.Warningf("The region is not configured and can't be found without a Keystone v3 service catalog")
+		return ""
+	}
+	catalog, err := result.ExtractServiceCatalog()
+	if err != nil {
+		klog.Warningf("The region is not configured and the service catalog can't be read: %v", err)
+		return ""
+	}
+
+	regions := catalogRegions(catalog, "compute")
+	if len(regions) != 1 {
+		klog.Warningf("The region is not configured and the compute endpoints are in the regions %v of the service catalog, set the region in the [Global] section", regions)
+		return ""
+	}
+	klog.Infof("The region is not configured, using the region %s of the compute endpoints", regions[0])
+	return regions[0]
+}
+
+// catalogRegions returns the sorted regions of the endpoints of the service
+// type in the catalog.
+func catalogRegions(catalog *tokens3.ServiceCatalog, serviceType string) []string {
+	regions := sets.NewString()
+	for _, entry := range catalog.Entries {
+		if entry.Type != serviceType {
+			continue
+		}
+		for _, endpoint := range entry.Endpoints {
+			if endpoint.Region != "" {
+				regions.Insert(endpoint.Region)
+			}
+		}
+	}
+	return regions.List()
+}
+
+// resolveNetworkNames returns the IDs of the networks with the given names.
+// The names matching no network or several networks are skipped.
+func resolveNetworkNames(client *gophercloud.ServiceClient, names []string) []string {
+	var ids []string
+
+	for _, name := range names {
+		mc := metrics.NewMetricContext("network", "list")
+		allPages, err := networks.List(client, networks.ListOpts{Name: name}).AllPages()
+		if mc.ObserveRequest(err) != nil {
+			klog.Warningf("Failed to list networks named %s: %v", name, err)
+			continue
+		}
+		nets, err := networks.ExtractNetworks(allPages)
+		if err != nil {
+			klog.Warningf("Failed to list networks named %s: %v", name, err)
+			continue
+		}
+
+		switch len(nets) {
+		case 0:
+			klog.Warningf("Network %s not found, it is only matched by name", name)
+		case 1:
+			ids = append(ids, nets[0].ID)
+		default:
+			klog.Warningf("Found %d networks named %s, skipping it", len(nets), name)
+		}
+	}
+
+	return ids
+}
+
 // Initialize passes a Kubernetes clientBuilder interface to the cloud provider
 func (os *OpenStack) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	DefaultProviderClientPool.Start(stop)
+
+	clientset, err := clientBuilder.Client("cloud-controller-manager")
+	if err != nil {
+		klog.Errorf("Failed to create a Kubernetes client: %v", err)
+		return
+	}
+	os.kclient = clientset
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: clientset.CoreV1().Events(""),
+	})
+	os.eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "openstack-cloud-controller-manager"})
+
+	if os.lbOpts.UseOctavia && os.lbOpts.CleanupOrphans {
+		go os.cleanupOrphanedLoadBalancers(stop)
+	}
+	os.watchNodes(stop)
+}
+
+// cleanupOrphanedLoadBalancers deletes the load balancers of the Services that no longer exist once the Service
+// informer cache is synced.
+func (os *OpenStack) cleanupOrphanedLoadBalancers(stop <-chan struct{}) {
+	lb, ok := os.LoadBalancer()
+	if !ok {
+		klog.Errorf("Failed to create the load balancer client for cleaning up the orphaned load balancers")
+		return
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(os.kclient, 0)
+	serviceLister := informerFactory.Core().V1().Services().Lister()
+	informerFactory.Start(stop)
+	for informer, synced := range informerFactory.WaitForCacheSync(stop) {
+		if !synced {
+			klog.Errorf("Failed to sync the %v informer cache, skip cleaning up the orphaned load balancers", informer)
+			return
+		}
+	}
+
+	ctx, done := shutdown.track(shutdown.stopping)
+	defer done()
+	if err := lb.(*LbaasV2).cleanupOrphanedLoadBalancers(ctx, os.lbOpts.ClusterName, serviceLister); err != nil {
+		klog.Errorf("Failed to clean up the orphaned load balancers: %v", err)
+	}
 }
 
 // mapNodeNameToServerName maps a k8s NodeName to an OpenStack Server Name
@@ -698,16 +1286,73 @@ func getServerByName(client *gophercloud.ServiceClient, name types.NodeName) (*S
 	return &serverList[0], nil
 }
 
+// getServersByName returns all the servers named after the node.
+func getServersByName(client *gophercloud.ServiceClient, name types.NodeName) ([]servers.Server, error) {
+	opts := servers.ListOpts{
+		Name: fmt.Sprintf("^%s$", regexp.QuoteMeta(mapNodeNameToServerName(name))),
+	}
+
+	var serverList []servers.Server
+	err := foreachServer(client, opts, func(srv *servers.Server) (bool, error) {
+		serverList = append(serverList, *srv)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return serverList, nil
+}
+
+// getServerByMetadata returns the server whose metadata key is set to the given value.
+// Nova doesn't support filtering servers by metadata, so the filtering is done on the
+// client side.
+func getServerByMetadata(client *gophercloud.ServiceClient, key string, value string) (*servers.Server, error) {
+	var server *servers.Server
+
+	err := foreachServer(client, servers.ListOpts{}, func(srv *servers.Server) (bool, error) {
+		if v, ok := srv.Metadata[key]; !ok || v != value {
+			return true, nil
+		}
+		if server != nil {
+			return false, ErrMultipleResults
+		}
+		s := *srv
+		server = &s
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if server == nil {
+		return nil, ErrNotFound
+	}
+
+	return server, nil
+}
+
 // IP addresses order:
 // * interfaces private IPs
 // * access IPs
-// * metadata hostname
+// * metadata hostname, or the server name with dns-node-addresses
 // * server object Addresses (floating type)
+// When ip-version-preference is set, the addresses of the preferred IP family
+// are moved first, keeping the order above otherwise.
+// IPv6 link-local addresses are never reported.
 func nodeAddresses(srv *servers.Server, interfaces []attachinterfaces.Interface, networkingOpts NetworkingOpts) ([]v1.NodeAddress, error) {
+	if len(networkingOpts.NetworkRole) > 0 {
+		return networkRoleAddresses(srv, networkingOpts)
+	}
+
 	addrs := []v1.NodeAddress{}
 
 	// parse private IP addresses first in an ordered manner
 	for _, iface := range interfaces {
+		if len(networkingOpts.InternalNetworkIDs) > 0 && !util.Contains(networkingOpts.InternalNetworkIDs, iface.NetID) {
+			klog.V(5).Infof("Node '%s' interface '%s' ignored due to 'internal-network-name' option", srv.Name, iface.PortID)
+			continue
+		}
 		for _, fixedIP := range iface.FixedIPs {
 			if iface.PortState == "ACTIVE" {
 				isIPv6 := net.ParseIP(fixedIP.IPAddress).To4() == nil
@@ -742,14 +1387,7 @@ func nodeAddresses(srv *servers.Server, interfaces []attachinterfaces.Interface,
 		)
 	}
 
-	if srv.Metadata[TypeHostName] != "" {
-		AddToNodeAddresses(&addrs,
-			v1.NodeAddress{
-				Type:    v1.NodeHostName,
-				Address: srv.Metadata[TypeHostName],
-			},
-		)
-	}
+	addHostnameAddress(&addrs, srv, networkingOpts)
 
 	// process the rest
 	type Address struct {
@@ -769,11 +1407,14 @@ func nodeAddresses(srv *servers.Server, interfaces []attachinterfaces.Interface,
 	}
 	sort.Strings(networks)
 
+	floatingIPs := sets.NewString()
+
 	for _, network := range networks {
 		for _, props := range addresses[network] {
 			var addressType v1.NodeAddressType
 			if props.IPType == "floating" {
 				addressType = v1.NodeExternalIP
+				floatingIPs.Insert(props.Addr)
 			} else if util.Contains(networkingOpts.PublicNetworkName, network) {
 				addressType = v1.NodeExternalIP
 				// removing already added address to avoid listing it as both ExternalIP and InternalIP
@@ -809,9 +1450,369 @@ func nodeAddresses(srv *servers.Server, interfaces []attachinterfaces.Interface,
 		}
 	}
 
+	addrs = filterExternalAddresses(addrs, floatingIPs, networkingOpts)
+	addrs = removeLinkLocalAddresses(addrs)
+	sortNodeAddresses(addrs, networkingOpts.IPVersionPreference)
+
 	return addrs, nil
 }
 
+// networkRoleAddresses returns the addresses of the server on the networks of
+// network-role, in the order of the networks, classified by the role of their
+// network. The floating IPs are always ExternalIP addresses. The addresses of
+// the other networks, the interfaces and the access IPs are ignored.
+func networkRoleAddresses(srv *servers.Server, networkingOpts NetworkingOpts) ([]v1.NodeAddress, error) {
+	// The network roles are validated with the configuration
+	roles, err := parseNetworkRoles(networkingOpts.NetworkRole)
+	if err != nil {
+		return nil, err
+	}
+
+	type Address struct {
+		IPType string `mapstructure:"OS-EXT-IPS:type"`
+		Addr   string
+	}
+
+	var addresses map[string][]Address
+	if err := mapstructure.Decode(srv.Addresses, &addresses); err != nil {
+		return nil, err
+	}
+
+	addrs := []v1.NodeAddress{}
+	floatingIPs := sets.NewString()
+	listed := sets.NewString()
+	for _, role := range roles {
+		listed.Insert(role.network)
+		for _, props := range addresses[role.network] {
+			addressType := role.addressType
+			if props.IPType == "floating" {
+				addressType = v1.NodeExternalIP
+				floatingIPs.Insert(props.Addr)
+			}
+
+			isIPv6 := net.ParseIP(props.Addr).To4() == nil
+			if !(isIPv6 && networkingOpts.IPv6SupportDisabled) {
+				AddToNodeAddresses(&addrs,
+					v1.NodeAddress{
+						Type:    addressType,
+						Address: props.Addr,
+					},
+				)
+			}
+		}
+	}
+	for network := range addresses {
+		if !listed.Has(network) {
+			klog.V(5).Infof("Node '%s' addresses of network '%s' ignored due to the 'network-role' option", srv.Name, network)
+		}
+	}
+
+	addHostnameAddress(&addrs, srv, networkingOpts)
+
+	addrs = filterExternalAddresses(addrs, floatingIPs, networkingOpts)
+	addrs = removeLinkLocalAddresses(addrs)
+	sortNodeAddresses(addrs, networkingOpts.IPVersionPreference)
+
+	return addrs, nil
+}
+
+// addHostnameAddress adds the hostname metadata of the server as its Hostname
+// address, or else its name with dns-node-addresses
+func addHostnameAddress(addrs *[]v1.NodeAddress, srv *servers.Server, networkingOpts NetworkingOpts) {
+	if srv.Metadata[TypeHostName] != "" {
+		AddToNodeAddresses(addrs,
+			v1.NodeAddress{
+				Type:    v1.NodeHostName,
+				Address: srv.Metadata[TypeHostName],
+			},
+		)
+	} else if networkingOpts.DNSNodeAddresses {
+		if hostname, ok := sanitizeDNSName(srv.Name); ok {
+			AddToNodeAddresses(addrs,
+				v1.NodeAddress{
+					Type:    v1.NodeHostName,
+					Address: hostname,
+				},
+			)
+		} else if srv.Name != "" {
+			klog.V(5).Infof("Node '%s' name is not a valid DNS name, it is not listed as the Hostname address", srv.Name)
+		}
+	}
+}
+
+// sanitizeDNSName returns the lower case DNS name without its trailing dot,
+// and whether it's a valid DNS subdomain. An empty name is not valid.
+func sanitizeDNSName(name string) (string, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" || len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return "", false
+	}
+	return name, true
+}
+
+// addressTranslation rewrites the addresses of the from CIDR into the to
+// CIDR, keeping their host bits
+type addressTranslation struct {
+	from *net.IPNet
+	to   *net.IPNet
+}
+
+// parseAddressTranslations parses the "<from-cidr>-><to-cidr>" address
+// translations, both CIDRs of a translation are of the same IP family.
+func parseAddressTranslations(values []string) ([]addressTranslation, error) {
+	var translations []addressTranslation
+	for _, value := range values {
+		parts := strings.Split(value, "->")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not of the form <from-cidr>-><to-cidr>", value)
+		}
+		_, from, err := net.ParseCIDR(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", value, err)
+		}
+		_, to, err := net.ParseCIDR(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", value, err)
+		}
+		if len(from.IP) != len(to.IP) {
+			return nil, fmt.Errorf("%q translates between IP families", value)
+		}
+		translations = append(translations, addressTranslation{from: from, to: to})
+	}
+	return translations, nil
+}
+
+// translate returns the address in the to CIDR with the host bits of the
+// given address, or nil when the address is not in the from CIDR.
+func (t addressTranslation) translate(ip net.IP) net.IP {
+	if len(t.from.IP) == net.IPv4len {
+		ip = ip.To4()
+	}
+	if ip == nil || !t.from.Contains(ip) {
+		return nil
+	}
+
+	translated := make(net.IP, len(ip))
+	for b := range ip {
+		translated[b] = t.to.IP[b] | ip[b]&^t.to.Mask[b]
+	}
+	return translated
+}
+
+// isUsableAddress returns whether the translated address can be a node
+// address of the CIDR it was translated to.
+func isUsableAddress(ip net.IP, cidr *net.IPNet) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	// the network and the broadcast addresses of IPv4 subnets
+	if ones, bits := cidr.Mask.Size(); bits == 8*net.IPv4len && ones < 31 {
+		network, broadcast := true, true
+		for b := range ip {
+			host := ip[b] &^ cidr.Mask[b]
+			network = network && host == 0
+			broadcast = broadcast && host == ^cidr.Mask[b]
+		}
+		if network || broadcast {
+			return false
+		}
+	}
+	return true
+}
+
+// translateAddresses rewrites the IP addresses with the first address
+// translation of the networking options matching them, the addresses which
+// translate to unusable addresses are dropped. The order of the addresses is
+// kept.
+func translateAddresses(nodeName string, addrs []v1.NodeAddress, networkingOpts NetworkingOpts) []v1.NodeAddress {
+	if len(networkingOpts.AddressTranslation) == 0 {
+		return addrs
+	}
+	translations, err := parseAddressTranslations(networkingOpts.AddressTranslation)
+	if err != nil {
+		// rejected when the cloud provider is configured
+		klog.Errorf("Failed to parse the address translations: %v", err)
+		return addrs
+	}
+
+	result := []v1.NodeAddress{}
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr.Address)
+		if addr.Type == v1.NodeHostName || addr.Type == v1.NodeInternalDNS || addr.Type == v1.NodeExternalDNS || ip == nil {
+			AddToNodeAddresses(&result, addr)
+			continue
+		}
+
+		for _, t := range translations {
+			translated := t.translate(ip)
+			if translated == nil {
+				continue
+			}
+			if !isUsableAddress(translated, t.to) {
+				klog.Warningf("Node '%s' address '%s' dropped, it translates to the unusable address '%s'", nodeName, addr.Address, translated)
+				ip = nil
+				break
+			}
+			klog.V(5).Infof("Node '%s' address '%s' translated to '%s'", nodeName, addr.Address, translated)
+			ip = translated
+			break
+		}
+		if ip == nil {
+			continue
+		}
+		AddToNodeAddresses(&result, v1.NodeAddress{Type: addr.Type, Address: ip.String()})
+	}
+	return result
+}
+
+// parseCIDRs parses the CIDRs of the networking options, which are validated
+// with the configuration.
+func parseCIDRs(values []string) []*net.IPNet {
+	var cidrs []*net.IPNet
+	for _, value := range values {
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			klog.Errorf("Failed to parse the CIDR %q: %v", value, err)
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
+
+// externalIPAllowed returns whether the address can be an ExternalIP address
+// according to external-ip-deny-cidr and external-ip-allow-cidr. The denied
+// CIDRs are evaluated first, so that an address of both a denied and an
+// allowed CIDR is denied whatever their order and their prefix lengths.
+func externalIPAllowed(address string, allow, deny []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return true
+	}
+	for _, cidr := range deny {
+		if cidr.Contains(ip) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, cidr := range allow {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterExternalAddresses applies external-ipv4-source, external-ipv6-source,
+// external-ip-deny-cidr and external-ip-allow-cidr to the ExternalIP addresses, an
+// address is a floating IP when it is listed in the given floating IPs and a fixed
+// IP otherwise. Fixed IPs which can't be ExternalIP addresses are listed as
+// InternalIP addresses instead, floating IPs which can't be ExternalIP addresses
+// are removed. The order of the addresses is kept.
+func filterExternalAddresses(addrs []v1.NodeAddress, floatingIPs sets.String, networkingOpts NetworkingOpts) []v1.NodeAddress {
+	if networkingOpts.ExternalIPv4Source == "" && networkingOpts.ExternalIPv6Source == "" &&
+		len(networkingOpts.ExternalIPAllowCIDR) == 0 && len(networkingOpts.ExternalIPDenyCIDR) == 0 {
+		return addrs
+	}
+	allow, deny := parseCIDRs(networkingOpts.ExternalIPAllowCIDR), parseCIDRs(networkingOpts.ExternalIPDenyCIDR)
+
+	internal := sets.NewString()
+	for _, addr := range addrs {
+		if addr.Type == v1.NodeInternalIP {
+			internal.Insert(addr.Address)
+		}
+	}
+
+	result := []v1.NodeAddress{}
+	for _, addr := range addrs {
+		if addr.Type != v1.NodeExternalIP {
+			result = append(result, addr)
+			continue
+		}
+
+		source := networkingOpts.ExternalIPv4Source
+		if net.ParseIP(addr.Address).To4() == nil {
+			source = networkingOpts.ExternalIPv6Source
+		}
+		floating := floatingIPs.Has(addr.Address)
+		allowed := externalIPAllowed(addr.Address, allow, deny)
+
+		switch {
+		case (source == externalIPSourceFloating || !allowed) && !floating:
+			if !internal.Has(addr.Address) {
+				internal.Insert(addr.Address)
+				result = append(result, v1.NodeAddress{Type: v1.NodeInternalIP, Address: addr.Address})
+			}
+		case source == externalIPSourceFixed && floating:
+			klog.V(5).Infof("Floating IP '%s' ignored due to the 'external-ipv*-source' options", addr.Address)
+		case !allowed:
+			klog.V(5).Infof("Floating IP '%s' ignored due to the 'external-ip-*-cidr' options", addr.Address)
+		default:
+			result = append(result, addr)
+		}
+	}
+
+	return result
+}
+
+// removeLinkLocalAddresses removes the IPv6 link-local addresses, which
+// can't be used to reach the node.
+func removeLinkLocalAddresses(addrs []v1.NodeAddress) []v1.NodeAddress {
+	result := addrs[:0]
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr.Address)
+		if ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			klog.V(5).Infof("Link-local address '%s' ignored", addr.Address)
+			continue
+		}
+		result = append(result, addr)
+	}
+	return result
+}
+
+// nodeAddressTypeRanks is the order of the node addresses by type
+var nodeAddressTypeRanks = map[v1.NodeAddressType]int{
+	v1.NodeInternalIP:  0,
+	v1.NodeExternalIP:  1,
+	v1.NodeInternalDNS: 2,
+	v1.NodeExternalDNS: 3,
+	v1.NodeHostName:    4,
+}
+
+// sortNodeAddresses orders the node addresses by type, after moving the IP
+// addresses of the preferred IP family before the IP addresses of the other
+// family. The relative order of the addresses of the same type is kept, the
+// first InternalIP address being the node IP, so that the same addresses are
+// always listed in the same order.
+func sortNodeAddresses(addrs []v1.NodeAddress, preference string) {
+	familyRank := func(addr v1.NodeAddress) int {
+		ip := net.ParseIP(addr.Address)
+		if preference == "" || ip == nil {
+			return 0
+		}
+		isIPv6 := ip.To4() == nil
+		if isIPv6 == (preference == ipVersionPreferenceIPv6) {
+			return 0
+		}
+		return 1
+	}
+	typeRank := func(addr v1.NodeAddress) int {
+		if rank, ok := nodeAddressTypeRanks[addr.Type]; ok {
+			return rank
+		}
+		return len(nodeAddressTypeRanks)
+	}
+
+	sort.SliceStable(addrs, func(i, j int) bool {
+		if fi, fj := familyRank(addrs[i]), familyRank(addrs[j]); fi != fj {
+			return fi < fj
+		}
+		return typeRank(addrs[i]) < typeRank(addrs[j])
+	})
+}
+
 func getAddressesByName(client *gophercloud.ServiceClient, name types.NodeName, networkingOpts NetworkingOpts) ([]v1.NodeAddress, error) {
 	srv, err := getServerByName(client, name)
 	if err != nil {
@@ -876,6 +1877,50 @@ func getAttachedInterfacesByID(client *gophercloud.ServiceClient, serviceID stri
 	return interfaces, nil
 }
 
+// getTrunkSubportInterfaces returns the subports of the trunks whose parent port is
+// one of the given interfaces, in the same form as the attached interfaces.
+func getTrunkSubportInterfaces(client *gophercloud.ServiceClient, interfaces []attachinterfaces.Interface) ([]attachinterfaces.Interface, error) {
+	var subports []attachinterfaces.Interface
+
+	for _, iface := range interfaces {
+		mc := metrics.NewMetricContext("trunk", "list")
+		allPages, err := trunks.List(client, trunks.ListOpts{PortID: iface.PortID}).AllPages()
+		if mc.ObserveRequest(err) != nil {
+			return nil, err
+		}
+		allTrunks, err := trunks.ExtractTrunks(allPages)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, trunk := range allTrunks {
+			for _, subport := range trunk.Subports {
+				mc := metrics.NewMetricContext("port", "get")
+				port, err := ports.Get(client, subport.PortID).Extract()
+				if mc.ObserveRequest(err) != nil {
+					return nil, err
+				}
+
+				subportIface := attachinterfaces.Interface{
+					PortID:    port.ID,
+					PortState: port.Status,
+					NetID:     port.NetworkID,
+					MACAddr:   port.MACAddress,
+				}
+				for _, ip := range port.FixedIPs {
+					subportIface.FixedIPs = append(subportIface.FixedIPs, attachinterfaces.FixedIP{
+						SubnetID:  ip.SubnetID,
+						IPAddress: ip.IPAddress,
+					})
+				}
+				subports = append(subports, subportIface)
+			}
+		}
+	}
+
+	return subports, nil
+}
+
 // Clusters is a no-op
 func (os *OpenStack) Clusters() (cloudprovider.Clusters, bool) {
 	return nil, false
@@ -921,9 +1966,23 @@ func (os *OpenStack) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
 		return nil, false
 	}
 
+	secret, err := os.NewKeyManagerV1()
+	if err != nil {
+		klog.V(4).Infof("The TLS container references of the load balancers are not supported: %v", err)
+		secret = nil
+	}
+
 	klog.V(1).Info("Claiming to support LoadBalancer")
 
-	return &LbaasV2{LoadBalancer{network, compute, lb, os.lbOpts}}, true
+	return &LbaasV2{LoadBalancer{
+		network:       network,
+		compute:       compute,
+		lb:            lb,
+		secret:        secret,
+		opts:          os.lbOpts,
+		eventRecorder: os.eventRecorder,
+		health:        os.lbHealth,
+	}}, true
 }
 
 // Zones indicates that we support zones
@@ -940,7 +1999,7 @@ func (os *OpenStack) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
 	}
 
 	zone := cloudprovider.Zone{
-		FailureDomain: md.AvailabilityZone,
+		FailureDomain: os.failureDomain(md.AvailabilityZone, md.Meta),
 		Region:        os.region,
 	}
 	klog.V(4).Infof("Current zone is %v", zone)
@@ -951,12 +2010,15 @@ func (os *OpenStack) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
 // This is particularly useful in external cloud providers where the kubelet
 // does not initialize node data.
 func (os *OpenStack) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
-	instanceID, err := instanceIDFromProviderID(providerID)
+	instanceID, region, err := parseProviderID(providerID)
 	if err != nil {
 		return cloudprovider.Zone{}, err
 	}
+	if region == "" || !util.Contains(os.instancesOpts.AdditionalRegions, region) {
+		region = os.region
+	}
 
-	compute, err := os.NewComputeV2()
+	compute, err := os.newComputeV2(region)
 	if err != nil {
 		return cloudprovider.Zone{}, err
 	}
@@ -969,9 +2031,10 @@ func (os *OpenStack) GetZoneByProviderID(ctx context.Context, providerID string)
 	}
 
 	zone := cloudprovider.Zone{
-		FailureDomain: serverWithAttributesExt.AvailabilityZone,
-		Region:        os.region,
+		FailureDomain: os.failureDomain(serverWithAttributesExt.AvailabilityZone, serverWithAttributesExt.Metadata),
+		Region:        region,
 	}
+	observeZone(instanceID, zone)
 	klog.V(4).Infof("The instance %s in zone %v", serverWithAttributesExt.Name, zone)
 	return zone, nil
 }
@@ -994,13 +2057,34 @@ func (os *OpenStack) GetZoneByNodeName(ctx context.Context, nodeName types.NodeN
 	}
 
 	zone := cloudprovider.Zone{
-		FailureDomain: srv.AvailabilityZone,
+		FailureDomain: os.failureDomain(srv.AvailabilityZone, srv.Metadata),
 		Region:        os.region,
 	}
+	observeZone(srv.ID, zone)
 	klog.V(4).Infof("The instance %s in zone %v", srv.Name, zone)
 	return zone, nil
 }
 
+// observeZone records the zone of the instance of a node in the metrics
+func observeZone(instanceID string, zone cloudprovider.Zone) {
+	name := sanitizeLabel(zone.FailureDomain)
+	if name == "" {
+		name = "none"
+	}
+	metrics.ObserveInstanceZone(instanceID, name)
+}
+
+// failureDomain returns the zone of a server, which is the value of the
+// zone-metadata-key server metadata when set, or the availability zone.
+func (os *OpenStack) failureDomain(availabilityZone string, meta map[string]string) string {
+	if key := os.instancesOpts.ZoneMetadataKey; key != "" {
+		if zone := sanitizeLabel(meta[key]); zone != "" {
+			return zone
+		}
+	}
+	return availabilityZone
+}
+
 // Routes initializes routes support
 func (os *OpenStack) Routes() (cloudprovider.Routes, bool) {
 	klog.V(4).Info("openstack.Routes() called")
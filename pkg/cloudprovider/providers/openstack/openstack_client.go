@@ -25,22 +25,30 @@ import (
 
 // NewNetworkV2 creates a ServiceClient that may be used with the neutron v2 API
 func (os *OpenStack) NewNetworkV2() (*gophercloud.ServiceClient, error) {
+	return os.newNetworkV2(os.region)
+}
+
+func (os *OpenStack) newNetworkV2(region string) (*gophercloud.ServiceClient, error) {
 	network, err := openstack.NewNetworkV2(os.provider, gophercloud.EndpointOpts{
-		Region: os.region,
+		Region: region,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find network v2 endpoint for region %s: %v", os.region, err)
+		return nil, fmt.Errorf("failed to find network v2 endpoint for region %s: %v", region, err)
 	}
 	return network, nil
 }
 
 // NewComputeV2 creates a ServiceClient that may be used with the nova v2 API
 func (os *OpenStack) NewComputeV2() (*gophercloud.ServiceClient, error) {
+	return os.newComputeV2(os.region)
+}
+
+func (os *OpenStack) newComputeV2(region string) (*gophercloud.ServiceClient, error) {
 	compute, err := openstack.NewComputeV2(os.provider, gophercloud.EndpointOpts{
-		Region: os.region,
+		Region: region,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find compute v2 endpoint for region %s: %v", os.region, err)
+		return nil, fmt.Errorf("failed to find compute v2 endpoint for region %s: %v", region, err)
 	}
 	return compute, nil
 }
@@ -74,3 +82,14 @@ func (os *OpenStack) NewLoadBalancerV2() (*gophercloud.ServiceClient, error) {
 	}
 	return lb, nil
 }
+
+// NewKeyManagerV1 creates a ServiceClient that may be used with the Barbican v1 API
+func (os *OpenStack) NewKeyManagerV1() (*gophercloud.ServiceClient, error) {
+	secret, err := openstack.NewKeyManagerV1(os.provider, gophercloud.EndpointOpts{
+		Region: os.region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find key-manager v1 endpoint for region %s: %v", os.region, err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	tokens3 "github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+)
+
+const (
+	// clientPoolCheckInterval is the interval between the checks of the pooled tokens
+	clientPoolCheckInterval = 5 * time.Minute
+	// tokenRefreshBefore is how long before its expiry a token is refreshed,
+	// it's greater than clientPoolCheckInterval so that no token expires
+	// between two checks
+	tokenRefreshBefore = 2 * clientPoolCheckInterval
+
+	tokenRefreshReasonExpiring = "token_expiring"
+	tokenRefreshReasonInvalid  = "token_invalid"
+)
+
+// DefaultProviderClientPool is the pool of the provider clients shared by
+// the controllers of the process
+var DefaultProviderClientPool = NewProviderClientPool()
+
+// ProviderClientPool keeps the authenticated provider clients by their
+// authentication options and the options they are created with, so that the
+// same clients are shared rather than authenticating again. Once started, the
+// tokens of the pooled clients are validated periodically and refreshed before
+// they expire.
+type ProviderClientPool struct {
+	lock    sync.Mutex
+	clients map[providerClientKey]*gophercloud.ProviderClient
+	start   sync.Once
+}
+
+// providerClientKey identifies a pooled provider client
+type providerClientKey struct {
+	auth AuthOpts
+	// client is the key of the other options of the client, e.g. its
+	// transport and its rate limits
+	client string
+}
+
+// NewProviderClientPool creates an empty pool of provider clients
+func NewProviderClientPool() *ProviderClientPool {
+	return &ProviderClientPool{
+		clients: map[providerClientKey]*gophercloud.ProviderClient{},
+	}
+}
+
+// ClientOptionsKey returns the key of the options newClient applies to the
+// provider clients besides their authentication options, the clients created
+// with other options aren't shared.
+func ClientOptionsKey(opts ...interface{}) string {
+	// The options are plain config values, which are always encoded
+	key, _ := json.Marshal(opts)
+	return string(key)
+}
+
+// Get returns the pooled provider client of the authentication options and of
+// the client options key. The client is created with newClient when the pool
+// doesn't have one yet.
+func (p *ProviderClientPool) Get(cfg *AuthOpts, clientOpts string, newClient func() (*gophercloud.ProviderClient, error)) (*gophercloud.ProviderClient, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := providerClientKey{auth: *cfg, client: clientOpts}
+	if provider, ok := p.clients[key]; ok {
+		return provider, nil
+	}
+
+	provider, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	p.clients[key] = provider
+
+	return provider, nil
+}
+
+// Start checks the tokens of the pooled clients periodically until stopCh is
+// closed. The pool is started once, later calls do nothing.
+func (p *ProviderClientPool) Start(stopCh <-chan struct{}) {
+	p.start.Do(func() {
+		go wait.Until(p.check, clientPoolCheckInterval, stopCh)
+	})
+}
+
+// Close closes the idle connections of the pooled clients and empties the
+// pool
+func (p *ProviderClientPool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key, provider := range p.clients {
+		provider.HTTPClient.CloseIdleConnections()
+		delete(p.clients, key)
+	}
+}
+
+func (p *ProviderClientPool) check() {
+	p.lock.Lock()
+	clients := make(map[providerClientKey]*gophercloud.ProviderClient, len(p.clients))
+	for key, provider := range p.clients {
+		clients[key] = provider
+	}
+	p.lock.Unlock()
+
+	var earliest time.Time
+	for key, provider := range clients {
+		expiresAt, ok := checkProviderClient(provider)
+		if !ok {
+			// The client is created again on the next Get. The callers
+			// already holding it keep using it, its requests re-authenticate
+			// on their own when the token is rejected.
+			p.lock.Lock()
+			if p.clients[key] == provider {
+				delete(p.clients, key)
+			}
+			p.lock.Unlock()
+			continue
+		}
+		if !expiresAt.IsZero() && (earliest.IsZero() || expiresAt.Before(earliest)) {
+			earliest = expiresAt
+		}
+	}
+
+	if !earliest.IsZero() {
+		metrics.ObserveTokenExpiry(earliest)
+	}
+}
+
+// tokenExpiry returns the expiry of the Keystone v3 token of the provider client
+func tokenExpiry(provider *gophercloud.ProviderClient) (time.Time, bool) {
+	result, ok := provider.GetAuthResult().(tokens3.CreateResult)
+	if !ok {
+		return time.Time{}, false
+	}
+	token, err := result.ExtractToken()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return token.ExpiresAt, true
+}
+
+// checkProviderClient refreshes the token of the provider client when it
+// expires soon or is no longer valid, and returns the expiry of the current
+// token. It returns false when the token couldn't be refreshed.
+func checkProviderClient(provider *gophercloud.ProviderClient) (time.Time, bool) {
+	expiresAt, ok := tokenExpiry(provider)
+	if !ok {
+		// Only the Keystone v3 tokens are checked
+		return time.Time{}, true
+	}
+
+	var reason string
+	if time.Until(expiresAt) < tokenRefreshBefore {
+		reason = tokenRefreshReasonExpiring
+	} else if identity, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{}); err != nil {
+		klog.V(4).Infof("Failed to create an identity client to validate the token: %v", err)
+	} else if valid, err := tokens3.Validate(identity, provider.Token()); err != nil {
+		klog.V(4).Infof("Failed to validate the token: %v", err)
+	} else if !valid {
+		reason = tokenRefreshReasonInvalid
+	}
+
+	if reason == "" {
+		return expiresAt, true
+	}
+
+	if provider.ReauthFunc == nil {
+		klog.Warningf("The OpenStack token can't be refreshed (%s), the client isn't allowed to re-authenticate", reason)
+		return time.Time{}, false
+	}
+
+	klog.V(4).Infof("Refreshing the OpenStack token (%s)", reason)
+	err := metrics.ObserveTokenRefresh(reason, provider.Reauthenticate(provider.Token()))
+	if err != nil {
+		klog.Errorf("Failed to refresh the OpenStack token (%s): %v", reason, err)
+		return time.Time{}, false
+	}
+
+	expiresAt, _ = tokenExpiry(provider)
+	return expiresAt, true
+}
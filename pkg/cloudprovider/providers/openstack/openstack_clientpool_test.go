@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestProviderClientPool(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// the first token expires soon, the next ones are valid for an hour
+	auths := 0
+	valid := true
+	th.Mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			auths++
+			expiresAt := time.Now().Add(time.Hour)
+			if auths == 1 {
+				expiresAt = time.Now().Add(time.Minute)
+			}
+			w.Header().Add("X-Subject-Token", fmt.Sprintf("token-%d", auths))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": {"expires_at": "%s", "catalog": []}}`, expiresAt.UTC().Format(gophercloud.RFC3339Milli))
+		case http.MethodHead:
+			if !valid {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	cfg := &AuthOpts{AuthURL: th.Endpoint(), Username: "user", Password: "pass", DomainName: "default"}
+	created := 0
+	newClient := func() (*gophercloud.ProviderClient, error) {
+		created++
+		provider, err := openstack.NewClient(th.Endpoint())
+		if err != nil {
+			return nil, err
+		}
+		opts := cfg.ToAuth3Options()
+		return provider, openstack.AuthenticateV3(provider, &opts, gophercloud.EndpointOpts{})
+	}
+
+	pool := NewProviderClientPool()
+	provider, err := pool.Get(cfg, "", newClient)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "token-1", provider.Token())
+
+	// the client is shared
+	other, err := pool.Get(cfg, "", newClient)
+	th.AssertNoErr(t, err)
+	if other != provider {
+		t.Errorf("expected the pooled provider client")
+	}
+	th.AssertEquals(t, 1, created)
+
+	// the expiring token is refreshed
+	pool.check()
+	th.AssertEquals(t, "token-2", provider.Token())
+
+	// the valid token is kept
+	pool.check()
+	th.AssertEquals(t, "token-2", provider.Token())
+
+	// the invalid token is refreshed
+	valid = false
+	pool.check()
+	th.AssertEquals(t, "token-3", provider.Token())
+	th.AssertEquals(t, 1, created)
+
+	// the clients created with other options aren't shared
+	other, err = pool.Get(cfg, ClientOptionsKey(TransportOpts{MaxIdleConns: 10}), newClient)
+	th.AssertNoErr(t, err)
+	if other == provider {
+		t.Errorf("expected another provider client for other client options")
+	}
+	th.AssertEquals(t, 2, created)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// authEnvFileSuffix is the suffix of the environment variables naming the
+// file which contains the value, e.g. a mounted Secret key
+const authEnvFileSuffix = "_FILE"
+
+// authEnvVars returns the standard OpenStack environment variables of the
+// authentication options, with the options they set
+func authEnvVars(cfg *AuthOpts) []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"OS_AUTH_URL", &cfg.AuthURL},
+		{"OS_USER_ID", &cfg.UserID},
+		{"OS_USERNAME", &cfg.Username},
+		{"OS_PASSWORD", &cfg.Password},
+		{"OS_PROJECT_ID", &cfg.TenantID},
+		{"OS_PROJECT_NAME", &cfg.TenantName},
+		{"OS_DOMAIN_ID", &cfg.DomainID},
+		{"OS_DOMAIN_NAME", &cfg.DomainName},
+		{"OS_PROJECT_DOMAIN_ID", &cfg.TenantDomainID},
+		{"OS_PROJECT_DOMAIN_NAME", &cfg.TenantDomainName},
+		{"OS_USER_DOMAIN_ID", &cfg.UserDomainID},
+		{"OS_USER_DOMAIN_NAME", &cfg.UserDomainName},
+		{"OS_REGION_NAME", &cfg.Region},
+		{"OS_TRUST_ID", &cfg.TrustID},
+		{"OS_APPLICATION_CREDENTIAL_ID", &cfg.ApplicationCredentialID},
+		{"OS_APPLICATION_CREDENTIAL_NAME", &cfg.ApplicationCredentialName},
+		{"OS_APPLICATION_CREDENTIAL_SECRET", &cfg.ApplicationCredentialSecret},
+	}
+}
+
+// ApplyAuthEnv overrides the authentication options of the config file with
+// the standard OpenStack environment variables, e.g. OS_PASSWORD, or with the
+// content of the file named by the same variable with the _FILE suffix, e.g.
+// OS_PASSWORD_FILE. Setting both variables of an option is an error.
+func ApplyAuthEnv(cfg *AuthOpts) error {
+	return applyAuthEnv(cfg, os.Getenv, ioutil.ReadFile)
+}
+
+func applyAuthEnv(cfg *AuthOpts, getenv func(string) string, readFile func(string) ([]byte, error)) error {
+	for _, v := range authEnvVars(cfg) {
+		value, file := getenv(v.name), getenv(v.name+authEnvFileSuffix)
+		switch {
+		case value != "" && file != "":
+			return fmt.Errorf("both %s and %s%s are set, only one of them may be set", v.name, v.name, authEnvFileSuffix)
+		case value != "":
+			*v.value = value
+		case file != "":
+			data, err := readFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s%s: %v", v.name, authEnvFileSuffix, err)
+			}
+			// The trailing newline of the file is not part of the value
+			*v.value = strings.TrimRight(string(data), "\r\n")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestApplyAuthEnv(t *testing.T) {
+	files := map[string]string{
+		"/secrets/password":   "file-password\n",
+		"/secrets/app-secret": "file-secret",
+	}
+	readFile := func(path string) ([]byte, error) {
+		data, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("open %s: no such file or directory", path)
+		}
+		return []byte(data), nil
+	}
+	getenv := func(env map[string]string) func(string) string {
+		return func(name string) string { return env[name] }
+	}
+
+	cfg := AuthOpts{
+		AuthURL:                     "http://auth.url",
+		Username:                    "file-user",
+		Password:                    "config-password",
+		ApplicationCredentialSecret: "config-secret",
+		Region:                      "RegionOne",
+	}
+	err := applyAuthEnv(&cfg, getenv(map[string]string{
+		"OS_USERNAME":                           "env-user",
+		"OS_PASSWORD_FILE":                      "/secrets/password",
+		"OS_APPLICATION_CREDENTIAL_SECRET_FILE": "/secrets/app-secret",
+		"OS_REGION_NAME":                        "",
+	}), readFile)
+	th.AssertNoErr(t, err)
+
+	// the environment overrides the config file, the options without
+	// variable keep their value
+	th.AssertEquals(t, "http://auth.url", cfg.AuthURL)
+	th.AssertEquals(t, "env-user", cfg.Username)
+	th.AssertEquals(t, "file-password", cfg.Password)
+	th.AssertEquals(t, "file-secret", cfg.ApplicationCredentialSecret)
+	th.AssertEquals(t, "RegionOne", cfg.Region)
+
+	for _, test := range []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{"OS_PASSWORD": "env-password", "OS_PASSWORD_FILE": "/secrets/password"}, "both OS_PASSWORD and OS_PASSWORD_FILE are set"},
+		{map[string]string{"OS_PASSWORD_FILE": "/secrets/missing"}, "failed to read OS_PASSWORD_FILE"},
+	} {
+		err := applyAuthEnv(&AuthOpts{}, getenv(test.env), readFile)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("applyAuthEnv() with %v returned %v, expected %q", test.env, err, test.expected)
+		}
+	}
+}
+
+func TestReadConfigAuthEnv(t *testing.T) {
+	os.Setenv("OS_APPLICATION_CREDENTIAL_SECRET", "env-secret")
+	defer os.Unsetenv("OS_APPLICATION_CREDENTIAL_SECRET")
+
+	cfg, err := ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ application-credential-id = id
+ application-credential-secret = config-secret
+ `))
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "id", cfg.Global.ApplicationCredentialID)
+	th.AssertEquals(t, "env-secret", cfg.Global.ApplicationCredentialSecret)
+}
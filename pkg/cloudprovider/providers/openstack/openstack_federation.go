@@ -0,0 +1,173 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	tokens3 "github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"k8s.io/klog/v2"
+)
+
+// usesFederation returns whether the options authenticate with the access
+// token of a Keystone federated identity provider
+func usesFederation(cfg *AuthOpts) bool {
+	return cfg.IdentityProvider != ""
+}
+
+// readAccessToken returns the access token of the identity provider. The
+// access token file is read on every authentication, so that the token can be
+// refreshed by another process.
+func readAccessToken(cfg *AuthOpts) (string, error) {
+	if cfg.AccessTokenFile == "" {
+		if cfg.AccessToken == "" {
+			return "", fmt.Errorf("no access token of identity provider %s configured", cfg.IdentityProvider)
+		}
+		return cfg.AccessToken, nil
+	}
+
+	data, err := ioutil.ReadFile(cfg.AccessTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the access token of identity provider %s: %v", cfg.IdentityProvider, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// accessTokenExpiry returns the expiry of the access token when it's a JWT
+// with an exp claim
+func accessTokenExpiry(accessToken string) (time.Time, bool) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(claims.Exp), 0), true
+}
+
+// federatedScope returns the scope of the token obtained with the unscoped
+// federated token, the project when one is configured or else the domain
+func federatedScope(cfg *AuthOpts) tokens3.Scope {
+	if cfg.TenantID != "" {
+		return tokens3.Scope{ProjectID: cfg.TenantID}
+	}
+	if cfg.TenantName != "" {
+		return tokens3.Scope{
+			ProjectName: cfg.TenantName,
+			DomainID:    replaceEmpty(cfg.TenantDomainID, cfg.DomainID),
+			DomainName:  replaceEmpty(cfg.TenantDomainName, cfg.DomainName),
+		}
+	}
+	return tokens3.Scope{DomainID: cfg.DomainID, DomainName: cfg.DomainName}
+}
+
+// exchangeAccessToken exchanges the access token of the identity provider
+// for an unscoped Keystone token and returns the ID of that token
+func exchangeAccessToken(identity *gophercloud.ServiceClient, cfg *AuthOpts, accessToken string) (string, error) {
+	url := identity.ServiceURL("OS-FEDERATION", "identity_providers", cfg.IdentityProvider, "protocols", cfg.Protocol, "auth")
+	resp, err := identity.Post(url, nil, nil, &gophercloud.RequestOpts{
+		MoreHeaders: map[string]string{"Authorization": "Bearer " + accessToken},
+		OkCodes:     []int{http.StatusOK, http.StatusCreated},
+	})
+	if err != nil {
+		if isUnauthorized(err) {
+			if expiresAt, ok := accessTokenExpiry(accessToken); ok && time.Now().After(expiresAt) {
+				return "", fmt.Errorf("the access token of identity provider %s expired at %s and can't be refreshed, a new access token is required: %v",
+					cfg.IdentityProvider, expiresAt.UTC().Format(time.RFC3339), err)
+			}
+			return "", fmt.Errorf("identity provider %s rejected the access token: %v", cfg.IdentityProvider, err)
+		}
+		return "", fmt.Errorf("failed to exchange the access token of identity provider %s: %v", cfg.IdentityProvider, err)
+	}
+
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return "", fmt.Errorf("no token returned for the access token of identity provider %s", cfg.IdentityProvider)
+	}
+	return token, nil
+}
+
+func authenticateFederated(provider *gophercloud.ProviderClient, cfg *AuthOpts) error {
+	accessToken, err := readAccessToken(cfg)
+	if err != nil {
+		return err
+	}
+
+	identity, err := openstack.NewIdentityV3(provider, gophercloud.EndpointOpts{})
+	if err != nil {
+		return err
+	}
+
+	unscoped, err := exchangeAccessToken(identity, cfg, accessToken)
+	if err != nil {
+		return err
+	}
+
+	opts := tokens3.AuthOptions{
+		IdentityEndpoint: cfg.AuthURL,
+		TokenID:          unscoped,
+		Scope:            federatedScope(cfg),
+	}
+	return openstack.AuthenticateV3(provider, &opts, gophercloud.EndpointOpts{})
+}
+
+// AuthenticateFederated authenticates the provider client with the access
+// token of a Keystone federated identity provider, e.g. an OIDC access token
+// with the openid protocol. The access token is exchanged for an unscoped
+// token, which is then exchanged for a token scoped to the configured project
+// or domain. The exchange runs again on re-authentication.
+func AuthenticateFederated(provider *gophercloud.ProviderClient, cfg *AuthOpts) error {
+	if err := authenticateFederated(provider, cfg); err != nil {
+		return err
+	}
+
+	provider.ReauthFunc = func() error {
+		// Authenticate a throwaway copy of the client, whose requests are not
+		// re-authenticated and don't send the expired token
+		tac := *provider
+		tac.SetThrowaway(true)
+		tac.ReauthFunc = nil
+		if err := tac.SetTokenAndAuthResult(nil); err != nil {
+			return err
+		}
+
+		if err := authenticateFederated(&tac, cfg); err != nil {
+			klog.Errorf("Failed to re-authenticate with identity provider %s: %v", cfg.IdentityProvider, err)
+			return err
+		}
+		provider.CopyTokenFrom(&tac)
+		return nil
+	}
+
+	return nil
+}
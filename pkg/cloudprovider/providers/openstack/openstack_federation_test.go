@@ -0,0 +1,114 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func fakeJWT(expiresAt time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub": "user", "exp": %d}`, expiresAt.Unix())))
+	return "eyJhbGciOiJub25lIn0." + claims + ".signature"
+}
+
+func TestAuthenticateFederated(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	validAccessToken := fakeJWT(time.Now().Add(time.Hour))
+	exchanges := 0
+	th.Mux.HandleFunc("/v3/OS-FEDERATION/identity_providers/idp/protocols/openid/auth", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, http.MethodPost)
+		if r.Header.Get("Authorization") != "Bearer "+validAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		exchanges++
+		w.Header().Add("X-Subject-Token", fmt.Sprintf("unscoped-%d", exchanges))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": {"expires_at": "2030-01-01T00:00:00.000000Z"}}`)
+	})
+	th.Mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Auth struct {
+				Identity struct {
+					Token struct {
+						ID string
+					}
+				}
+				Scope struct {
+					Project struct {
+						ID string
+					}
+				}
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the token request: %v", err)
+		}
+		th.AssertEquals(t, "project", req.Auth.Scope.Project.ID)
+		w.Header().Add("X-Subject-Token", strings.Replace(req.Auth.Identity.Token.ID, "unscoped", "scoped", 1))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": {"expires_at": "2030-01-01T00:00:00.000000Z", "catalog": []}}`)
+	})
+
+	cfg := &AuthOpts{
+		AuthURL:          th.Endpoint(),
+		TenantID:         "project",
+		IdentityProvider: "idp",
+		Protocol:         "openid",
+		AccessToken:      validAccessToken,
+	}
+
+	provider, err := openstack.NewClient(th.Endpoint())
+	th.AssertNoErr(t, err)
+	th.AssertNoErr(t, AuthenticateFederated(provider, cfg))
+	th.AssertEquals(t, "scoped-1", provider.Token())
+
+	// the re-authentication exchanges the access token again
+	th.AssertNoErr(t, provider.Reauthenticate(provider.Token()))
+	th.AssertEquals(t, "scoped-2", provider.Token())
+
+	// the expired access token is reported
+	cfg.AccessToken = fakeJWT(time.Now().Add(-time.Hour))
+	err = provider.Reauthenticate(provider.Token())
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected the expired access token to be reported, got %v", err)
+	}
+	th.AssertEquals(t, "scoped-2", provider.Token())
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	expiresAt := time.Unix(1600000000, 0)
+	actual, ok := accessTokenExpiry(fakeJWT(expiresAt))
+	if !ok || !actual.Equal(expiresAt) {
+		t.Errorf("expected the expiry %v, got %v", expiresAt, actual)
+	}
+
+	if _, ok := accessTokenExpiry("opaque-token"); ok {
+		t.Errorf("expected no expiry of an opaque access token")
+	}
+}
@@ -18,17 +18,41 @@ package openstack
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"net"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/extendedserverattributes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/servergroups"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/dns"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/portsbinding"
+	neutronports "github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"k8s.io/klog/v2"
 
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
 	"k8s.io/cloud-provider-openstack/pkg/util/errors"
@@ -37,15 +61,314 @@ import (
 
 // Instances encapsulates an implementation of Instances for OpenStack.
 type Instances struct {
-	compute        *gophercloud.ServiceClient
-	opts           MetadataOpts
-	networkingOpts NetworkingOpts
+	compute          *gophercloud.ServiceClient
+	network          *gophercloud.ServiceClient
+	trunks           bool
+	regions          map[string]regionClients
+	region           string
+	regionProviderID bool
+	projectID        string
+	opts             MetadataOpts
+	networkingOpts   NetworkingOpts
+	instancesOpts    InstancesOpts
+	kclient          kubernetes.Interface
+	eventRecorder    record.EventRecorder
+	nodes            toolscache.Indexer
+	flavorCache      *cache.LRUExpireCache
+	serverWarmup     *serverWarmup
+	serverGroups     *serverGroupMembers
+	lookupBackoff    *lookupBackoff
+	metadataSyncs    *metadataDebounce
+	dryRun           bool
+	// nodeSelector selects the nodes backed by servers, nil selects all of them
+	nodeSelector labels.Selector
+}
+
+// serverWarmup serves the first lookups of the servers by ID, e.g. during the
+// initial sync of the nodes, from a single list of the servers of the project
+type serverWarmup struct {
+	once  sync.Once
+	cache *cache.LRUExpireCache
+}
+
+// serverGroupMembers caches the Nova server group of the servers. A server
+// joins its group when it's created and never leaves it, so the groups are
+// listed again only for the servers missing from the previous list, at most
+// once per serverGroupRelistInterval in each region.
+type serverGroupMembers struct {
+	lock sync.Mutex
+	// groups is the group name of the "<region>/<server ID>" servers
+	groups map[string]string
+	// listed is when the groups of each region were listed
+	listed map[string]time.Time
+}
+
+func newServerGroupMembers() *serverGroupMembers {
+	return &serverGroupMembers{groups: map[string]string{}, listed: map[string]time.Time{}}
+}
+
+// lookupBackoff delays the metadata lookups of the nodes failing with a
+// transient error, so that the nodes failing repeatedly don't make requests
+// on every sync. The delay of a node doubles on every failure up to max and
+// is reset on success.
+type lookupBackoff struct {
+	lock    sync.Mutex
+	initial time.Duration
+	max     time.Duration
+	nodes   map[string]*nodeBackoff
+}
+
+// nodeBackoff is the backoff state of a node
+type nodeBackoff struct {
+	delay time.Duration
+	until time.Time
+	err   error
+}
+
+func newLookupBackoff(initial, max time.Duration) *lookupBackoff {
+	if max < initial {
+		max = initial
+	}
+	return &lookupBackoff{initial: initial, max: max, nodes: map[string]*nodeBackoff{}}
+}
+
+// check returns the last error of the node while it's backing off
+func (b *lookupBackoff) check(node string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state, ok := b.nodes[node]
+	if !ok || !time.Now().Before(state.until) {
+		return nil
+	}
+	klog.V(5).InfoS("Lookup of node backing off after a transient error", "node", node, "until", state.until)
+	return state.err
+}
+
+// observe backs off the node after a transient error and resets its backoff
+// otherwise
+func (b *lookupBackoff) observe(node string, err error) {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !stderrors.Is(err, ErrBackendUnavailable) && !errors.IsTransient(err) {
+		delete(b.nodes, node)
+		return
+	}
+
+	state, ok := b.nodes[node]
+	if !ok {
+		state = &nodeBackoff{delay: b.initial}
+		b.nodes[node] = state
+	} else {
+		state.delay *= 2
+		if state.delay > b.max {
+			state.delay = b.max
+		}
+	}
+	state.until = time.Now().Add(state.delay)
+	state.err = err
+	klog.V(4).InfoS("Backing off the lookup of node after a transient error", "node", node, "delay", state.delay, "err", err)
+}
+
+// metadataDebounce serves the metadata of a node computed less than interval
+// ago instead of looking up its server again, so that the nodes synced again
+// and again, e.g. while an endpoint is flapping, don't make requests on every
+// sync. The metadata of a node is computed again once the interval is over or
+// as soon as the providerID of the node changes.
+type metadataDebounce struct {
+	lock     sync.Mutex
+	interval time.Duration
+	nodes    map[string]*nodeMetadata
+}
+
+// nodeMetadata is the last metadata computed for a node
+type nodeMetadata struct {
+	providerID string
+	computed   time.Time
+	metadata   *cloudprovider.InstanceMetadata
+}
+
+func newMetadataDebounce(interval time.Duration) *metadataDebounce {
+	return &metadataDebounce{interval: interval, nodes: map[string]*nodeMetadata{}}
+}
+
+// get returns the metadata of the node computed within the interval, nil
+// when it must be computed again
+func (d *metadataDebounce) get(node *v1.Node) *cloudprovider.InstanceMetadata {
+	if d == nil {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	state, ok := d.nodes[node.Name]
+	if !ok || state.providerID != node.Spec.ProviderID || time.Since(state.computed) >= d.interval {
+		return nil
+	}
+	klog.V(5).InfoS("Skipping the metadata sync of node", "node", klog.KObj(node), "computed", state.computed)
+	metrics.ObserveMetadataSyncSkipped()
+	return state.metadata
+}
+
+// observe records the metadata computed for the node, the failed lookups are
+// not recorded so that the next sync looks up the node again
+func (d *metadataDebounce) observe(node *v1.Node, md *cloudprovider.InstanceMetadata, err error) {
+	if d == nil {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err != nil {
+		delete(d.nodes, node.Name)
+		return
+	}
+	d.nodes[node.Name] = &nodeMetadata{providerID: node.Spec.ProviderID, computed: time.Now(), metadata: md}
+}
+
+// forget removes the metadata of the deleted node
+func (d *metadataDebounce) forget(node string) {
+	if d == nil {
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.nodes, node)
+}
+
+// regionClients are the clients of an additional region
+type regionClients struct {
+	compute *gophercloud.ServiceClient
+	network *gophercloud.ServiceClient
+	trunks  bool
 }
 
 const (
-	instanceShutoff = "SHUTOFF"
+	// defaultAPITimeout is the default timeout of the OpenStack API requests of the instances
+	defaultAPITimeout = 30 * time.Second
+	// lookupBackoffInitial and lookupBackoffMax are the default first and
+	// maximum backoff of the metadata lookups of a node failing with a
+	// transient error
+	lookupBackoffInitial = 5 * time.Second
+	lookupBackoffMax     = 5 * time.Minute
+	// defaultMetadataSyncInterval is the default minimum interval between the
+	// metadata lookups of a node
+	defaultMetadataSyncInterval = 30 * time.Second
+
+	instanceShutoff   = "SHUTOFF"
+	instanceSuspended = "SUSPENDED"
+	instancePaused    = "PAUSED"
+	instanceError     = "ERROR"
+	// the servers in these states are still returned by Nova during database
+	// inconsistencies, though they are gone
+	instanceDeleted     = "DELETED"
+	instanceSoftDeleted = "SOFT_DELETED"
+
+	// LabelFaultReason is the node label holding the fault message of an instance in ERROR state
+	LabelFaultReason = "node.openstack.org/fault-reason"
+	// LabelTagPrefix is the prefix of the node labels exposing the server tags
+	LabelTagPrefix = "tag.openstack.org/"
+	// LabelMetadataPrefix is the prefix of the node labels exposing the server metadata
+	LabelMetadataPrefix = "metadata.openstack.org/"
+	// LabelComputeHost is the node label holding the compute host of the instance
+	LabelComputeHost = "node.openstack.org/compute-host"
+	// LabelFlavorVCPUs, LabelFlavorRAM and LabelFlavorDisk are the node labels
+	// holding the number of vCPUs, the MiB of RAM and the GiB of root disk of
+	// the server flavor
+	LabelFlavorVCPUs = "node.openstack.org/flavor-vcpus"
+	LabelFlavorRAM   = "node.openstack.org/flavor-ram-mb"
+	LabelFlavorDisk  = "node.openstack.org/flavor-disk-gb"
+
+	// computeHostLabelHostID labels the nodes with the host ID of their server,
+	// an obfuscated compute host name unique per project
+	computeHostLabelHostID = "host-id"
+	// computeHostLabelHost labels the nodes with the compute host name of their
+	// server, which Nova only returns with the appropriate policy
+	computeHostLabelHost = "host"
+
+	// EventReasonInstanceNotFound is the reason of the events and log lines
+	// reporting that the server of a node doesn't exist anymore
+	EventReasonInstanceNotFound = "InstanceNotFound"
+
+	// nodeProviderIDIndex is the index of the node informer cache by providerID
+	nodeProviderIDIndex = "providerID"
+
+	// tagsMicroversion is the first compute API microversion returning the server tags
+	tagsMicroversion = "2.26"
+
+	// LabelServerGroup is the node label holding the Nova server group of the instance
+	LabelServerGroup = "node.openstack.org/server-group"
+	// serverGroupRelistInterval is the minimum interval between the lists of
+	// the server groups of a region
+	serverGroupRelistInterval = time.Minute
 )
 
+var (
+	// ErrMultipleInstances is used when several servers match a node
+	ErrMultipleInstances = stderrors.New("multiple servers match the node")
+	// ErrRegionMismatch is used when the region of a node providerID is not supported
+	ErrRegionMismatch = stderrors.New("providerID region is not supported")
+	// ErrBackendUnavailable is used when the OpenStack API failed with a transient error
+	ErrBackendUnavailable = stderrors.New("OpenStack API is unavailable")
+	// ErrNameLookupDisabled is used when the server of a node without providerID
+	// is looked up while disable-name-lookup is set
+	ErrNameLookupDisabled = stderrors.New("looking up servers by node name is disabled, the node must have a providerID")
+	// ErrNodeNotManaged is used when the metadata of a node not matching the
+	// node-selector is requested
+	ErrNodeNotManaged = stderrors.New("node doesn't match the node-selector, it is not managed by the cloud provider")
+)
+
+// InstanceError is returned when the server of a node can't be resolved. It
+// matches its Class with errors.Is and unwraps to the underlying error Err.
+type InstanceError struct {
+	Class error
+	Node  string
+	Err   error
+}
+
+func (e *InstanceError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("node %s: %v", e.Node, e.Class)
+	}
+	return fmt.Sprintf("node %s: %v: %v", e.Node, e.Class, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *InstanceError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the class of the error
+func (e *InstanceError) Is(target error) bool {
+	return target == e.Class
+}
+
+// wrapInstanceError wraps a lookup error of the server of a node into an
+// InstanceError when its class is known.
+func wrapInstanceError(node string, err error) error {
+	switch {
+	case err == nil, err == cloudprovider.InstanceNotFound:
+		return err
+	case err == ErrMultipleResults:
+		return &InstanceError{Class: ErrMultipleInstances, Node: node, Err: err}
+	case errors.IsTransient(err):
+		return &InstanceError{Class: ErrBackendUnavailable, Node: node, Err: err}
+	}
+	return err
+}
+
 // Instances returns an implementation of Instances for OpenStack.
 func (os *OpenStack) Instances() (cloudprovider.Instances, bool) {
 	return os.instances()
@@ -58,21 +381,168 @@ func (os *OpenStack) InstancesV2() (cloudprovider.InstancesV2, bool) {
 }
 
 func (os *OpenStack) instances() (*Instances, bool) {
-	klog.V(4).Info("openstack.Instances() called")
+	klog.V(4).InfoS("openstack.Instances() called")
 
 	compute, err := os.NewComputeV2()
 	if err != nil {
-		klog.Errorf("unable to access compute v2 API : %v", err)
+		klog.ErrorS(err, "Unable to access compute v2 API")
 		return nil, false
 	}
 
+	regions := map[string]regionClients{}
+	for _, region := range os.instancesOpts.AdditionalRegions {
+		regionCompute, err := os.newComputeV2(region)
+		if err != nil {
+			klog.ErrorS(err, "Unable to access compute v2 API of additional region", "region", region)
+			continue
+		}
+		regionNetwork, trunks := os.instancesNetworkClient(region)
+		regions[region] = regionClients{
+			compute: regionCompute,
+			network: regionNetwork,
+			trunks:  trunks,
+		}
+	}
+
+	var nodeSelector labels.Selector
+	if os.instancesOpts.NodeSelector != "" {
+		// The selector is validated with the configuration
+		nodeSelector, err = labels.Parse(os.instancesOpts.NodeSelector)
+		if err != nil {
+			klog.ErrorS(err, "Invalid node-selector", "nodeSelector", os.instancesOpts.NodeSelector)
+			return nil, false
+		}
+	}
+
+	network, trunks := os.instancesNetworkClient(os.region)
 	return &Instances{
-		compute:        compute,
-		opts:           os.metadataOpts,
-		networkingOpts: os.networkingOpts,
+		compute:          compute,
+		network:          network,
+		trunks:           trunks,
+		regions:          regions,
+		region:           os.region,
+		regionProviderID: os.regionProviderID,
+		projectID:        os.projectID,
+		opts:             os.metadataOpts,
+		networkingOpts:   os.networkingOpts,
+		instancesOpts:    os.instancesOpts,
+		kclient:          os.kclient,
+		eventRecorder:    os.eventRecorder,
+		nodes:            os.nodes,
+		flavorCache:      os.flavorCache,
+		serverWarmup:     os.serverWarmup,
+		serverGroups:     os.serverGroups,
+		lookupBackoff:    os.lookupBackoff,
+		metadataSyncs:    os.metadataSyncs,
+		dryRun:           instancesDryRun,
+		nodeSelector:     nodeSelector,
 	}, true
 }
 
+// instancesNetworkClient returns the network client of the region, used to
+// list the ports of the servers, or nil when Neutron is not available, and
+// whether the Neutron trunk extension is available to resolve the subports of
+// trunks.
+func (os *OpenStack) instancesNetworkClient(region string) (*gophercloud.ServiceClient, bool) {
+	network, err := os.newNetworkV2(region)
+	if err != nil {
+		klog.Warningf("Failed to create an OpenStack Network client, the server ports and trunk subports will be ignored: %v", err)
+		return nil, false
+	}
+	exts, err := networkExtensions(network)
+	if err != nil {
+		klog.Warningf("Failed to list Neutron extensions, trunk subports will be ignored: %v", err)
+		return network, false
+	}
+	return network, exts["trunk"]
+}
+
+// watchNodes starts the node informer. Its cache resolves the nodes of the
+// providerIDs the controllers look up, and the metrics of the instances of
+// the deleted nodes, which are no longer looked up, e.g. when the node is
+// deleted before its server, are no longer recorded.
+func (os *OpenStack) watchNodes(stop <-chan struct{}) {
+	informerFactory := informers.NewSharedInformerFactory(os.kclient, 0)
+	informer := informerFactory.Core().V1().Nodes().Informer()
+	if err := informer.AddIndexers(toolscache.Indexers{nodeProviderIDIndex: nodeProviderIDIndexFunc}); err != nil {
+		klog.Errorf("Failed to index the nodes by providerID: %v", err)
+	} else {
+		os.nodes = informer.GetIndexer()
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		DeleteFunc: os.forgetDeletedNode,
+	})
+	informerFactory.Start(stop)
+}
+
+// nodeProviderIDIndexFunc indexes the nodes by providerID
+func nodeProviderIDIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok || node.Spec.ProviderID == "" {
+		return nil, nil
+	}
+	return []string{node.Spec.ProviderID}, nil
+}
+
+// nodeByProviderID returns the node of the providerID from the node informer
+// cache. The Instances methods taking a providerID are passed the providerID
+// of a node, a node with only the providerID is returned when it isn't cached.
+func (i *Instances) nodeByProviderID(providerID string) *v1.Node {
+	if i.nodes != nil {
+		objs, err := i.nodes.ByIndex(nodeProviderIDIndex, providerID)
+		if err == nil && len(objs) == 1 {
+			if node, ok := objs[0].(*v1.Node); ok {
+				return node
+			}
+		}
+	}
+	return &v1.Node{Spec: v1.NodeSpec{ProviderID: providerID}}
+}
+
+// getNodeByName returns the node of the given name from the node informer
+// cache, or from the API server when it isn't cached. The Instances methods
+// taking a node name are passed the name of a node, a node with only the name
+// is returned without a Kubernetes client or when the node isn't found.
+func (i *Instances) getNodeByName(ctx context.Context, name types.NodeName) (*v1.Node, error) {
+	if i.nodes != nil {
+		obj, exists, err := i.nodes.GetByKey(string(name))
+		if err == nil && exists {
+			if node, ok := obj.(*v1.Node); ok {
+				return node, nil
+			}
+		}
+	}
+	if i.kclient != nil {
+		node, err := i.kclient.CoreV1().Nodes().Get(ctx, string(name), metav1.GetOptions{})
+		if err == nil {
+			return node, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get node %s: %v", name, err)
+		}
+	}
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: string(name)}}, nil
+}
+
+func (os *OpenStack) forgetDeletedNode(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+	os.metadataSyncs.forget(node.Name)
+	if node.Spec.ProviderID == "" {
+		return
+	}
+	instanceID, _, err := parseProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return
+	}
+	metrics.ForgetInstance(instanceID)
+}
+
 // CurrentNodeName implements Instances.CurrentNodeName
 // Note this is *not* necessarily the same as hostname.
 func (i *Instances) CurrentNodeName(ctx context.Context, hostname string) (types.NodeName, error) {
@@ -90,203 +560,1011 @@ func (i *Instances) AddSSHKeyToAllInstances(ctx context.Context, user string, ke
 
 // NodeAddresses implements Instances.NodeAddresses
 func (i *Instances) NodeAddresses(ctx context.Context, name types.NodeName) ([]v1.NodeAddress, error) {
-	klog.V(4).Infof("NodeAddresses(%v) called", name)
+	klog.V(4).InfoS("NodeAddresses() called", "node", name)
 
-	addrs, err := getAddressesByName(i.compute, name, i.networkingOpts)
+	node, err := i.getNodeByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	md, err := i.InstanceMetadata(ctx, node)
 	if err != nil {
 		return nil, err
 	}
 
-	klog.V(4).Infof("NodeAddresses(%v) => %v", name, addrs)
-	return addrs, nil
+	klog.V(4).InfoS("NodeAddresses() returned", "node", name, "addresses", md.NodeAddresses)
+	return md.NodeAddresses, nil
 }
 
 // NodeAddressesByProviderID returns the node addresses of an instances with the specified unique providerID
 // This method will not be called from the node that is requesting this ID. i.e. metadata service
 // and other local methods cannot be used here
 func (i *Instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
-	klog.V(4).Infof("NodeAddressesByProviderID (%v) called", providerID)
-
-	instanceID, err := instanceIDFromProviderID(providerID)
+	klog.V(4).InfoS("NodeAddressesByProviderID() called", "providerID", providerID)
 
+	md, err := i.InstanceMetadata(ctx, i.nodeByProviderID(providerID))
 	if err != nil {
 		return []v1.NodeAddress{}, err
 	}
 
-	mc := metrics.NewMetricContext("server", "get")
-	server, err := servers.Get(i.compute, instanceID).Extract()
+	klog.V(4).InfoS("NodeAddressesByProviderID() returned", "providerID", providerID, "addresses", md.NodeAddresses)
+	return md.NodeAddresses, nil
+}
 
-	if mc.ObserveRequest(err) != nil {
-		return []v1.NodeAddress{}, err
+// isManaged returns whether the node matches the node-selector, the nodes not
+// matching are externally managed
+func (i *Instances) isManaged(node *v1.Node) bool {
+	return i.nodeSelector == nil || i.nodeSelector.Matches(labels.Set(node.Labels))
+}
+
+// InstanceExists returns true if the instance for the given node exists. The
+// nodes not matching the node-selector are reported as existing, so that they
+// are never deleted, and so are the nodes whose instance is not found in
+// dry-run mode.
+func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
+	if !i.isManaged(node) {
+		klog.V(5).InfoS("Node doesn't match the node-selector, it is reported as existing", "node", klog.KObj(node))
+		return true, nil
 	}
 
-	interfaces, err := getAttachedInterfacesByID(i.compute, server.ID)
+	ri, err := i.regionInstances(node)
 	if err != nil {
-		return []v1.NodeAddress{}, err
+		return false, err
 	}
 
-	addresses, err := nodeAddresses(server, interfaces, i.networkingOpts)
+	_, err = ri.getInstance(ctx, node)
+	if err == cloudprovider.InstanceNotFound && ri.dryRun {
+		klog.V(2).InfoS("Dry run: node would be deleted, its server is not found", "node", klog.KObj(node), "providerID", node.Spec.ProviderID)
+		return true, nil
+	}
+	if err == cloudprovider.InstanceNotFound {
+		ri.recordInstanceNotFound(node)
+		return false, nil
+	}
 	if err != nil {
-		return []v1.NodeAddress{}, err
+		return false, err
 	}
 
-	klog.V(4).Infof("NodeAddressesByProviderID(%v) => %v", providerID, addresses)
-	return addresses, nil
-}
-
-// InstanceExists returns true if the instance for the given node exists.
-func (i *Instances) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
-	return i.InstanceExistsByProviderID(ctx, node.Spec.ProviderID)
+	return true, nil
 }
 
 // InstanceExistsByProviderID returns true if the instance with the given provider id still exists.
 // If false is returned with no error, the instance will be immediately deleted by the cloud controller manager.
+// The node of the providerID is looked up in its region like by InstanceExists.
 func (i *Instances) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
-	instanceID, err := instanceIDFromProviderID(providerID)
-	if err != nil {
-		return false, err
-	}
+	return i.InstanceExists(ctx, i.nodeByProviderID(providerID))
+}
 
-	mc := metrics.NewMetricContext("server", "get")
-	_, err = servers.Get(i.compute, instanceID).Extract()
-	if mc.ObserveRequest(err) != nil {
-		if errors.IsNotFound(err) {
-			return false, nil
-		}
-		return false, err
+// recordInstanceNotFound reports that the server of the node doesn't exist
+// anymore, which makes the node lifecycle controller delete the node.
+func (i *Instances) recordInstanceNotFound(node *v1.Node) {
+	lookup := fmt.Sprintf("providerID %q", node.Spec.ProviderID)
+	if node.Spec.ProviderID == "" {
+		lookup = fmt.Sprintf("name %q", node.Name)
 	}
+	message := fmt.Sprintf("Server of %s not found in region %s, the node will be deleted", lookup, i.region)
 
-	return true, nil
+	// The nodes missing from the node informer cache only have a providerID
+	if node.Name == "" {
+		klog.Warningf("%s: %s", EventReasonInstanceNotFound, message)
+		return
+	}
+	klog.Warningf("%s: node %s: %s", EventReasonInstanceNotFound, node.Name, message)
+	if i.eventRecorder != nil {
+		i.eventRecorder.Event(node, v1.EventTypeWarning, EventReasonInstanceNotFound, message)
+	}
 }
 
 // InstanceShutdown returns true if the instances is in safe state to detach volumes.
-// It is the only state, where volumes can be detached immediately.
+// It is the only state, where volumes can be detached immediately. The nodes are
+// never reported as shut down in dry-run mode, so that they aren't tainted.
 func (i *Instances) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
-	return i.InstanceShutdownByProviderID(ctx, node.Spec.ProviderID)
-}
+	if !i.isManaged(node) {
+		return false, nil
+	}
 
-// InstanceShutdownByProviderID returns true if the instances is in safe state to detach volumes.
-// It is the only state, where volumes can be detached immediately.
-func (i *Instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
-	instanceID, err := instanceIDFromProviderID(providerID)
+	ri, err := i.regionInstances(node)
 	if err != nil {
 		return false, err
 	}
 
-	mc := metrics.NewMetricContext("server", "get")
-	server, err := servers.Get(i.compute, instanceID).Extract()
-	if mc.ObserveRequest(err) != nil {
+	server, err := ri.getInstance(ctx, node)
+	if err != nil {
 		return false, err
 	}
 
-	// SHUTOFF is the only state where we can detach volumes immediately
-	if server.Status == instanceShutoff {
-		return true, nil
+	shutdown := ri.isShutdown(server.Status)
+	if shutdown && ri.dryRun {
+		klog.V(2).InfoS("Dry run: node would be tainted as shut down", "node", klog.KObj(node), "status", server.Status)
+		return false, nil
+	}
+	return shutdown, nil
+}
+
+// InstanceShutdownByProviderID returns true if the instances is in safe state to detach volumes.
+// It is the only state, where volumes can be detached immediately.
+func (i *Instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
+	return i.InstanceShutdown(ctx, i.nodeByProviderID(providerID))
+}
+
+// isShutdown returns true if the given server status is a shut down state.
+// SHUTOFF is the only state where we can detach volumes immediately, PAUSED
+// and SUSPENDED are also considered shut down when shutdown-suspended is set.
+func (i *Instances) isShutdown(status string) bool {
+	switch status {
+	case instanceShutoff:
+		return true
+	case instancePaused, instanceSuspended:
+		return i.instancesOpts.ShutdownSuspended
 	}
-	return false, nil
+	return false
 }
 
-// InstanceMetadata returns metadata of the specified instance.
+// InstanceMetadata returns metadata of the specified instance. The lookups of
+// the nodes failing with a transient error back off, returning the last error.
 func (i *Instances) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
-	instanceID, err := instanceIDFromProviderID(node.Spec.ProviderID)
-	if err != nil {
+	if !i.isManaged(node) {
+		return nil, ErrNodeNotManaged
+	}
+	// The nodes missing from the node informer cache have no name to track
+	// their lookups by
+	if node.Name == "" {
+		return i.instanceMetadata(ctx, node)
+	}
+	if err := i.lookupBackoff.check(node.Name); err != nil {
 		return nil, err
 	}
+	if md := i.metadataSyncs.get(node); md != nil {
+		return md, nil
+	}
+
+	md, err := i.instanceMetadata(ctx, node)
+	i.lookupBackoff.observe(node.Name, err)
+	i.metadataSyncs.observe(node, md, err)
+	return md, err
+}
 
-	srv, err := servers.Get(i.compute, instanceID).Extract()
+func (i *Instances) instanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
+	ri, err := i.regionInstances(node)
 	if err != nil {
 		return nil, err
 	}
 
-	instanceType, err := srvInstanceType(i.compute, srv)
+	srv, err := ri.getInstance(ctx, node)
 	if err != nil {
 		return nil, err
 	}
 
-	interfaces, err := getAttachedInterfacesByID(i.compute, srv.ID)
+	instanceType, err := ri.srvInstanceType(ctx, srv)
 	if err != nil {
 		return nil, err
 	}
-	addresses, err := nodeAddresses(srv, interfaces, i.networkingOpts)
+
+	compute, cancel := ri.computeClient(ctx)
+	defer cancel()
+
+	addresses, err := ri.nodeAddresses(compute, srv)
 	if err != nil {
 		return nil, err
 	}
 
-	return &cloudprovider.InstanceMetadata{
-		ProviderID:    node.Spec.ProviderID,
+	labels := ri.nodeLabels(srv)
+	if ri.instancesOpts.ComputeHostLabel != "" {
+		host, err := ri.computeHost(compute, srv)
+		if err != nil {
+			klog.Warningf("Failed to get the compute host of node %s: %v", node.Name, err)
+		} else {
+			labels[LabelComputeHost] = sanitizeLabel(host)
+		}
+	}
+	if ri.instancesOpts.ServerGroupLabel {
+		group, err := ri.serverGroup(ctx, srv)
+		if err != nil {
+			klog.Warningf("Failed to get the server group of node %s: %v", node.Name, err)
+		} else {
+			labels[LabelServerGroup] = sanitizeLabel(group)
+		}
+	}
+	if ri.instancesOpts.FlavorLabels {
+		flavorLabels, err := ri.flavorLabels(ctx, srv)
+		if err != nil {
+			klog.Warningf("Failed to get the flavor of node %s: %v", node.Name, err)
+		}
+		for key, value := range flavorLabels {
+			labels[key] = value
+		}
+	}
+
+	if err := ri.updateNodeLabels(ctx, node, labels); err != nil {
+		klog.Warningf("Failed to update labels of node %s: %v", node.Name, err)
+	}
+
+	// The providerID of a node can't be changed once set, nodes keep
+	// their providerID even if its format is not the configured one.
+	providerID := node.Spec.ProviderID
+	if providerID == "" {
+		providerID = ri.makeInstanceID(srv)
+	}
+
+	md := &cloudprovider.InstanceMetadata{
+		ProviderID:    providerID,
 		InstanceType:  instanceType,
 		NodeAddresses: addresses,
-	}, nil
+	}
+	if ri.dryRun {
+		klog.V(2).InfoS("Dry run: node metadata would be set", "node", klog.KObj(node), "providerID", md.ProviderID, "instanceType", md.InstanceType, "addresses", md.NodeAddresses)
+		return nil, ErrDryRun
+	}
+
+	return md, nil
 }
 
-// InstanceID returns the kubelet's cloud provider ID.
-func (os *OpenStack) InstanceID() (string, error) {
-	if len(os.localInstanceID) == 0 {
-		id, err := readInstanceID(os.metadataOpts.SearchOrder)
-		if err != nil {
-			return "", err
-		}
-		os.localInstanceID = id
-	}
-	return os.localInstanceID, nil
+// portAddresses are the addresses of the Neutron ports of a server adjusting
+// the addresses of its attached interfaces
+type portAddresses struct {
+	// excluded are the fixed IPs of the ports owned by the excluded device owners
+	excluded sets.String
+	// pairs are the IPv6 allowed address pairs within allowed-address-pair-cidr
+	pairs []string
+	// dnsNames are the DNS names of the ports with dns-node-addresses
+	dnsNames []string
+	// bindingHosts are the compute hosts the ports are bound to by port ID,
+	// the ports whose binding isn't readable by the user are omitted
+	bindingHosts map[string]string
 }
 
-// InstanceID returns the cloud provider ID of the specified instance.
-func (i *Instances) InstanceID(ctx context.Context, name types.NodeName) (string, error) {
-	srv, err := getServerByName(i.compute, name)
+// serverPort is a Neutron port with its DNS names, which are empty when the
+// dns-integration extension is not enabled, and its binding, which only the
+// admins can read by default
+type serverPort struct {
+	neutronports.Port
+	dns.PortDNSExt
+	portsbinding.PortsBindingExt
+}
+
+// nodeAddresses returns the addresses of the server, without the fixed IPs of
+// its ports owned by the excluded device owners and with the allowed address
+// pairs of its ports within allowed-address-pair-cidr.
+func (i *Instances) nodeAddresses(compute *gophercloud.ServiceClient, srv *servers.Server) ([]v1.NodeAddress, error) {
+	interfaces, portAddrs, err := i.getAttachedInterfaces(compute, srv.ID)
 	if err != nil {
-		if err == ErrNotFound {
-			return "", cloudprovider.InstanceNotFound
+		return nil, err
+	}
+
+	addresses, err := nodeAddresses(srv, interfaces, i.networkingOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range portAddrs.excluded.List() {
+		klog.V(5).InfoS("Node address ignored due to the 'exclude-device-owner' option", "node", srv.Name, "address", ip)
+		RemoveFromNodeAddresses(&addresses, v1.NodeAddress{Address: ip})
+	}
+	if !i.networkingOpts.IPv6SupportDisabled {
+		for _, ip := range portAddrs.pairs {
+			AddToNodeAddresses(&addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
 		}
-		return "", err
 	}
-	// In the future it is possible to also return an endpoint as:
-	// <endpoint>/<instanceid>
-	return "/" + srv.ID, nil
+	for _, name := range portAddrs.dnsNames {
+		AddToNodeAddresses(&addresses, v1.NodeAddress{Type: v1.NodeInternalDNS, Address: name})
+	}
+	if i.networkingOpts.CheckPortBindings {
+		i.checkPortBindings(srv, portAddrs.bindingHosts)
+	}
+
+	addresses = translateAddresses(srv.Name, addresses, i.networkingOpts)
+	sortNodeAddresses(addresses, i.networkingOpts.IPVersionPreference)
+	return addresses, nil
 }
 
-// InstanceTypeByProviderID returns the cloudprovider instance type of the node with the specified unique providerID
-// This method will not be called from the node that is requesting this ID. i.e. metadata service
-// and other local methods cannot be used here
-func (i *Instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
-	instanceID, err := instanceIDFromProviderID(providerID)
+// getAttachedInterfaces returns the interfaces attached to the server, including
+// the subports of the trunks whose parent port is attached to the server when
+// the Neutron trunk extension is available. When Neutron is available, the
+// interfaces of the ports owned by the excluded device owners are left out and
+// the addresses of the ports of the server are returned. When it isn't, no
+// interface is returned with nova-addresses-fallback.
+func (i *Instances) getAttachedInterfaces(compute *gophercloud.ServiceClient, serverID string) ([]attachinterfaces.Interface, portAddresses, error) {
+	portAddrs := portAddresses{excluded: sets.NewString(), bindingHosts: map[string]string{}}
 
-	if err != nil {
-		return "", err
+	// The interfaces are Neutron ports, Nova can't list them without Neutron
+	if i.network == nil && i.networkingOpts.NovaAddressesFallback {
+		klog.V(5).InfoS("Server interfaces ignored, Neutron is not available", "server", serverID)
+		return nil, portAddrs, nil
 	}
 
-	mc := metrics.NewMetricContext("server", "get")
-	server, err := servers.Get(i.compute, instanceID).Extract()
+	interfaces, err := getAttachedInterfacesByID(compute, serverID)
+	if err != nil || i.network == nil {
+		return interfaces, portAddrs, err
+	}
 
-	if mc.ObserveRequest(err) != nil {
-		return "", err
+	// Share the context and timeout of the compute requests
+	network := *i.network
+	network.ProviderClient = compute.ProviderClient
+
+	ports, err := getServerPorts(&network, serverID)
+	if err != nil {
+		return nil, portAddrs, err
+	}
+	owners := sets.NewString(i.networkingOpts.excludedDeviceOwners()...)
+	excludedPorts := sets.NewString()
+	for _, port := range ports {
+		if owners.Has(port.DeviceOwner) {
+			excludedPorts.Insert(port.ID)
+			for _, ip := range port.FixedIPs {
+				portAddrs.excluded.Insert(ip.IPAddress)
+			}
+			continue
+		}
+		portAddrs.pairs = append(portAddrs.pairs, allowedAddressPairIPs(port.Port, i.networkingOpts.AllowedAddressPairCIDR)...)
+		if port.HostID != "" {
+			portAddrs.bindingHosts[port.ID] = port.HostID
+		}
+		if i.networkingOpts.DNSNodeAddresses {
+			portAddrs.dnsNames = append(portAddrs.dnsNames, portDNSNames(port)...)
+		}
 	}
 
-	return srvInstanceType(i.compute, server)
-}
+	included := interfaces[:0]
+	for _, iface := range interfaces {
+		if excludedPorts.Has(iface.PortID) {
+			klog.V(5).InfoS("Server interface ignored due to the 'exclude-device-owner' option", "server", serverID, "port", iface.PortID)
+			continue
+		}
+		included = append(included, iface)
+	}
 
-// InstanceType returns the type of the specified instance.
-func (i *Instances) InstanceType(ctx context.Context, name types.NodeName) (string, error) {
-	srv, err := getServerByName(i.compute, name)
+	if !i.trunks {
+		return included, portAddrs, nil
+	}
 
+	subports, err := getTrunkSubportInterfaces(&network, included)
 	if err != nil {
-		return "", err
+		return nil, portAddrs, err
 	}
 
-	return srvInstanceType(i.compute, &srv.Server)
+	return append(included, subports...), portAddrs, nil
 }
 
-func srvInstanceType(client *gophercloud.ServiceClient, srv *servers.Server) (string, error) {
-	keys := []string{"original_name", "id"}
-	for _, key := range keys {
-		val, found := srv.Flavor[key]
-		if found {
-			flavor, ok := val.(string)
+// getServerPorts gets all the ports attached to a server with their DNS names
+// and their binding
+func getServerPorts(network *gophercloud.ServiceClient, serverID string) ([]serverPort, error) {
+	mc := metrics.NewMetricContext("port", "list")
+	allPages, err := neutronports.List(network, neutronports.ListOpts{DeviceID: serverID}).AllPages()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	var ports []serverPort
+	if err := neutronports.ExtractPortsInto(allPages, &ports); err != nil {
+		return nil, err
+	}
+	return ports, nil
+}
+
+// checkPortBindings warns about the ports of the server bound to another
+// compute host than the server, e.g. after a failed migration. Nova only
+// returns the obfuscated ID of the host of the servers to the users, so the
+// binding hosts are compared by the ID Nova computes for them.
+func (i *Instances) checkPortBindings(srv *servers.Server, bindingHosts map[string]string) {
+	if srv.HostID == "" || i.projectID == "" {
+		return
+	}
+	for _, portID := range sets.StringKeySet(bindingHosts).List() {
+		host := bindingHosts[portID]
+		if novaHostID(i.projectID, host) != srv.HostID {
+			klog.Warningf("PortBindingMismatch: port %s of server %s(%s) is bound to host %s, which is not the host of the server", portID, srv.Name, srv.ID, host)
+		}
+	}
+}
+
+// novaHostID returns the ID of the compute host as Nova reports it in the
+// servers of the project, the SHA-224 of the project ID and the host name.
+func novaHostID(projectID, host string) string {
+	return fmt.Sprintf("%x", sha256.Sum224([]byte(projectID+host)))
+}
+
+// portDNSNames returns the valid DNS names of the port, the FQDNs of its DNS
+// assignments or else its DNS name. The empty names are omitted.
+func portDNSNames(port serverPort) []string {
+	var candidates []string
+	for _, assignment := range port.DNSAssignment {
+		candidates = append(candidates, assignment["fqdn"])
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, port.DNSName)
+	}
+
+	var names []string
+	for _, candidate := range candidates {
+		name, ok := sanitizeDNSName(candidate)
+		if !ok {
+			if candidate != "" {
+				klog.V(5).InfoS("Port DNS name ignored, it is not a valid DNS name", "port", port.ID, "dnsName", candidate)
+			}
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// allowedAddressPairIPs returns the IPv6 addresses of the allowed address
+// pairs of the port within the given CIDRs. The pairs holding a prefix rather
+// than a single address and the link-local addresses are ignored.
+func allowedAddressPairIPs(port neutronports.Port, cidrs []string) []string {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		// the CIDRs are validated at startup
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+
+	var ips []string
+	for _, pair := range port.AllowedAddressPairs {
+		address := pair.IPAddress
+		if ip, ipNet, err := net.ParseCIDR(address); err == nil {
+			if ones, bits := ipNet.Mask.Size(); ones != bits {
+				klog.V(5).InfoS("Port allowed address pair ignored, it is not a single address", "port", port.ID, "address", address)
+				continue
+			}
+			address = ip.String()
+		}
+
+		ip := net.ParseIP(address)
+		if ip == nil || ip.To4() != nil || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		for _, ipNet := range nets {
+			if ipNet.Contains(ip) {
+				ips = append(ips, ip.String())
+				break
+			}
+		}
+	}
+
+	return ips
+}
+
+// computeClient returns a copy of the compute client whose requests are bound
+// to the given context and limited to the configured API timeout. The requests
+// are tracked as in-flight until the returned cancel function is called once
+// they're done, and are canceled once the shutdown grace period is over. The
+// copy re-authenticates the shared provider client, so that the refreshed
+// token is used by all the service clients.
+func (i *Instances) computeClient(ctx context.Context) (*gophercloud.ServiceClient, context.CancelFunc) {
+	timeout := i.instancesOpts.APITimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	ctx, done := shutdown.track(ctx)
+	ctx, cancelTimeout := context.WithTimeout(ctx, timeout)
+	cancel := func() {
+		cancelTimeout()
+		done()
+	}
+
+	shared := i.compute.ProviderClient
+	provider := *shared
+	provider.Context = ctx
+	if shared.ReauthFunc != nil {
+		provider.ReauthFunc = func() error {
+			// Another request may have refreshed the token in the meantime
+			if shared.Token() == provider.Token() {
+				if err := shared.ReauthFunc(); err != nil {
+					return err
+				}
+			}
+			provider.CopyTokenFrom(shared)
+			return nil
+		}
+	}
+	client := *i.compute
+	client.ProviderClient = &provider
+	if len(i.instancesOpts.TagLabels) > 0 {
+		client.Microversion = tagsMicroversion
+	}
+
+	return &client, cancel
+}
+
+// retry calls fn until it succeeds or fails with an error which is not
+// transient, at most api-max-retries more times. The delay between the
+// attempts starts from api-retry-delay and doubles after each attempt.
+func (i *Instances) retry(ctx context.Context, resource string, request string, fn func() error) error {
+	delay := i.instancesOpts.APIRetryDelay.Duration
+
+	err := fn()
+	for attempt := uint(0); attempt < i.instancesOpts.APIMaxRetries && errors.IsTransient(err); attempt++ {
+		klog.V(4).InfoS("Retrying after a transient error", "resource", resource, "request", request, "delay", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		metrics.NewMetricContext(resource, request).ObserveRetry()
+		err = fn()
+		delay *= 2
+	}
+
+	return err
+}
+
+// regionInstances returns the instances of the region of the node providerID,
+// the region of the instances or one of the additional regions.
+func (i *Instances) regionInstances(node *v1.Node) (*Instances, error) {
+	if node.Spec.ProviderID == "" {
+		return i, nil
+	}
+
+	_, region, err := parseProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" || region == i.region {
+		return i, nil
+	}
+
+	clients, ok := i.regions[region]
+	if !ok {
+		return nil, &InstanceError{
+			Class: ErrRegionMismatch,
+			Node:  node.Name,
+			Err:   fmt.Errorf("ProviderID \"%s\" didn't match supported region \"%s\"", node.Spec.ProviderID, i.region),
+		}
+	}
+
+	ri := *i
+	ri.compute = clients.compute
+	ri.network = clients.network
+	ri.trunks = clients.trunks
+	ri.region = region
+	ri.regions = nil
+	// the warmup only covers the servers of the region of the instances
+	ri.serverWarmup = nil
+	return &ri, nil
+}
+
+// getInstance returns the server of the given node. The server is looked up by the
+// node providerID or, when the node has no providerID yet, by the node name.
+// Failures of a known class are returned as an InstanceError.
+func (i *Instances) getInstance(ctx context.Context, node *v1.Node) (*servers.Server, error) {
+	server, err := i.lookupInstance(ctx, node)
+	if err == nil && isDeleted(server.Status) {
+		klog.V(4).InfoS("Server of node is deleted, considering it not found", "server", server.ID, "node", klog.KObj(node), "status", server.Status)
+		metrics.ForgetInstance(server.ID)
+		return nil, cloudprovider.InstanceNotFound
+	}
+	return server, wrapInstanceError(node.Name, err)
+}
+
+// isDeleted returns whether the server status is one of a deleted server.
+func isDeleted(status string) bool {
+	return status == instanceDeleted || status == instanceSoftDeleted
+}
+
+func (i *Instances) lookupInstance(ctx context.Context, node *v1.Node) (*servers.Server, error) {
+	if node.Spec.ProviderID == "" {
+		return i.getInstanceByName(ctx, node.Name)
+	}
+
+	instanceID, region, err := parseProviderID(node.Spec.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	if region != "" && region != i.region {
+		return nil, &InstanceError{
+			Class: ErrRegionMismatch,
+			Node:  node.Name,
+			Err:   fmt.Errorf("ProviderID \"%s\" didn't match supported region \"%s\"", node.Spec.ProviderID, i.region),
+		}
+	}
+
+	if server := i.warmupServer(ctx, instanceID); server != nil {
+		metrics.ObserveInstanceStatus(server.ID, server.Status)
+		return server, nil
+	}
+
+	compute, cancel := i.computeClient(ctx)
+	defer cancel()
+
+	var server *servers.Server
+	err = i.retry(ctx, "server", "get", func() error {
+		var err error
+		mc := metrics.NewMetricContext("server", "get")
+		server, err = servers.Get(compute, instanceID).Extract()
+		return mc.ObserveRequest(err)
+	})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			metrics.ForgetInstance(instanceID)
+			return nil, cloudprovider.InstanceNotFound
+		}
+		return nil, err
+	}
+
+	metrics.ObserveInstanceStatus(server.ID, server.Status)
+	return server, nil
+}
+
+// warmupServer returns the server with the given ID from the warmup cache, or
+// nil when it is not cached. The first call lists all the servers of the
+// project to fill the cache, the concurrent calls wait for the list.
+func (i *Instances) warmupServer(ctx context.Context, instanceID string) *servers.Server {
+	if i.serverWarmup == nil {
+		return nil
+	}
+
+	i.serverWarmup.once.Do(func() {
+		compute, cancel := i.computeClient(ctx)
+		defer cancel()
+
+		mc := metrics.NewMetricContext("server", "list")
+		allPages, err := servers.List(compute, servers.ListOpts{}).AllPages()
+		if mc.ObserveRequest(err) != nil {
+			klog.Warningf("Failed to list the servers to warm up the instances cache: %v", err)
+			return
+		}
+		allServers, err := servers.ExtractServers(allPages)
+		if err != nil {
+			klog.Warningf("Failed to list the servers to warm up the instances cache: %v", err)
+			return
+		}
+
+		for idx := range allServers {
+			i.serverWarmup.cache.Add(allServers[idx].ID, &allServers[idx], i.instancesOpts.WarmupCacheTTL.Duration)
+		}
+		klog.V(4).InfoS("Warmed up the instances cache", "servers", len(allServers))
+	})
+
+	s, ok := i.serverWarmup.cache.Get(instanceID)
+	if !ok {
+		return nil
+	}
+	// the cached servers are shared, return a copy
+	server := *s.(*servers.Server)
+	return &server
+}
+
+// getInstanceByName returns the server named after the node, or, when
+// node-name-metadata-key is configured, the server whose metadata key holds
+// the node name.
+func (i *Instances) getInstanceByName(ctx context.Context, name string) (*servers.Server, error) {
+	if i.instancesOpts.DisableNameLookup {
+		return nil, ErrNameLookupDisabled
+	}
+
+	var server *servers.Server
+	var err error
+
+	compute, cancel := i.computeClient(ctx)
+	defer cancel()
+
+	err = i.retry(ctx, "server", "list", func() error {
+		if key := i.instancesOpts.NodeNameMetadataKey; key != "" {
+			server, err = getServerByMetadata(compute, key, name)
+			return err
+		}
+
+		var srv *ServerAttributesExt
+		srv, err = getServerByName(compute, types.NodeName(name))
+		if err == nil {
+			server = &srv.Server
+		} else if err == ErrMultipleResults {
+			server, err = i.getProjectInstanceByName(compute, name)
+		}
+		return err
+	})
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, cloudprovider.InstanceNotFound
+		}
+		return nil, err
+	}
+
+	metrics.ObserveInstanceStatus(server.ID, server.Status)
+	return server, nil
+}
+
+// getProjectInstanceByName returns the server named after the node in the
+// project of the cloud provider, so that servers of the same name in other
+// projects visible to the cloud provider don't make the lookup ambiguous.
+func (i *Instances) getProjectInstanceByName(client *gophercloud.ServiceClient, name string) (*servers.Server, error) {
+	if i.projectID == "" {
+		return nil, ErrMultipleResults
+	}
+
+	serverList, err := getServersByName(client, types.NodeName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var server *servers.Server
+	for idx := range serverList {
+		if serverList[idx].TenantID != i.projectID {
+			continue
+		}
+		if server != nil {
+			return nil, ErrMultipleResults
+		}
+		server = &serverList[idx]
+	}
+	if server == nil {
+		return nil, ErrNotFound
+	}
+
+	return server, nil
+}
+
+// nodeLabels returns the node labels managed by OpenStack for the given server.
+// A label mapped to an empty value must be removed from the node.
+func (i *Instances) nodeLabels(srv *servers.Server) map[string]string {
+	labels := map[string]string{}
+
+	if i.instancesOpts.ExposeFaultReason {
+		labels[LabelFaultReason] = ""
+		if srv.Status == instanceError {
+			labels[LabelFaultReason] = sanitizeLabel(srv.Fault.Message)
+		}
+	}
+
+	if len(i.instancesOpts.TagLabels) > 0 {
+		tags := sets.NewString()
+		if srv.Tags != nil {
+			tags.Insert(*srv.Tags...)
+		}
+		for _, tag := range i.instancesOpts.TagLabels {
+			name := sanitizeLabel(tag)
+			if name == "" {
+				continue
+			}
+			labels[LabelTagPrefix+name] = ""
+			if tags.Has(tag) {
+				labels[LabelTagPrefix+name] = "true"
+			}
+		}
+	}
+
+	for _, key := range i.instancesOpts.MetadataLabels {
+		name := sanitizeLabel(key)
+		if name == "" {
+			continue
+		}
+		// A value without valid characters removes the label like a
+		// missing key
+		labels[LabelMetadataPrefix+name] = sanitizeLabel(srv.Metadata[key])
+	}
+
+	return labels
+}
+
+// serverGroup returns the name of the Nova server group of the server, or an
+// empty name when the server is in no group. The servers only missing from the
+// previous list of the groups are reported in no group until the next list.
+func (i *Instances) serverGroup(ctx context.Context, srv *servers.Server) (string, error) {
+	m := i.serverGroups
+	if m == nil {
+		return "", nil
+	}
+	key := i.region + "/" + srv.ID
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if group, ok := m.groups[key]; ok {
+		return group, nil
+	}
+	if listed, ok := m.listed[i.region]; ok && time.Since(listed) < serverGroupRelistInterval {
+		return "", nil
+	}
+
+	compute, cancel := i.computeClient(ctx)
+	defer cancel()
+
+	mc := metrics.NewMetricContext("server_group", "list")
+	allPages, err := servergroups.List(compute).AllPages()
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+	groups, err := servergroups.ExtractServerGroups(allPages)
+	if err != nil {
+		return "", err
+	}
+
+	// The servers of the deleted groups are in no group anymore
+	for k := range m.groups {
+		if strings.HasPrefix(k, i.region+"/") {
+			delete(m.groups, k)
+		}
+	}
+	for _, group := range groups {
+		name := group.Name
+		if name == "" {
+			name = group.ID
+		}
+		for _, member := range group.Members {
+			m.groups[i.region+"/"+member] = name
+		}
+	}
+	m.listed[i.region] = time.Now()
+
+	return m.groups[key], nil
+}
+
+// computeHost returns the compute host of the server according to
+// compute-host-label. The host name is empty when the Nova policy doesn't
+// allow reading the extended server attributes.
+func (i *Instances) computeHost(compute *gophercloud.ServiceClient, srv *servers.Server) (string, error) {
+	if i.instancesOpts.ComputeHostLabel != computeHostLabelHost {
+		return srv.HostID, nil
+	}
+
+	var attrs extendedserverattributes.ServerAttributesExt
+	mc := metrics.NewMetricContext("server", "get")
+	err := servers.Get(compute, srv.ID).ExtractInto(&attrs)
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+	return attrs.Host, nil
+}
+
+// updateNodeLabels patches the labels of the node when they differ from the given ones.
+// Labels mapped to an empty value are removed from the node.
+func (i *Instances) updateNodeLabels(ctx context.Context, node *v1.Node, labels map[string]string) error {
+	if (i.kclient == nil && !i.dryRun) || node.Name == "" {
+		return nil
+	}
+
+	patch := map[string]interface{}{}
+	for key, value := range labels {
+		current, found := node.Labels[key]
+		if value == "" && found {
+			patch[key] = nil
+		} else if value != "" && current != value {
+			patch[key] = value
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	if i.dryRun {
+		klog.V(2).InfoS("Dry run: labels of node would be patched", "node", klog.KObj(node), "patch", patch)
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": patch,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = i.kclient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+var labelInvalidChars = regexp.MustCompile(`[^-A-Za-z0-9_.]+`)
+
+// sanitizeLabel converts the input into a valid label value. Invalid characters
+// are replaced with '-', the value is truncated to 63 characters and trimmed so
+// that it begins and ends with an alphanumeric character.
+func sanitizeLabel(input string) string {
+	value := labelInvalidChars.ReplaceAllString(input, "-")
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(value, "-_.")
+}
+
+// InstanceID returns the kubelet's cloud provider ID.
+func (os *OpenStack) InstanceID() (string, error) {
+	if len(os.localInstanceID) == 0 {
+		id, err := readInstanceID(os.metadataOpts.SearchOrder)
+		if err != nil {
+			return "", err
+		}
+		os.localInstanceID = id
+	}
+	return os.localInstanceID, nil
+}
+
+// InstanceID returns the cloud provider ID of the specified instance.
+// The controller manager prefixes it with the provider name to make the
+// providerID of the node.
+func (i *Instances) InstanceID(ctx context.Context, name types.NodeName) (string, error) {
+	node, err := i.getNodeByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	md, err := i.InstanceMetadata(ctx, node)
+	if err != nil {
+		return "", err
+	}
+
+	if idx := strings.Index(md.ProviderID, "://"); idx >= 0 {
+		return md.ProviderID[idx+len("://"):], nil
+	}
+	return md.ProviderID, nil
+}
+
+// InstanceTypeByProviderID returns the cloudprovider instance type of the node with the specified unique providerID
+// This method will not be called from the node that is requesting this ID. i.e. metadata service
+// and other local methods cannot be used here
+func (i *Instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
+	md, err := i.InstanceMetadata(ctx, i.nodeByProviderID(providerID))
+	if err != nil {
+		return "", err
+	}
+	return md.InstanceType, nil
+}
+
+// InstanceType returns the type of the specified instance.
+func (i *Instances) InstanceType(ctx context.Context, name types.NodeName) (string, error) {
+	node, err := i.getNodeByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	md, err := i.InstanceMetadata(ctx, node)
+	if err != nil {
+		return "", err
+	}
+	return md.InstanceType, nil
+}
+
+// srvInstanceType returns the instance type of the server, which is the value of
+// the flavor-class-extra-spec flavor extra spec when configured and set, or the
+// flavor name.
+func (i *Instances) srvInstanceType(ctx context.Context, srv *servers.Server) (string, error) {
+	name, err := i.srvFlavorName(ctx, srv)
+	if err != nil || i.instancesOpts.FlavorClassExtraSpec == "" {
+		return name, err
+	}
+
+	if class := sanitizeLabel(i.srvFlavorClass(ctx, srv)); class != "" {
+		return class, nil
+	}
+	return name, nil
+}
+
+// srvFlavorClass returns the value of the flavor-class-extra-spec extra spec of the
+// server flavor. The extra specs are embedded in the server since the compute API
+// microversion 2.47, they are otherwise fetched by flavor ID.
+func (i *Instances) srvFlavorClass(ctx context.Context, srv *servers.Server) string {
+	key := i.instancesOpts.FlavorClassExtraSpec
+
+	if specs, ok := srv.Flavor["extra_specs"].(map[string]interface{}); ok {
+		class, _ := specs[key].(string)
+		return class
+	}
+
+	flavorID, ok := srv.Flavor["id"].(string)
+	if !ok {
+		return ""
+	}
+	specs, err := i.getFlavorExtraSpecs(ctx, flavorID)
+	if err != nil {
+		klog.Warningf("Failed to get the extra specs of flavor %s: %v", flavorID, err)
+		return ""
+	}
+	return specs[key]
+}
+
+func (i *Instances) srvFlavorName(ctx context.Context, srv *servers.Server) (string, error) {
+	keys := []string{"original_name", "id"}
+	for _, key := range keys {
+		val, found := srv.Flavor[key]
+		if found {
+			flavor, ok := val.(string)
 			if ok {
 				if key == "id" {
-					mc := metrics.NewMetricContext("flavor", "get")
-					f, err := flavors.Get(client, flavor).Extract()
-					if mc.ObserveRequest(err) == nil {
+					f, err := i.getFlavor(ctx, flavor)
+					if err == nil {
 						return f.Name, nil
 					}
 				}
@@ -297,24 +1575,196 @@ func srvInstanceType(client *gophercloud.ServiceClient, srv *servers.Server) (st
 	return "", fmt.Errorf("flavor name/id not found")
 }
 
-// If Instances.InstanceID or cloudprovider.GetInstanceProviderID is changed, the regexp should be changed too.
-var providerIDRegexp = regexp.MustCompile(`^` + ProviderName + `:///([^/]+)$`)
+// flavorLabels returns the node labels of the vCPUs, RAM and disk of the server
+// flavor. The flavor details are embedded in the server since the compute API
+// microversion 2.47, the flavor is otherwise fetched by ID like for the instance
+// type, from the flavor cache when it is enabled.
+func (i *Instances) flavorLabels(ctx context.Context, srv *servers.Server) (map[string]string, error) {
+	var vcpus, ram, disk int
+	if v, ok := srv.Flavor["vcpus"].(float64); ok {
+		r, _ := srv.Flavor["ram"].(float64)
+		d, _ := srv.Flavor["disk"].(float64)
+		vcpus, ram, disk = int(v), int(r), int(d)
+	} else {
+		flavorID, ok := srv.Flavor["id"].(string)
+		if !ok {
+			return nil, fmt.Errorf("flavor details and id not found")
+		}
+		f, err := i.getFlavor(ctx, flavorID)
+		if err != nil {
+			return nil, err
+		}
+		vcpus, ram, disk = f.VCPUs, f.RAM, f.Disk
+	}
+
+	return map[string]string{
+		LabelFlavorVCPUs: strconv.Itoa(vcpus),
+		LabelFlavorRAM:   strconv.Itoa(ram),
+		LabelFlavorDisk:  strconv.Itoa(disk),
+	}, nil
+}
+
+// getFlavor returns the flavor with the given ID. Flavors rarely change, so they
+// are served from the flavor cache when it is enabled.
+func (i *Instances) getFlavor(ctx context.Context, flavorID string) (*flavors.Flavor, error) {
+	if i.flavorCache != nil {
+		if f, ok := i.flavorCache.Get(i.region + "/" + flavorID); ok {
+			return f.(*flavors.Flavor), nil
+		}
+	}
+
+	compute, cancel := i.computeClient(ctx)
+	defer cancel()
+
+	mc := metrics.NewMetricContext("flavor", "get")
+	f, err := flavors.Get(compute, flavorID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	if i.flavorCache != nil {
+		i.flavorCache.Add(i.region+"/"+flavorID, f, i.instancesOpts.FlavorCacheTTL.Duration)
+	}
+	return f, nil
+}
+
+// getFlavorExtraSpecs returns the extra specs of the flavor with the given ID,
+// which are cached alongside the flavors when the flavor cache is enabled.
+func (i *Instances) getFlavorExtraSpecs(ctx context.Context, flavorID string) (map[string]string, error) {
+	cacheKey := i.region + "/" + flavorID + "/extra_specs"
+	if i.flavorCache != nil {
+		if specs, ok := i.flavorCache.Get(cacheKey); ok {
+			return specs.(map[string]string), nil
+		}
+	}
+
+	compute, cancel := i.computeClient(ctx)
+	defer cancel()
+
+	mc := metrics.NewMetricContext("flavor_extra_specs", "list")
+	specs, err := flavors.ListExtraSpecs(compute, flavorID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+
+	if i.flavorCache != nil {
+		i.flavorCache.Add(cacheKey, specs, i.instancesOpts.FlavorCacheTTL.Duration)
+	}
+	return specs, nil
+}
+
+// makeInstanceID returns the providerID of the given server, which includes
+// the region when the regional providerID format is enabled.
+func (i *Instances) makeInstanceID(srv *servers.Server) string {
+	return formatProviderID(providerIDScheme, i.region, srv.ID, i.regionProviderID)
+}
+
+// formatProviderID returns the providerID of the instance, in the regional
+// format when regional is set.
+func formatProviderID(scheme string, region string, instanceID string, regional bool) string {
+	if regional {
+		return fmt.Sprintf("%s://%s/%s", scheme, region, instanceID)
+	}
+	return fmt.Sprintf("%s:///%s", scheme, instanceID)
+}
+
+// LocalProviderID returns the providerID of the local instance, whose ID is
+// read from the config drive or the metadata service in the given search
+// order, without any OpenStack API request. The providerID has the regional
+// format when OS_CCM_REGIONAL is "true", and the scheme set by
+// OS_CCM_PROVIDER_ID_SCHEME. It lets the node bootstrap tooling set the
+// --provider-id flag of the kubelet.
+func LocalProviderID(searchOrder string, region string) (string, error) {
+	regional := os.Getenv(RegionalProviderIDEnv) == "true"
+	if regional && (region == "" || strings.Contains(region, "/")) {
+		return "", fmt.Errorf("invalid region %q for the regional providerID format enabled by %s", region, RegionalProviderIDEnv)
+	}
+
+	scheme := ProviderName
+	if idScheme := os.Getenv(ProviderIDSchemeEnv); idScheme != "" {
+		if !providerIDSchemeRegexp.MatchString(idScheme) {
+			return "", fmt.Errorf("invalid providerID scheme %q in %s", idScheme, ProviderIDSchemeEnv)
+		}
+		scheme = idScheme
+	}
+
+	if searchOrder == "" {
+		searchOrder = fmt.Sprintf("%s,%s", metadata.ConfigDriveID, metadata.MetadataID)
+	}
+	if err := checkMetadataSearchOrder(searchOrder); err != nil {
+		return "", err
+	}
+	md, err := metadata.Get(searchOrder)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the instance ID from the metadata: %v", err)
+	}
+
+	return formatProviderID(scheme, region, md.UUID, regional), nil
+}
+
+// RegionalProviderID converts a providerID of the given region into the
+// regional providerID format. It helps migrating the tooling relying on node
+// providerIDs, the providerID of an existing node itself can't be changed.
+func RegionalProviderID(providerID string, region string) (string, error) {
+	instanceID, providerRegion, err := parseProviderID(providerID)
+	if err != nil {
+		return "", err
+	}
+	if providerRegion != "" && providerRegion != region {
+		return "", fmt.Errorf("ProviderID \"%s\" didn't match region \"%s\"", providerID, region)
+	}
+	return formatProviderID(providerIDScheme, region, instanceID, true), nil
+}
+
+var (
+	// providerIDScheme is the scheme of the providerIDs made for the nodes,
+	// set from OS_CCM_PROVIDER_ID_SCHEME at startup. The controller manager
+	// prefixes the InstanceID of the nodes registered without providerID
+	// with the provider name instead.
+	providerIDScheme = ProviderName
+
+	// If Instances.InstanceID or cloudprovider.GetInstanceProviderID is changed, the regexp should be changed too.
+	providerIDRegexp = regexp.MustCompile(`^` + ProviderName + `://([^/]*)/([^/]+)$`)
+
+	providerIDSchemeRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*$`)
+)
+
+// setProviderIDScheme replaces the scheme of the providerIDs made for the
+// nodes. The providerIDs of both the given scheme and the "openstack" scheme
+// are parsed, so that the existing nodes keep working.
+func setProviderIDScheme(scheme string) error {
+	if !providerIDSchemeRegexp.MatchString(scheme) {
+		return fmt.Errorf("invalid providerID scheme %q in %s", scheme, ProviderIDSchemeEnv)
+	}
+	providerIDScheme = scheme
+	providerIDRegexp = regexp.MustCompile(`^(?:` + ProviderName + `|` + regexp.QuoteMeta(scheme) + `)://([^/]*)/([^/]+)$`)
+	return nil
+}
 
 // instanceIDFromProviderID splits a provider's id and return instanceID.
-// A providerID is build out of '${ProviderName}:///${instance-id}'which contains ':///'.
+// A providerID is build out of '${ProviderName}:///${instance-id}'which contains ':///',
+// or '${ProviderName}://${region}/${instance-id}' in the regional format, where
+// the scheme ${ProviderName} may be replaced by OS_CCM_PROVIDER_ID_SCHEME.
 // See cloudprovider.GetInstanceProviderID and Instances.InstanceID.
 func instanceIDFromProviderID(providerID string) (instanceID string, err error) {
+	instanceID, _, err = parseProviderID(providerID)
+	return instanceID, err
+}
+
+// parseProviderID splits a provider's id and returns the instanceID and the
+// region, which is empty for the non regional providerID format.
+func parseProviderID(providerID string) (instanceID string, region string, err error) {
 
 	// https://github.com/kubernetes/kubernetes/issues/85731
 	if providerID != "" && !strings.Contains(providerID, "://") {
-		providerID = ProviderName + "://" + providerID
+		providerID = providerIDScheme + "://" + providerID
 	}
 
 	matches := providerIDRegexp.FindStringSubmatch(providerID)
-	if len(matches) != 2 {
-		return "", fmt.Errorf("ProviderID \"%s\" didn't match expected format \"openstack:///InstanceID\"", providerID)
+	if len(matches) != 3 {
+		return "", "", fmt.Errorf("ProviderID \"%s\" didn't match expected format \"%s:///InstanceID\" or \"%s://Region/InstanceID\"", providerID, providerIDScheme, providerIDScheme)
 	}
-	return matches[1], nil
+	return matches[2], matches[1], nil
 }
 
 // AddToNodeAddresses appends the NodeAddresses to the passed-by-pointer slice,
@@ -0,0 +1,1554 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/dns"
+	neutronports "github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
+)
+
+const serverListResponse = `
+{
+	"servers": [
+		{
+			"id": "7b9cf879-7146-417c-abfd-cb4272f0c935",
+			"name": "server-1",
+			"status": "ACTIVE",
+			"metadata": {"k8s-node-name": "node-1"}
+		},
+		{
+			"id": "9e5476bd-a4ec-4653-93d6-72c93aa682ba",
+			"name": "node-1",
+			"status": "ACTIVE",
+			"metadata": {}
+		}
+	]
+}
+`
+
+func handleServerList(t *testing.T, response string) {
+	th.Mux.HandleFunc("/servers/detail", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, response)
+	})
+}
+
+func TestSanitizeLabel(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "nova", expected: "nova"},
+		{input: "No valid host was found. ", expected: "No-valid-host-was-found"},
+		{input: "--rack/1--", expected: "rack-1"},
+		{input: strings.Repeat("a", 70), expected: strings.Repeat("a", 63)},
+		{input: strings.Repeat("a", 62) + " b", expected: strings.Repeat("a", 62)},
+		{input: "", expected: ""},
+	}
+
+	for _, test := range testCases {
+		if value := sanitizeLabel(test.input); value != test.expected {
+			t.Errorf("sanitizeLabel(%q) = %q, expected %q", test.input, value, test.expected)
+		}
+	}
+}
+
+func TestNodeLabelsFaultReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     InstancesOpts
+		server   servers.Server
+		expected map[string]string
+	}{
+		{
+			name:     "disabled",
+			server:   servers.Server{Status: instanceError, Fault: servers.Fault{Message: "No valid host was found"}},
+			expected: map[string]string{},
+		},
+		{
+			name:     "error",
+			opts:     InstancesOpts{ExposeFaultReason: true},
+			server:   servers.Server{Status: instanceError, Fault: servers.Fault{Message: "No valid host was found"}},
+			expected: map[string]string{LabelFaultReason: "No-valid-host-was-found"},
+		},
+		{
+			name:     "active",
+			opts:     InstancesOpts{ExposeFaultReason: true},
+			server:   servers.Server{Status: "ACTIVE"},
+			expected: map[string]string{LabelFaultReason: ""},
+		},
+	}
+
+	for _, test := range testCases {
+		i := &Instances{instancesOpts: test.opts}
+		if labels := i.nodeLabels(&test.server); !reflect.DeepEqual(labels, test.expected) {
+			t.Errorf("%s: nodeLabels() = %v, expected %v", test.name, labels, test.expected)
+		}
+	}
+}
+
+func TestNodeLabelsTags(t *testing.T) {
+	tags := []string{"worker", "team:blue", "unlisted"}
+	testCases := []struct {
+		name     string
+		opts     InstancesOpts
+		server   servers.Server
+		expected map[string]string
+	}{
+		{
+			name:     "disabled",
+			server:   servers.Server{Tags: &tags},
+			expected: map[string]string{},
+		},
+		{
+			name:   "tagged",
+			opts:   InstancesOpts{TagLabels: []string{"worker", "team:blue", "gpu"}},
+			server: servers.Server{Tags: &tags},
+			expected: map[string]string{
+				LabelTagPrefix + "worker":    "true",
+				LabelTagPrefix + "team-blue": "true",
+				LabelTagPrefix + "gpu":       "",
+			},
+		},
+		{
+			name:     "no tags",
+			opts:     InstancesOpts{TagLabels: []string{"worker"}},
+			server:   servers.Server{},
+			expected: map[string]string{LabelTagPrefix + "worker": ""},
+		},
+	}
+
+	for _, test := range testCases {
+		i := &Instances{instancesOpts: test.opts}
+		if labels := i.nodeLabels(&test.server); !reflect.DeepEqual(labels, test.expected) {
+			t.Errorf("%s: nodeLabels() = %v, expected %v", test.name, labels, test.expected)
+		}
+	}
+}
+
+func TestNodeLabelsMetadata(t *testing.T) {
+	long := strings.Repeat("a", 62) + "-b" + strings.Repeat("c", 10)
+	testCases := []struct {
+		name     string
+		opts     InstancesOpts
+		server   servers.Server
+		expected map[string]string
+	}{
+		{
+			name:     "disabled",
+			server:   servers.Server{Metadata: map[string]string{"rack": "r1"}},
+			expected: map[string]string{},
+		},
+		{
+			name: "listed keys",
+			opts: InstancesOpts{MetadataLabels: []string{"rack", "placement:group", "gpu"}},
+			server: servers.Server{Metadata: map[string]string{
+				"rack":            "r1",
+				"placement:group": "batch jobs",
+				"unlisted":        "value",
+			}},
+			expected: map[string]string{
+				LabelMetadataPrefix + "rack":            "r1",
+				LabelMetadataPrefix + "placement-group": "batch-jobs",
+				LabelMetadataPrefix + "gpu":             "",
+			},
+		},
+		{
+			name: "truncated",
+			opts: InstancesOpts{MetadataLabels: []string{long, "rack"}},
+			server: servers.Server{Metadata: map[string]string{
+				long:   long,
+				"rack": "///",
+			}},
+			expected: map[string]string{
+				LabelMetadataPrefix + strings.Repeat("a", 62): strings.Repeat("a", 62),
+				LabelMetadataPrefix + "rack":                  "",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		i := &Instances{instancesOpts: test.opts}
+		if labels := i.nodeLabels(&test.server); !reflect.DeepEqual(labels, test.expected) {
+			t.Errorf("%s: nodeLabels() = %v, expected %v", test.name, labels, test.expected)
+		}
+	}
+}
+
+func TestUpdateNodeLabelsDryRun(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	i := &Instances{
+		kclient: kubefake.NewSimpleClientset(node),
+		dryRun:  true,
+	}
+
+	if err := i.updateNodeLabels(context.TODO(), node, map[string]string{LabelFaultReason: "fault"}); err != nil {
+		t.Fatalf("updateNodeLabels() returned error: %v", err)
+	}
+
+	current, err := i.kclient.CoreV1().Nodes().Get(context.TODO(), "node", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if len(current.Labels) != 0 {
+		t.Errorf("updateNodeLabels() patched the node in dry-run mode: %v", current.Labels)
+	}
+}
+
+func TestInstancesDryRun(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	th.Mux.HandleFunc("/servers/server-id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"server": {"id": "server-id", "name": "node-1", "status": "SHUTOFF", "flavor": {"original_name": "m1.small"}}}`)
+	})
+	th.Mux.HandleFunc("/servers/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	i := &Instances{
+		compute:        fake.ServiceClient(),
+		networkingOpts: NetworkingOpts{NovaAddressesFallback: true},
+		dryRun:         true,
+	}
+
+	if _, err := i.NodeAddressesByProviderID(context.TODO(), "openstack:///server-id"); err != ErrDryRun {
+		t.Errorf("NodeAddressesByProviderID() returned %v, expected %v", err, ErrDryRun)
+	}
+	if exists, err := i.InstanceExistsByProviderID(context.TODO(), "openstack:///gone"); err != nil || !exists {
+		t.Errorf("InstanceExistsByProviderID() returned %v, %v, expected the node not to be deleted", exists, err)
+	}
+	if off, err := i.InstanceShutdownByProviderID(context.TODO(), "openstack:///server-id"); err != nil || off {
+		t.Errorf("InstanceShutdownByProviderID() returned %v, %v, expected the node not to be tainted", off, err)
+	}
+}
+
+func TestSrvInstanceTypeFlavorCache(t *testing.T) {
+	i := &Instances{
+		instancesOpts: InstancesOpts{FlavorCacheTTL: MyDuration{time.Minute}},
+		flavorCache:   cache.NewLRUExpireCache(flavorCacheSize),
+	}
+	i.flavorCache.Add("/1", &flavors.Flavor{ID: "1", Name: "m1.small"}, time.Minute)
+
+	// the compute client is nil, the flavor must be served from the cache
+	instanceType, err := i.srvInstanceType(context.TODO(), &servers.Server{Flavor: map[string]interface{}{"id": "1"}})
+	if err != nil {
+		t.Fatalf("srvInstanceType returned error: %v", err)
+	}
+	if instanceType != "m1.small" {
+		t.Errorf("srvInstanceType returned %q, expected %q", instanceType, "m1.small")
+	}
+
+	instanceType, err = i.srvInstanceType(context.TODO(), &servers.Server{Flavor: map[string]interface{}{"original_name": "m1.large"}})
+	if err != nil {
+		t.Fatalf("srvInstanceType returned error: %v", err)
+	}
+	if instanceType != "m1.large" {
+		t.Errorf("srvInstanceType returned %q, expected %q", instanceType, "m1.large")
+	}
+}
+
+func TestSrvInstanceTypeFlavorClass(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var requests int
+	th.Mux.HandleFunc("/flavors/1/os-extra_specs", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		requests++
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"extra_specs": {"class": "general purpose", "hw:cpu_policy": "shared"}}`)
+	})
+	th.Mux.HandleFunc("/flavors/2/os-extra_specs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"extra_specs": {}}`)
+	})
+
+	i := &Instances{
+		compute: fake.ServiceClient(),
+		instancesOpts: InstancesOpts{
+			FlavorCacheTTL:       MyDuration{time.Minute},
+			FlavorClassExtraSpec: "class",
+		},
+		flavorCache: cache.NewLRUExpireCache(flavorCacheSize),
+	}
+	i.flavorCache.Add("/1", &flavors.Flavor{ID: "1", Name: "m1.small"}, time.Minute)
+	i.flavorCache.Add("/2", &flavors.Flavor{ID: "2", Name: "m1.large"}, time.Minute)
+
+	testCases := []struct {
+		flavor   map[string]interface{}
+		expected string
+	}{
+		{
+			flavor:   map[string]interface{}{"id": "1"},
+			expected: "general-purpose",
+		},
+		{
+			// served from the cache
+			flavor:   map[string]interface{}{"id": "1"},
+			expected: "general-purpose",
+		},
+		{
+			flavor:   map[string]interface{}{"id": "2"},
+			expected: "m1.large",
+		},
+		{
+			flavor: map[string]interface{}{
+				"original_name": "m1.xlarge",
+				"extra_specs":   map[string]interface{}{"class": "memory"},
+			},
+			expected: "memory",
+		},
+	}
+
+	for _, test := range testCases {
+		instanceType, err := i.srvInstanceType(context.TODO(), &servers.Server{Flavor: test.flavor})
+		if err != nil {
+			t.Fatalf("srvInstanceType returned error: %v", err)
+		}
+		if instanceType != test.expected {
+			t.Errorf("srvInstanceType(%v) returned %q, expected %q", test.flavor, instanceType, test.expected)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("the extra specs of flavor 1 were requested %d times, expected 1", requests)
+	}
+}
+
+func TestFlavorLabels(t *testing.T) {
+	i := &Instances{
+		instancesOpts: InstancesOpts{FlavorLabels: true, FlavorCacheTTL: MyDuration{time.Minute}},
+		flavorCache:   cache.NewLRUExpireCache(flavorCacheSize),
+	}
+	i.flavorCache.Add("/1", &flavors.Flavor{ID: "1", Name: "m1.small", VCPUs: 2, RAM: 4096, Disk: 20}, time.Minute)
+
+	testCases := []struct {
+		flavor   map[string]interface{}
+		expected map[string]string
+	}{
+		{
+			// the compute client is nil, the flavor must be served from the cache
+			flavor:   map[string]interface{}{"id": "1"},
+			expected: map[string]string{LabelFlavorVCPUs: "2", LabelFlavorRAM: "4096", LabelFlavorDisk: "20"},
+		},
+		{
+			flavor:   map[string]interface{}{"original_name": "m1.large", "vcpus": 8.0, "ram": 16384.0, "disk": 0.0},
+			expected: map[string]string{LabelFlavorVCPUs: "8", LabelFlavorRAM: "16384", LabelFlavorDisk: "0"},
+		},
+	}
+
+	for _, test := range testCases {
+		labels, err := i.flavorLabels(context.TODO(), &servers.Server{Flavor: test.flavor})
+		if err != nil {
+			t.Fatalf("flavorLabels(%v) returned error: %v", test.flavor, err)
+		}
+		if !reflect.DeepEqual(labels, test.expected) {
+			t.Errorf("flavorLabels(%v) = %v, expected %v", test.flavor, labels, test.expected)
+		}
+	}
+
+	if _, err := i.flavorLabels(context.TODO(), &servers.Server{Flavor: map[string]interface{}{"original_name": "m1.tiny"}}); err == nil {
+		t.Errorf("flavorLabels() without flavor details nor id didn't return an error")
+	}
+}
+
+func TestServerGroup(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var requests int
+	th.Mux.HandleFunc("/os-server-groups", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		requests++
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"server_groups": [
+			{"id": "group-1", "name": "workers", "policies": ["anti-affinity"], "members": ["server-1", "server-2"]},
+			{"id": "group-2", "name": "", "policies": ["affinity"], "members": ["server-3"]}
+		]}`)
+	})
+
+	i := &Instances{
+		compute:      fake.ServiceClient(),
+		serverGroups: newServerGroupMembers(),
+	}
+
+	for _, test := range []struct {
+		serverID string
+		expected string
+	}{
+		{"server-1", "workers"},
+		{"server-2", "workers"},
+		{"server-3", "group-2"},
+		{"server-4", ""},
+	} {
+		group, err := i.serverGroup(context.TODO(), &servers.Server{ID: test.serverID})
+		th.AssertNoErr(t, err)
+		if group != test.expected {
+			t.Errorf("serverGroup(%s) = %q, expected %q", test.serverID, group, test.expected)
+		}
+	}
+	// the groups are listed once, the server in no group doesn't list them
+	// again before the relist interval
+	if requests != 1 {
+		t.Errorf("the server groups were listed %d times, expected 1", requests)
+	}
+
+	i.serverGroups.listed[""] = time.Now().Add(-serverGroupRelistInterval)
+	if _, err := i.serverGroup(context.TODO(), &servers.Server{ID: "server-4"}); err != nil || requests != 2 {
+		t.Errorf("serverGroup() returned %v after %d lists, expected the groups listed again", err, requests)
+	}
+}
+
+func TestGetInstanceByMetadata(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	handleServerList(t, serverListResponse)
+
+	i := &Instances{
+		compute:       fake.ServiceClient(),
+		instancesOpts: InstancesOpts{NodeNameMetadataKey: "k8s-node-name"},
+	}
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	server, err := i.getInstance(context.TODO(), node)
+	if err != nil {
+		t.Fatalf("getInstance returned error: %v", err)
+	}
+	if server.ID != "7b9cf879-7146-417c-abfd-cb4272f0c935" {
+		t.Errorf("getInstance returned server %s, expected the server matching the metadata key", server.ID)
+	}
+
+	node = &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}}
+	if _, err := i.getInstance(context.TODO(), node); err != cloudprovider.InstanceNotFound {
+		t.Errorf("getInstance returned %v, expected %v", err, cloudprovider.InstanceNotFound)
+	}
+}
+
+func TestInstancesByName(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	handleServerList(t, `{"servers": [{
+		"id": "7b9cf879-7146-417c-abfd-cb4272f0c935", "name": "server-1", "status": "ACTIVE",
+		"flavor": {"original_name": "m1.small"},
+		"addresses": {"private": [{"addr": "10.0.0.10", "version": 4}]},
+		"metadata": {"k8s-node-name": "node-1", "rack": "r1"}}]}`)
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	i := &Instances{
+		compute:        fake.ServiceClient(),
+		networkingOpts: NetworkingOpts{NovaAddressesFallback: true},
+		instancesOpts:  InstancesOpts{NodeNameMetadataKey: "k8s-node-name", MetadataLabels: []string{"rack"}},
+		kclient:        kubefake.NewSimpleClientset(node),
+	}
+	name := types.NodeName(node.Name)
+
+	// The node controller looks up the nodes without providerID by name
+	addresses, err := i.NodeAddresses(context.TODO(), name)
+	th.AssertNoErr(t, err)
+	expected := []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.10"}}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("NodeAddresses() = %v, expected %v", addresses, expected)
+	}
+	current, err := i.kclient.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "r1", current.Labels[LabelMetadataPrefix+"rack"])
+
+	instanceType, err := i.InstanceType(context.TODO(), name)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "m1.small", instanceType)
+
+	id, err := i.InstanceID(context.TODO(), name)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "/7b9cf879-7146-417c-abfd-cb4272f0c935", id)
+
+	// The controller manager makes the regional providerID of the regional ID
+	i.region, i.regionProviderID = "RegionOne", true
+	id, err = i.InstanceID(context.TODO(), name)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935", id)
+
+	// The lifecycle controller deletes the nodes whose instance isn't found
+	if _, err := i.InstanceID(context.TODO(), "node-2"); err != cloudprovider.InstanceNotFound {
+		t.Errorf("InstanceID() of a missing server returned %v, expected %v", err, cloudprovider.InstanceNotFound)
+	}
+}
+
+func TestIsShutdown(t *testing.T) {
+	testCases := []struct {
+		status            string
+		shutdown          bool
+		shutdownSuspended bool
+	}{
+		{status: "ACTIVE", shutdown: false, shutdownSuspended: false},
+		{status: "BUILD", shutdown: false, shutdownSuspended: false},
+		{status: "ERROR", shutdown: false, shutdownSuspended: false},
+		{status: "MIGRATING", shutdown: false, shutdownSuspended: false},
+		{status: "REBOOT", shutdown: false, shutdownSuspended: false},
+		{status: "RESCUE", shutdown: false, shutdownSuspended: false},
+		{status: "SHELVED_OFFLOADED", shutdown: false, shutdownSuspended: false},
+		{status: "SHUTOFF", shutdown: true, shutdownSuspended: true},
+		{status: "PAUSED", shutdown: false, shutdownSuspended: true},
+		{status: "SUSPENDED", shutdown: false, shutdownSuspended: true},
+	}
+
+	for _, test := range testCases {
+		i := &Instances{}
+		if shutdown := i.isShutdown(test.status); shutdown != test.shutdown {
+			t.Errorf("isShutdown(%s) = %t, expected %t", test.status, shutdown, test.shutdown)
+		}
+
+		i.instancesOpts.ShutdownSuspended = true
+		if shutdown := i.isShutdown(test.status); shutdown != test.shutdownSuspended {
+			t.Errorf("isShutdown(%s) with shutdown-suspended = %t, expected %t", test.status, shutdown, test.shutdownSuspended)
+		}
+	}
+}
+
+func TestGetInstanceByNameMultipleProjects(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	handleServerList(t, `
+{
+	"servers": [
+		{"id": "7b9cf879-7146-417c-abfd-cb4272f0c935", "name": "node-1", "tenant_id": "project-1"},
+		{"id": "9e5476bd-a4ec-4653-93d6-72c93aa682ba", "name": "node-1", "tenant_id": "project-2"}
+	]
+}
+`)
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+	i := &Instances{compute: fake.ServiceClient()}
+	if _, err := i.getInstance(context.TODO(), node); !errors.Is(err, ErrMultipleInstances) || !errors.Is(err, ErrMultipleResults) {
+		t.Errorf("getInstance without project returned %v, expected %v", err, ErrMultipleInstances)
+	}
+
+	i.projectID = "project-2"
+	server, err := i.getInstance(context.TODO(), node)
+	if err != nil {
+		t.Fatalf("getInstance returned error: %v", err)
+	}
+	if server.ID != "9e5476bd-a4ec-4653-93d6-72c93aa682ba" {
+		t.Errorf("getInstance returned server %s, expected the server of project-2", server.ID)
+	}
+
+	i.projectID = "project-3"
+	if _, err := i.getInstance(context.TODO(), node); err != cloudprovider.InstanceNotFound {
+		t.Errorf("getInstance returned %v, expected %v", err, cloudprovider.InstanceNotFound)
+	}
+}
+
+func TestMakeInstanceID(t *testing.T) {
+	srv := &servers.Server{ID: "7b9cf879-7146-417c-abfd-cb4272f0c935"}
+
+	for _, regional := range []bool{false, true} {
+		i := &Instances{region: "RegionOne", regionProviderID: regional}
+		providerID := i.makeInstanceID(srv)
+
+		instanceID, region, err := parseProviderID(providerID)
+		if err != nil {
+			t.Fatalf("parseProviderID(%s) returned error: %v", providerID, err)
+		}
+		if instanceID != srv.ID {
+			t.Errorf("parseProviderID(%s) returned instance ID %s, expected %s", providerID, instanceID, srv.ID)
+		}
+		if regional && region != "RegionOne" {
+			t.Errorf("parseProviderID(%s) returned region %q, expected %q", providerID, region, "RegionOne")
+		}
+		if !regional && region != "" {
+			t.Errorf("parseProviderID(%s) returned region %q, expected no region", providerID, region)
+		}
+	}
+}
+
+func TestProviderIDScheme(t *testing.T) {
+	defer setProviderIDScheme(ProviderName)
+
+	if err := setProviderIDScheme("legacy/scheme"); err == nil {
+		t.Errorf("setProviderIDScheme accepted an invalid scheme")
+	}
+	if err := setProviderIDScheme("legacy-os"); err != nil {
+		t.Fatalf("setProviderIDScheme returned error: %v", err)
+	}
+
+	srv := &servers.Server{ID: "7b9cf879-7146-417c-abfd-cb4272f0c935"}
+	for _, regional := range []bool{false, true} {
+		i := &Instances{region: "RegionOne", regionProviderID: regional}
+		providerID := i.makeInstanceID(srv)
+		if !strings.HasPrefix(providerID, "legacy-os://") {
+			t.Errorf("makeInstanceID() = %s, expected the legacy-os scheme", providerID)
+		}
+
+		instanceID, region, err := parseProviderID(providerID)
+		if err != nil {
+			t.Fatalf("parseProviderID(%s) returned error: %v", providerID, err)
+		}
+		if instanceID != srv.ID {
+			t.Errorf("parseProviderID(%s) returned instance ID %s, expected %s", providerID, instanceID, srv.ID)
+		}
+		if regional != (region == "RegionOne") {
+			t.Errorf("parseProviderID(%s) returned region %q", providerID, region)
+		}
+
+		regionalID, err := RegionalProviderID(providerID, "RegionOne")
+		if err != nil {
+			t.Fatalf("RegionalProviderID(%s) returned error: %v", providerID, err)
+		}
+		if regionalID != "legacy-os://RegionOne/"+srv.ID {
+			t.Errorf("RegionalProviderID(%s) = %s", providerID, regionalID)
+		}
+	}
+
+	// the providerIDs of the default scheme are still accepted
+	for _, providerID := range []string{"openstack:///" + srv.ID, srv.ID} {
+		instanceID, err := instanceIDFromProviderID(providerID)
+		if err != nil {
+			t.Fatalf("instanceIDFromProviderID(%s) returned error: %v", providerID, err)
+		}
+		if instanceID != srv.ID {
+			t.Errorf("instanceIDFromProviderID(%s) = %s, expected %s", providerID, instanceID, srv.ID)
+		}
+	}
+	if _, err := instanceIDFromProviderID("aws:///" + srv.ID); err == nil {
+		t.Errorf("instanceIDFromProviderID accepted a providerID of another scheme")
+	}
+}
+
+func TestLocalProviderID(t *testing.T) {
+	env := clearEnviron(t)
+	defer resetEnviron(t, env)
+	metadata.Set(&FakeMetadata)
+	defer metadata.Clear()
+
+	testCases := []struct {
+		env      map[string]string
+		region   string
+		expected string
+		fail     bool
+	}{
+		{region: "RegionOne", expected: "openstack:///" + FakeMetadata.UUID},
+		{
+			env:      map[string]string{RegionalProviderIDEnv: "true"},
+			region:   "RegionOne",
+			expected: "openstack://RegionOne/" + FakeMetadata.UUID,
+		},
+		{env: map[string]string{RegionalProviderIDEnv: "true"}, fail: true},
+		{
+			env:      map[string]string{ProviderIDSchemeEnv: "legacy-os"},
+			expected: "legacy-os:///" + FakeMetadata.UUID,
+		},
+		{env: map[string]string{ProviderIDSchemeEnv: "legacy/os"}, fail: true},
+	}
+
+	for _, test := range testCases {
+		for key, value := range test.env {
+			os.Setenv(key, value)
+		}
+
+		providerID, err := LocalProviderID("", test.region)
+		for key := range test.env {
+			os.Unsetenv(key)
+		}
+		if (err != nil) != test.fail {
+			t.Errorf("LocalProviderID(%v, %q) returned error %v", test.env, test.region, err)
+		}
+		if providerID != test.expected {
+			t.Errorf("LocalProviderID(%v, %q) = %q, expected %q", test.env, test.region, providerID, test.expected)
+		}
+		if test.fail {
+			continue
+		}
+
+		// the controller manager parses the providerIDs of its own scheme
+		if scheme := test.env[ProviderIDSchemeEnv]; scheme != "" {
+			th.AssertNoErr(t, setProviderIDScheme(scheme))
+		}
+		instanceID, region, err := parseProviderID(providerID)
+		th.AssertNoErr(t, setProviderIDScheme(ProviderName))
+		if err != nil {
+			t.Fatalf("parseProviderID(%s) returned error: %v", providerID, err)
+		}
+		if instanceID != FakeMetadata.UUID {
+			t.Errorf("parseProviderID(%s) returned instance ID %s, expected %s", providerID, instanceID, FakeMetadata.UUID)
+		}
+		expectedRegion := ""
+		if test.env[RegionalProviderIDEnv] == "true" {
+			expectedRegion = test.region
+		}
+		if region != expectedRegion {
+			t.Errorf("parseProviderID(%s) returned region %q, expected %q", providerID, region, expectedRegion)
+		}
+	}
+}
+
+func TestRegionalProviderID(t *testing.T) {
+	testCases := []struct {
+		providerID string
+		expected   string
+		fail       bool
+	}{
+		{
+			providerID: "openstack:///7b9cf879-7146-417c-abfd-cb4272f0c935",
+			expected:   "openstack://RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935",
+		},
+		{
+			providerID: "/7b9cf879-7146-417c-abfd-cb4272f0c935",
+			expected:   "openstack://RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935",
+		},
+		{
+			providerID: "openstack://RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935",
+			expected:   "openstack://RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935",
+		},
+		{
+			providerID: "openstack://RegionTwo/7b9cf879-7146-417c-abfd-cb4272f0c935",
+			fail:       true,
+		},
+		{
+			providerID: "openstack:7b9cf879-7146-417c-abfd-cb4272f0c935",
+			fail:       true,
+		},
+	}
+
+	for _, test := range testCases {
+		providerID, err := RegionalProviderID(test.providerID, "RegionOne")
+		if (err != nil) != test.fail {
+			t.Errorf("RegionalProviderID(%s): expected err: %t, got err: %v", test.providerID, test.fail, err)
+		}
+		if providerID != test.expected {
+			t.Errorf("RegionalProviderID(%s) = %q, expected %q", test.providerID, providerID, test.expected)
+		}
+	}
+}
+
+func TestRegionInstances(t *testing.T) {
+	secondary := &gophercloud.ServiceClient{Endpoint: "http://secondary/"}
+	i := &Instances{
+		compute: &gophercloud.ServiceClient{Endpoint: "http://primary/"},
+		region:  "RegionOne",
+		regions: map[string]regionClients{
+			"RegionTwo": {compute: secondary},
+		},
+	}
+
+	testCases := []struct {
+		providerID string
+		region     string
+		fail       bool
+	}{
+		{providerID: "", region: "RegionOne"},
+		{providerID: "openstack:///instance", region: "RegionOne"},
+		{providerID: "openstack://RegionOne/instance", region: "RegionOne"},
+		{providerID: "openstack://RegionTwo/instance", region: "RegionTwo"},
+		{providerID: "openstack://RegionThree/instance", fail: true},
+	}
+
+	for _, test := range testCases {
+		node := &v1.Node{Spec: v1.NodeSpec{ProviderID: test.providerID}}
+		ri, err := i.regionInstances(node)
+		if test.fail {
+			if err == nil {
+				t.Errorf("regionInstances(%q) succeeded, expected an error", test.providerID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("regionInstances(%q) returned error: %v", test.providerID, err)
+			continue
+		}
+		if ri.region != test.region {
+			t.Errorf("regionInstances(%q) returned region %q, expected %q", test.providerID, ri.region, test.region)
+		}
+		if test.region == "RegionTwo" && ri.compute != secondary {
+			t.Errorf("regionInstances(%q) didn't use the compute client of the region", test.providerID)
+		}
+	}
+}
+
+func TestInstancesByProviderIDRegion(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	// The server is only found in the secondary region
+	th.Mux.HandleFunc("/servers/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s in the primary region", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	th.Mux.HandleFunc("/secondary/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"server": {"id": "%s", "name": "node-2", "status": "ACTIVE",
+			"flavor": {"original_name": "m1.small"},
+			"addresses": {"private": [{"addr": "10.0.1.10", "version": 4}]},
+			"metadata": {"rack": "r1"}}}`, instanceID)
+	})
+	th.Mux.HandleFunc("/secondary/servers/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack://RegionTwo/" + instanceID},
+	}
+	secondary := fake.ServiceClient()
+	secondary.Endpoint += "secondary/"
+	i := &Instances{
+		compute: fake.ServiceClient(),
+		region:  "RegionOne",
+		regions: map[string]regionClients{
+			"RegionTwo": {compute: secondary},
+		},
+		networkingOpts: NetworkingOpts{NovaAddressesFallback: true},
+		instancesOpts:  InstancesOpts{MetadataLabels: []string{"rack"}},
+		kclient:        kubefake.NewSimpleClientset(node),
+		nodes:          newNodeIndexer(),
+	}
+	if err := i.nodes.Add(node); err != nil {
+		t.Fatalf("failed to add node %s to the cache: %v", node.Name, err)
+	}
+
+	addresses, err := i.NodeAddressesByProviderID(context.TODO(), node.Spec.ProviderID)
+	th.AssertNoErr(t, err)
+	expected := []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.1.10"}}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("NodeAddressesByProviderID() = %v, expected %v", addresses, expected)
+	}
+
+	// The labels of the node of the providerID are updated
+	current, err := i.kclient.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "r1", current.Labels[LabelMetadataPrefix+"rack"])
+
+	instanceType, err := i.InstanceTypeByProviderID(context.TODO(), node.Spec.ProviderID)
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "m1.small", instanceType)
+
+	if exists, err := i.InstanceExistsByProviderID(context.TODO(), node.Spec.ProviderID); err != nil || !exists {
+		t.Errorf("InstanceExistsByProviderID() returned %v, %v, expected the server of the secondary region to exist", exists, err)
+	}
+	if off, err := i.InstanceShutdownByProviderID(context.TODO(), node.Spec.ProviderID); err != nil || off {
+		t.Errorf("InstanceShutdownByProviderID() returned %v, %v, expected the server not to be shut down", off, err)
+	}
+
+	// The providerIDs of the nodes missing from the cache are looked up in their region too
+	if exists, err := i.InstanceExistsByProviderID(context.TODO(), "openstack://RegionTwo/gone"); err != nil || exists {
+		t.Errorf("InstanceExistsByProviderID() of a missing server returned %v, %v, expected false", exists, err)
+	}
+}
+
+func TestComputeClientReauth(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	th.Mux.HandleFunc("/servers/server-id", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "new-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"server": {"id": "server-id", "status": "ACTIVE"}}`)
+	})
+
+	shared, err := openstack.NewClient(th.Endpoint())
+	th.AssertNoErr(t, err)
+	shared.SetToken("old-token")
+	reauths := 0
+	shared.ReauthFunc = func() error {
+		reauths++
+		shared.SetToken("new-token")
+		return nil
+	}
+	i := &Instances{compute: &gophercloud.ServiceClient{ProviderClient: shared, Endpoint: th.Endpoint()}}
+
+	compute, cancel := i.computeClient(context.TODO())
+	_, err = servers.Get(compute, "server-id").Extract()
+	cancel()
+	th.AssertNoErr(t, err)
+	// The token of the shared provider client is refreshed
+	th.AssertEquals(t, "new-token", shared.Token())
+
+	compute, cancel = i.computeClient(context.TODO())
+	_, err = servers.Get(compute, "server-id").Extract()
+	cancel()
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 1, reauths)
+}
+
+func TestGetInstanceRetry(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	var attempts int
+	th.Mux.HandleFunc("/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"server": {"id": "%s", "name": "node-1", "status": "ACTIVE"}}`, instanceID)
+	})
+
+	i := &Instances{
+		compute: fake.ServiceClient(),
+		instancesOpts: InstancesOpts{
+			APIMaxRetries: 1,
+			APIRetryDelay: MyDuration{time.Millisecond},
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///" + instanceID},
+	}
+
+	_, err := i.getInstance(context.TODO(), node)
+	if err == nil {
+		t.Errorf("getInstance succeeded, expected the retries to be exhausted")
+	}
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("getInstance returned %v, expected %v", err, ErrBackendUnavailable)
+	}
+	var unavailable gophercloud.ErrDefault503
+	if !errors.As(err, &unavailable) {
+		t.Errorf("getInstance returned %v, expected it to wrap the API error", err)
+	}
+
+	attempts = 0
+	i.instancesOpts.APIMaxRetries = 3
+	server, err := i.getInstance(context.TODO(), node)
+	if err != nil {
+		t.Fatalf("getInstance returned error: %v", err)
+	}
+	if server.ID != instanceID {
+		t.Errorf("getInstance returned server %s, expected %s", server.ID, instanceID)
+	}
+	if attempts != 3 {
+		t.Errorf("getInstance made %d attempts, expected 3", attempts)
+	}
+}
+
+func TestGetInstanceWarmup(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var lists, gets int
+	th.Mux.HandleFunc("/servers/detail", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		lists++
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, serverListResponse)
+	})
+	th.Mux.HandleFunc("/servers/unlisted", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		gets++
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"server": {"id": "unlisted", "name": "node-3", "status": "ACTIVE"}}`)
+	})
+
+	i := &Instances{
+		compute:       fake.ServiceClient(),
+		instancesOpts: InstancesOpts{WarmupCacheTTL: MyDuration{time.Minute}},
+		serverWarmup:  &serverWarmup{cache: cache.NewLRUExpireCache(serverWarmupCacheSize)},
+	}
+	node := func(instanceID string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Spec:       v1.NodeSpec{ProviderID: "openstack:///" + instanceID},
+		}
+	}
+
+	for _, instanceID := range []string{"7b9cf879-7146-417c-abfd-cb4272f0c935", "9e5476bd-a4ec-4653-93d6-72c93aa682ba"} {
+		server, err := i.getInstance(context.TODO(), node(instanceID))
+		if err != nil {
+			t.Fatalf("getInstance returned error: %v", err)
+		}
+		if server.ID != instanceID {
+			t.Errorf("getInstance returned server %s, expected %s", server.ID, instanceID)
+		}
+	}
+	if lists != 1 || gets != 0 {
+		t.Errorf("expected the servers to be listed once and not fetched, got %d lists and %d gets", lists, gets)
+	}
+
+	// the servers missing from the list are fetched
+	server, err := i.getInstance(context.TODO(), node("unlisted"))
+	if err != nil {
+		t.Fatalf("getInstance returned error: %v", err)
+	}
+	if server.ID != "unlisted" {
+		t.Errorf("getInstance returned server %s, expected unlisted", server.ID)
+	}
+	if lists != 1 || gets != 1 {
+		t.Errorf("expected the unlisted server to be fetched, got %d lists and %d gets", lists, gets)
+	}
+}
+
+func TestGetInstanceRegionMismatch(t *testing.T) {
+	i := &Instances{region: "RegionOne"}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack://RegionTwo/instance"},
+	}
+
+	_, err := i.InstanceExists(context.TODO(), node)
+	var instanceErr *InstanceError
+	if !errors.As(err, &instanceErr) || instanceErr.Class != ErrRegionMismatch || instanceErr.Node != "node-1" {
+		t.Errorf("InstanceExists returned %v, expected %v", err, ErrRegionMismatch)
+	}
+}
+
+func TestDisableNameLookup(t *testing.T) {
+	// No request is expected, the compute client has no endpoint
+	i := &Instances{instancesOpts: InstancesOpts{DisableNameLookup: true}}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+	if _, err := i.InstanceExists(context.TODO(), node); err != ErrNameLookupDisabled {
+		t.Errorf("InstanceExists returned %v, expected %v", err, ErrNameLookupDisabled)
+	}
+	if _, err := i.InstanceMetadata(context.TODO(), node); err != ErrNameLookupDisabled {
+		t.Errorf("InstanceMetadata returned %v, expected %v", err, ErrNameLookupDisabled)
+	}
+	if _, err := i.InstanceID(context.TODO(), types.NodeName(node.Name)); err != ErrNameLookupDisabled {
+		t.Errorf("InstanceID returned %v, expected %v", err, ErrNameLookupDisabled)
+	}
+}
+
+func TestNodeSelector(t *testing.T) {
+	// No request is expected, the compute client has no endpoint
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"type": "baremetal"}}}
+	i := &Instances{
+		nodeSelector: labels.SelectorFromSet(labels.Set{"type": "virtual"}),
+		kclient:      kubefake.NewSimpleClientset(node),
+		nodes:        newNodeIndexer(),
+	}
+
+	if exists, err := i.InstanceExists(context.TODO(), node); err != nil || !exists {
+		t.Errorf("InstanceExists returned %v, %v, expected the node to exist", exists, err)
+	}
+	if off, err := i.InstanceShutdown(context.TODO(), node); err != nil || off {
+		t.Errorf("InstanceShutdown returned %v, %v, expected the node not to be shut down", off, err)
+	}
+	if _, err := i.InstanceMetadata(context.TODO(), node); err != ErrNodeNotManaged {
+		t.Errorf("InstanceMetadata returned %v, expected %v", err, ErrNodeNotManaged)
+	}
+	if _, err := i.InstanceID(context.TODO(), types.NodeName(node.Name)); err != ErrNodeNotManaged {
+		t.Errorf("InstanceID returned %v, expected %v", err, ErrNodeNotManaged)
+	}
+
+	// The lifecycle controller only passes the providerID of the node
+	baremetal := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{"type": "baremetal"}},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///baremetal"},
+	}
+	if err := i.nodes.Add(baremetal); err != nil {
+		t.Fatalf("failed to add node %s to the cache: %v", baremetal.Name, err)
+	}
+	if exists, err := i.InstanceExistsByProviderID(context.TODO(), baremetal.Spec.ProviderID); err != nil || !exists {
+		t.Errorf("InstanceExistsByProviderID returned %v, %v, expected the node to exist", exists, err)
+	}
+}
+
+// newNodeIndexer returns a node informer cache indexed by providerID
+func newNodeIndexer() toolscache.Indexer {
+	return toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{nodeProviderIDIndex: nodeProviderIDIndexFunc})
+}
+
+func TestMetadataDebounce(t *testing.T) {
+	d := newMetadataDebounce(time.Minute)
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: v1.NodeSpec{ProviderID: "openstack:///server-1"}}
+	md := &cloudprovider.InstanceMetadata{ProviderID: "openstack:///server-1", InstanceType: "m1.small"}
+
+	if got := d.get(node); got != nil {
+		t.Errorf("get() = %v, expected the metadata of a new node to be computed", got)
+	}
+
+	// the metadata is served within the interval
+	d.observe(node, md, nil)
+	if got := d.get(node); got != md {
+		t.Errorf("get() = %v, expected %v", got, md)
+	}
+
+	// and computed again when the providerID changes or the interval is over
+	moved := node.DeepCopy()
+	moved.Spec.ProviderID = "openstack:///server-2"
+	if got := d.get(moved); got != nil {
+		t.Errorf("get() = %v, expected the metadata of the moved node to be computed", got)
+	}
+	d.nodes["node-1"].computed = time.Now().Add(-time.Minute)
+	if got := d.get(node); got != nil {
+		t.Errorf("get() = %v, expected the metadata to be computed after the interval", got)
+	}
+
+	// the failed lookups and the deleted nodes are forgotten
+	d.observe(node, nil, errors.New("lookup failed"))
+	if _, ok := d.nodes["node-1"]; ok {
+		t.Errorf("expected the failed lookup not to be recorded")
+	}
+	d.observe(node, md, nil)
+	d.forget("node-1")
+	if got := d.get(node); got != nil {
+		t.Errorf("get() = %v, expected the metadata of the deleted node to be forgotten", got)
+	}
+
+	// a nil debounce never serves metadata
+	var disabled *metadataDebounce
+	disabled.observe(node, md, nil)
+	if got := disabled.get(node); got != nil {
+		t.Errorf("get() = %v, expected no metadata", got)
+	}
+}
+
+func TestLookupBackoff(t *testing.T) {
+	b := newLookupBackoff(lookupBackoffInitial, lookupBackoffMax)
+	transient := &InstanceError{Class: ErrBackendUnavailable, Node: "node-1", Err: gophercloud.ErrDefault503{}}
+
+	// the healthy nodes don't back off
+	b.observe("node-1", nil)
+	if err := b.check("node-1"); err != nil {
+		t.Errorf("check() = %v, expected no backoff", err)
+	}
+
+	// the failing nodes back off with a doubling delay up to the maximum
+	b.observe("node-1", transient)
+	if err := b.check("node-1"); err != transient {
+		t.Errorf("check() = %v, expected %v", err, transient)
+	}
+	if err := b.check("node-2"); err != nil {
+		t.Errorf("check() = %v, expected no backoff of another node", err)
+	}
+	b.observe("node-1", transient)
+	th.AssertEquals(t, 2*lookupBackoffInitial, b.nodes["node-1"].delay)
+	b.nodes["node-1"].delay = lookupBackoffMax
+	b.observe("node-1", transient)
+	th.AssertEquals(t, lookupBackoffMax, b.nodes["node-1"].delay)
+
+	// the backoff is reset on success, and on errors that aren't transient
+	b.nodes["node-1"].until = time.Now()
+	b.observe("node-1", nil)
+	if _, ok := b.nodes["node-1"]; ok {
+		t.Errorf("expected the backoff of the node to be reset")
+	}
+	b.observe("node-1", cloudprovider.InstanceNotFound)
+	if err := b.check("node-1"); err != nil {
+		t.Errorf("check() = %v, expected no backoff", err)
+	}
+}
+
+func TestGetInstanceNotFoundNotRetried(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	var attempts int
+	th.Mux.HandleFunc("/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	i := &Instances{
+		compute: fake.ServiceClient(),
+		instancesOpts: InstancesOpts{
+			APIMaxRetries: 3,
+			APIRetryDelay: MyDuration{time.Millisecond},
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///" + instanceID},
+	}
+
+	if _, err := i.getInstance(context.TODO(), node); err != cloudprovider.InstanceNotFound {
+		t.Errorf("getInstance returned %v, expected %v", err, cloudprovider.InstanceNotFound)
+	}
+	if attempts != 1 {
+		t.Errorf("getInstance made %d attempts, expected 1", attempts)
+	}
+}
+
+func TestNodeAddressesExcludeDeviceOwner(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const serverID = "server-id"
+
+	th.Mux.HandleFunc("/servers/"+serverID+"/os-interface", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"interfaceAttachments": [
+			{"port_id": "compute-port", "net_id": "net", "port_state": "ACTIVE", "fixed_ips": [{"ip_address": "10.0.0.10"}]},
+			{"port_id": "dhcp-port", "net_id": "net", "port_state": "ACTIVE", "fixed_ips": [{"ip_address": "10.0.0.2"}]},
+			{"port_id": "router-port", "net_id": "net", "port_state": "ACTIVE", "fixed_ips": [{"ip_address": "10.0.0.1"}]}
+		]}`)
+	})
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		th.TestFormValues(t, r, map[string]string{"device_id": serverID})
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ports": [
+			{"id": "compute-port", "device_owner": "compute:nova", "fixed_ips": [{"ip_address": "10.0.0.10"}], "allowed_address_pairs": [{"ip_address": "2001:db8::10"}],
+			 "dns_name": "node", "dns_assignment": [{"hostname": "node", "ip_address": "10.0.0.10", "fqdn": "Node.example.org."}]},
+			{"id": "dhcp-port", "device_owner": "network:dhcp", "fixed_ips": [{"ip_address": "10.0.0.2"}], "dns_name": "dhcp"},
+			{"id": "router-port", "device_owner": "network:router_interface", "fixed_ips": [{"ip_address": "10.0.0.1"}]}
+		]}`)
+	})
+	th.Mux.HandleFunc("/trunks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"trunks": []}`)
+	})
+
+	srv := &servers.Server{
+		ID:   serverID,
+		Name: "node",
+		Addresses: map[string]interface{}{
+			"net": []interface{}{
+				map[string]interface{}{"addr": "10.0.0.10", "OS-EXT-IPS:type": "fixed"},
+				map[string]interface{}{"addr": "10.0.0.2", "OS-EXT-IPS:type": "fixed"},
+				map[string]interface{}{"addr": "10.0.0.1", "OS-EXT-IPS:type": "fixed"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		opts     NetworkingOpts
+		expected []v1.NodeAddress
+	}{
+		{
+			name:     "default device owners",
+			expected: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.10"}},
+		},
+		{
+			name: "allowed address pairs",
+			opts: NetworkingOpts{AllowedAddressPairCIDR: []string{"2001:db8::/64"}},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: v1.NodeInternalIP, Address: "2001:db8::10"},
+			},
+		},
+		{
+			name: "custom device owners",
+			opts: NetworkingOpts{ExcludeDeviceOwner: []string{"network:dhcp"}},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+		{
+			name: "dns node addresses",
+			opts: NetworkingOpts{DNSNodeAddresses: true},
+			expected: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+				{Type: v1.NodeInternalDNS, Address: "node.example.org"},
+				{Type: v1.NodeHostName, Address: "node"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := &Instances{
+				compute:        fake.ServiceClient(),
+				network:        fake.ServiceClient(),
+				networkingOpts: test.opts,
+			}
+			addresses, err := i.nodeAddresses(fake.ServiceClient(), srv)
+			th.AssertNoErr(t, err)
+			if !reflect.DeepEqual(addresses, test.expected) {
+				t.Errorf("nodeAddresses() = %v, expected %v", addresses, test.expected)
+			}
+		})
+	}
+}
+
+func TestNodeAddressesNovaFallback(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// Without Neutron, Nova fails to list the interfaces
+	th.Mux.HandleFunc("/servers/server-id/os-interface", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	})
+
+	srv := &servers.Server{
+		ID:         "server-id",
+		AccessIPv4: "50.56.176.99",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{"addr": "10.0.0.10", "version": 4},
+			},
+		},
+	}
+
+	i := &Instances{compute: fake.ServiceClient()}
+	if _, err := i.nodeAddresses(fake.ServiceClient(), srv); err == nil {
+		t.Errorf("nodeAddresses() without Neutron nor nova-addresses-fallback didn't return an error")
+	}
+
+	i.networkingOpts.NovaAddressesFallback = true
+	addresses, err := i.nodeAddresses(fake.ServiceClient(), srv)
+	th.AssertNoErr(t, err)
+	expected := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+		{Type: v1.NodeExternalIP, Address: "50.56.176.99"},
+	}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("nodeAddresses() = %v, expected %v", addresses, expected)
+	}
+
+	// The interfaces are still listed when Neutron is available
+	i.network = fake.ServiceClient()
+	if _, err := i.nodeAddresses(fake.ServiceClient(), srv); err == nil {
+		t.Errorf("nodeAddresses() with Neutron didn't list the interfaces")
+	}
+}
+
+func TestPortBindings(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "server-id", r.URL.Query().Get("device_id"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ports": [
+			{"id": "bound", "device_id": "server-id", "binding:host_id": "compute-1"},
+			{"id": "unreadable", "device_id": "server-id"}
+		]}`)
+	})
+
+	ports, err := getServerPorts(fake.ServiceClient(), "server-id")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, 2, len(ports))
+	th.AssertEquals(t, "compute-1", ports[0].HostID)
+	th.AssertEquals(t, "", ports[1].HostID)
+
+	// the host IDs are computed like Nova does for the servers of the project
+	th.AssertEquals(t, "2e374e4286cee287c246b03d45c64c813fa985b8064ae61fd28c9f35", novaHostID("project-1", "compute-1"))
+}
+
+func TestPortDNSNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     serverPort
+		expected []string
+	}{
+		{
+			name: "no dns names",
+		},
+		{
+			name:     "dns name",
+			port:     serverPort{PortDNSExt: dns.PortDNSExt{DNSName: "node-1"}},
+			expected: []string{"node-1"},
+		},
+		{
+			name: "dns assignments",
+			port: serverPort{PortDNSExt: dns.PortDNSExt{
+				DNSName: "node-1",
+				DNSAssignment: []map[string]string{
+					{"hostname": "node-1", "ip_address": "10.0.0.10", "fqdn": "node-1.example.org."},
+					{"hostname": "node-1", "ip_address": "10.0.0.11", "fqdn": ""},
+					{"hostname": "node_1", "ip_address": "10.0.0.12", "fqdn": "node_1.example.org."},
+				},
+			}},
+			expected: []string{"node-1.example.org"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if names := portDNSNames(test.port); !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("portDNSNames() = %v, expected %v", names, test.expected)
+			}
+		})
+	}
+}
+
+func TestAllowedAddressPairIPs(t *testing.T) {
+	port := neutronports.Port{
+		ID: "port",
+		AllowedAddressPairs: []neutronports.AddressPair{
+			{IPAddress: "2001:db8:1::10"},
+			{IPAddress: "2001:db8:1::20/128"},
+			{IPAddress: "2001:db8:1:1::/64"},
+			{IPAddress: "2001:db8:2::10"},
+			{IPAddress: "fe80::10"},
+			{IPAddress: "10.0.0.10"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cidrs    []string
+		expected []string
+	}{
+		{
+			name: "no CIDR",
+		},
+		{
+			name:     "single CIDR",
+			cidrs:    []string{"2001:db8:1::/48"},
+			expected: []string{"2001:db8:1::10", "2001:db8:1::20"},
+		},
+		{
+			name:     "several CIDRs",
+			cidrs:    []string{"2001:db8:1::/48", "2001:db8:2::/48", "fe80::/10", "10.0.0.0/8"},
+			expected: []string{"2001:db8:1::10", "2001:db8:1::20", "2001:db8:2::10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ips := allowedAddressPairIPs(port, test.cidrs)
+			if !reflect.DeepEqual(ips, test.expected) {
+				t.Errorf("allowedAddressPairIPs() = %v, expected %v", ips, test.expected)
+			}
+		})
+	}
+}
+
+func TestComputeHost(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	th.Mux.HandleFunc("/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"server": {"id": "%s", "name": "node-1", "status": "ACTIVE", "OS-EXT-SRV-ATTR:host": "compute-1.example.com"}}`, instanceID)
+	})
+
+	srv := &servers.Server{ID: instanceID, HostID: "29d3c8c896a45aa4c34e52247875d7fefc3d94bbcc9f622b5d204362"}
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{mode: computeHostLabelHostID, expected: "29d3c8c896a45aa4c34e52247875d7fefc3d94bbcc9f622b5d204362"},
+		{mode: computeHostLabelHost, expected: "compute-1.example.com"},
+	}
+
+	for _, test := range tests {
+		i := &Instances{instancesOpts: InstancesOpts{ComputeHostLabel: test.mode}}
+		host, err := i.computeHost(fake.ServiceClient(), srv)
+		if err != nil {
+			t.Fatalf("computeHost() with %s returned error: %v", test.mode, err)
+		}
+		if host != test.expected {
+			t.Errorf("computeHost() with %s = %q, expected %q", test.mode, host, test.expected)
+		}
+	}
+}
+
+func TestInstanceExistsNotFoundEvent(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	th.Mux.HandleFunc("/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	recorder := record.NewFakeRecorder(1)
+	i := &Instances{
+		compute:       fake.ServiceClient(),
+		region:        "RegionOne",
+		eventRecorder: recorder,
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///" + instanceID},
+	}
+
+	exists, err := i.InstanceExists(context.TODO(), node)
+	if err != nil {
+		t.Fatalf("InstanceExists returned error: %v", err)
+	}
+	if exists {
+		t.Errorf("InstanceExists returned true, expected false")
+	}
+
+	select {
+	case event := <-recorder.Events:
+		expected := fmt.Sprintf("Warning %s Server of providerID \"openstack:///%s\" not found in region RegionOne, the node will be deleted", EventReasonInstanceNotFound, instanceID)
+		if event != expected {
+			t.Errorf("got event %q, expected %q", event, expected)
+		}
+	default:
+		t.Errorf("expected an event on the node")
+	}
+}
+
+func TestGetInstanceDeleted(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	const instanceID = "7b9cf879-7146-417c-abfd-cb4272f0c935"
+	var status string
+	th.Mux.HandleFunc("/servers/"+instanceID, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"server": {"id": "%s", "name": "node-1", "status": "%s"}}`, instanceID, status)
+	})
+
+	i := &Instances{
+		compute: fake.ServiceClient(),
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       v1.NodeSpec{ProviderID: "openstack:///" + instanceID},
+	}
+
+	for _, status = range []string{"DELETED", "SOFT_DELETED"} {
+		if _, err := i.getInstance(context.TODO(), node); err != cloudprovider.InstanceNotFound {
+			t.Errorf("getInstance of a %s server returned %v, expected %v", status, err, cloudprovider.InstanceNotFound)
+		}
+		if exists, err := i.InstanceExistsByProviderID(context.TODO(), node.Spec.ProviderID); err != nil || exists {
+			t.Errorf("InstanceExistsByProviderID of a %s server returned %t, %v, expected false", status, exists, err)
+		}
+	}
+
+	for _, status = range []string{"ACTIVE", "SHUTOFF", "ERROR", "SHELVED_OFFLOADED"} {
+		server, err := i.getInstance(context.TODO(), node)
+		if err != nil {
+			t.Errorf("getInstance of a %s server returned %v", status, err)
+		} else if server.Status != status {
+			t.Errorf("getInstance returned a %s server, expected %s", server.Status, status)
+		}
+	}
+}
@@ -22,8 +22,10 @@ import (
 	"net"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
@@ -43,9 +45,12 @@ import (
 	"github.com/gophercloud/gophercloud/pagination"
 	secgroups "github.com/gophercloud/utils/openstack/networking/v2/extensions/security/groups"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	cloudprovider "k8s.io/cloud-provider"
 	klog "k8s.io/klog/v2"
 
@@ -67,6 +72,9 @@ const (
 	loadbalancerActiveInitDelay = 1 * time.Second
 	loadbalancerActiveFactor    = 1.2
 	loadbalancerActiveSteps     = 19
+	// loadbalancerActivePollInterval is the interval of the polls of the
+	// provisioning status of a loadbalancer with a create-timeout
+	loadbalancerActivePollInterval = 5 * time.Second
 
 	// loadbalancerDelete* is configuration of exponential backoff for
 	// waiting for delete operation to complete. Starting with 1
@@ -76,13 +84,24 @@ const (
 	loadbalancerDeleteFactor    = 1.2
 	loadbalancerDeleteSteps     = 13
 
-	activeStatus = "ACTIVE"
-	errorStatus  = "ERROR"
+	activeStatus   = "ACTIVE"
+	errorStatus    = "ERROR"
+	degradedStatus = "DEGRADED"
 
-	annotationXForwardedFor = "X-Forwarded-For"
+	annotationXForwardedFor   = "X-Forwarded-For"
+	annotationXForwardedPort  = "X-Forwarded-Port"
+	annotationXForwardedProto = "X-Forwarded-Proto"
+
+	// poolProtocolPROXYV2 is the pool protocol of the PROXY protocol version 2, supported since Octavia 2.22
+	poolProtocolPROXYV2 v2pools.Protocol = "PROXYV2"
+
+	// securityGroupRuleDescription marks the security group rules managed by the controller manager, the other rules
+	// of the security group of a Service are never updated nor deleted
+	securityGroupRuleDescription = "Managed by openstack-cloud-controller-manager"
 
 	// ServiceAnnotationLoadBalancerInternal defines whether or not to create an internal loadbalancer. Default: false.
 	ServiceAnnotationLoadBalancerInternal             = "service.beta.kubernetes.io/openstack-internal-load-balancer"
+	ServiceAnnotationLoadBalancerOpenStackInternal    = "loadbalancer.openstack.org/internal"
 	ServiceAnnotationLoadBalancerConnLimit            = "loadbalancer.openstack.org/connection-limit"
 	ServiceAnnotationLoadBalancerFloatingNetworkID    = "loadbalancer.openstack.org/floating-network-id"
 	ServiceAnnotationLoadBalancerFloatingSubnet       = "loadbalancer.openstack.org/floating-subnet"
@@ -98,11 +117,88 @@ const (
 	ServiceAnnotationLoadBalancerTimeoutMemberData    = "loadbalancer.openstack.org/timeout-member-data"
 	ServiceAnnotationLoadBalancerTimeoutTCPInspect    = "loadbalancer.openstack.org/timeout-tcp-inspect"
 	ServiceAnnotationLoadBalancerXForwardedFor        = "loadbalancer.openstack.org/x-forwarded-for"
+	ServiceAnnotationLoadBalancerXForwardedPort       = "loadbalancer.openstack.org/x-forwarded-port"
+	ServiceAnnotationLoadBalancerXForwardedProto      = "loadbalancer.openstack.org/x-forwarded-proto"
 	ServiceAnnotationLoadBalancerFlavorID             = "loadbalancer.openstack.org/flavor-id"
 	ServiceAnnotationLoadBalancerAvailabilityZone     = "loadbalancer.openstack.org/availability-zone"
+	// ServiceAnnotationLoadBalancerVIPSubnetID and ServiceAnnotationLoadBalancerVIPSubnetName pin the subnet the VIP is
+	// allocated on, the subnet must belong to the VIP network. The VIP subnet can't be changed once the load balancer is created.
+	ServiceAnnotationLoadBalancerVIPSubnetID   = "loadbalancer.openstack.org/vip-subnet-id"
+	ServiceAnnotationLoadBalancerVIPSubnetName = "loadbalancer.openstack.org/vip-subnet-name"
+	// ServiceAnnotationLoadBalancerDrainTimeout is the number of seconds a removed member is drained for before it is
+	// deleted, the member stops receiving new connections while the existing connections are allowed to finish.
+	ServiceAnnotationLoadBalancerDrainTimeout = "loadbalancer.openstack.org/drain-timeout"
+	// ServiceAnnotationLoadBalancerCreateTimeout is the number of seconds the loadbalancer of the Service is waited for
+	// to become ACTIVE after it is created or updated, instead of the default backoff.
+	ServiceAnnotationLoadBalancerCreateTimeout = "loadbalancer.openstack.org/create-timeout"
+	// ServiceAnnotationLoadBalancerLBMethod is the load balancing algorithm of the pools, if not specified, use 'lb-method' config.
+	ServiceAnnotationLoadBalancerLBMethod = "loadbalancer.openstack.org/lb-method"
+	// ServiceAnnotationLoadBalancerFlavor is the name of the Octavia flavor, ignored if the flavor ID is specified.
+	ServiceAnnotationLoadBalancerFlavor = "loadbalancer.openstack.org/flavor"
+	// ServiceAnnotationLoadBalancerProvider is the Octavia provider of the load balancer, if not specified, use 'lb-provider' config.
+	// The provider and the flavor can't be changed once the load balancer is created.
+	ServiceAnnotationLoadBalancerProvider = "loadbalancer.openstack.org/provider"
 	// ServiceAnnotationLoadBalancerEnableHealthMonitor defines whether or not to create health monitor for the load balancer
 	// pool, if not specified, use 'create-monitor' config. The health monitor can be created or deleted dynamically.
 	ServiceAnnotationLoadBalancerEnableHealthMonitor = "loadbalancer.openstack.org/enable-health-monitor"
+	// ServiceAnnotationLoadBalancerHealthMonitorType is the type of the health monitor, defaults to the protocol of the port.
+	// The HTTP method, URL path and expected codes annotations are only valid for the HTTP and HTTPS types.
+	ServiceAnnotationLoadBalancerHealthMonitorType          = "loadbalancer.openstack.org/health-monitor-type"
+	ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod    = "loadbalancer.openstack.org/health-monitor-http-method"
+	ServiceAnnotationLoadBalancerHealthMonitorURLPath       = "loadbalancer.openstack.org/health-monitor-url-path"
+	ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes = "loadbalancer.openstack.org/health-monitor-expected-codes"
+	// ServiceAnnotationLoadBalancerHealthMonitorDelay, ServiceAnnotationLoadBalancerHealthMonitorTimeout and
+	// ServiceAnnotationLoadBalancerHealthMonitorMaxRetries override the monitor-delay, monitor-timeout and
+	// monitor-max-retries config of the health monitor, the delay and the timeout are in seconds.
+	ServiceAnnotationLoadBalancerHealthMonitorDelay      = "loadbalancer.openstack.org/health-monitor-delay"
+	ServiceAnnotationLoadBalancerHealthMonitorTimeout    = "loadbalancer.openstack.org/health-monitor-timeout"
+	ServiceAnnotationLoadBalancerHealthMonitorMaxRetries = "loadbalancer.openstack.org/health-monitor-max-retries"
+	// ServiceAnnotationLoadBalancerHealthMonitorInitialDelay is the number of seconds the members added to the pools
+	// are kept administratively down for, so that the health monitor doesn't mark slow-starting backends down.
+	ServiceAnnotationLoadBalancerHealthMonitorInitialDelay = "loadbalancer.openstack.org/health-monitor-initial-delay"
+	// ServiceAnnotationLoadBalancerShared is the name of the load balancer shared by the Services having the
+	// same value, each Service gets its own listeners and pools on the shared load balancer.
+	ServiceAnnotationLoadBalancerShared = "loadbalancer.openstack.org/shared-load-balancer"
+	// ServiceAnnotationLoadBalancerFloatingIP is the ID or the address of an existing floating IP to associate
+	// with the load balancer VIP port. The floating IP is not released when the Service is deleted.
+	ServiceAnnotationLoadBalancerFloatingIP = "loadbalancer.openstack.org/floating-ip"
+	// ServiceAnnotationLoadBalancerDefaultTLSContainerRef is the Barbican reference of the certificate container, or of
+	// the PKCS12 secret, of the TERMINATED_HTTPS listeners created for the TCP ports of the Service.
+	ServiceAnnotationLoadBalancerDefaultTLSContainerRef = "loadbalancer.openstack.org/default-tls-container-ref"
+	// ServiceAnnotationLoadBalancerSNIContainerRefs is the comma separated list of the Barbican references of the
+	// certificates served by the TERMINATED_HTTPS listeners with SNI.
+	ServiceAnnotationLoadBalancerSNIContainerRefs = "loadbalancer.openstack.org/sni-container-refs"
+	// ServiceAnnotationLoadBalancerKeepOnDelete retains the Octavia load balancer, with its listeners, pools and
+	// floating IP, when the Service is deleted so that another Service can adopt it. Default: false.
+	ServiceAnnotationLoadBalancerKeepOnDelete = "loadbalancer.openstack.org/keep-on-delete"
+	// ServiceAnnotationLoadBalancerAdopt is the ID of a load balancer retained on the deletion of a Service of the
+	// cluster, which the Service adopts instead of creating a new load balancer.
+	ServiceAnnotationLoadBalancerAdopt = "loadbalancer.openstack.org/adopt-load-balancer"
+
+	// NodeAnnotationLoadBalancerMemberWeight is the weight, from 1 to 256, of the pool members of the node
+	NodeAnnotationLoadBalancerMemberWeight = "loadbalancer.openstack.org/member-weight"
+	// defaultMemberWeight is the weight of the pool members of the nodes without a valid weight annotation
+	defaultMemberWeight = 1
+	// maxMemberWeight is the maximum weight of an Octavia member
+	maxMemberWeight = 256
+	// maxHealthMonitorRetries is the maximum number of retries of an Octavia health monitor
+	maxHealthMonitorRetries = 10
+	// memberWarmupRetryDelay is the delay before bringing up the warmed up members again after a failure
+	memberWarmupRetryDelay = 10 * time.Second
+	// memberDrainRetryDelay is the delay before deleting the drained members again after a failure
+	memberDrainRetryDelay = 10 * time.Second
+
+	// EventReasonInvalidTLSContainerRef is the reason of the events of the Services whose TLS container references
+	// can't be used
+	EventReasonInvalidTLSContainerRef = "InvalidTLSContainerRef"
+	// EventReasonLoadBalancerUnhealthy is the reason of the events of the Services whose load balancer, or one of
+	// its listeners, pools or members, goes ERROR or DEGRADED
+	EventReasonLoadBalancerUnhealthy = "LoadBalancerUnhealthy"
+	// EventReasonLoadBalancerHealthy is the reason of the events of the Services whose load balancer recovered
+	EventReasonLoadBalancerHealthy = "LoadBalancerHealthy"
+	// EventReasonNamespaceNotAllowed is the reason of the events of the Services whose namespace isn't allowed to
+	// have load balancers
+	EventReasonNamespaceNotAllowed = "LoadBalancerNamespaceNotAllowed"
 )
 
 // LbaasV2 is a LoadBalancer implementation for Neutron LBaaS v2 API
@@ -118,18 +214,39 @@ type serviceConfig struct {
 	lbNetworkID          string
 	lbSubnetID           string
 	lbMemberSubnetID     string
+	lbVIPSubnetID        string
 	lbPublicNetworkID    string
 	lbPublicSubnetID     string
 	keepClientIP         bool
-	enableProxyProtocol  bool
+	insertHeaders        map[string]string
+	proxyProtocol        v2pools.Protocol
 	timeoutClientData    int
 	timeoutMemberConnect int
 	timeoutMemberData    int
 	timeoutTCPInspect    int
 	allowedCIDR          []string
 	enableMonitor        bool
+	drainTimeout         time.Duration
+	createTimeout        time.Duration
+	lbMethod             v2pools.LBMethod
+	monitorType          string
+	monitorHTTPMethod    string
+	monitorURLPath       string
+	monitorExpectedCodes string
+	monitorDelay         int
+	monitorTimeout       int
+	monitorMaxRetries    int
+	memberInitialDelay   time.Duration
 	flavorID             string
+	lbProvider           string
 	availabilityZone     string
+	sharedLBName         string
+	listenerNamePrefix   string
+	tlsContainerRef      string
+	sniContainerRefs     []string
+	// serviceTag is the tag of the load balancer of the Service, empty when
+	// the load balancer is shared or Octavia doesn't support tags
+	serviceTag string
 }
 
 type listenerKey struct {
@@ -170,6 +287,62 @@ func getLoadBalancers(client *gophercloud.ServiceClient, opts loadbalancers.List
 	return allLoadbalancers, nil
 }
 
+// serviceTag returns the tag of the load balancer of the Service, which is
+// deterministic unlike the load balancer ID
+func serviceTag(service *corev1.Service) string {
+	return fmt.Sprintf("kube_service_uid_%s", service.UID)
+}
+
+// serviceLoadBalancerTag returns the tag of the load balancer of the Service, empty when the load balancer is
+// shared or Octavia doesn't support the tags
+func (lbaas *LbaasV2) serviceLoadBalancerTag(service *corev1.Service) string {
+	if !lbaas.opts.UseOctavia || service.UID == "" || getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerShared, "") != "" {
+		return ""
+	}
+	if !openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTags) {
+		return ""
+	}
+	return serviceTag(service)
+}
+
+// getLoadbalancerByTag gets the load balancer tagged with the given tag, whatever its status except when it's being
+// deleted.
+func getLoadbalancerByTag(client *gophercloud.ServiceClient, tag string) (*loadbalancers.LoadBalancer, error) {
+	allLoadbalancers, err := getLoadBalancers(client, loadbalancers.ListOpts{Tags: []string{tag}})
+	if err != nil {
+		return nil, err
+	}
+
+	var validLBs []loadbalancers.LoadBalancer
+	for _, lb := range allLoadbalancers {
+		if lb.ProvisioningStatus != "DELETED" && lb.ProvisioningStatus != "PENDING_DELETE" {
+			validLBs = append(validLBs, lb)
+		}
+	}
+
+	if len(validLBs) > 1 {
+		return nil, ErrMultipleResults
+	}
+	if len(validLBs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &validLBs[0], nil
+}
+
+// getServiceLoadbalancer gets the load balancer of the Service by its tag when it's tagged, else by its name, so that
+// the load balancer created by an interrupted reconcile is adopted rather than created again.
+func getServiceLoadbalancer(client *gophercloud.ServiceClient, svcConf *serviceConfig, name string, legacyName string) (*loadbalancers.LoadBalancer, error) {
+	if svcConf.serviceTag != "" {
+		loadbalancer, err := getLoadbalancerByTag(client, svcConf.serviceTag)
+		if err != ErrNotFound {
+			return loadbalancer, err
+		}
+	}
+	// The load balancers created before the tags were set are found by name
+	return getLoadbalancerByName(client, name, legacyName)
+}
+
 // getLoadbalancerByName get the load balancer which is in valid status by the given name/legacy name.
 func getLoadbalancerByName(client *gophercloud.ServiceClient, name string, legacyName string) (*loadbalancers.LoadBalancer, error) {
 	var validLBs []loadbalancers.LoadBalancer
@@ -239,6 +412,36 @@ func getListenersByLoadBalancerID(client *gophercloud.ServiceClient, id string)
 	return existingListeners, nil
 }
 
+// filterServiceListeners splits the listeners of a shared load balancer into the
+// listeners owned by a Service, whose name starts with the given prefix, and the others.
+func filterServiceListeners(allListeners []listeners.Listener, prefix string) ([]listeners.Listener, []listeners.Listener) {
+	var owned, others []listeners.Listener
+	for _, l := range allListeners {
+		if strings.HasPrefix(l.Name, prefix) {
+			owned = append(owned, l)
+		} else {
+			others = append(others, l)
+		}
+	}
+	return owned, others
+}
+
+// checkListenerConflicts returns an error when a port of the Service is already
+// used by a listener of another Service sharing the load balancer.
+func checkListenerConflicts(service *corev1.Service, otherListeners []listeners.Listener, svcConf *serviceConfig) error {
+	for _, port := range service.Spec.Ports {
+		for _, l := range otherListeners {
+			// Listeners of different transport protocols can use the same port
+			if l.ProtocolPort != int(port.Port) || listenerTransport(listeners.Protocol(l.Protocol)) != listenerTransport(toListenersProtocol(port.Protocol)) {
+				continue
+			}
+			return fmt.Errorf("port %d/%s of Service %s/%s is already used by listener %s of the shared load balancer %s",
+				port.Port, port.Protocol, service.Namespace, service.Name, l.Name, svcConf.sharedLBName)
+		}
+	}
+	return nil
+}
+
 // get listener for a port or nil if does not exist
 func getListenerForPort(existingListeners []listeners.Listener, port corev1.ServicePort) *listeners.Listener {
 	for _, l := range existingListeners {
@@ -324,13 +527,40 @@ func waitLoadbalancerActiveProvisioningStatus(client *gophercloud.ServiceClient,
 	}
 
 	var provisioningStatus string
-	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+	err := wait.ExponentialBackoff(backoff, loadbalancerActive(client, loadbalancerID, &provisioningStatus))
+
+	if err == wait.ErrWaitTimeout {
+		err = fmt.Errorf("loadbalancer failed to go into ACTIVE provisioning status within allotted time")
+	}
+	return provisioningStatus, err
+}
+
+// waitLoadbalancerActiveTimeout waits for the loadbalancer to be ACTIVE for
+// the given timeout, or with the default backoff when the timeout is not set.
+func waitLoadbalancerActiveTimeout(client *gophercloud.ServiceClient, loadbalancerID string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return waitLoadbalancerActiveProvisioningStatus(client, loadbalancerID)
+	}
+
+	var provisioningStatus string
+	err := wait.PollImmediate(loadbalancerActivePollInterval, timeout, loadbalancerActive(client, loadbalancerID, &provisioningStatus))
+
+	if err == wait.ErrWaitTimeout {
+		err = fmt.Errorf("loadbalancer failed to go into ACTIVE provisioning status within %v", timeout)
+	}
+	return provisioningStatus, err
+}
+
+// loadbalancerActive returns the condition of the loadbalancer being ACTIVE,
+// the last provisioning status of the loadbalancer is stored in status.
+func loadbalancerActive(client *gophercloud.ServiceClient, loadbalancerID string, status *string) wait.ConditionFunc {
+	return func() (bool, error) {
 		mc := metrics.NewMetricContext("loadbalancer", "get")
 		loadbalancer, err := loadbalancers.Get(client, loadbalancerID).Extract()
 		if mc.ObserveRequest(err) != nil {
 			return false, err
 		}
-		provisioningStatus = loadbalancer.ProvisioningStatus
+		*status = loadbalancer.ProvisioningStatus
 		if loadbalancer.ProvisioningStatus == activeStatus {
 			return true, nil
 		} else if loadbalancer.ProvisioningStatus == errorStatus {
@@ -338,13 +568,13 @@ func waitLoadbalancerActiveProvisioningStatus(client *gophercloud.ServiceClient,
 		} else {
 			return false, nil
 		}
-
-	})
-
-	if err == wait.ErrWaitTimeout {
-		err = fmt.Errorf("loadbalancer failed to go into ACTIVE provisioning status within allotted time")
 	}
-	return provisioningStatus, err
+}
+
+// isPendingStatus returns whether the provisioning status is one of a
+// loadbalancer still being provisioned.
+func isPendingStatus(provisioningStatus string) bool {
+	return strings.HasPrefix(provisioningStatus, "PENDING_")
 }
 
 func waitLoadbalancerDeleted(client *gophercloud.ServiceClient, loadbalancerID string) error {
@@ -392,6 +622,29 @@ func toListenersProtocol(protocol corev1.Protocol) listeners.Protocol {
 	}
 }
 
+// getListenerProtocol returns the protocol of the listener for the Service port, only TCP ports are
+// switched to TERMINATED_HTTPS for the TLS termination or to HTTP for the X-Forwarded-For header so
+// that the other ports of mixed-protocol Services are kept.
+func getListenerProtocol(port corev1.ServicePort, svcConf *serviceConfig) listeners.Protocol {
+	if svcConf.tlsContainerRef != "" && port.Protocol == corev1.ProtocolTCP {
+		return listeners.ProtocolTerminatedHTTPS
+	}
+	if svcConf.keepClientIP && port.Protocol == corev1.ProtocolTCP {
+		return listeners.ProtocolHTTP
+	}
+	return toListenersProtocol(port.Protocol)
+}
+
+// listenerTransport returns the transport protocol of the listener protocol.
+func listenerTransport(protocol listeners.Protocol) listeners.Protocol {
+	switch protocol {
+	case listeners.ProtocolUDP, listeners.Protocol(corev1.ProtocolSCTP):
+		return protocol
+	default:
+		return listeners.ProtocolTCP
+	}
+}
+
 func createNodeSecurityGroup(client *gophercloud.ServiceClient, nodeSecurityGroupID string, port int, protocol corev1.Protocol, lbSecGroup string) error {
 	v4NodeSecGroupRuleCreateOpts := rules.CreateOpts{
 		Direction:     rules.DirIngress,
@@ -449,7 +702,7 @@ func (lbaas *LbaasV2) createLoadBalancer(service *corev1.Service, name, clusterN
 		} else if lbaas.opts.NetworkID != "" {
 			createOpts.VipNetworkID = lbaas.opts.NetworkID
 		} else {
-			klog.V(4).Infof("network-id parameter not passed, it will be inferred from subnet-id")
+			klog.V(4).InfoS("network-id parameter not passed, it will be inferred from subnet-id")
 		}
 	}
 
@@ -476,7 +729,19 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(service *corev1.Service, name, c
 	createOpts := loadbalancers.CreateOpts{
 		Name:        name,
 		Description: fmt.Sprintf("Kubernetes external service %s/%s from cluster %s", service.Namespace, service.Name, clusterName),
-		Provider:    lbaas.opts.LBProvider,
+		Provider:    svcConf.lbProvider,
+	}
+	if svcConf.sharedLBName != "" {
+		createOpts.Description = fmt.Sprintf("Kubernetes shared load balancer %s from cluster %s", svcConf.sharedLBName, clusterName)
+	}
+
+	if svcConf.lbProvider != lbaas.opts.LBProvider {
+		if _, err := openstackutil.GetProvider(lbaas.lb, svcConf.lbProvider); err != nil {
+			if err == openstackutil.ErrNotFound {
+				return nil, fmt.Errorf("the Octavia provider %s is not enabled", svcConf.lbProvider)
+			}
+			return nil, fmt.Errorf("failed to get the Octavia provider %s: %v", svcConf.lbProvider, err)
+		}
 	}
 
 	if svcConf.flavorID != "" {
@@ -487,13 +752,21 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(service *corev1.Service, name, c
 		createOpts.AvailabilityZone = svcConf.availabilityZone
 	}
 
+	// The tag is set by the creation, so that the load balancer is found by its tag even if the reconcile is
+	// interrupted before the load balancer is ACTIVE
+	if svcConf.serviceTag != "" {
+		createOpts.Tags = []string{svcConf.serviceTag}
+	}
+
 	vipPort := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerPortID, "")
 	lbClass := lbaas.opts.LBClasses[svcConf.configClassName]
 
 	if vipPort != "" {
 		createOpts.VipPortID = vipPort
 	} else {
-		if lbClass != nil && lbClass.SubnetID != "" {
+		if svcConf.lbVIPSubnetID != "" {
+			createOpts.VipSubnetID = svcConf.lbVIPSubnetID
+		} else if lbClass != nil && lbClass.SubnetID != "" {
 			createOpts.VipSubnetID = lbClass.SubnetID
 		} else {
 			createOpts.VipSubnetID = svcConf.lbSubnetID
@@ -504,7 +777,7 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(service *corev1.Service, name, c
 		} else if svcConf.lbNetworkID != "" {
 			createOpts.VipNetworkID = svcConf.lbNetworkID
 		} else {
-			klog.V(4).Infof("network-id parameter not passed, it will be inferred from subnet-id")
+			klog.V(4).InfoS("network-id parameter not passed, it will be inferred from subnet-id")
 		}
 	}
 
@@ -520,8 +793,8 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(service *corev1.Service, name, c
 		return nil, fmt.Errorf("error creating loadbalancer %v: %v", createOpts, err)
 	}
 
-	// In case subnet ID is not configured
-	if lbaas.opts.SubnetID == "" {
+	// In case subnet ID is not configured, a pinned VIP subnet is specific to the Service
+	if lbaas.opts.SubnetID == "" && svcConf.lbVIPSubnetID == "" {
 		lbaas.opts.SubnetID = loadbalancer.VipSubnetID
 		svcConf.lbMemberSubnetID = loadbalancer.VipSubnetID
 	}
@@ -529,11 +802,43 @@ func (lbaas *LbaasV2) createOctaviaLoadBalancer(service *corev1.Service, name, c
 	return loadbalancer, nil
 }
 
+// getVIPSubnetID returns the ID of the VIP subnet set by the annotations of the Service, the subnet must belong to the
+// VIP network if there is one.
+func (lbaas *LbaasV2) getVIPSubnetID(service *corev1.Service, svcConf *serviceConfig) (string, error) {
+	var subnet *subnets.Subnet
+	if subnetID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerVIPSubnetID, ""); subnetID != "" {
+		mc := metrics.NewMetricContext("subnet", "get")
+		s, err := subnets.Get(lbaas.network, subnetID).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return "", fmt.Errorf("failed to find VIP subnet %q: %v", subnetID, err)
+		}
+		subnet = s
+	} else if subnetName := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerVIPSubnetName, ""); subnetName != "" {
+		s, err := lbaas.getSubnet(subnetName)
+		if err != nil {
+			return "", fmt.Errorf("failed to find VIP subnet %q: %v", subnetName, err)
+		}
+		subnet = s
+	} else {
+		return "", nil
+	}
+
+	networkID := svcConf.lbNetworkID
+	if lbClass := lbaas.opts.LBClasses[svcConf.configClassName]; lbClass != nil && lbClass.NetworkID != "" {
+		networkID = lbClass.NetworkID
+	}
+	if networkID != "" && subnet.NetworkID != networkID {
+		return "", fmt.Errorf("VIP subnet %q doesn't belong to the network %q", subnet.ID, networkID)
+	}
+
+	return subnet.ID, nil
+}
+
 // GetLoadBalancer returns whether the specified load balancer exists and its status
 func (lbaas *LbaasV2) GetLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service) (*corev1.LoadBalancerStatus, bool, error) {
-	name := lbaas.GetLoadBalancerName(ctx, clusterName, service)
-	legacyName := lbaas.GetLoadBalancerLegacyName(ctx, clusterName, service)
-	loadbalancer, err := getLoadbalancerByName(lbaas.lb, name, legacyName)
+	name, legacyName := lbaas.getLoadBalancerNames(ctx, clusterName, service)
+	svcConf := &serviceConfig{serviceTag: lbaas.serviceLoadBalancerTag(service)}
+	loadbalancer, err := getServiceLoadbalancer(lbaas.lb, svcConf, name, legacyName)
 	if err == ErrNotFound {
 		return nil, false, nil
 	}
@@ -570,6 +875,27 @@ func (lbaas *LbaasV2) GetLoadBalancerLegacyName(ctx context.Context, clusterName
 	return cloudprovider.DefaultLoadBalancerName(service)
 }
 
+// getLoadBalancerNames returns the name and the legacy name of the load balancer of the
+// Service, a shared load balancer has no legacy name.
+func (lbaas *LbaasV2) getLoadBalancerNames(ctx context.Context, clusterName string, service *corev1.Service) (string, string) {
+	if shared := service.Annotations[ServiceAnnotationLoadBalancerShared]; lbaas.opts.UseOctavia && shared != "" {
+		return sharedLoadBalancerName(clusterName, shared), ""
+	}
+	return lbaas.GetLoadBalancerName(ctx, clusterName, service), lbaas.GetLoadBalancerLegacyName(ctx, clusterName, service)
+}
+
+// sharedLoadBalancerName returns the name of the load balancer shared by the Services
+// with the given shared-load-balancer annotation.
+func sharedLoadBalancerName(clusterName, shared string) string {
+	return cutString(fmt.Sprintf("kube_shared_%s_%s", clusterName, shared))
+}
+
+// serviceListenerPrefix returns the prefix of the names of the listeners owned by
+// the Service on a shared load balancer.
+func serviceListenerPrefix(clusterName string, service *corev1.Service) string {
+	return fmt.Sprintf("kube_service_%s_%s_%s_", clusterName, service.Namespace, service.Name)
+}
+
 // cutString makes sure the string length doesn't exceed 255, which is usually the maximum string length in OpenStack.
 func cutString(original string) string {
 	ret := original
@@ -610,11 +936,11 @@ func getStringFromServiceAnnotation(service *corev1.Service, annotationKey strin
 		//if there is an annotation for this setting, set the "setting" var to it
 		// annotationValue can be empty, it is working as designed
 		// it makes possible for instance provisioning loadbalancer without floatingip
-		klog.V(4).Infof("Found a Service Annotation: %v = %v", annotationKey, annotationValue)
+		klog.V(4).InfoS("Found a Service Annotation", "service", klog.KObj(service), "annotation", annotationKey, "value", annotationValue)
 		return annotationValue
 	}
 	//if there is no annotation, set "settings" var to the value from cloud config
-	klog.V(4).Infof("Could not find a Service Annotation; falling back on cloud-config setting: %v = %v", annotationKey, defaultSetting)
+	klog.V(4).InfoS("Could not find a Service Annotation; falling back on cloud-config setting", "service", klog.KObj(service), "annotation", annotationKey, "default", defaultSetting)
 	return defaultSetting
 }
 
@@ -627,16 +953,35 @@ func getIntFromServiceAnnotation(service *corev1.Service, annotationKey string,
 			return defaultSetting
 		}
 
-		klog.V(4).Infof("Found a Service Annotation: %v = %v", annotationKey, annotationValue)
+		klog.V(4).InfoS("Found a Service Annotation", "service", klog.KObj(service), "annotation", annotationKey, "value", annotationValue)
 		return returnValue
 	}
-	klog.V(4).Infof("Could not find a Service Annotation; falling back to default setting: %v = %v", annotationKey, defaultSetting)
+	klog.V(4).InfoS("Could not find a Service Annotation; falling back to default setting", "service", klog.KObj(service), "annotation", annotationKey, "default", defaultSetting)
 	return defaultSetting
 }
 
+// getMinIntFromServiceAnnotation searches a given v1.Service for a specific annotationKey and either returns the
+// annotation's integer value or a specified defaultSetting. Unlike getIntFromServiceAnnotation, a value which is
+// not an integer or is lower than minValue is an error.
+func getMinIntFromServiceAnnotation(service *corev1.Service, annotationKey string, defaultSetting int, minValue int) (int, error) {
+	annotationValue, ok := service.Annotations[annotationKey]
+	if !ok {
+		klog.V(4).InfoS("Could not find a Service Annotation; falling back to default setting", "service", klog.KObj(service), "annotation", annotationKey, "default", defaultSetting)
+		return defaultSetting, nil
+	}
+
+	returnValue, err := strconv.Atoi(annotationValue)
+	if err != nil || returnValue < minValue {
+		return 0, fmt.Errorf("invalid %s annotation: %q, specify an integer greater than or equal to %d", annotationKey, annotationValue, minValue)
+	}
+
+	klog.V(4).InfoS("Found a Service Annotation", "service", klog.KObj(service), "annotation", annotationKey, "value", returnValue)
+	return returnValue, nil
+}
+
 //getBoolFromServiceAnnotation searches a given v1.Service for a specific annotationKey and either returns the annotation's boolean value or a specified defaultSetting
 func getBoolFromServiceAnnotation(service *corev1.Service, annotationKey string, defaultSetting bool) (bool, error) {
-	klog.V(4).Infof("getBoolFromServiceAnnotation(%v, %v, %v)", service, annotationKey, defaultSetting)
+	klog.V(4).InfoS("getBoolFromServiceAnnotation() called", "service", klog.KObj(service), "annotation", annotationKey, "default", defaultSetting)
 	if annotationValue, ok := service.Annotations[annotationKey]; ok {
 		returnValue := false
 		switch annotationValue {
@@ -648,13 +993,121 @@ func getBoolFromServiceAnnotation(service *corev1.Service, annotationKey string,
 			return returnValue, fmt.Errorf("unknown %s annotation: %v, specify \"true\" or \"false\" ", annotationKey, annotationValue)
 		}
 
-		klog.V(4).Infof("Found a Service Annotation: %v = %v", annotationKey, returnValue)
+		klog.V(4).InfoS("Found a Service Annotation", "service", klog.KObj(service), "annotation", annotationKey, "value", returnValue)
 		return returnValue, nil
 	}
-	klog.V(4).Infof("Could not find a Service Annotation; falling back to default setting: %v = %v", annotationKey, defaultSetting)
+	klog.V(4).InfoS("Could not find a Service Annotation; falling back to default setting", "service", klog.KObj(service), "annotation", annotationKey, "default", defaultSetting)
 	return defaultSetting, nil
 }
 
+// getInsertHeadersFromServiceAnnotation returns the headers inserted by the HTTP listeners of the Service. As the
+// listeners are only switched to HTTP by the x-forwarded-for annotation, the other headers require it.
+func getInsertHeadersFromServiceAnnotation(service *corev1.Service, keepClientIP bool) (map[string]string, error) {
+	if !keepClientIP {
+		for _, annotation := range []string{ServiceAnnotationLoadBalancerXForwardedPort, ServiceAnnotationLoadBalancerXForwardedProto} {
+			enabled, err := getBoolFromServiceAnnotation(service, annotation, false)
+			if err != nil {
+				return nil, err
+			}
+			if enabled {
+				return nil, fmt.Errorf("annotation %s requires annotation %s, the headers can't be inserted by TCP listeners", annotation, ServiceAnnotationLoadBalancerXForwardedFor)
+			}
+		}
+		return nil, nil
+	}
+
+	headers := map[string]string{annotationXForwardedFor: "true"}
+	for _, h := range []struct{ header, annotation string }{
+		{annotationXForwardedPort, ServiceAnnotationLoadBalancerXForwardedPort},
+		{annotationXForwardedProto, ServiceAnnotationLoadBalancerXForwardedProto},
+	} {
+		enabled, err := getBoolFromServiceAnnotation(service, h.annotation, false)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			headers[h.header] = "true"
+		}
+	}
+	return headers, nil
+}
+
+// reconcileInsertHeaders returns the insert headers of a listener updated with the headers managed by the
+// annotations, and whether they changed. The other headers of the listener are kept.
+func reconcileInsertHeaders(current, desired map[string]string) (map[string]string, bool) {
+	headers := make(map[string]string, len(current))
+	for k, v := range current {
+		headers[k] = v
+	}
+
+	changed := false
+	for _, header := range []string{annotationXForwardedFor, annotationXForwardedPort, annotationXForwardedProto} {
+		value, ok := desired[header]
+		if ok && headers[header] != value {
+			headers[header] = value
+			changed = true
+		} else if _, exists := headers[header]; !ok && exists {
+			delete(headers, header)
+			changed = true
+		}
+	}
+	return headers, changed
+}
+
+// getInternalFromServiceAnnotation returns whether the load balancer of the Service is internal, the
+// loadbalancer.openstack.org/internal annotation takes precedence over the legacy one.
+func getInternalFromServiceAnnotation(service *corev1.Service, defaultSetting bool) (bool, error) {
+	internal, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerInternal, defaultSetting)
+	if err != nil {
+		return false, err
+	}
+	return getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerOpenStackInternal, internal)
+}
+
+// getProxyProtocolFromServiceAnnotation returns the pool protocol selected by the proxy-protocol
+// annotation of the Service, or an empty protocol when the PROXY protocol is not enabled.
+func getProxyProtocolFromServiceAnnotation(service *corev1.Service) (v2pools.Protocol, error) {
+	switch value := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProxyEnabled, "false"); value {
+	case "false":
+		return "", nil
+	case "true", "v1":
+		return v2pools.ProtocolPROXY, nil
+	case "v2":
+		return poolProtocolPROXYV2, nil
+	default:
+		return "", fmt.Errorf("unknown %s annotation: %v, specify \"true\", \"v1\", \"v2\" or \"false\"", ServiceAnnotationLoadBalancerProxyEnabled, value)
+	}
+}
+
+// isProxyProtocol returns true if the given pool protocol is a version of the PROXY protocol.
+func isProxyProtocol(protocol string) bool {
+	return protocol == string(v2pools.ProtocolPROXY) || protocol == string(poolProtocolPROXYV2)
+}
+
+// providerLBMethods are the load balancing algorithms supported by the known Octavia providers.
+var providerLBMethods = map[string]sets.String{
+	"amphora": sets.NewString("ROUND_ROBIN", "LEAST_CONNECTIONS", "SOURCE_IP"),
+	"octavia": sets.NewString("ROUND_ROBIN", "LEAST_CONNECTIONS", "SOURCE_IP"),
+	"ovn":     sets.NewString("SOURCE_IP_PORT"),
+}
+
+// getLBMethodFromServiceAnnotation returns the load balancing algorithm of the Service, it must be supported by the
+// Octavia provider if the provider is known.
+func getLBMethodFromServiceAnnotation(service *corev1.Service, defaultLBMethod, provider string) (v2pools.LBMethod, error) {
+	lbMethod := strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerLBMethod, defaultLBMethod))
+	switch lbMethod {
+	case "ROUND_ROBIN", "LEAST_CONNECTIONS", "SOURCE_IP", "SOURCE_IP_PORT":
+	default:
+		return "", fmt.Errorf("unknown %s annotation: %v", ServiceAnnotationLoadBalancerLBMethod, lbMethod)
+	}
+
+	if methods, ok := providerLBMethods[provider]; ok && !methods.Has(lbMethod) {
+		return "", fmt.Errorf("load balancing algorithm %s is not supported by the Octavia provider %s", lbMethod, provider)
+	}
+
+	return v2pools.LBMethod(lbMethod), nil
+}
+
 // getSubnetIDForLB returns subnet-id for a specific node
 func getSubnetIDForLB(compute *gophercloud.ServiceClient, node corev1.Node) (string, error) {
 	ipAddress, err := nodeAddressForLB(&node)
@@ -698,6 +1151,12 @@ func getPorts(network *gophercloud.ServiceClient, listOpts neutronports.ListOpts
 	return allPorts, nil
 }
 
+// getAttachedPorts gets all the ports attached to a server, filtered by Neutron
+// and following the pagination of the port list.
+func getAttachedPorts(network *gophercloud.ServiceClient, serverID string) ([]neutronports.Port, error) {
+	return getPorts(network, neutronports.ListOpts{DeviceID: serverID})
+}
+
 // applyNodeSecurityGroupIDForLB associates the security group with all the ports on the nodes.
 func applyNodeSecurityGroupIDForLB(compute *gophercloud.ServiceClient, network *gophercloud.ServiceClient, nodes []*corev1.Node, sg string) error {
 	for _, node := range nodes {
@@ -707,25 +1166,14 @@ func applyNodeSecurityGroupIDForLB(compute *gophercloud.ServiceClient, network *
 			return err
 		}
 
-		listOpts := neutronports.ListOpts{DeviceID: srv.ID}
-		allPorts, err := getPorts(network, listOpts)
+		allPorts, err := getAttachedPorts(network, srv.ID)
 		if err != nil {
 			return err
 		}
 
 		for _, port := range allPorts {
-			newSGs := append(port.SecurityGroups, sg)
-			updateOpts := neutronports.UpdateOpts{SecurityGroups: &newSGs}
-			mc := metrics.NewMetricContext("port", "update")
-			res := neutronports.Update(network, port.ID, updateOpts)
-			if mc.ObserveRequest(res.Err) != nil {
-				return fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
-			}
-			// Add the security group ID as a tag to the port in order to find all these ports when removing the security group.
-			mc = metrics.NewMetricContext("port_tag", "add")
-			err := neutrontags.Add(network, "ports", port.ID, sg).ExtractErr()
-			if mc.ObserveRequest(err) != nil {
-				return fmt.Errorf("failed to add tag %s to port %s: %v", sg, port.ID, res.Err)
+			if err := addPortSecurityGroup(network, port, sg); err != nil {
+				return err
 			}
 		}
 	}
@@ -733,6 +1181,48 @@ func applyNodeSecurityGroupIDForLB(compute *gophercloud.ServiceClient, network *
 	return nil
 }
 
+// addPortSecurityGroup associates the security group with the port and tags the port with the security group ID.
+func addPortSecurityGroup(network *gophercloud.ServiceClient, port neutronports.Port, sg string) error {
+	newSGs := append(port.SecurityGroups, sg)
+	updateOpts := neutronports.UpdateOpts{SecurityGroups: &newSGs}
+	mc := metrics.NewMetricContext("port", "update")
+	res := neutronports.Update(network, port.ID, updateOpts)
+	if mc.ObserveRequest(res.Err) != nil {
+		return fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
+	}
+	// Add the security group ID as a tag to the port in order to find all these ports when removing the security group.
+	mc = metrics.NewMetricContext("port_tag", "add")
+	err := neutrontags.Add(network, "ports", port.ID, sg).ExtractErr()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("failed to add tag %s to port %s: %v", sg, port.ID, err)
+	}
+
+	return nil
+}
+
+// removePortSecurityGroup disassociates the security group from the port and removes the security group ID tag.
+func removePortSecurityGroup(network *gophercloud.ServiceClient, port neutronports.Port, sg string) error {
+	existingSGs := sets.NewString(port.SecurityGroups...)
+	existingSGs.Delete(sg)
+
+	// Update port security groups
+	newSGs := existingSGs.List()
+	updateOpts := neutronports.UpdateOpts{SecurityGroups: &newSGs}
+	mc := metrics.NewMetricContext("port", "update")
+	res := neutronports.Update(network, port.ID, updateOpts)
+	if mc.ObserveRequest(res.Err) != nil {
+		return fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
+	}
+	// Remove the security group ID tag from the port.
+	mc = metrics.NewMetricContext("port_tag", "delete")
+	err := neutrontags.Delete(network, "ports", port.ID, sg).ExtractErr()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("failed to remove tag %s to port %s: %v", sg, port.ID, err)
+	}
+
+	return nil
+}
+
 // disassociateSecurityGroupForLB removes the given security group from the ports
 func disassociateSecurityGroupForLB(network *gophercloud.ServiceClient, sg string) error {
 	// Find all the ports that have the security group associated.
@@ -744,103 +1234,268 @@ func disassociateSecurityGroupForLB(network *gophercloud.ServiceClient, sg strin
 
 	// Disassocate security group and remove the tag.
 	for _, port := range allPorts {
-		existingSGs := sets.NewString()
-		for _, sgID := range port.SecurityGroups {
-			existingSGs.Insert(sgID)
-		}
-		existingSGs.Delete(sg)
-
-		// Update port security groups
-		newSGs := existingSGs.List()
-		updateOpts := neutronports.UpdateOpts{SecurityGroups: &newSGs}
-		mc := metrics.NewMetricContext("port", "update")
-		res := neutronports.Update(network, port.ID, updateOpts)
-		if mc.ObserveRequest(res.Err) != nil {
-			return fmt.Errorf("failed to update security group for port %s: %v", port.ID, res.Err)
-		}
-		// Remove the security group ID tag from the port.
-		mc = metrics.NewMetricContext("port_tag", "delete")
-		err := neutrontags.Delete(network, "ports", port.ID, sg).ExtractErr()
-		if mc.ObserveRequest(err) != nil {
-			return fmt.Errorf("failed to remove tag %s to port %s: %v", sg, port.ID, res.Err)
+		if err := removePortSecurityGroup(network, port, sg); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// getNodeSecurityGroupIDForLB lists node-security-groups for specific nodes
-func getNodeSecurityGroupIDForLB(compute *gophercloud.ServiceClient, network *gophercloud.ServiceClient, nodes []*corev1.Node) ([]string, error) {
-	secGroupIDs := sets.NewString()
+// ensureOctaviaSecurityGroup ensures the security group of the Service only allows the traffic from the member subnet
+// of the load balancer to the node ports of the Service, and is associated with the ports of the nodes on that subnet.
+func (lbaas *LbaasV2) ensureOctaviaSecurityGroup(clusterName string, service *corev1.Service, nodes []*corev1.Node, svcConf *serviceConfig) error {
+	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+	if svcConf.lbMemberSubnetID == "" {
+		return fmt.Errorf("no member subnet found for the security group of Service %s", serviceName)
+	}
 
-	for _, node := range nodes {
-		nodeName := types.NodeName(node.Name)
-		srv, err := getServerByName(compute, nodeName)
-		if err != nil {
-			return []string{}, err
-		}
+	mc := metrics.NewMetricContext("subnet", "get")
+	subnet, err := subnets.Get(lbaas.network, svcConf.lbMemberSubnetID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("failed to find subnet %s from openstack: %v", svcConf.lbMemberSubnetID, err)
+	}
 
-		// Get the security groups of all the ports on the worker nodes. In future, we could filter the ports by some way.
-		// case 0: node1:SG1  node2:SG1  return SG1
-		// case 1: node1:SG1  node2:SG2  return SG1,SG2
-		// case 2: node1:SG1,SG2  node2:SG3,SG4  return SG1,SG2,SG3,SG4
-		// case 3: node1:SG1,SG2  node2:SG2,SG3  return SG1,SG2,SG3
-		listOpts := neutronports.ListOpts{DeviceID: srv.ID}
-		allPorts, err := getPorts(network, listOpts)
-		if err != nil {
-			return []string{}, err
+	lbSecGroupName := getSecurityGroupName(service)
+	lbSecGroupID, err := secgroups.IDFromName(lbaas.network, lbSecGroupName)
+	if err != nil {
+		if !isSecurityGroupNotFound(err) {
+			return fmt.Errorf("error occurred finding security group: %s: %v", lbSecGroupName, err)
 		}
 
-		for _, port := range allPorts {
-			for _, sg := range port.SecurityGroups {
-				secGroupIDs.Insert(sg)
-			}
+		lbSecGroupCreateOpts := groups.CreateOpts{
+			Name:        lbSecGroupName,
+			Description: fmt.Sprintf("Security Group for %s Service LoadBalancer in cluster %s", serviceName, clusterName),
+		}
+		mc := metrics.NewMetricContext("security_group", "create")
+		lbSecGroup, err := groups.Create(lbaas.network, lbSecGroupCreateOpts).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return fmt.Errorf("failed to create Security Group for loadbalancer service %s: %v", serviceName, err)
 		}
+		lbSecGroupID = lbSecGroup.ID
+		klog.V(2).InfoS("Created security group for Service", "securityGroup", lbSecGroupID, "service", serviceName)
 	}
 
-	return secGroupIDs.List(), nil
-}
-
-// isSecurityGroupNotFound return true while 'err' is object of gophercloud.ErrResourceNotFound
-func isSecurityGroupNotFound(err error) bool {
-	errType := reflect.TypeOf(err).String()
-	errTypeSlice := strings.Split(errType, ".")
-	errTypeValue := ""
-	if len(errTypeSlice) != 0 {
-		errTypeValue = errTypeSlice[len(errTypeSlice)-1]
-	}
-	if errTypeValue == "ErrResourceNotFound" {
-		return true
+	if err := ensureSecurityGroupRules(lbaas.network, lbSecGroupID, service.Spec.Ports, subnet.CIDR); err != nil {
+		return err
 	}
 
-	return false
+	return lbaas.ensureMembersSecurityGroup(nodes, lbSecGroupID, subnet.ID)
 }
 
-// getFloatingNetworkIDForLB returns a floating-network-id for cluster.
-func getFloatingNetworkIDForLB(client *gophercloud.ServiceClient) (string, error) {
-	var floatingNetworkIds []string
+// ensureSecurityGroupRules ensures the security group has an ingress rule from cidr to the node port of each of the
+// ports. Only the rules created by the controller manager are updated, the other rules are left as is.
+func ensureSecurityGroupRules(network *gophercloud.ServiceClient, sgID string, ports []corev1.ServicePort, cidr string) error {
+	ethertype := rules.EtherType4
+	if ip, _, err := net.ParseCIDR(cidr); err != nil {
+		return fmt.Errorf("error parsing subnet CIDR %s: %v", cidr, err)
+	} else if ip.To4() == nil {
+		ethertype = rules.EtherType6
+	}
 
-	type NetworkWithExternalExt struct {
-		networks.Network
-		external.NetworkExternalExt
+	type ruleKey struct {
+		protocol string
+		port     int
+	}
+	missing := make(map[ruleKey]bool)
+	for _, port := range ports {
+		missing[ruleKey{protocol: string(toRuleProtocol(port.Protocol)), port: int(port.NodePort)}] = true
 	}
 
-	mc := metrics.NewMetricContext("network", "list")
-	err := networks.List(client, networks.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
-		var externalNetwork []NetworkWithExternalExt
-		err := networks.ExtractNetworksInto(page, &externalNetwork)
-		if err != nil {
-			return false, err
-		}
+	opts := rules.ListOpts{
+		Direction:  string(rules.DirIngress),
+		SecGroupID: sgID,
+	}
+	sgRules, err := getSecurityGroupRules(network, opts)
+	if err != nil && !cpoerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to find security group rules in %s: %v", sgID, err)
+	}
 
-		for _, externalNet := range externalNetwork {
-			if externalNet.External {
-				floatingNetworkIds = append(floatingNetworkIds, externalNet.ID)
-			}
+	for _, rule := range sgRules {
+		if rule.Description != securityGroupRuleDescription {
+			continue
+		}
+		key := ruleKey{protocol: rule.Protocol, port: rule.PortRangeMin}
+		if missing[key] && rule.PortRangeMax == rule.PortRangeMin && rule.RemoteIPPrefix == cidr && rule.EtherType == string(ethertype) {
+			delete(missing, key)
+			continue
 		}
 
-		if len(floatingNetworkIds) > 1 {
-			return false, ErrMultipleResults
+		klog.V(4).InfoS("Deleting obsolete rule of security group", "rule", rule.ID, "securityGroup", sgID)
+		mc := metrics.NewMetricContext("security_group_rule", "delete")
+		err := rules.Delete(network, rule.ID).ExtractErr()
+		if err != nil && !cpoerrors.IsNotFound(err) {
+			mc.ObserveRequest(err)
+			return fmt.Errorf("error occurred deleting security group rule: %s: %v", rule.ID, err)
+		}
+		mc.ObserveRequest(nil)
+	}
+
+	for _, port := range ports {
+		key := ruleKey{protocol: string(toRuleProtocol(port.Protocol)), port: int(port.NodePort)}
+		if !missing[key] {
+			continue
+		}
+		delete(missing, key)
+
+		sgRuleCreateOpts := rules.CreateOpts{
+			Description:    securityGroupRuleDescription,
+			Direction:      rules.DirIngress,
+			PortRangeMax:   key.port,
+			PortRangeMin:   key.port,
+			Protocol:       rules.RuleProtocol(key.protocol),
+			RemoteIPPrefix: cidr,
+			SecGroupID:     sgID,
+			EtherType:      ethertype,
+		}
+		mc := metrics.NewMetricContext("security_group_rule", "create")
+		_, err := rules.Create(network, sgRuleCreateOpts).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return fmt.Errorf("failed to create rule for security group %s: %v", sgID, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureMembersSecurityGroup associates the security group with the ports of the nodes on the member subnet, and
+// disassociates it from the other ports it was associated with, e.g. the ports of the removed nodes.
+func (lbaas *LbaasV2) ensureMembersSecurityGroup(nodes []*corev1.Node, sg string, subnetID string) error {
+	memberPorts := sets.NewString()
+	for _, node := range nodes {
+		serverID, err := lbaas.getNodeServerID(node)
+		if err != nil {
+			return fmt.Errorf("failed to find the server of node %s: %v", node.Name, err)
+		}
+
+		allPorts, err := getAttachedPorts(lbaas.network, serverID)
+		if err != nil {
+			return err
+		}
+
+		for _, port := range allPorts {
+			if !portHasSubnet(port, subnetID) {
+				continue
+			}
+			memberPorts.Insert(port.ID)
+			if cpoutil.Contains(port.SecurityGroups, sg) {
+				continue
+			}
+			if err := addPortSecurityGroup(lbaas.network, port, sg); err != nil {
+				return err
+			}
+		}
+	}
+
+	associatedPorts, err := getPorts(lbaas.network, neutronports.ListOpts{TagsAny: sg})
+	if err != nil {
+		return err
+	}
+	for _, port := range associatedPorts {
+		if memberPorts.Has(port.ID) {
+			continue
+		}
+		if err := removePortSecurityGroup(lbaas.network, port, sg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getNodeServerID returns the ID of the server of the node, from its provider ID if set.
+func (lbaas *LbaasV2) getNodeServerID(node *corev1.Node) (string, error) {
+	if node.Spec.ProviderID != "" {
+		return instanceIDFromProviderID(node.Spec.ProviderID)
+	}
+
+	srv, err := getServerByName(lbaas.compute, types.NodeName(node.Name))
+	if err != nil {
+		return "", err
+	}
+	return srv.ID, nil
+}
+
+func portHasSubnet(port neutronports.Port, subnetID string) bool {
+	for _, ip := range port.FixedIPs {
+		if ip.SubnetID == subnetID {
+			return true
+		}
+	}
+	return false
+}
+
+// getNodeSecurityGroupIDForLB lists node-security-groups for specific nodes
+func getNodeSecurityGroupIDForLB(compute *gophercloud.ServiceClient, network *gophercloud.ServiceClient, nodes []*corev1.Node) ([]string, error) {
+	secGroupIDs := sets.NewString()
+
+	for _, node := range nodes {
+		nodeName := types.NodeName(node.Name)
+		srv, err := getServerByName(compute, nodeName)
+		if err != nil {
+			return []string{}, err
+		}
+
+		// Get the security groups of all the ports on the worker nodes. In future, we could filter the ports by some way.
+		// case 0: node1:SG1  node2:SG1  return SG1
+		// case 1: node1:SG1  node2:SG2  return SG1,SG2
+		// case 2: node1:SG1,SG2  node2:SG3,SG4  return SG1,SG2,SG3,SG4
+		// case 3: node1:SG1,SG2  node2:SG2,SG3  return SG1,SG2,SG3
+		allPorts, err := getAttachedPorts(network, srv.ID)
+		if err != nil {
+			return []string{}, err
+		}
+
+		for _, port := range allPorts {
+			for _, sg := range port.SecurityGroups {
+				secGroupIDs.Insert(sg)
+			}
+		}
+	}
+
+	return secGroupIDs.List(), nil
+}
+
+// isSecurityGroupNotFound return true while 'err' is object of gophercloud.ErrResourceNotFound
+func isSecurityGroupNotFound(err error) bool {
+	errType := reflect.TypeOf(err).String()
+	errTypeSlice := strings.Split(errType, ".")
+	errTypeValue := ""
+	if len(errTypeSlice) != 0 {
+		errTypeValue = errTypeSlice[len(errTypeSlice)-1]
+	}
+	if errTypeValue == "ErrResourceNotFound" {
+		return true
+	}
+
+	return false
+}
+
+// getFloatingNetworkIDForLB returns a floating-network-id for cluster.
+func getFloatingNetworkIDForLB(client *gophercloud.ServiceClient) (string, error) {
+	var floatingNetworkIds []string
+
+	type NetworkWithExternalExt struct {
+		networks.Network
+		external.NetworkExternalExt
+	}
+
+	mc := metrics.NewMetricContext("network", "list")
+	err := networks.List(client, networks.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		var externalNetwork []NetworkWithExternalExt
+		err := networks.ExtractNetworksInto(page, &externalNetwork)
+		if err != nil {
+			return false, err
+		}
+
+		for _, externalNet := range externalNetwork {
+			if externalNet.External {
+				floatingNetworkIds = append(floatingNetworkIds, externalNet.ID)
+			}
+		}
+
+		if len(floatingNetworkIds) > 1 {
+			return false, ErrMultipleResults
 		}
 		return true, nil
 	})
@@ -850,7 +1505,7 @@ func getFloatingNetworkIDForLB(client *gophercloud.ServiceClient) (string, error
 		}
 
 		if err == ErrMultipleResults {
-			klog.V(4).Infof("find multiple external networks, pick the first one when there are no explicit configuration.")
+			klog.V(4).InfoS("Found multiple external networks, picking the first one as there is no explicit configuration")
 			return floatingNetworkIds[0], mc.ObserveRequest(nil)
 		}
 		return "", mc.ObserveRequest(err)
@@ -865,14 +1520,14 @@ func getFloatingNetworkIDForLB(client *gophercloud.ServiceClient) (string, error
 
 func (lbaas *LbaasV2) deleteListeners(lbID string, listenerList []listeners.Listener) error {
 	for _, listener := range listenerList {
-		klog.V(2).Infof("Deleting obsolete listener %s:", listener.ID)
+		klog.V(2).InfoS("Deleting obsolete listener", "listener", listener.ID)
 
 		pool, err := openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
 		if err != nil && err != openstackutil.ErrNotFound {
 			return fmt.Errorf("error getting pool for obsolete listener %s: %v", listener.ID, err)
 		}
 		if pool != nil {
-			klog.V(2).Infof("Deleting obsolete pool %s for listener %s", pool.ID, listener.ID)
+			klog.V(2).InfoS("Deleting obsolete pool for listener", "pool", pool.ID, "listener", listener.ID)
 
 			// Delete pool automatically deletes all its members.
 			mc := metrics.NewMetricContext("loadbalancer_pool", "delete")
@@ -900,16 +1555,50 @@ func (lbaas *LbaasV2) deleteListeners(lbID string, listenerList []listeners.List
 			return fmt.Errorf("timeout when waiting for loadbalancer to be ACTIVE after deleting listener, current provisioning status %s", provisioningStatus)
 		}
 
-		klog.V(2).Infof("Deleted obsolete listener %s for load balancer %s", listener.ID, lbID)
+		klog.V(2).InfoS("Deleted obsolete listener for load balancer", "listener", listener.ID, "loadbalancer", lbID)
 	}
 
 	return nil
 }
 
+// getAnnotatedFloatingIP returns the existing floating IP with the given ID or address.
+// The floating IP must be on the floating network of the Service and either free or
+// already associated with the given VIP port.
+func (lbaas *LbaasV2) getAnnotatedFloatingIP(value string, portID string, svcConf *serviceConfig) (*floatingips.FloatingIP, error) {
+	var floatIP *floatingips.FloatingIP
+	if net.ParseIP(value) != nil {
+		existingIPs, err := openstackutil.GetFloatingIPs(lbaas.network, floatingips.ListOpts{FloatingIP: value})
+		if err != nil {
+			return nil, fmt.Errorf("failed when trying to get floating IP %s: %v", value, err)
+		}
+		if len(existingIPs) == 0 {
+			return nil, fmt.Errorf("floating IP %s not found", value)
+		}
+		floatIP = &existingIPs[0]
+	} else {
+		mc := metrics.NewMetricContext("floating_ip", "get")
+		fip, err := floatingips.Get(lbaas.network, value).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return nil, fmt.Errorf("failed when trying to get floating IP %s: %v", value, err)
+		}
+		floatIP = fip
+	}
+
+	if svcConf.lbPublicNetworkID != "" && floatIP.FloatingNetworkID != svcConf.lbPublicNetworkID {
+		return nil, fmt.Errorf("floating IP %s doesn't belong to the floating network %s", floatIP.FloatingIP, svcConf.lbPublicNetworkID)
+	}
+	if floatIP.PortID != "" && floatIP.PortID != portID {
+		return nil, fmt.Errorf("floating IP %s is already associated with port %s", floatIP.FloatingIP, floatIP.PortID)
+	}
+
+	return floatIP, nil
+}
+
 // Priority of choosing VIP port floating IP:
-// 1. The floating IP that is already attached to the VIP port.
-// 2. Floating IP specified in Spec.LoadBalancerIP
-// 3. Create a new one
+// 1. Floating IP specified in the floating-ip annotation
+// 2. The floating IP that is already attached to the VIP port.
+// 3. Floating IP specified in Spec.LoadBalancerIP
+// 4. Create a new one
 func (lbaas *LbaasV2) getServiceAddress(clusterName string, service *corev1.Service, lb *loadbalancers.LoadBalancer, svcConf *serviceConfig) (string, error) {
 	if svcConf.internal {
 		return lb.VipAddress, nil
@@ -918,8 +1607,29 @@ func (lbaas *LbaasV2) getServiceAddress(clusterName string, service *corev1.Serv
 	var floatIP *floatingips.FloatingIP
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 
-	// first attempt: fetch floating IP attached to load balancer's VIP port
 	portID := lb.VipPortID
+
+	// the floating IP specified in the annotation must be used, associate it with
+	// the loadbalancer's VIP port if needed
+	if value := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerFloatingIP, ""); value != "" {
+		floatIP, err := lbaas.getAnnotatedFloatingIP(value, portID, svcConf)
+		if err != nil {
+			return "", err
+		}
+		if floatIP.PortID == "" {
+			floatUpdateOpts := floatingips.UpdateOpts{
+				PortID: &portID,
+			}
+			mc := metrics.NewMetricContext("floating_ip", "update")
+			floatIP, err = floatingips.Update(lbaas.network, floatIP.ID, floatUpdateOpts).Extract()
+			if mc.ObserveRequest(err) != nil {
+				return "", fmt.Errorf("error updating LB floatingip %+v: %v", floatUpdateOpts, err)
+			}
+		}
+		return floatIP.FloatingIP, nil
+	}
+
+	// first attempt: fetch floating IP attached to load balancer's VIP port
 	floatIP, err := openstackutil.GetFloatingIPByPortID(lbaas.network, portID)
 	if err != nil {
 		return "", fmt.Errorf("failed when getting floating IP for port %s: %v", portID, err)
@@ -957,7 +1667,7 @@ func (lbaas *LbaasV2) getServiceAddress(clusterName string, service *corev1.Serv
 	// third attempt: create a new floating IP
 	if floatIP == nil {
 		if svcConf.lbPublicNetworkID != "" {
-			klog.V(2).Infof("Creating floating IP %s for loadbalancer %s", loadBalancerIP, lb.ID)
+			klog.V(2).InfoS("Creating floating IP for loadbalancer", "floatingIP", loadBalancerIP, "loadbalancer", lb.ID)
 
 			floatIPOpts := floatingips.CreateOpts{
 				FloatingNetworkID: svcConf.lbPublicNetworkID,
@@ -971,7 +1681,7 @@ func (lbaas *LbaasV2) getServiceAddress(clusterName string, service *corev1.Serv
 				floatIPOpts.FloatingIP = loadBalancerIP
 			}
 
-			klog.V(4).Infof("Creating floating ip with opts %+v", floatIPOpts)
+			klog.V(4).InfoS("Creating floating IP", "opts", floatIPOpts)
 
 			mc := metrics.NewMetricContext("floating_ip", "create")
 			floatIP, err = floatingips.Create(lbaas.network, floatIPOpts).Extract()
@@ -993,21 +1703,62 @@ func (lbaas *LbaasV2) getServiceAddress(clusterName string, service *corev1.Serv
 func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, pool *v2pools.Pool, port corev1.ServicePort, svcConf *serviceConfig) error {
 	monitorID := pool.MonitorID
 
+	monitorType := getHealthMonitorType(port, svcConf)
+
+	if monitorID != "" && svcConf.enableMonitor {
+		mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "get")
+		monitor, err := v2monitors.Get(lbaas.lb, monitorID).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return fmt.Errorf("failed to get health monitor %s for pool %s: %v", monitorID, pool.ID, err)
+		}
+
+		if monitor.Type != monitorType {
+			// The type of a health monitor can't be updated
+			klog.InfoS("Deleting health monitor of pool to change its type", "monitor", monitorID, "pool", pool.ID, "type", monitor.Type, "newType", monitorType)
+
+			mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "delete")
+			err := v2monitors.Delete(lbaas.lb, monitorID).ExtractErr()
+			if mc.ObserveRequest(err) != nil {
+				return fmt.Errorf("failed to delete health monitor %s for pool %s, error: %v", monitorID, pool.ID, err)
+			}
+			provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+			if err != nil {
+				return fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE after deleting health monitor, current provisioning status %s", lbID, provisioningStatus)
+			}
+			monitorID = ""
+		} else if updateOpts, changed := healthMonitorUpdateOpts(monitor, monitorType, svcConf); changed {
+			klog.InfoS("Updating health monitor of pool", "monitor", monitorID, "pool", pool.ID)
+
+			mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "update")
+			_, err := v2monitors.Update(lbaas.lb, monitorID, updateOpts).Extract()
+			if mc.ObserveRequest(err) != nil {
+				return fmt.Errorf("failed to update health monitor %s for pool %s: %v", monitorID, pool.ID, err)
+			}
+			provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+			if err != nil {
+				return fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE after updating health monitor, current provisioning status %s", lbID, provisioningStatus)
+			}
+		}
+	}
+
 	if monitorID == "" && svcConf.enableMonitor {
-		klog.V(4).Infof("Creating monitor for pool %s", pool.ID)
+		klog.V(4).InfoS("Creating monitor for pool", "pool", pool.ID)
 
-		monitorProtocol := string(port.Protocol)
-		if port.Protocol == corev1.ProtocolUDP {
-			monitorProtocol = "UDP-CONNECT"
+		createOpts := v2monitors.CreateOpts{
+			PoolID:     pool.ID,
+			Type:       monitorType,
+			Delay:      svcConf.monitorDelay,
+			Timeout:    svcConf.monitorTimeout,
+			MaxRetries: svcConf.monitorMaxRetries,
+		}
+		if isHTTPMonitor(monitorType) {
+			createOpts.HTTPMethod = svcConf.monitorHTTPMethod
+			createOpts.URLPath = svcConf.monitorURLPath
+			createOpts.ExpectedCodes = svcConf.monitorExpectedCodes
 		}
+
 		mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "create")
-		monitor, err := v2monitors.Create(lbaas.lb, v2monitors.CreateOpts{
-			PoolID:     pool.ID,
-			Type:       monitorProtocol,
-			Delay:      int(lbaas.opts.MonitorDelay.Duration.Seconds()),
-			Timeout:    int(lbaas.opts.MonitorTimeout.Duration.Seconds()),
-			MaxRetries: int(lbaas.opts.MonitorMaxRetries),
-		}).Extract()
+		monitor, err := v2monitors.Create(lbaas.lb, createOpts).Extract()
 		if mc.ObserveRequest(err) != nil {
 			return fmt.Errorf("failed to create healthmonitor for pool %s: %v", pool.ID, err)
 		}
@@ -1017,7 +1768,7 @@ func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, pool *v2pools.Pool
 		}
 		monitorID = monitor.ID
 	} else if monitorID != "" && !svcConf.enableMonitor {
-		klog.Infof("Deleting health monitor %s for pool %s", monitorID, pool.ID)
+		klog.InfoS("Deleting health monitor of pool", "monitor", monitorID, "pool", pool.ID)
 
 		mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "delete")
 		err := v2monitors.Delete(lbaas.lb, monitorID).ExtractErr()
@@ -1027,12 +1778,137 @@ func (lbaas *LbaasV2) ensureOctaviaHealthMonitor(lbID string, pool *v2pools.Pool
 	}
 
 	if monitorID != "" {
-		klog.Infof("Health monitor %s for pool %s created.", monitorID, pool.ID)
+		klog.InfoS("Health monitor of pool created", "monitor", monitorID, "pool", pool.ID)
+	}
+
+	return nil
+}
+
+// healthMonitorUpdateOpts returns the update of the health monitor to the configuration of the Service, and whether
+// the health monitor differs from it.
+func healthMonitorUpdateOpts(monitor *v2monitors.Monitor, monitorType string, svcConf *serviceConfig) (v2monitors.UpdateOpts, bool) {
+	opts := v2monitors.UpdateOpts{
+		Delay:      svcConf.monitorDelay,
+		Timeout:    svcConf.monitorTimeout,
+		MaxRetries: svcConf.monitorMaxRetries,
+	}
+	changed := monitor.Delay != opts.Delay || monitor.Timeout != opts.Timeout || monitor.MaxRetries != opts.MaxRetries
+
+	if isHTTPMonitor(monitorType) {
+		opts.HTTPMethod = svcConf.monitorHTTPMethod
+		opts.URLPath = svcConf.monitorURLPath
+		opts.ExpectedCodes = svcConf.monitorExpectedCodes
+		changed = changed || monitor.HTTPMethod != opts.HTTPMethod || monitor.URLPath != opts.URLPath || monitor.ExpectedCodes != opts.ExpectedCodes
+	}
+
+	return opts, changed
+}
+
+// getHealthMonitorType returns the type of the health monitor for the Service port. The type set by the annotation is
+// ignored for the ports whose protocol doesn't support it, e.g. the UDP ports of a mixed-protocol Service.
+func getHealthMonitorType(port corev1.ServicePort, svcConf *serviceConfig) string {
+	var defaultType string
+	var supportedTypes sets.String
+	switch port.Protocol {
+	case corev1.ProtocolUDP:
+		defaultType = "UDP-CONNECT"
+		supportedTypes = sets.NewString("HTTP", "PING", "TCP", "UDP-CONNECT")
+	case corev1.ProtocolSCTP:
+		defaultType = "SCTP"
+		supportedTypes = sets.NewString("HTTP", "PING", "SCTP", "TCP", "UDP-CONNECT")
+	default:
+		defaultType = "TCP"
+		supportedTypes = sets.NewString("HTTP", "HTTPS", "PING", "TCP", "TLS-HELLO")
+	}
+
+	if svcConf.monitorType == "" {
+		return defaultType
+	}
+	if !supportedTypes.Has(svcConf.monitorType) {
+		klog.V(2).InfoS("Health monitor type isn't supported for the port, using the default type", "type", svcConf.monitorType, "port", port.Port, "protocol", port.Protocol, "defaultType", defaultType)
+		return defaultType
+	}
+	return svcConf.monitorType
+}
+
+// isHTTPMonitor returns true if the health monitor type supports the HTTP method, URL path and expected codes.
+func isHTTPMonitor(monitorType string) bool {
+	return monitorType == "HTTP" || monitorType == "HTTPS"
+}
+
+// getHealthMonitorConfig reads the health monitor annotations of the Service.
+func getHealthMonitorConfig(service *corev1.Service, svcConf *serviceConfig) error {
+	svcConf.monitorType = strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorType, ""))
+	switch svcConf.monitorType {
+	case "", "HTTP", "HTTPS", "PING", "SCTP", "TCP", "TLS-HELLO", "UDP-CONNECT":
+	default:
+		return fmt.Errorf("unknown %s annotation: %v", ServiceAnnotationLoadBalancerHealthMonitorType, svcConf.monitorType)
+	}
+
+	httpAnnotations := []string{
+		ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod,
+		ServiceAnnotationLoadBalancerHealthMonitorURLPath,
+		ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes,
+	}
+	if !isHTTPMonitor(svcConf.monitorType) {
+		for _, annotation := range httpAnnotations {
+			if _, ok := service.Annotations[annotation]; ok {
+				return fmt.Errorf("annotation %s requires annotation %s to be HTTP or HTTPS", annotation, ServiceAnnotationLoadBalancerHealthMonitorType)
+			}
+		}
+		return nil
+	}
+
+	// Use the Octavia defaults so that the existing monitors can be compared
+	svcConf.monitorHTTPMethod = strings.ToUpper(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod, "GET"))
+	svcConf.monitorURLPath = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorURLPath, "/")
+	svcConf.monitorExpectedCodes = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes, "200")
+	if !strings.HasPrefix(svcConf.monitorURLPath, "/") {
+		return fmt.Errorf("invalid %s annotation: %q, the URL path must start with /", ServiceAnnotationLoadBalancerHealthMonitorURLPath, svcConf.monitorURLPath)
 	}
 
 	return nil
 }
 
+// getHealthMonitorTiming reads the delay, timeout, max retries and initial delay annotations of the health monitor of
+// the Service, the config of the load balancers is the default. The timeout must be lower than the delay when any of
+// them is set by an annotation.
+func getHealthMonitorTiming(service *corev1.Service, svcConf *serviceConfig, opts LoadBalancerOpts) error {
+	delay, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorDelay, int(opts.MonitorDelay.Duration.Seconds()), 1)
+	if err != nil {
+		return err
+	}
+	timeout, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorTimeout, int(opts.MonitorTimeout.Duration.Seconds()), 1)
+	if err != nil {
+		return err
+	}
+	maxRetries, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorMaxRetries, int(opts.MonitorMaxRetries), 1)
+	if err != nil {
+		return err
+	}
+	if maxRetries > maxHealthMonitorRetries {
+		return fmt.Errorf("invalid %s annotation: %d, the max retries must be from 1 to %d", ServiceAnnotationLoadBalancerHealthMonitorMaxRetries, maxRetries, maxHealthMonitorRetries)
+	}
+
+	_, delaySet := service.Annotations[ServiceAnnotationLoadBalancerHealthMonitorDelay]
+	_, timeoutSet := service.Annotations[ServiceAnnotationLoadBalancerHealthMonitorTimeout]
+	if (delaySet || timeoutSet) && timeout >= delay {
+		return fmt.Errorf("invalid health monitor timeout %ds, it must be lower than the delay %ds, set by annotations %s and %s",
+			timeout, delay, ServiceAnnotationLoadBalancerHealthMonitorTimeout, ServiceAnnotationLoadBalancerHealthMonitorDelay)
+	}
+
+	initialDelay, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerHealthMonitorInitialDelay, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	svcConf.monitorDelay = delay
+	svcConf.monitorTimeout = timeout
+	svcConf.monitorMaxRetries = maxRetries
+	svcConf.memberInitialDelay = time.Duration(initialDelay) * time.Second
+	return nil
+}
+
 // Make sure the pool is created for the Service, nodes are added as pool members.
 func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, listener *listeners.Listener, service *corev1.Service, port corev1.ServicePort, nodes []*corev1.Node, svcConf *serviceConfig) (*v2pools.Pool, error) {
 	var members []v2pools.BatchUpdateMemberOpts
@@ -1050,30 +1926,59 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, listener *listeners.Listene
 			}
 		}
 
+		weight := getMemberWeight(node)
 		member := v2pools.BatchUpdateMemberOpts{
 			Address:      addr,
 			ProtocolPort: int(port.NodePort),
 			Name:         &node.Name,
 			SubnetID:     &svcConf.lbMemberSubnetID,
+			Weight:       &weight,
 		}
 		members = append(members, member)
 		newMembers.Insert(fmt.Sprintf("%s-%d", addr, member.ProtocolPort))
 	}
 
+	// By default, use the protocol of the listerner, the PROXY protocol and X-Forwarded-For only apply to TCP ports
+	poolProto := v2pools.Protocol(listener.Protocol)
+	if listeners.Protocol(listener.Protocol) == listeners.ProtocolTerminatedHTTPS {
+		// The TLS connections are terminated by the load balancer
+		poolProto = v2pools.ProtocolHTTP
+	}
+	if port.Protocol == corev1.ProtocolTCP {
+		if svcConf.proxyProtocol != "" {
+			poolProto = svcConf.proxyProtocol
+		} else if svcConf.keepClientIP && poolProto != v2pools.ProtocolHTTP {
+			klog.V(4).InfoS("Forcing the protocol of the pool because the annotation is set", "protocol", v2pools.ProtocolHTTP, "annotation", ServiceAnnotationLoadBalancerXForwardedFor)
+			poolProto = v2pools.ProtocolHTTP
+		}
+	}
+
 	pool, err := openstackutil.GetPoolByListener(lbaas.lb, lbID, listener.ID)
 	if err != nil && err != openstackutil.ErrNotFound {
 		return nil, fmt.Errorf("error getting pool for listener %s: %v", listener.ID, err)
 	}
-	if pool == nil {
-		// By default, use the protocol of the listerner
-		poolProto := v2pools.Protocol(listener.Protocol)
 
-		if svcConf.enableProxyProtocol {
-			poolProto = v2pools.ProtocolPROXY
-		} else if svcConf.keepClientIP && poolProto != v2pools.ProtocolHTTP {
-			klog.V(4).Infof("Forcing to use %q protocol for pool because annotation %q is set", v2pools.ProtocolHTTP, ServiceAnnotationLoadBalancerXForwardedFor)
-			poolProto = v2pools.ProtocolHTTP
+	// The protocol of a pool can't be updated, the pool is recreated when the
+	// PROXY protocol is enabled, disabled or its version is changed.
+	if pool != nil && pool.Protocol != string(poolProto) && (isProxyProtocol(pool.Protocol) || isProxyProtocol(string(poolProto))) {
+		klog.V(2).InfoS("Deleting pool of listener to change its protocol", "pool", pool.ID, "listener", listener.ID, "protocol", pool.Protocol, "newProtocol", poolProto)
+
+		// Delete pool automatically deletes all its members and its health monitor.
+		mc := metrics.NewMetricContext("loadbalancer_pool", "delete")
+		err := v2pools.Delete(lbaas.lb, pool.ID).ExtractErr()
+		if err != nil && !cpoerrors.IsNotFound(err) {
+			mc.ObserveRequest(err)
+			return nil, fmt.Errorf("error deleting pool %s for listener %s: %v", pool.ID, listener.ID, err)
+		}
+		mc.ObserveRequest(nil)
+		provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+		if err != nil {
+			return nil, fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE after deleting pool, current provisioning status %s", lbID, provisioningStatus)
 		}
+		pool = nil
+	}
+
+	if pool == nil {
 
 		affinity := service.Spec.SessionAffinity
 		var persistence *v2pools.SessionPersistence
@@ -1084,15 +1989,14 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, listener *listeners.Listene
 			persistence = &v2pools.SessionPersistence{Type: "SOURCE_IP"}
 		}
 
-		lbmethod := v2pools.LBMethod(lbaas.opts.LBMethod)
 		createOpt := v2pools.CreateOpts{
 			Protocol:    poolProto,
-			LBMethod:    lbmethod,
+			LBMethod:    svcConf.lbMethod,
 			ListenerID:  listener.ID,
 			Persistence: persistence,
 		}
 
-		klog.V(2).Infof("Creating pool for listener %s using protocol %s", listener.ID, poolProto)
+		klog.V(2).InfoS("Creating pool for listener", "listener", listener.ID, "protocol", poolProto)
 
 		mc := metrics.NewMetricContext("loadbalancer_pool", "create")
 		pool, err = v2pools.Create(lbaas.lb, createOpt).Extract()
@@ -1104,28 +2008,399 @@ func (lbaas *LbaasV2) ensureOctaviaPool(lbID string, listener *listeners.Listene
 		if err != nil {
 			return nil, fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE after creating pool, current provisioning status %s", lbID, provisioningStatus)
 		}
+	} else if pool.LBMethod != string(svcConf.lbMethod) {
+		klog.V(2).InfoS("Updating the load balancing algorithm of pool", "pool", pool.ID, "lbMethod", pool.LBMethod, "newLBMethod", svcConf.lbMethod)
+
+		mc := metrics.NewMetricContext("loadbalancer_pool", "update")
+		updated, err := v2pools.Update(lbaas.lb, pool.ID, v2pools.UpdateOpts{LBMethod: svcConf.lbMethod}).Extract()
+		if mc.ObserveRequest(err) != nil {
+			return nil, fmt.Errorf("error updating pool %s for listener %s: %v", pool.ID, listener.ID, err)
+		}
+		pool = updated
+
+		provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+		if err != nil {
+			return nil, fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE after updating pool, current provisioning status %s", lbID, provisioningStatus)
+		}
 	}
 
-	klog.V(2).Infof("Pool %s created for listener %s", pool.ID, listener.ID)
+	klog.V(2).InfoS("Pool created for listener", "pool", pool.ID, "listener", listener.ID)
 
 	curMembers := sets.NewString()
 	poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
 	if err != nil {
-		klog.Errorf("failed to get members in the pool %s: %v", pool.ID, err)
+		klog.ErrorS(err, "Failed to get the members of the pool", "pool", pool.ID)
 	}
 	for _, m := range poolMembers {
 		curMembers.Insert(fmt.Sprintf("%s-%d", m.Address, m.ProtocolPort))
 	}
 
-	if !curMembers.Equal(newMembers) {
-		klog.V(2).Infof("Updating %d members for pool %s", len(members), pool.ID)
-		if err := openstackutil.BatchUpdatePoolMembers(lbaas.lb, lbID, pool.ID, members); err != nil {
-			return nil, err
+	// Without the current members, the existing members can't be told apart from the new ones
+	var stateChanged bool
+	var warmingUp time.Duration
+	if err == nil {
+		members, stateChanged, warmingUp = warmUpPoolMembers(pool.ID, members, poolMembers, svcConf.memberInitialDelay, memberWarmups)
+	}
+	members, weightChanged, draining := drainPoolMembers(members, newMembers, poolMembers, svcConf.drainTimeout, memberDrains)
+	for _, m := range members {
+		newMembers.Insert(fmt.Sprintf("%s-%d", m.Address, m.ProtocolPort))
+	}
+
+	if !curMembers.Equal(newMembers) || weightChanged || stateChanged {
+		klog.V(2).InfoS("Updating the members of pool", "pool", pool.ID, "members", len(members))
+		if err := openstackutil.BatchUpdatePoolMembers(lbaas.lb, lbID, pool.ID, members); err != nil {
+			return nil, err
+		}
+		klog.V(2).InfoS("Successfully updated the members of pool", "pool", pool.ID, "members", len(members))
+	}
+	if warmingUp > 0 {
+		lbaas.scheduleMemberWarmup(lbID, pool.ID, svcConf.memberInitialDelay, warmingUp)
+	}
+	if draining > 0 {
+		lbaas.scheduleMemberDrain(lbID, pool.ID, svcConf.drainTimeout, draining)
+	}
+
+	return pool, nil
+}
+
+// getMemberWeight returns the weight of the pool members of the node, from its
+// member weight annotation or else the default weight
+func getMemberWeight(node *corev1.Node) int {
+	value, ok := node.Annotations[NodeAnnotationLoadBalancerMemberWeight]
+	if !ok {
+		return defaultMemberWeight
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 1 || weight > maxMemberWeight {
+		klog.Warningf("Invalid annotation %s of node %s: %q, the weight must be an integer from 1 to %d, using the default weight %d",
+			NodeAnnotationLoadBalancerMemberWeight, node.Name, value, maxMemberWeight, defaultMemberWeight)
+		return defaultMemberWeight
+	}
+	return weight
+}
+
+// drainingMembers records when the draining of the pool members started.
+type drainingMembers struct {
+	sync.Mutex
+	started map[string]time.Time
+	// timers delete the drained members of each pool
+	timers map[string]*time.Timer
+}
+
+var memberDrains = &drainingMembers{started: make(map[string]time.Time), timers: make(map[string]*time.Timer)}
+
+// start returns the time the draining of the member started, the draining starts now if it hasn't yet.
+func (d *drainingMembers) start(memberID string) time.Time {
+	d.Lock()
+	defer d.Unlock()
+	if t, ok := d.started[memberID]; ok {
+		return t
+	}
+	d.started[memberID] = time.Now()
+	return d.started[memberID]
+}
+
+func (d *drainingMembers) forget(memberID string) {
+	d.Lock()
+	defer d.Unlock()
+	delete(d.started, memberID)
+}
+
+// remaining returns whether the member is draining and how long its drain timeout still lasts.
+func (d *drainingMembers) remaining(memberID string, drainTimeout time.Duration) (bool, time.Duration) {
+	d.Lock()
+	defer d.Unlock()
+	started, draining := d.started[memberID]
+	if !draining {
+		return false, 0
+	}
+	if remaining := drainTimeout - time.Since(started); remaining > 0 {
+		return true, remaining
+	}
+	return true, 0
+}
+
+// drainPoolMembers keeps the pool members which are no longer wanted with a weight of 0 until the drain timeout expires,
+// and restores the weight of the drained members which are wanted again. It returns the members to update the pool with,
+// whether the weight of any member changed, including the wanted members whose weight differs from their current
+// weight, and how long until the next drain timeout expires, 0 when no member is draining.
+func drainPoolMembers(members []v2pools.BatchUpdateMemberOpts, wanted sets.String, poolMembers []v2pools.Member, drainTimeout time.Duration, drains *drainingMembers) ([]v2pools.BatchUpdateMemberOpts, bool, time.Duration) {
+	weightChanged := false
+	var next time.Duration
+	for _, m := range poolMembers {
+		key := fmt.Sprintf("%s-%d", m.Address, m.ProtocolPort)
+		if wanted.Has(key) {
+			for i := range members {
+				if members[i].Address != m.Address || members[i].ProtocolPort != m.ProtocolPort {
+					continue
+				}
+				if members[i].Weight == nil {
+					if m.Weight != 0 {
+						continue
+					}
+					weight := defaultMemberWeight
+					members[i].Weight = &weight
+				} else if *members[i].Weight == m.Weight {
+					continue
+				}
+				if m.Weight == 0 {
+					klog.V(2).InfoS("Restoring the weight of drained member", "member", m.ID)
+				} else {
+					klog.V(2).InfoS("Updating the weight of member", "member", m.ID, "weight", m.Weight, "newWeight", *members[i].Weight)
+				}
+				weightChanged = true
+			}
+			drains.forget(m.ID)
+			continue
+		}
+
+		var remaining time.Duration
+		if drainTimeout > 0 {
+			remaining = drainTimeout - time.Since(drains.start(m.ID))
+		}
+		if remaining <= 0 {
+			drains.forget(m.ID)
+			continue
+		}
+		if next == 0 || remaining < next {
+			next = remaining
+		}
+
+		if m.Weight != 0 {
+			klog.V(2).InfoS("Draining member", "member", m.ID, "drainTimeout", drainTimeout)
+			weightChanged = true
+		}
+		name, subnetID, weight := m.Name, m.SubnetID, 0
+		members = append(members, v2pools.BatchUpdateMemberOpts{
+			Address:      m.Address,
+			ProtocolPort: m.ProtocolPort,
+			Name:         &name,
+			SubnetID:     &subnetID,
+			Weight:       &weight,
+		})
+	}
+
+	return members, weightChanged, next
+}
+
+// scheduleMemberDrain deletes the drained members of the pool after the delay, the Service controller doesn't resync
+// the load balancers so the next reconcile of the Service may only happen much later.
+func (lbaas *LbaasV2) scheduleMemberDrain(lbID, poolID string, drainTimeout, delay time.Duration) {
+	memberDrains.Lock()
+	defer memberDrains.Unlock()
+	if timer, ok := memberDrains.timers[poolID]; ok {
+		timer.Stop()
+	}
+	memberDrains.timers[poolID] = time.AfterFunc(delay, func() {
+		lbaas.deleteDrainedMembers(lbID, poolID, drainTimeout)
+	})
+}
+
+// deleteDrainedMembers deletes the members of the pool whose drain timeout expired, and schedules the members still
+// draining.
+func (lbaas *LbaasV2) deleteDrainedMembers(lbID, poolID string, drainTimeout time.Duration) {
+	if shutdown.stopping.Err() != nil {
+		return
+	}
+
+	poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, poolID)
+	if err != nil {
+		if cpoerrors.IsNotFound(err) {
+			return
+		}
+		klog.Warningf("Failed to get the members of pool %s to delete after their drain timeout: %v", poolID, err)
+		lbaas.scheduleMemberDrain(lbID, poolID, drainTimeout, memberDrainRetryDelay)
+		return
+	}
+
+	var next time.Duration
+	for _, m := range poolMembers {
+		draining, remaining := memberDrains.remaining(m.ID, drainTimeout)
+		if !draining || m.Weight != 0 {
+			continue
+		}
+		if remaining > 0 {
+			if next == 0 || remaining < next {
+				next = remaining
+			}
+			continue
+		}
+
+		klog.V(2).InfoS("Deleting member of pool after its drain timeout", "member", m.ID, "pool", poolID)
+		mc := metrics.NewMetricContext("loadbalancer_member", "delete")
+		err := v2pools.DeleteMember(lbaas.lb, poolID, m.ID).ExtractErr()
+		if err != nil && !cpoerrors.IsNotFound(err) {
+			mc.ObserveRequest(err)
+		} else {
+			mc.ObserveRequest(nil)
+			_, err = waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+		}
+		if err != nil {
+			// The member is deleted by the retry or by the next reconcile, as its drain timeout expired
+			klog.Warningf("Failed to delete member %s of pool %s after its drain timeout: %v", m.ID, poolID, err)
+			next = memberDrainRetryDelay
+			break
+		}
+		memberDrains.forget(m.ID)
+	}
+
+	if next > 0 {
+		lbaas.scheduleMemberDrain(lbID, poolID, drainTimeout, next)
+	}
+}
+
+// warmingMembers records when the pool members were added, the members are kept administratively down until their
+// initial delay is over.
+type warmingMembers struct {
+	sync.Mutex
+	added map[string]time.Time
+	// timers bring up the warmed up members of each pool
+	timers map[string]*time.Timer
+}
+
+var memberWarmups = &warmingMembers{added: make(map[string]time.Time), timers: make(map[string]*time.Timer)}
+
+// state returns whether the pool member must be up and how long its initial delay still lasts. The initial delay of
+// the members which aren't in the pool yet starts now, the existing members which aren't warming up are up.
+func (w *warmingMembers) state(memberKey string, exists bool, initialDelay time.Duration) (bool, time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+	added, warming := w.added[memberKey]
+	if !warming && !exists {
+		added, warming = time.Now(), true
+		w.added[memberKey] = added
+	}
+	if remaining := initialDelay - time.Since(added); warming && remaining > 0 {
+		return false, remaining
+	}
+	delete(w.added, memberKey)
+	return true, 0
+}
+
+// forget stops warming up the pool member and returns whether it was warming up.
+func (w *warmingMembers) forget(memberKey string) bool {
+	w.Lock()
+	defer w.Unlock()
+	_, warming := w.added[memberKey]
+	delete(w.added, memberKey)
+	return warming
+}
+
+// forgetOthers stops warming up the members of the pool which are not in wanted.
+func (w *warmingMembers) forgetOthers(poolID string, wanted sets.String) {
+	w.Lock()
+	defer w.Unlock()
+	for memberKey := range w.added {
+		if strings.HasPrefix(memberKey, poolID+"/") && !wanted.Has(memberKey) {
+			delete(w.added, memberKey)
+		}
+	}
+}
+
+// warmUpPoolMembers sets the administrative state of the wanted pool members. With an initial delay, the members
+// added to the pool are down until their initial delay is over, so that the health monitor doesn't evaluate them,
+// and the other members are up. Without initial delay, only the members still warming up are brought up. It returns
+// the members to update the pool with, whether the state of an existing member changed and how long until the next
+// member is warmed up, 0 when no member is warming up.
+func warmUpPoolMembers(poolID string, members []v2pools.BatchUpdateMemberOpts, poolMembers []v2pools.Member, initialDelay time.Duration, warmups *warmingMembers) ([]v2pools.BatchUpdateMemberOpts, bool, time.Duration) {
+	current := make(map[string]v2pools.Member, len(poolMembers))
+	for _, m := range poolMembers {
+		current[fmt.Sprintf("%s-%d", m.Address, m.ProtocolPort)] = m
+	}
+
+	stateChanged := false
+	var next time.Duration
+	wanted := sets.NewString()
+	for i := range members {
+		key := fmt.Sprintf("%s-%d", members[i].Address, members[i].ProtocolPort)
+		memberKey := poolID + "/" + key
+		wanted.Insert(memberKey)
+		m, exists := current[key]
+
+		up := true
+		if initialDelay <= 0 {
+			if !warmups.forget(memberKey) {
+				continue
+			}
+		} else {
+			var remaining time.Duration
+			up, remaining = warmups.state(memberKey, exists, initialDelay)
+			if remaining > 0 && (next == 0 || remaining < next) {
+				next = remaining
+			}
+		}
+
+		members[i].AdminStateUp = &up
+		if exists && m.AdminStateUp != up {
+			if up {
+				klog.V(2).InfoS("Bringing up member of pool after its initial delay", "member", m.ID, "pool", poolID)
+			}
+			stateChanged = true
+		}
+	}
+	warmups.forgetOthers(poolID, wanted)
+
+	return members, stateChanged, next
+}
+
+// scheduleMemberWarmup brings up the members of the pool once they are warmed up, after the delay, so that they don't
+// wait for the next reconcile of the Service.
+func (lbaas *LbaasV2) scheduleMemberWarmup(lbID, poolID string, initialDelay, delay time.Duration) {
+	memberWarmups.Lock()
+	defer memberWarmups.Unlock()
+	if timer, ok := memberWarmups.timers[poolID]; ok {
+		timer.Stop()
+	}
+	memberWarmups.timers[poolID] = time.AfterFunc(delay, func() {
+		lbaas.enableWarmedUpMembers(lbID, poolID, initialDelay)
+	})
+}
+
+// enableWarmedUpMembers brings up the members of the pool whose initial delay is over, and schedules the members
+// still warming up.
+func (lbaas *LbaasV2) enableWarmedUpMembers(lbID, poolID string, initialDelay time.Duration) {
+	if shutdown.stopping.Err() != nil {
+		return
+	}
+
+	poolMembers, err := openstackutil.GetMembersbyPool(lbaas.lb, poolID)
+	if err != nil {
+		if cpoerrors.IsNotFound(err) {
+			memberWarmups.forgetOthers(poolID, sets.NewString())
+			return
+		}
+		klog.Warningf("Failed to get the members of pool %s to bring up after their initial delay: %v", poolID, err)
+		lbaas.scheduleMemberWarmup(lbID, poolID, initialDelay, memberWarmupRetryDelay)
+		return
+	}
+
+	var next time.Duration
+	for _, m := range poolMembers {
+		up, remaining := memberWarmups.state(fmt.Sprintf("%s/%s-%d", poolID, m.Address, m.ProtocolPort), true, initialDelay)
+		if remaining > 0 && (next == 0 || remaining < next) {
+			next = remaining
+		}
+		if !up || m.AdminStateUp {
+			continue
+		}
+
+		klog.V(2).InfoS("Bringing up member of pool after its initial delay", "member", m.ID, "pool", poolID)
+		mc := metrics.NewMetricContext("loadbalancer_member", "update")
+		_, err := v2pools.UpdateMember(lbaas.lb, poolID, m.ID, v2pools.UpdateMemberOpts{AdminStateUp: &up}).Extract()
+		if mc.ObserveRequest(err) == nil {
+			_, err = waitLoadbalancerActiveProvisioningStatus(lbaas.lb, lbID)
+		}
+		if err != nil {
+			// The member is brought up by the retry or by the next reconcile, as it's no longer warming up
+			klog.Warningf("Failed to bring up member %s of pool %s after its initial delay: %v", m.ID, poolID, err)
+			next = memberWarmupRetryDelay
+			break
 		}
-		klog.V(2).Infof("Successfully updated %d members for pool %s", len(members), pool.ID)
 	}
 
-	return pool, nil
+	if next > 0 {
+		lbaas.scheduleMemberWarmup(lbID, poolID, initialDelay, next)
+	}
 }
 
 // Make sure the listener is created for Service
@@ -1137,12 +2412,8 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 		lbListeners[key] = &oldListeners[i]
 	}
 
-	proto := toListenersProtocol(port.Protocol)
-	if svcConf.keepClientIP {
-		proto = listeners.ProtocolHTTP
-	}
 	listener, ok := lbListeners[listenerKey{
-		Protocol: proto,
+		Protocol: getListenerProtocol(port, svcConf),
 		Port:     int(port.Port),
 	}]
 
@@ -1154,6 +2425,9 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 			ConnLimit:      &svcConf.connLimit,
 			LoadbalancerID: lbID,
 		}
+		if svcConf.listenerNamePrefix != "" {
+			listenerCreateOpt.Name = cutString(fmt.Sprintf("%s%s_%d", svcConf.listenerNamePrefix, listenerProtocol, port.Port))
+		}
 
 		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout) {
 			listenerCreateOpt.TimeoutClientData = &svcConf.timeoutClientData
@@ -1162,19 +2436,26 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 			listenerCreateOpt.TimeoutTCPInspect = &svcConf.timeoutTCPInspect
 		}
 
-		if svcConf.keepClientIP {
+		if svcConf.keepClientIP && port.Protocol == corev1.ProtocolTCP {
 			if listenerCreateOpt.Protocol != listeners.ProtocolHTTP {
-				klog.V(4).Infof("Forcing to use %q protocol for listener because %q annotation is set", listeners.ProtocolHTTP, ServiceAnnotationLoadBalancerXForwardedFor)
+				klog.V(4).InfoS("Forcing the protocol of the listener because the annotation is set", "protocol", listeners.ProtocolHTTP, "annotation", ServiceAnnotationLoadBalancerXForwardedFor)
 				listenerCreateOpt.Protocol = listeners.ProtocolHTTP
 			}
-			listenerCreateOpt.InsertHeaders = map[string]string{annotationXForwardedFor: "true"}
+			listenerCreateOpt.InsertHeaders = svcConf.insertHeaders
+		}
+
+		if svcConf.tlsContainerRef != "" && port.Protocol == corev1.ProtocolTCP {
+			klog.V(4).InfoS("Using the protocol for listener because the annotation is set", "protocol", listeners.ProtocolTerminatedHTTPS, "annotation", ServiceAnnotationLoadBalancerDefaultTLSContainerRef)
+			listenerCreateOpt.Protocol = listeners.ProtocolTerminatedHTTPS
+			listenerCreateOpt.DefaultTlsContainerRef = svcConf.tlsContainerRef
+			listenerCreateOpt.SniContainerRefs = svcConf.sniContainerRefs
 		}
 
 		if len(svcConf.allowedCIDR) > 0 {
 			listenerCreateOpt.AllowedCIDRs = svcConf.allowedCIDR
 		}
 
-		klog.V(2).Infof("Creating listener for port %d using protocol %s", int(port.Port), listenerProtocol)
+		klog.V(2).InfoS("Creating listener for port", "port", int(port.Port), "protocol", listenerProtocol)
 
 		var err error
 		listener, err = openstackutil.CreateListener(lbaas.lb, lbID, listenerCreateOpt)
@@ -1182,7 +2463,7 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 			return nil, fmt.Errorf("failed to create listener for loadbalancer %s: %v", lbID, err)
 		}
 
-		klog.V(4).Infof("Listener %s created for loadbalancer %s", listener.ID, lbID)
+		klog.V(4).InfoS("Listener created for loadbalancer", "listener", listener.ID, "loadbalancer", lbID)
 	} else {
 		listenerChanged := false
 		updateOpts := listeners.UpdateOpts{}
@@ -1191,14 +2472,13 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 			updateOpts.ConnLimit = &svcConf.connLimit
 			listenerChanged = true
 		}
-		updateOpts.InsertHeaders = &listener.InsertHeaders
-		listenerKeepClientIP := listener.InsertHeaders[annotationXForwardedFor] == "true"
-		if svcConf.keepClientIP != listenerKeepClientIP {
-			if svcConf.keepClientIP {
-				(*updateOpts.InsertHeaders)[annotationXForwardedFor] = "true"
-			} else {
-				delete(*updateOpts.InsertHeaders, annotationXForwardedFor)
-			}
+		var insertHeaders map[string]string
+		if svcConf.keepClientIP && port.Protocol == corev1.ProtocolTCP {
+			insertHeaders = svcConf.insertHeaders
+		}
+		headers, headersChanged := reconcileInsertHeaders(listener.InsertHeaders, insertHeaders)
+		updateOpts.InsertHeaders = &headers
+		if headersChanged {
 			listenerChanged = true
 		}
 		if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout) {
@@ -1225,12 +2505,27 @@ func (lbaas *LbaasV2) ensureOctaviaListener(lbID string, oldListeners []listener
 				listenerChanged = true
 			}
 		}
+		// The certificates are rotated in place
+		if listeners.Protocol(listener.Protocol) == listeners.ProtocolTerminatedHTTPS {
+			if svcConf.tlsContainerRef != listener.DefaultTlsContainerRef {
+				updateOpts.DefaultTlsContainerRef = &svcConf.tlsContainerRef
+				listenerChanged = true
+			}
+			if !cpoutil.StringListEqual(svcConf.sniContainerRefs, listener.SniContainerRefs) {
+				sniContainerRefs := svcConf.sniContainerRefs
+				if sniContainerRefs == nil {
+					sniContainerRefs = []string{}
+				}
+				updateOpts.SniContainerRefs = &sniContainerRefs
+				listenerChanged = true
+			}
+		}
 
 		if listenerChanged {
 			if err := openstackutil.UpdateListener(lbaas.lb, lbID, listener.ID, updateOpts); err != nil {
 				return nil, fmt.Errorf("failed to update listener %s of loadbalancer %s: %v", listener.ID, lbID, err)
 			}
-			klog.V(2).Infof("Listener %s updated for loadbalancer %s", listener.ID, lbID)
+			klog.V(2).InfoS("Listener updated for loadbalancer", "listener", listener.ID, "loadbalancer", lbID)
 		}
 	}
 
@@ -1247,19 +2542,28 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 	if len(ports) == 0 {
 		return fmt.Errorf("no service ports provided")
 	}
+	for _, port := range ports {
+		if port.Protocol == corev1.ProtocolSCTP && !openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureSCTP) {
+			return fmt.Errorf("SCTP port %d of Service %s is not supported by the Octavia version", port.Port, serviceName)
+		}
+	}
 
 	// If in the config file internal-lb=true, user is not allowed to create external service.
 	if lbaas.opts.InternalLB {
 		svcConf.internal = true
 	} else {
-		internal, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerInternal, lbaas.opts.InternalLB)
+		internal, err := getInternalFromServiceAnnotation(service, lbaas.opts.InternalLB)
 		if err != nil {
 			return err
 		}
 		svcConf.internal = internal
 	}
 
-	svcConf.connLimit = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerConnLimit, -1)
+	connLimit, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerConnLimit, -1, -1)
+	if err != nil {
+		return err
+	}
+	svcConf.connLimit = connLimit
 
 	svcConf.lbNetworkID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerNetworkID, lbaas.opts.NetworkID)
 	svcConf.lbSubnetID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerSubnetID, lbaas.opts.SubnetID)
@@ -1283,7 +2587,7 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 		var floatingNetworkID string
 		var floatingSubnetID string
 
-		klog.V(4).Infof("Ensure an external loadbalancer service")
+		klog.V(4).InfoS("Ensure an external loadbalancer service")
 
 		svcConf.configClassName = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerClass, "")
 		if svcConf.configClassName != "" {
@@ -1292,7 +2596,7 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 				return fmt.Errorf("invalid loadbalancer class %q", svcConf.configClassName)
 			}
 
-			klog.V(4).Infof("Found loadbalancer class %q with %+v", svcConf.configClassName, lbClass)
+			klog.V(4).InfoS("Found loadbalancer class", "class", svcConf.configClassName, "config", lbClass)
 
 			// Get floating network id and floating subnet id from loadbalancer class
 			if lbClass.FloatingNetworkID != "" {
@@ -1347,28 +2651,57 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 		svcConf.lbPublicNetworkID = floatingNetworkID
 		svcConf.lbPublicSubnetID = floatingSubnetID
 	} else {
-		klog.V(4).Infof("Ensure an internal loadbalancer service.")
+		klog.V(4).InfoS("Ensure an internal loadbalancer service")
+
+		// An internal load balancer never gets a floating IP
+		if _, ok := service.Annotations[ServiceAnnotationLoadBalancerFloatingIP]; ok {
+			return fmt.Errorf("annotation %s cannot be used for an internal loadbalancer", ServiceAnnotationLoadBalancerFloatingIP)
+		}
 	}
 
+	vipSubnetID, err := lbaas.getVIPSubnetID(service, svcConf)
+	if err != nil {
+		return err
+	}
+	svcConf.lbVIPSubnetID = vipSubnetID
+
 	keepClientIP, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerXForwardedFor, false)
 	if err != nil {
 		return err
 	}
-	useProxyProtocol, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProxyEnabled, false)
+	proxyProtocol, err := getProxyProtocolFromServiceAnnotation(service)
 	if err != nil {
 		return err
 	}
-	if useProxyProtocol && keepClientIP {
+	if proxyProtocol != "" && keepClientIP {
 		return fmt.Errorf("annotation %s and %s cannot be used together", ServiceAnnotationLoadBalancerProxyEnabled, ServiceAnnotationLoadBalancerXForwardedFor)
 	}
 	svcConf.keepClientIP = keepClientIP
-	svcConf.enableProxyProtocol = useProxyProtocol
+	svcConf.proxyProtocol = proxyProtocol
+
+	insertHeaders, err := getInsertHeadersFromServiceAnnotation(service, keepClientIP)
+	if err != nil {
+		return err
+	}
+	svcConf.insertHeaders = insertHeaders
 
 	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureTimeout) {
-		svcConf.timeoutClientData = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTimeoutClientData, 50000)
-		svcConf.timeoutMemberConnect = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTimeoutMemberConnect, 5000)
-		svcConf.timeoutMemberData = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTimeoutMemberData, 50000)
-		svcConf.timeoutTCPInspect = getIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTimeoutTCPInspect, 0)
+		for _, timeout := range []struct {
+			annotation   string
+			defaultValue int
+			value        *int
+		}{
+			{ServiceAnnotationLoadBalancerTimeoutClientData, 50000, &svcConf.timeoutClientData},
+			{ServiceAnnotationLoadBalancerTimeoutMemberConnect, 5000, &svcConf.timeoutMemberConnect},
+			{ServiceAnnotationLoadBalancerTimeoutMemberData, 50000, &svcConf.timeoutMemberData},
+			{ServiceAnnotationLoadBalancerTimeoutTCPInspect, 0, &svcConf.timeoutTCPInspect},
+		} {
+			value, err := getMinIntFromServiceAnnotation(service, timeout.annotation, timeout.defaultValue, 0)
+			if err != nil {
+				return err
+			}
+			*timeout.value = value
+		}
 	}
 
 	var listenerAllowedCIDRs []string
@@ -1377,7 +2710,7 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 		return fmt.Errorf("failed to get source ranges for loadbalancer service %s: %v", serviceName, err)
 	}
 	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureVIPACL) {
-		klog.V(4).Info("LoadBalancerSourceRanges is suppported")
+		klog.V(4).InfoS("LoadBalancerSourceRanges is suppported")
 		listenerAllowedCIDRs = sourceRanges.StringSlice()
 	} else {
 		klog.Warning("LoadBalancerSourceRanges is ignored")
@@ -1386,8 +2719,19 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 
 	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureFlavors) {
 		svcConf.flavorID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerFlavorID, lbaas.opts.FlavorID)
+
+		flavorName := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerFlavor, "")
+		if _, ok := service.Annotations[ServiceAnnotationLoadBalancerFlavorID]; !ok && flavorName != "" {
+			flavorID, err := openstackutil.GetFlavorIDByName(lbaas.lb, flavorName)
+			if err != nil {
+				return fmt.Errorf("failed to find the Octavia flavor %s: %v", flavorName, err)
+			}
+			svcConf.flavorID = flavorID
+		}
 	}
 
+	svcConf.lbProvider = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProvider, lbaas.opts.LBProvider)
+
 	availabilityZone := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAvailabilityZone, lbaas.opts.AvailabilityZone)
 	if openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureAvailabilityZones) {
 		svcConf.availabilityZone = availabilityZone
@@ -1401,9 +2745,196 @@ func (lbaas *LbaasV2) checkService(service *corev1.Service, nodes []*corev1.Node
 	}
 	svcConf.enableMonitor = enableHealthMonitor
 
+	drainTimeout, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerDrainTimeout, 0, 0)
+	if err != nil {
+		return err
+	}
+	svcConf.drainTimeout = time.Duration(drainTimeout) * time.Second
+
+	createTimeout, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerCreateTimeout, 0, 0)
+	if err != nil {
+		return err
+	}
+	svcConf.createTimeout = time.Duration(createTimeout) * time.Second
+
+	lbMethod, err := getLBMethodFromServiceAnnotation(service, lbaas.opts.LBMethod, svcConf.lbProvider)
+	if err != nil {
+		return err
+	}
+	svcConf.lbMethod = lbMethod
+
+	if err := getHealthMonitorConfig(service, svcConf); err != nil {
+		return err
+	}
+	if err := getHealthMonitorTiming(service, svcConf, lbaas.opts); err != nil {
+		return err
+	}
+
+	if err := lbaas.checkTLSContainerRefs(service, svcConf); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkTLSContainerRefs reads the TLS container references of the Service and checks that they are active in
+// Barbican, a Warning event is recorded on the Service for the references which can't be used.
+func (lbaas *LbaasV2) checkTLSContainerRefs(service *corev1.Service, svcConf *serviceConfig) error {
+	svcConf.tlsContainerRef = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerDefaultTLSContainerRef, "")
+	for _, ref := range strings.Split(getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerSNIContainerRefs, ""), ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			svcConf.sniContainerRefs = append(svcConf.sniContainerRefs, ref)
+		}
+	}
+	if svcConf.tlsContainerRef == "" {
+		if len(svcConf.sniContainerRefs) > 0 {
+			return lbaas.invalidTLSContainerRef(service, fmt.Errorf("annotation %s requires annotation %s", ServiceAnnotationLoadBalancerSNIContainerRefs, ServiceAnnotationLoadBalancerDefaultTLSContainerRef))
+		}
+		return nil
+	}
+
+	if lbaas.secret == nil {
+		return lbaas.invalidTLSContainerRef(service, fmt.Errorf("annotation %s is set but the key-manager service is not available", ServiceAnnotationLoadBalancerDefaultTLSContainerRef))
+	}
+
+	for _, ref := range append([]string{svcConf.tlsContainerRef}, svcConf.sniContainerRefs...) {
+		status, err := openstackutil.GetContainerRefStatus(lbaas.secret, ref)
+		if err != nil {
+			if err == openstackutil.ErrNotFound {
+				return lbaas.invalidTLSContainerRef(service, fmt.Errorf("the TLS container %s is not found", ref))
+			}
+			return fmt.Errorf("failed to get the TLS container %s: %v", ref, err)
+		}
+		if status != activeStatus {
+			return lbaas.invalidTLSContainerRef(service, fmt.Errorf("the TLS container %s is %s instead of %s", ref, status, activeStatus))
+		}
+	}
+
 	return nil
 }
 
+// checkNamespaceAllowed returns an error, and records it on the Service, when the namespace of the Service isn't
+// allowed to have load balancers. The Service is left pending, the load balancers created before the namespace
+// was disallowed are kept but no longer updated.
+func (lbaas *LbaasV2) checkNamespaceAllowed(service *corev1.Service) error {
+	if len(lbaas.opts.AllowedNamespaces) == 0 {
+		return nil
+	}
+	for _, namespace := range lbaas.opts.AllowedNamespaces {
+		if namespace == service.Namespace {
+			return nil
+		}
+	}
+
+	err := fmt.Errorf("the Services of namespace %s are not allowed to have a load balancer, see `allowed-namespace` in section [LoadBalancer]", service.Namespace)
+	if lbaas.eventRecorder != nil {
+		lbaas.eventRecorder.Event(service, corev1.EventTypeWarning, EventReasonNamespaceNotAllowed, err.Error())
+	}
+	return err
+}
+
+// invalidTLSContainerRef records the error on the Service and returns it.
+func (lbaas *LbaasV2) invalidTLSContainerRef(service *corev1.Service, err error) error {
+	if lbaas.eventRecorder != nil {
+		lbaas.eventRecorder.Event(service, corev1.EventTypeWarning, EventReasonInvalidTLSContainerRef, err.Error())
+	}
+	return err
+}
+
+// loadBalancerHealth remembers the unhealthy objects of each load balancer, so that only the transitions of their
+// statuses are recorded as events
+type loadBalancerHealth struct {
+	sync.Mutex
+	problems map[string]map[string]string
+}
+
+func newLoadBalancerHealth() *loadBalancerHealth {
+	return &loadBalancerHealth{problems: make(map[string]map[string]string)}
+}
+
+// swap remembers the problems of the load balancer and returns the previous ones.
+func (h *loadBalancerHealth) swap(lbID string, problems map[string]string) map[string]string {
+	if h == nil {
+		return nil
+	}
+	h.Lock()
+	defer h.Unlock()
+	previous := h.problems[lbID]
+	if len(problems) == 0 {
+		delete(h.problems, lbID)
+	} else {
+		h.problems[lbID] = problems
+	}
+	return previous
+}
+
+func isUnhealthyStatus(status string) bool {
+	return status == errorStatus || status == degradedStatus
+}
+
+// getLoadBalancerProblems returns the statuses of the objects of the status tree which are ERROR or DEGRADED, keyed
+// by the description of the object. A member is described with its pool.
+func getLoadBalancerProblems(tree *loadbalancers.StatusTree) map[string]string {
+	problems := make(map[string]string)
+	check := func(object, provisioningStatus, operatingStatus string) {
+		if isUnhealthyStatus(provisioningStatus) {
+			problems[object] = fmt.Sprintf("provisioning status %s", provisioningStatus)
+		} else if isUnhealthyStatus(operatingStatus) {
+			problems[object] = fmt.Sprintf("operating status %s", operatingStatus)
+		}
+	}
+
+	if tree == nil || tree.Loadbalancer == nil {
+		return problems
+	}
+	lb := tree.Loadbalancer
+	check("loadbalancer", lb.ProvisioningStatus, lb.OperatingStatus)
+	for _, listener := range lb.Listeners {
+		check(fmt.Sprintf("listener %s", listener.ID), listener.ProvisioningStatus, listener.OperatingStatus)
+		for _, pool := range listener.Pools {
+			poolName := fmt.Sprintf("pool %s(%s)", pool.Name, pool.ID)
+			check(poolName, pool.ProvisioningStatus, pool.OperatingStatus)
+			for _, member := range pool.Members {
+				check(fmt.Sprintf("member %s:%d(%s) of %s", member.Address, member.ProtocolPort, member.ID, poolName), member.ProvisioningStatus, member.OperatingStatus)
+			}
+		}
+	}
+	return problems
+}
+
+// recordLoadBalancerHealth records the transitions of the statuses of the load balancer of the Service as events of
+// the Service. Failing to get the statuses doesn't fail the reconciliation of the Service.
+func (lbaas *LbaasV2) recordLoadBalancerHealth(service *corev1.Service, lbID string) {
+	if lbaas.eventRecorder == nil {
+		return
+	}
+
+	mc := metrics.NewMetricContext("loadbalancer", "get_statuses")
+	tree, err := loadbalancers.GetStatuses(lbaas.lb, lbID).Extract()
+	if mc.ObserveRequest(err) != nil {
+		klog.Warningf("Failed to get the statuses of loadbalancer %s: %v", lbID, err)
+		return
+	}
+
+	problems := getLoadBalancerProblems(tree)
+	previous := lbaas.health.swap(lbID, problems)
+
+	objects := make([]string, 0, len(problems))
+	for object := range problems {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+	for _, object := range objects {
+		if previous[object] == problems[object] {
+			continue
+		}
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeWarning, EventReasonLoadBalancerUnhealthy, "Loadbalancer %s: the %s is in %s", lbID, object, problems[object])
+	}
+	if len(problems) == 0 && len(previous) > 0 {
+		lbaas.eventRecorder.Eventf(service, corev1.EventTypeNormal, EventReasonLoadBalancerHealthy, "Loadbalancer %s recovered", lbID)
+	}
+}
+
 func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
 	svcConf := new(serviceConfig)
 
@@ -1413,26 +2944,59 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
 
+	svcConf.sharedLBName = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerShared, "")
+	if svcConf.sharedLBName != "" {
+		svcConf.listenerNamePrefix = serviceListenerPrefix(clusterName, service)
+	}
+
+	svcConf.serviceTag = lbaas.serviceLoadBalancerTag(service)
+
 	// Use more meaningful name for the load balancer but still need to check the legacy name for backward compatibility.
-	name := lbaas.GetLoadBalancerName(ctx, clusterName, service)
-	legacyName := lbaas.GetLoadBalancerLegacyName(ctx, clusterName, service)
-	loadbalancer, err := getLoadbalancerByName(lbaas.lb, name, legacyName)
+	name, legacyName := lbaas.getLoadBalancerNames(ctx, clusterName, service)
+	operation := "update"
+	loadbalancer, err := getServiceLoadbalancer(lbaas.lb, svcConf, name, legacyName)
+	if adoptID := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerAdopt, ""); err == ErrNotFound && adoptID != "" && svcConf.sharedLBName == "" {
+		loadbalancer, err = lbaas.adoptLoadBalancer(service, adoptID, name, clusterName, svcConf)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if err != nil {
 		if err != ErrNotFound {
 			return nil, fmt.Errorf("error getting loadbalancer for Service %s: %v", serviceName, err)
 		}
 
-		klog.V(2).Infof("Creating loadbalancer %s", name)
+		klog.V(2).InfoS("Creating loadbalancer", "name", name)
+		operation = "create"
 		loadbalancer, err = lbaas.createOctaviaLoadBalancer(service, name, clusterName, svcConf)
 		if err != nil {
 			return nil, fmt.Errorf("error creating loadbalancer %s: %v", name, err)
 		}
 	} else {
-		klog.V(2).Infof("LoadBalancer %s(%s) already exists", loadbalancer.Name, loadbalancer.ID)
+		klog.V(2).InfoS("LoadBalancer already exists", "name", loadbalancer.Name, "loadbalancer", loadbalancer.ID)
+
+		// Octavia can't move the VIP to another subnet
+		if svcConf.lbVIPSubnetID != "" && svcConf.lbVIPSubnetID != loadbalancer.VipSubnetID {
+			return nil, fmt.Errorf("the VIP subnet of loadbalancer %s can't be changed from %s to %s", loadbalancer.ID, loadbalancer.VipSubnetID, svcConf.lbVIPSubnetID)
+		}
+		// Neither the provider nor the flavor of a load balancer can be updated
+		if _, ok := service.Annotations[ServiceAnnotationLoadBalancerProvider]; ok && svcConf.lbProvider != loadbalancer.Provider {
+			return nil, fmt.Errorf("the provider of loadbalancer %s can't be changed from %s to %s", loadbalancer.ID, loadbalancer.Provider, svcConf.lbProvider)
+		}
+		if _, ok := service.Annotations[ServiceAnnotationLoadBalancerFlavor]; ok && svcConf.flavorID != loadbalancer.FlavorID {
+			return nil, fmt.Errorf("the flavor of loadbalancer %s can't be changed from %s to %s", loadbalancer.ID, loadbalancer.FlavorID, svcConf.flavorID)
+		}
 	}
 
-	provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, loadbalancer.ID)
+	start := time.Now()
+	provisioningStatus, err := waitLoadbalancerActiveTimeout(lbaas.lb, loadbalancer.ID, svcConf.createTimeout)
+	metrics.ObserveLoadBalancerProvisioning(operation, provisioningStatus, start)
 	if err != nil {
+		if isPendingStatus(provisioningStatus) {
+			// The loadbalancer is found by its name when the Service is synced again
+			klog.V(2).InfoS("Loadbalancer is still pending, the Service will be synced again", "loadbalancer", loadbalancer.ID, "status", provisioningStatus, "service", serviceName, "err", err)
+			return nil, fmt.Errorf("loadbalancer %s is still %s, retrying later", loadbalancer.ID, provisioningStatus)
+		}
 		return nil, fmt.Errorf("timeout when waiting for loadbalancer %s to be ACTIVE, current provisioning status %s", loadbalancer.ID, provisioningStatus)
 	}
 
@@ -1441,6 +3005,15 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		return nil, fmt.Errorf("failed to get listeners for load balancer %s: %v", loadbalancer.Name, err)
 	}
 
+	// Only the listeners of the Service are managed on a shared load balancer
+	if svcConf.listenerNamePrefix != "" {
+		var otherListeners []listeners.Listener
+		oldListeners, otherListeners = filterServiceListeners(oldListeners, svcConf.listenerNamePrefix)
+		if err := checkListenerConflicts(service, otherListeners, svcConf); err != nil {
+			return nil, err
+		}
+	}
+
 	for _, port := range service.Spec.Ports {
 		listener, err := lbaas.ensureOctaviaListener(loadbalancer.ID, oldListeners, service, port, svcConf)
 		if err != nil {
@@ -1465,6 +3038,14 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 		return nil, err
 	}
 
+	if lbaas.opts.ManageSecurityGroups {
+		if err := lbaas.ensureOctaviaSecurityGroup(clusterName, service, nodes, svcConf); err != nil {
+			return nil, fmt.Errorf("failed to ensure the security group of Service %s: %v", serviceName, err)
+		}
+	}
+
+	lbaas.recordLoadBalancerHealth(service, loadbalancer.ID)
+
 	addr, err := lbaas.getServiceAddress(clusterName, service, loadbalancer, svcConf)
 	if err != nil {
 		return nil, err
@@ -1480,13 +3061,22 @@ func (lbaas *LbaasV2) ensureOctaviaLoadBalancer(ctx context.Context, clusterName
 // EnsureLoadBalancer creates a new load balancer or updates the existing one.
 func (lbaas *LbaasV2) EnsureLoadBalancer(ctx context.Context, clusterName string, apiService *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
 	mc := metrics.NewMetricContext("loadbalancer", "ensure")
+	if err := shutdown.stopping.Err(); err != nil {
+		return nil, mc.ObserveReconcile(fmt.Errorf("not ensuring the loadbalancer of Service %s/%s on shutdown: %v", apiService.Namespace, apiService.Name, err))
+	}
+	ctx, done := shutdown.track(ctx)
+	defer done()
 	status, err := lbaas.ensureLoadBalancer(ctx, clusterName, apiService, nodes)
 	return status, mc.ObserveReconcile(err)
 }
 
 func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string, apiService *corev1.Service, nodes []*corev1.Node) (*corev1.LoadBalancerStatus, error) {
 	serviceName := fmt.Sprintf("%s/%s", apiService.Namespace, apiService.Name)
-	klog.V(4).Infof("EnsureLoadBalancer(%s, %s)", clusterName, serviceName)
+	klog.V(4).InfoS("EnsureLoadBalancer() called", "cluster", clusterName, "service", klog.KObj(apiService))
+
+	if err := lbaas.checkNamespaceAllowed(apiService); err != nil {
+		return nil, err
+	}
 
 	if lbaas.opts.UseOctavia {
 		return lbaas.ensureOctaviaLoadBalancer(ctx, clusterName, apiService, nodes)
@@ -1518,7 +3108,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 		return nil, fmt.Errorf("no ports provided to openstack load balancer")
 	}
 
-	internalAnnotation, err := getBoolFromServiceAnnotation(apiService, ServiceAnnotationLoadBalancerInternal, lbaas.opts.InternalLB)
+	internalAnnotation, err := getInternalFromServiceAnnotation(apiService, lbaas.opts.InternalLB)
 	if err != nil {
 		return nil, err
 	}
@@ -1527,7 +3117,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 	var floatingNetworkID string
 	var floatingSubnetID string
 	if !internalAnnotation {
-		klog.V(4).Infof("Ensure an external loadbalancer service")
+		klog.V(4).InfoS("Ensure an external loadbalancer service")
 
 		class := getStringFromServiceAnnotation(apiService, ServiceAnnotationLoadBalancerClass, "")
 		if class != "" {
@@ -1536,7 +3126,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 				return nil, fmt.Errorf("invalid loadbalancer class %q", class)
 			}
 
-			klog.V(4).Infof("found loadbalancer class %q with %+v", class, lbClass)
+			klog.V(4).InfoS("Found loadbalancer class", "class", class, "config", lbClass)
 
 			// read floating network id and floating subnet id from loadbalancer class
 			if lbClass.FloatingNetworkID != "" {
@@ -1587,7 +3177,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			}
 		}
 	} else {
-		klog.V(4).Infof("Ensure an internal loadbalancer service.")
+		klog.V(4).InfoS("Ensure an internal loadbalancer service")
 	}
 
 	var keepClientIP bool
@@ -1631,7 +3221,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 		return nil, fmt.Errorf("failed to get source ranges for loadbalancer service %s: %v", serviceName, err)
 	}
 	if lbaas.opts.UseOctavia && openstackutil.IsOctaviaFeatureSupported(lbaas.lb, openstackutil.OctaviaFeatureVIPACL) {
-		klog.V(4).Info("loadBalancerSourceRanges is suppported")
+		klog.V(4).InfoS("loadBalancerSourceRanges is suppported")
 		listenerAllowedCIDRs = sourceRanges.StringSlice()
 	} else if !IsAllowAll(sourceRanges) && !lbaas.opts.ManageSecurityGroups {
 		return nil, fmt.Errorf("source range restrictions are not supported for openstack load balancers without managing security groups")
@@ -1657,7 +3247,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			return nil, fmt.Errorf("error getting loadbalancer for Service %s: %v", serviceName, err)
 		}
 
-		klog.V(2).Infof("Creating loadbalancer %s", name)
+		klog.V(2).InfoS("Creating loadbalancer", "name", name)
 
 		portID := ""
 		if lbClass == nil {
@@ -1668,7 +3258,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			return nil, fmt.Errorf("error creating loadbalancer %s: %v", name, err)
 		}
 	} else {
-		klog.V(2).Infof("LoadBalancer %s already exists", loadbalancer.Name)
+		klog.V(2).InfoS("LoadBalancer already exists", "name", loadbalancer.Name)
 	}
 
 	provisioningStatus, err := waitLoadbalancerActiveProvisioningStatus(lbaas.lb, loadbalancer.ID)
@@ -1686,7 +3276,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 		connLimit := -1
 		tmp, err := strconv.Atoi(climit)
 		if err != nil {
-			klog.V(4).Infof("Could not parse int value from \"%s\" error \"%v\" failing back to default", climit, err)
+			klog.V(4).InfoS("Could not parse int value, failing back to default", "value", climit, "err", err)
 		} else {
 			connLimit = tmp
 		}
@@ -1704,8 +3294,8 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			if lbaas.opts.UseOctavia {
 				if keepClientIP {
 					if listenerCreateOpt.Protocol != listeners.ProtocolHTTP {
-						klog.V(4).Infof("Forcing to use %q protocol for listener because %q "+
-							"annotation is set", listeners.ProtocolHTTP, ServiceAnnotationLoadBalancerXForwardedFor)
+						klog.V(4).InfoS("Forcing the protocol of the listener because the annotation is set", "protocol", listeners.ProtocolHTTP,
+							"annotation", ServiceAnnotationLoadBalancerXForwardedFor)
 						listenerCreateOpt.Protocol = listeners.ProtocolHTTP
 					}
 					listenerCreateOpt.InsertHeaders = map[string]string{"X-Forwarded-For": "true"}
@@ -1721,14 +3311,14 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 				}
 			}
 
-			klog.V(4).Infof("Creating listener for port %d using protocol: %s", int(port.Port), listenerProtocol)
+			klog.V(4).InfoS("Creating listener for port", "port", int(port.Port), "protocol", listenerProtocol)
 
 			listener, err = openstackutil.CreateListener(lbaas.lb, loadbalancer.ID, listenerCreateOpt)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create listener for loadbalancer %s: %v", loadbalancer.ID, err)
 			}
 
-			klog.V(4).Infof("Listener %s created for loadbalancer %s", listener.ID, loadbalancer.ID)
+			klog.V(4).InfoS("Listener created for loadbalancer", "listener", listener.ID, "loadbalancer", loadbalancer.ID)
 		} else {
 			listenerChanged := false
 			updateOpts := listeners.UpdateOpts{}
@@ -1752,7 +3342,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 					return nil, fmt.Errorf("failed to update listener %s of loadbalancer %s: %v", listener.ID, loadbalancer.ID, err)
 				}
 
-				klog.V(4).Infof("Listener %s updated for loadbalancer %s", listener.ID, loadbalancer.ID)
+				klog.V(4).InfoS("Listener updated for loadbalancer", "listener", listener.ID, "loadbalancer", loadbalancer.ID)
 			}
 		}
 
@@ -1772,8 +3362,8 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 				if useProxyProtocol {
 					poolProto = v2pools.ProtocolPROXY
 				} else if keepClientIP && poolProto != v2pools.ProtocolHTTP {
-					klog.V(4).Infof("Forcing to use %q protocol for pool because %q "+
-						"annotation is set", v2pools.ProtocolHTTP, ServiceAnnotationLoadBalancerXForwardedFor)
+					klog.V(4).InfoS("Forcing the protocol of the pool because the annotation is set", "protocol", v2pools.ProtocolHTTP,
+						"annotation", ServiceAnnotationLoadBalancerXForwardedFor)
 					poolProto = v2pools.ProtocolHTTP
 				}
 			}
@@ -1787,7 +3377,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 				Persistence: persistence,
 			}
 
-			klog.V(4).Infof("Creating pool for listener %s using protocol %s", listener.ID, poolProto)
+			klog.V(4).InfoS("Creating pool for listener", "listener", listener.ID, "protocol", poolProto)
 
 			mc := metrics.NewMetricContext("loadbalancer_pool", "create")
 			pool, err = v2pools.Create(lbaas.lb, createOpt).Extract()
@@ -1801,7 +3391,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 
 		}
 
-		klog.V(4).Infof("Pool created for listener %s: %s", listener.ID, pool.ID)
+		klog.V(4).InfoS("Pool created for listener", "listener", listener.ID, "pool", pool.ID)
 
 		members, err := openstackutil.GetMembersbyPool(lbaas.lb, pool.ID)
 		if err != nil && !cpoerrors.IsNotFound(err) {
@@ -1820,7 +3410,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			}
 
 			if !memberExists(members, addr, int(port.NodePort)) {
-				klog.V(4).Infof("Creating member for pool %s", pool.ID)
+				klog.V(4).InfoS("Creating member for pool", "pool", pool.ID)
 				mc := metrics.NewMetricContext("loadbalancer_member", "create")
 				_, err := v2pools.CreateMember(lbaas.lb, pool.ID, v2pools.CreateMemberOpts{
 					Name:         cutString(fmt.Sprintf("member_%d_%s_%s", portIndex, node.Name, name)),
@@ -1841,12 +3431,12 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 				members = popMember(members, addr, int(port.NodePort))
 			}
 
-			klog.V(4).Infof("Ensured pool %s has member for %s at %s", pool.ID, node.Name, addr)
+			klog.V(4).InfoS("Ensured pool has member for node", "pool", pool.ID, "node", klog.KObj(node), "address", addr)
 		}
 
 		// Delete obsolete members for this pool
 		for _, member := range members {
-			klog.V(4).Infof("Deleting obsolete member %s for pool %s address %s", member.ID, pool.ID, member.Address)
+			klog.V(4).InfoS("Deleting obsolete member of pool", "member", member.ID, "pool", pool.ID, "address", member.Address)
 			mc := metrics.NewMetricContext("loadbalancer_member", "delete")
 			err := v2pools.DeleteMember(lbaas.lb, pool.ID, member.ID).ExtractErr()
 			if err != nil && !cpoerrors.IsNotFound(err) {
@@ -1866,7 +3456,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			return nil, err
 		}
 		if monitorID == "" && enableHealthMonitor {
-			klog.V(4).Infof("Creating monitor for pool %s", pool.ID)
+			klog.V(4).InfoS("Creating monitor for pool", "pool", pool.ID)
 			monitorProtocol := string(port.Protocol)
 			if port.Protocol == corev1.ProtocolUDP {
 				monitorProtocol = "UDP-CONNECT"
@@ -1889,7 +3479,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			}
 			monitorID = monitor.ID
 		} else if monitorID != "" && !enableHealthMonitor {
-			klog.Infof("Deleting health monitor %s for pool %s", monitorID, pool.ID)
+			klog.InfoS("Deleting health monitor of pool", "monitor", monitorID, "pool", pool.ID)
 			mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "delete")
 			err := v2monitors.Delete(lbaas.lb, monitorID).ExtractErr()
 			if mc.ObserveRequest(err) != nil {
@@ -1900,7 +3490,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 
 	// All remaining listeners are obsolete, delete
 	for _, listener := range oldListeners {
-		klog.V(4).Infof("Deleting obsolete listener %s:", listener.ID)
+		klog.V(4).InfoS("Deleting obsolete listener", "listener", listener.ID)
 		// get pool for listener
 		pool, err := openstackutil.GetPoolByListener(lbaas.lb, loadbalancer.ID, listener.ID)
 		if err != nil && err != openstackutil.ErrNotFound {
@@ -1910,7 +3500,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			// get and delete monitor
 			monitorID := pool.MonitorID
 			if monitorID != "" {
-				klog.V(4).Infof("Deleting obsolete monitor %s for pool %s", monitorID, pool.ID)
+				klog.V(4).InfoS("Deleting obsolete monitor of pool", "monitor", monitorID, "pool", pool.ID)
 				mc := metrics.NewMetricContext("loadbalancer_healthmonitor", "delete")
 				err = v2monitors.Delete(lbaas.lb, monitorID).ExtractErr()
 				if err != nil && !cpoerrors.IsNotFound(err) {
@@ -1930,7 +3520,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 			}
 			if members != nil {
 				for _, member := range members {
-					klog.V(4).Infof("Deleting obsolete member %s for pool %s address %s", member.ID, pool.ID, member.Address)
+					klog.V(4).InfoS("Deleting obsolete member of pool", "member", member.ID, "pool", pool.ID, "address", member.Address)
 					mc := metrics.NewMetricContext("loadbalancer_member", "delete")
 					err := v2pools.DeleteMember(lbaas.lb, pool.ID, member.ID).ExtractErr()
 					if err != nil && !cpoerrors.IsNotFound(err) {
@@ -1944,7 +3534,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 					}
 				}
 			}
-			klog.V(4).Infof("Deleting obsolete pool %s for listener %s", pool.ID, listener.ID)
+			klog.V(4).InfoS("Deleting obsolete pool for listener", "pool", pool.ID, "listener", listener.ID)
 			// delete pool
 			mc := metrics.NewMetricContext("loadbalancer_pool", "delete")
 			err = v2pools.Delete(lbaas.lb, pool.ID).ExtractErr()
@@ -1970,7 +3560,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 		if err != nil {
 			return nil, fmt.Errorf("timeout when waiting for loadbalancer to be ACTIVE after deleting listener, current provisioning status %s", provisioningStatus)
 		}
-		klog.V(2).Infof("Deleted obsolete listener: %s", listener.ID)
+		klog.V(2).InfoS("Deleted obsolete listener", "listener", listener.ID)
 	}
 
 	// Priority of choosing VIP port floating IP:
@@ -2020,7 +3610,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 		// third attempt: create a new floating IP
 		if floatIP == nil {
 			if floatingNetworkID != "" {
-				klog.V(4).Infof("Creating floating IP %s for loadbalancer %s", loadBalancerIP, loadbalancer.ID)
+				klog.V(4).InfoS("Creating floating IP for loadbalancer", "floatingIP", loadBalancerIP, "loadbalancer", loadbalancer.ID)
 				floatIPOpts := floatingips.CreateOpts{
 					FloatingNetworkID: floatingNetworkID,
 					PortID:            portID,
@@ -2035,7 +3625,7 @@ func (lbaas *LbaasV2) ensureLoadBalancer(ctx context.Context, clusterName string
 					floatIPOpts.FloatingIP = loadBalancerIP
 				}
 
-				klog.V(4).Infof("creating floating ip with opts %+v", floatIPOpts)
+				klog.V(4).InfoS("Creating floating IP", "opts", floatIPOpts)
 				mc := metrics.NewMetricContext("floating_ip", "create")
 				floatIP, err = floatingips.Create(lbaas.network, floatIPOpts).Extract()
 				if mc.ObserveRequest(err) != nil {
@@ -2101,7 +3691,7 @@ func (lbaas *LbaasV2) ensureSecurityGroup(clusterName string, apiService *corev1
 			return fmt.Errorf("failed to find node-security-group for loadbalancer service %s/%s: %v", apiService.Namespace, apiService.Name, err)
 		}
 
-		klog.V(4).Infof("find node-security-group %v for loadbalancer service %s/%s", lbaas.opts.NodeSecurityGroupIDs, apiService.Namespace, apiService.Name)
+		klog.V(4).InfoS("Found node-security-group for loadbalancer service", "securityGroups", lbaas.opts.NodeSecurityGroupIDs, "service", klog.KObj(apiService))
 	}
 
 	// get service ports
@@ -2325,7 +3915,7 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 	}
 
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	klog.V(2).Infof("Updating %d nodes for Service %s in cluster %s", len(nodes), serviceName, clusterName)
+	klog.V(2).InfoS("Updating the nodes of Service", "service", serviceName, "cluster", clusterName, "nodes", len(nodes))
 
 	// Find subnet ID for creating members
 	if lbaas.opts.SubnetID != "" {
@@ -2358,19 +3948,37 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 	if err != nil {
 		return err
 	}
-	useProxyProtocol, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProxyEnabled, false)
+	proxyProtocol, err := getProxyProtocolFromServiceAnnotation(service)
 	if err != nil {
 		return err
 	}
-	if useProxyProtocol && keepClientIP {
+	if proxyProtocol != "" && keepClientIP {
 		return fmt.Errorf("annotation %s and %s cannot be used together", ServiceAnnotationLoadBalancerProxyEnabled, ServiceAnnotationLoadBalancerXForwardedFor)
 	}
 	svcConf.keepClientIP = keepClientIP
-	svcConf.enableProxyProtocol = useProxyProtocol
+	svcConf.proxyProtocol = proxyProtocol
+	svcConf.tlsContainerRef = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerDefaultTLSContainerRef, "")
+
+	drainTimeout, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerDrainTimeout, 0, 0)
+	if err != nil {
+		return err
+	}
+	svcConf.drainTimeout = time.Duration(drainTimeout) * time.Second
+
+	// The initial delay of the members applies to the nodes added by the updates
+	if err := getHealthMonitorTiming(service, svcConf, lbaas.opts); err != nil {
+		return err
+	}
+
+	lbProvider := getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerProvider, lbaas.opts.LBProvider)
+	lbMethod, err := getLBMethodFromServiceAnnotation(service, lbaas.opts.LBMethod, lbProvider)
+	if err != nil {
+		return err
+	}
+	svcConf.lbMethod = lbMethod
 
 	// Get load balancer
-	name := lbaas.GetLoadBalancerName(ctx, clusterName, service)
-	legacyName := lbaas.GetLoadBalancerLegacyName(ctx, clusterName, service)
+	name, legacyName := lbaas.getLoadBalancerNames(ctx, clusterName, service)
 	loadbalancer, err := getLoadbalancerByName(lbaas.lb, name, legacyName)
 	if err != nil {
 		return err
@@ -2385,6 +3993,9 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 	if err != nil {
 		return fmt.Errorf("error getting listeners for LB %s: %v", loadbalancer.ID, err)
 	}
+	if getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerShared, "") != "" {
+		allListeners, _ = filterServiceListeners(allListeners, serviceListenerPrefix(clusterName, service))
+	}
 	for _, l := range allListeners {
 		key := listenerKey{Protocol: listeners.Protocol(l.Protocol), Port: l.ProtocolPort}
 		lbListeners[key] = l
@@ -2392,12 +4003,8 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 
 	// Update pool members for each listener.
 	for _, port := range ports {
-		proto := toListenersProtocol(port.Protocol)
-		if svcConf.keepClientIP {
-			proto = listeners.ProtocolHTTP
-		}
 		listener, ok := lbListeners[listenerKey{
-			Protocol: proto,
+			Protocol: getListenerProtocol(port, svcConf),
 			Port:     int(port.Port),
 		}]
 		if !ok {
@@ -2410,17 +4017,34 @@ func (lbaas *LbaasV2) updateOctaviaLoadBalancer(ctx context.Context, clusterName
 		}
 	}
 
+	if lbaas.opts.ManageSecurityGroups {
+		if err := lbaas.ensureOctaviaSecurityGroup(clusterName, service, nodes, svcConf); err != nil {
+			return fmt.Errorf("failed to ensure the security group of Service %s: %v", serviceName, err)
+		}
+	}
+
+	lbaas.recordLoadBalancerHealth(service, loadbalancer.ID)
+
 	return nil
 }
 
 // UpdateLoadBalancer updates hosts under the specified load balancer.
 func (lbaas *LbaasV2) UpdateLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) error {
 	mc := metrics.NewMetricContext("loadbalancer", "update")
+	if err := shutdown.stopping.Err(); err != nil {
+		return mc.ObserveReconcile(fmt.Errorf("not updating the loadbalancer of Service %s/%s on shutdown: %v", service.Namespace, service.Name, err))
+	}
+	ctx, done := shutdown.track(ctx)
+	defer done()
 	err := lbaas.updateLoadBalancer(ctx, clusterName, service, nodes)
 	return mc.ObserveReconcile(err)
 }
 
 func (lbaas *LbaasV2) updateLoadBalancer(ctx context.Context, clusterName string, service *corev1.Service, nodes []*corev1.Node) error {
+	if err := lbaas.checkNamespaceAllowed(service); err != nil {
+		return err
+	}
+
 	if lbaas.opts.UseOctavia {
 		return lbaas.updateOctaviaLoadBalancer(ctx, clusterName, service, nodes)
 	}
@@ -2429,7 +4053,7 @@ func (lbaas *LbaasV2) updateLoadBalancer(ctx context.Context, clusterName string
 	// and not recommended to use in production. No new features should be added.
 
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	klog.V(4).Infof("UpdateLoadBalancer(%v, %s, %v)", clusterName, serviceName, nodes)
+	klog.V(4).InfoS("UpdateLoadBalancer() called", "cluster", clusterName, "service", klog.KObj(service), "nodes", len(nodes))
 
 	lbaas.opts.SubnetID = getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerSubnetID, lbaas.opts.SubnetID)
 	if len(lbaas.opts.SubnetID) == 0 && len(nodes) > 0 {
@@ -2583,7 +4207,7 @@ func (lbaas *LbaasV2) updateSecurityGroup(clusterName string, apiService *corev1
 	if err != nil {
 		return fmt.Errorf("failed to find node-security-group for loadbalancer service %s/%s: %v", apiService.Namespace, apiService.Name, err)
 	}
-	klog.V(4).Infof("find node-security-group %v for loadbalancer service %s/%s", lbaas.opts.NodeSecurityGroupIDs, apiService.Namespace, apiService.Name)
+	klog.V(4).InfoS("Found node-security-group for loadbalancer service", "securityGroups", lbaas.opts.NodeSecurityGroupIDs, "service", klog.KObj(apiService))
 
 	original := sets.NewString(originalNodeSecurityGroupIDs...)
 	current := sets.NewString(lbaas.opts.NodeSecurityGroupIDs...)
@@ -2660,17 +4284,139 @@ func (lbaas *LbaasV2) updateSecurityGroup(clusterName string, apiService *corev1
 // EnsureLoadBalancerDeleted deletes the specified load balancer
 func (lbaas *LbaasV2) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *corev1.Service) error {
 	mc := metrics.NewMetricContext("loadbalancer", "delete")
+	if err := shutdown.stopping.Err(); err != nil {
+		return mc.ObserveReconcile(fmt.Errorf("not deleting the loadbalancer of Service %s/%s on shutdown: %v", service.Namespace, service.Name, err))
+	}
+	ctx, done := shutdown.track(ctx)
+	defer done()
 	err := lbaas.ensureLoadBalancerDeleted(ctx, clusterName, service)
 	return mc.ObserveReconcile(err)
 }
 
+// retainedDescriptionRegexp matches the description of the load balancers retained on the deletion of their Service.
+var retainedDescriptionRegexp = regexp.MustCompile(`^Kubernetes retained load balancer of service ([^/ ]+)/([^ ]+) from cluster (.+)$`)
+
+// retainedLoadBalancerName returns the name of the load balancer of the Service once retained, which no Service
+// finds by name.
+func retainedLoadBalancerName(clusterName string, service *corev1.Service) string {
+	return cutString(fmt.Sprintf("kube_retained_%s_%s_%s", clusterName, service.Namespace, service.Name))
+}
+
+// retainLoadBalancer keeps the load balancer of the deleted Service. It is renamed, described as retained and its
+// Service tag is removed, so that neither the orphan cleanup nor a new Service with the same name finds it, only the
+// Services adopting it by its ID.
+func (lbaas *LbaasV2) retainLoadBalancer(service *corev1.Service, loadbalancer *loadbalancers.LoadBalancer, clusterName string) error {
+	name := retainedLoadBalancerName(clusterName, service)
+	description := fmt.Sprintf("Kubernetes retained load balancer of service %s/%s from cluster %s", service.Namespace, service.Name, clusterName)
+	updateOpts := loadbalancers.UpdateOpts{Name: &name, Description: &description}
+	var tags []string
+	for _, tag := range loadbalancer.Tags {
+		if !strings.HasPrefix(tag, "kube_service_uid_") {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) != len(loadbalancer.Tags) {
+		updateOpts.Tags = &tags
+	}
+
+	mc := metrics.NewMetricContext("loadbalancer", "update")
+	_, err := loadbalancers.Update(lbaas.lb, loadbalancer.ID, updateOpts).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("failed to retain loadbalancer %s of Service %s/%s: %v", loadbalancer.ID, service.Namespace, service.Name, err)
+	}
+	klog.InfoS("Retained loadbalancer of the deleted Service", "name", name, "loadbalancer", loadbalancer.ID, "service", klog.KObj(service))
+	return nil
+}
+
+// adoptLoadBalancer gives the Service the load balancer retained on the deletion of a Service of the cluster. The
+// load balancer gets the name, the description and the tag of the Service, its listeners and pools are then
+// reconciled like those of the load balancers created for the Service.
+func (lbaas *LbaasV2) adoptLoadBalancer(service *corev1.Service, id, name, clusterName string, svcConf *serviceConfig) (*loadbalancers.LoadBalancer, error) {
+	mc := metrics.NewMetricContext("loadbalancer", "get")
+	loadbalancer, err := loadbalancers.Get(lbaas.lb, id).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("failed to get the loadbalancer %s adopted by Service %s/%s: %v", id, service.Namespace, service.Name, err)
+	}
+	match := retainedDescriptionRegexp.FindStringSubmatch(loadbalancer.Description)
+	if match == nil || match[3] != clusterName {
+		return nil, fmt.Errorf("loadbalancer %s can't be adopted by Service %s/%s, it wasn't retained by a Service of cluster %s", id, service.Namespace, service.Name, clusterName)
+	}
+
+	description := fmt.Sprintf("Kubernetes external service %s/%s from cluster %s", service.Namespace, service.Name, clusterName)
+	updateOpts := loadbalancers.UpdateOpts{Name: &name, Description: &description}
+	if svcConf.serviceTag != "" {
+		tags := append(loadbalancer.Tags, svcConf.serviceTag)
+		updateOpts.Tags = &tags
+	}
+
+	mc = metrics.NewMetricContext("loadbalancer", "update")
+	loadbalancer, err = loadbalancers.Update(lbaas.lb, id, updateOpts).Extract()
+	if mc.ObserveRequest(err) != nil {
+		return nil, fmt.Errorf("failed to adopt loadbalancer %s for Service %s/%s: %v", id, service.Namespace, service.Name, err)
+	}
+	klog.InfoS("Service adopted loadbalancer retained by another Service", "service", klog.KObj(service), "loadbalancer", id, "retainedBy", match[1]+"/"+match[2])
+	return loadbalancer, nil
+}
+
+// orphanDescriptionRegexp matches the description of the load balancers created for a single Service.
+var orphanDescriptionRegexp = regexp.MustCompile(`^Kubernetes external service ([^/ ]+)/([^ ]+) from cluster (.+)$`)
+
+// cleanupOrphanedLoadBalancers deletes the load balancers of the cluster whose Service no longer exists. Only the load
+// balancers whose name and description both match a Service of the cluster are considered, and the Service must be
+// missing from the informer cache.
+func (lbaas *LbaasV2) cleanupOrphanedLoadBalancers(ctx context.Context, clusterName string, serviceLister corelisters.ServiceLister) error {
+	mc := metrics.NewMetricContext("loadbalancer", "list")
+	allPages, err := loadbalancers.List(lbaas.lb, loadbalancers.ListOpts{}).AllPages()
+	if mc.ObserveRequest(err) != nil {
+		return fmt.Errorf("error listing loadbalancers: %v", err)
+	}
+	lbList, err := loadbalancers.ExtractLoadBalancers(allPages)
+	if err != nil {
+		return fmt.Errorf("error extracting loadbalancers: %v", err)
+	}
+
+	for _, lb := range lbList {
+		if ctx.Err() != nil {
+			klog.InfoS("Stopped cleaning up the orphaned loadbalancers", "err", ctx.Err())
+			return nil
+		}
+
+		match := orphanDescriptionRegexp.FindStringSubmatch(lb.Description)
+		if match == nil || match[3] != clusterName {
+			continue
+		}
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: match[1], Name: match[2]}}
+		if lb.Name != lbaas.GetLoadBalancerName(ctx, clusterName, service) {
+			continue
+		}
+
+		_, err := serviceLister.Services(service.Namespace).Get(service.Name)
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "Failed to get Service of loadbalancer", "service", klog.KObj(service), "loadbalancer", lb.ID)
+			continue
+		}
+
+		klog.InfoS("Deleting orphaned loadbalancer of the deleted Service", "name", lb.Name, "loadbalancer", lb.ID, "service", klog.KObj(service), "cluster", clusterName)
+		if err := lbaas.ensureLoadBalancerDeleted(ctx, clusterName, service); err != nil {
+			klog.ErrorS(err, "Failed to delete orphaned loadbalancer", "loadbalancer", lb.ID)
+			continue
+		}
+		klog.InfoS("Deleted orphaned loadbalancer", "name", lb.Name, "loadbalancer", lb.ID)
+	}
+
+	return nil
+}
+
 func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName string, service *corev1.Service) error {
 	serviceName := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
-	klog.V(4).Infof("EnsureLoadBalancerDeleted(%s, %s)", clusterName, serviceName)
+	klog.V(4).InfoS("EnsureLoadBalancerDeleted() called", "cluster", clusterName, "service", klog.KObj(service))
 
-	name := lbaas.GetLoadBalancerName(ctx, clusterName, service)
-	legacyName := lbaas.GetLoadBalancerLegacyName(ctx, clusterName, service)
-	loadbalancer, err := getLoadbalancerByName(lbaas.lb, name, legacyName)
+	name, legacyName := lbaas.getLoadBalancerNames(ctx, clusterName, service)
+	svcConf := &serviceConfig{serviceTag: lbaas.serviceLoadBalancerTag(service)}
+	loadbalancer, err := getServiceLoadbalancer(lbaas.lb, svcConf, name, legacyName)
 	if err != nil && err != ErrNotFound {
 		return err
 	}
@@ -2678,12 +4424,54 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 		return nil
 	}
 
+	// Only delete the listeners of the Service from a shared load balancer, the
+	// load balancer itself is deleted with the listeners of the last Service.
+	if shared := service.Annotations[ServiceAnnotationLoadBalancerShared]; lbaas.opts.UseOctavia && shared != "" {
+		allListeners, err := getListenersByLoadBalancerID(lbaas.lb, loadbalancer.ID)
+		if err != nil {
+			return fmt.Errorf("error getting LB %s listeners: %v", loadbalancer.ID, err)
+		}
+		owned, others := filterServiceListeners(allListeners, serviceListenerPrefix(clusterName, service))
+		if err := lbaas.deleteListeners(loadbalancer.ID, owned); err != nil {
+			return err
+		}
+		if len(others) > 0 {
+			klog.V(2).InfoS("Shared loadbalancer is still used by the listeners of other Services", "loadbalancer", loadbalancer.ID, "listeners", len(others))
+			// The security group belongs to the Service, not to the shared load balancer
+			if lbaas.opts.ManageSecurityGroups {
+				if err := lbaas.EnsureSecurityGroupDeleted(clusterName, service); err != nil {
+					return fmt.Errorf("failed to delete Security Group for loadbalancer service %s: %v", serviceName, err)
+				}
+			}
+			return nil
+		}
+	}
+
+	keepOnDelete, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerKeepOnDelete, false)
+	if err != nil {
+		return err
+	}
+	if keepOnDelete && lbaas.opts.UseOctavia {
+		if service.Annotations[ServiceAnnotationLoadBalancerShared] != "" {
+			klog.Warningf("The shared loadbalancer %s of Service %s can't be retained, deleting it", loadbalancer.ID, serviceName)
+		} else {
+			// The security group opens the node ports of the deleted Service, the adopting Service gets its own
+			if lbaas.opts.ManageSecurityGroups {
+				if err := lbaas.EnsureSecurityGroupDeleted(clusterName, service); err != nil {
+					return fmt.Errorf("failed to delete Security Group for loadbalancer service %s: %v", serviceName, err)
+				}
+			}
+			return lbaas.retainLoadBalancer(service, loadbalancer, clusterName)
+		}
+	}
+
 	keepFloatingAnnotation, err := getBoolFromServiceAnnotation(service, ServiceAnnotationLoadBalancerKeepFloatingIP, false)
 	if err != nil {
 		return err
 	}
 
-	if !keepFloatingAnnotation {
+	// A floating IP specified in the floating-ip annotation is never released
+	if !keepFloatingAnnotation && getStringFromServiceAnnotation(service, ServiceAnnotationLoadBalancerFloatingIP, "") == "" {
 		if loadbalancer.VipPortID != "" {
 			portID := loadbalancer.VipPortID
 			fip, err := openstackutil.GetFloatingIPByPortID(lbaas.network, portID)
@@ -2814,6 +4602,7 @@ func (lbaas *LbaasV2) ensureLoadBalancerDeleted(ctx context.Context, clusterName
 			return fmt.Errorf("failed to delete loadbalancer: %v", err)
 		}
 	}
+	lbaas.health.swap(loadbalancer.ID, nil)
 
 	// Delete the Security Group
 	if lbaas.opts.ManageSecurityGroups {
@@ -2853,6 +4642,11 @@ func (lbaas *LbaasV2) EnsureSecurityGroupDeleted(clusterName string, service *co
 	}
 	mc.ObserveRequest(nil)
 
+	if lbaas.opts.UseOctavia {
+		// The rules of the members were deleted with the security group
+		return nil
+	}
+
 	if len(lbaas.opts.NodeSecurityGroupIDs) == 0 {
 		// Just happen when nodes have not Security Group, or should not happen
 		// UpdateLoadBalancer and EnsureLoadBalancer can set lbaas.opts.NodeSecurityGroupIDs when it is empty
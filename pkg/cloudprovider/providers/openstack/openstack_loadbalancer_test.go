@@ -0,0 +1,1439 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
+	v2monitors "github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/monitors"
+	v2pools "github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/pools"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestFilterServiceListeners(t *testing.T) {
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	prefix := serviceListenerPrefix("kubernetes", service)
+
+	allListeners := []listeners.Listener{
+		{ID: "1", Name: prefix + "TCP_80"},
+		{ID: "2", Name: "kube_service_kubernetes_default_api_TCP_443"},
+		{ID: "3", Name: prefix + "UDP_53"},
+	}
+
+	owned, others := filterServiceListeners(allListeners, prefix)
+	if len(owned) != 2 || owned[0].ID != "1" || owned[1].ID != "3" {
+		t.Errorf("filterServiceListeners() returned owned listeners %v", owned)
+	}
+	if len(others) != 1 || others[0].ID != "2" {
+		t.Errorf("filterServiceListeners() returned other listeners %v", others)
+	}
+}
+
+func TestCheckListenerConflicts(t *testing.T) {
+	otherListeners := []listeners.Listener{
+		{Name: "kube_service_kubernetes_default_api_TCP_443", Protocol: "TCP", ProtocolPort: 443},
+		{Name: "kube_service_kubernetes_default_dns_UDP_53", Protocol: "UDP", ProtocolPort: 53},
+		{Name: "kube_service_kubernetes_default_web_HTTP_8080", Protocol: "HTTP", ProtocolPort: 8080},
+	}
+
+	testCases := []struct {
+		name  string
+		ports []corev1.ServicePort
+		fail  bool
+	}{
+		{
+			name:  "distinct ports",
+			ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}},
+		},
+		{
+			name:  "same port different transport",
+			ports: []corev1.ServicePort{{Port: 53, Protocol: corev1.ProtocolTCP}},
+		},
+		{
+			name:  "same port SCTP",
+			ports: []corev1.ServicePort{{Port: 53, Protocol: corev1.ProtocolSCTP}},
+		},
+		{
+			name:  "same port",
+			ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}, {Port: 443, Protocol: corev1.ProtocolTCP}},
+			fail:  true,
+		},
+		{
+			name:  "same port HTTP listener",
+			ports: []corev1.ServicePort{{Port: 8080, Protocol: corev1.ProtocolTCP}},
+			fail:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+			Spec:       corev1.ServiceSpec{Ports: test.ports},
+		}
+		err := checkListenerConflicts(service, otherListeners, &serviceConfig{sharedLBName: "shared"})
+		if (err != nil) != test.fail {
+			t.Errorf("%s: checkListenerConflicts() returned %v", test.name, err)
+		}
+	}
+}
+
+func TestGetMinIntFromServiceAnnotation(t *testing.T) {
+	testCases := []struct {
+		value    *string
+		expected int
+		fail     bool
+	}{
+		{value: nil, expected: 50000},
+		{value: strPtr("0"), expected: 0},
+		{value: strPtr("30000"), expected: 30000},
+		{value: strPtr("-1"), fail: true},
+		{value: strPtr("1m"), fail: true},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		if test.value != nil {
+			service.Annotations = map[string]string{ServiceAnnotationLoadBalancerTimeoutClientData: *test.value}
+		}
+
+		value, err := getMinIntFromServiceAnnotation(service, ServiceAnnotationLoadBalancerTimeoutClientData, 50000, 0)
+		if (err != nil) != test.fail {
+			t.Errorf("getMinIntFromServiceAnnotation(%v) returned error %v", test.value, err)
+		}
+		if err == nil && value != test.expected {
+			t.Errorf("getMinIntFromServiceAnnotation(%v) = %d, expected %d", test.value, value, test.expected)
+		}
+	}
+}
+
+func TestGetProxyProtocolFromServiceAnnotation(t *testing.T) {
+	testCases := []struct {
+		value    *string
+		expected v2pools.Protocol
+		fail     bool
+	}{
+		{value: nil, expected: ""},
+		{value: strPtr("false"), expected: ""},
+		{value: strPtr("true"), expected: v2pools.ProtocolPROXY},
+		{value: strPtr("v1"), expected: v2pools.ProtocolPROXY},
+		{value: strPtr("v2"), expected: poolProtocolPROXYV2},
+		{value: strPtr("v3"), fail: true},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		if test.value != nil {
+			service.Annotations = map[string]string{ServiceAnnotationLoadBalancerProxyEnabled: *test.value}
+		}
+
+		protocol, err := getProxyProtocolFromServiceAnnotation(service)
+		if (err != nil) != test.fail {
+			t.Errorf("getProxyProtocolFromServiceAnnotation(%v) returned error %v", test.value, err)
+		}
+		if protocol != test.expected {
+			t.Errorf("getProxyProtocolFromServiceAnnotation(%v) = %q, expected %q", test.value, protocol, test.expected)
+		}
+	}
+}
+
+func TestGetHealthMonitorConfig(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    serviceConfig
+		fail        bool
+	}{
+		{annotations: nil, expected: serviceConfig{}},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorType: "tcp"},
+			expected:    serviceConfig{monitorType: "TCP"},
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorType: "HTTP"},
+			expected:    serviceConfig{monitorType: "HTTP", monitorHTTPMethod: "GET", monitorURLPath: "/", monitorExpectedCodes: "200"},
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHealthMonitorType:          "HTTPS",
+				ServiceAnnotationLoadBalancerHealthMonitorHTTPMethod:    "head",
+				ServiceAnnotationLoadBalancerHealthMonitorURLPath:       "/healthz",
+				ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes: "200-204",
+			},
+			expected: serviceConfig{monitorType: "HTTPS", monitorHTTPMethod: "HEAD", monitorURLPath: "/healthz", monitorExpectedCodes: "200-204"},
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorType: "SCTP"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorURLPath: "/healthz"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHealthMonitorType:          "TCP",
+				ServiceAnnotationLoadBalancerHealthMonitorExpectedCodes: "200",
+			},
+			fail: true,
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHealthMonitorType:    "HTTP",
+				ServiceAnnotationLoadBalancerHealthMonitorURLPath: "healthz",
+			},
+			fail: true,
+		},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		service.Annotations = test.annotations
+
+		svcConf := serviceConfig{}
+		err := getHealthMonitorConfig(service, &svcConf)
+		if (err != nil) != test.fail {
+			t.Errorf("getHealthMonitorConfig(%v) returned error %v", test.annotations, err)
+		}
+		if err == nil && !reflect.DeepEqual(svcConf, test.expected) {
+			t.Errorf("getHealthMonitorConfig(%v) = %+v, expected %+v", test.annotations, svcConf, test.expected)
+		}
+	}
+}
+
+func TestGetHealthMonitorTiming(t *testing.T) {
+	opts := LoadBalancerOpts{
+		MonitorDelay:      MyDuration{5 * time.Second},
+		MonitorTimeout:    MyDuration{3 * time.Second},
+		MonitorMaxRetries: 1,
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    serviceConfig
+		fail        bool
+	}{
+		{annotations: nil, expected: serviceConfig{monitorDelay: 5, monitorTimeout: 3, monitorMaxRetries: 1}},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHealthMonitorDelay:        "10",
+				ServiceAnnotationLoadBalancerHealthMonitorTimeout:      "4",
+				ServiceAnnotationLoadBalancerHealthMonitorMaxRetries:   "3",
+				ServiceAnnotationLoadBalancerHealthMonitorInitialDelay: "60",
+			},
+			expected: serviceConfig{monitorDelay: 10, monitorTimeout: 4, monitorMaxRetries: 3, memberInitialDelay: time.Minute},
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorDelay: "3"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorTimeout: "0"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorMaxRetries: "11"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerHealthMonitorInitialDelay: "-1"},
+			fail:        true,
+		},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		service.Annotations = test.annotations
+
+		svcConf := serviceConfig{}
+		err := getHealthMonitorTiming(service, &svcConf, opts)
+		if (err != nil) != test.fail {
+			t.Errorf("getHealthMonitorTiming(%v) returned error %v", test.annotations, err)
+		}
+		if err == nil && !reflect.DeepEqual(svcConf, test.expected) {
+			t.Errorf("getHealthMonitorTiming(%v) = %+v, expected %+v", test.annotations, svcConf, test.expected)
+		}
+	}
+}
+
+func TestHealthMonitorUpdateOpts(t *testing.T) {
+	svcConf := &serviceConfig{monitorDelay: 5, monitorTimeout: 3, monitorMaxRetries: 1, monitorHTTPMethod: "GET", monitorURLPath: "/", monitorExpectedCodes: "200"}
+	monitor := &v2monitors.Monitor{Delay: 5, Timeout: 3, MaxRetries: 1}
+
+	if _, changed := healthMonitorUpdateOpts(monitor, "TCP", svcConf); changed {
+		t.Errorf("healthMonitorUpdateOpts() changed the TCP monitor %+v, expected unchanged", monitor)
+	}
+	if _, changed := healthMonitorUpdateOpts(monitor, "HTTP", svcConf); !changed {
+		t.Errorf("healthMonitorUpdateOpts() didn't change the HTTP settings of monitor %+v", monitor)
+	}
+
+	monitor.MaxRetries = 3
+	opts, changed := healthMonitorUpdateOpts(monitor, "TCP", svcConf)
+	if !changed || opts.MaxRetries != 1 || opts.URLPath != "" {
+		t.Errorf("healthMonitorUpdateOpts() = %+v, %v, expected the max retries changed to 1", opts, changed)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestGetVIPSubnetID(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/subnets/vip-subnet", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"subnet": {"id": "vip-subnet", "name": "vip", "network_id": "lb-net"}}`)
+	})
+	th.Mux.HandleFunc("/subnets", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		if r.URL.Query().Get("name") != "vip" {
+			fmt.Fprint(w, `{"subnets": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"subnets": [{"id": "vip-subnet", "name": "vip", "network_id": "lb-net"}]}`)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{
+		network: fake.ServiceClient(),
+		opts: LoadBalancerOpts{
+			LBClasses: map[string]*LBClass{"other": {NetworkID: "other-net"}},
+		},
+	}}
+
+	testCases := []struct {
+		annotations map[string]string
+		svcConf     serviceConfig
+		expected    string
+		fail        bool
+	}{
+		{annotations: nil, expected: ""},
+		{annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetID: "vip-subnet"}, expected: "vip-subnet"},
+		{annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetName: "vip"}, expected: "vip-subnet"},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetID: "vip-subnet"},
+			svcConf:     serviceConfig{lbNetworkID: "lb-net"},
+			expected:    "vip-subnet",
+		},
+		{annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetName: "unknown"}, fail: true},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetID: "vip-subnet"},
+			svcConf:     serviceConfig{lbNetworkID: "other-net"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerVIPSubnetID: "vip-subnet"},
+			svcConf:     serviceConfig{lbNetworkID: "lb-net", configClassName: "other"},
+			fail:        true,
+		},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		service.Annotations = test.annotations
+
+		subnetID, err := lbaas.getVIPSubnetID(service, &test.svcConf)
+		if (err != nil) != test.fail {
+			t.Errorf("getVIPSubnetID(%v) returned error %v", test.annotations, err)
+		}
+		if subnetID != test.expected {
+			t.Errorf("getVIPSubnetID(%v) = %q, expected %q", test.annotations, subnetID, test.expected)
+		}
+	}
+}
+
+func TestDrainPoolMembers(t *testing.T) {
+	drains := &drainingMembers{started: map[string]time.Time{
+		"expired": time.Now().Add(-2 * time.Minute),
+		"back":    time.Now(),
+	}}
+
+	poolMembers := []v2pools.Member{
+		{ID: "kept", Address: "10.0.0.1", ProtocolPort: 30000, Weight: 1},
+		{ID: "back", Address: "10.0.0.2", ProtocolPort: 30000, Weight: 0},
+		{ID: "removed", Address: "10.0.0.3", ProtocolPort: 30000, Weight: 1, Name: "node-3", SubnetID: "subnet"},
+		{ID: "expired", Address: "10.0.0.4", ProtocolPort: 30000, Weight: 0},
+	}
+	members := []v2pools.BatchUpdateMemberOpts{
+		{Address: "10.0.0.1", ProtocolPort: 30000},
+		{Address: "10.0.0.2", ProtocolPort: 30000},
+	}
+	wanted := sets.NewString("10.0.0.1-30000", "10.0.0.2-30000")
+
+	members, weightChanged, next := drainPoolMembers(members, wanted, poolMembers, time.Minute, drains)
+	if !weightChanged {
+		t.Errorf("drainPoolMembers() didn't change any weight")
+	}
+	if next <= 0 || next > time.Minute {
+		t.Errorf("drainPoolMembers() returned the next drain timeout in %v, expected within a minute", next)
+	}
+	if len(members) != 3 {
+		t.Fatalf("drainPoolMembers() returned %d members, expected 3: %+v", len(members), members)
+	}
+	if members[0].Weight != nil {
+		t.Errorf("member %s weight is %d, expected unchanged", members[0].Address, *members[0].Weight)
+	}
+	if members[1].Weight == nil || *members[1].Weight != 1 {
+		t.Errorf("member %s weight wasn't restored", members[1].Address)
+	}
+	if members[2].Address != "10.0.0.3" || members[2].Weight == nil || *members[2].Weight != 0 || *members[2].SubnetID != "subnet" {
+		t.Errorf("member 10.0.0.3 isn't drained: %+v", members[2])
+	}
+	if _, ok := drains.started["removed"]; !ok {
+		t.Errorf("draining of member removed wasn't recorded")
+	}
+	if _, ok := drains.started["expired"]; ok {
+		t.Errorf("draining of member expired wasn't forgotten")
+	}
+	if _, ok := drains.started["back"]; ok {
+		t.Errorf("draining of member back wasn't forgotten")
+	}
+
+	// Without a drain timeout the members are deleted straight away
+	members, weightChanged, next = drainPoolMembers(nil, sets.NewString(), poolMembers[2:3], 0, drains)
+	if len(members) != 0 || weightChanged || next != 0 {
+		t.Errorf("drainPoolMembers() without drain timeout returned %+v, %v, %v", members, weightChanged, next)
+	}
+
+	// The members are only updated when a weight changes
+	one, ten := 1, 10
+	members = []v2pools.BatchUpdateMemberOpts{{Address: "10.0.0.1", ProtocolPort: 30000, Weight: &one}}
+	if _, weightChanged, _ = drainPoolMembers(members, sets.NewString("10.0.0.1-30000"), poolMembers[0:1], 0, drains); weightChanged {
+		t.Errorf("drainPoolMembers() changed the weight of member 10.0.0.1, expected unchanged")
+	}
+	members = []v2pools.BatchUpdateMemberOpts{{Address: "10.0.0.1", ProtocolPort: 30000, Weight: &ten}}
+	if _, weightChanged, _ = drainPoolMembers(members, sets.NewString("10.0.0.1-30000"), poolMembers[0:1], 0, drains); !weightChanged {
+		t.Errorf("drainPoolMembers() didn't change the weight of member 10.0.0.1")
+	}
+}
+
+func TestDeleteDrainedMembers(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/lbaas/pools/drain-pool/members", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"members": [
+			{"id": "drained", "address": "10.0.0.1", "protocol_port": 30000, "weight": 0},
+			{"id": "draining", "address": "10.0.0.2", "protocol_port": 30000, "weight": 0},
+			{"id": "kept", "address": "10.0.0.3", "protocol_port": 30000, "weight": 1}
+		]}`)
+	})
+	deleted := sets.NewString()
+	th.Mux.HandleFunc("/lbaas/pools/drain-pool/members/", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "DELETE")
+		deleted.Insert(strings.TrimPrefix(r.URL.Path, "/lbaas/pools/drain-pool/members/"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	th.Mux.HandleFunc("/lbaas/loadbalancers/lb", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancer": {"id": "lb", "provisioning_status": "ACTIVE"}}`)
+	})
+
+	memberDrains.Lock()
+	memberDrains.started["drained"] = time.Now().Add(-2 * time.Minute)
+	memberDrains.started["draining"] = time.Now()
+	memberDrains.Unlock()
+	defer func() {
+		memberDrains.Lock()
+		defer memberDrains.Unlock()
+		if timer, ok := memberDrains.timers["drain-pool"]; ok {
+			timer.Stop()
+			delete(memberDrains.timers, "drain-pool")
+		}
+		delete(memberDrains.started, "drained")
+		delete(memberDrains.started, "draining")
+	}()
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fake.ServiceClient()}}
+	lbaas.deleteDrainedMembers("lb", "drain-pool", time.Minute)
+
+	if !deleted.Equal(sets.NewString("drained")) {
+		t.Errorf("deleteDrainedMembers() deleted members %v, expected drained", deleted.List())
+	}
+	memberDrains.Lock()
+	defer memberDrains.Unlock()
+	if _, ok := memberDrains.started["drained"]; ok {
+		t.Errorf("draining of the deleted member drained wasn't forgotten")
+	}
+	if _, ok := memberDrains.timers["drain-pool"]; !ok {
+		t.Errorf("deletion of member draining wasn't scheduled")
+	}
+}
+
+func TestWarmUpPoolMembers(t *testing.T) {
+	warmups := &warmingMembers{added: map[string]time.Time{
+		"pool/10.0.0.2-30000": time.Now().Add(-2 * time.Minute),
+		"pool/10.0.0.4-30000": time.Now(),
+	}}
+
+	poolMembers := []v2pools.Member{
+		{ID: "existing", Address: "10.0.0.1", ProtocolPort: 30000, AdminStateUp: true},
+		{ID: "warmed", Address: "10.0.0.2", ProtocolPort: 30000, AdminStateUp: false},
+	}
+	members := []v2pools.BatchUpdateMemberOpts{
+		{Address: "10.0.0.1", ProtocolPort: 30000},
+		{Address: "10.0.0.2", ProtocolPort: 30000},
+		{Address: "10.0.0.3", ProtocolPort: 30000},
+	}
+
+	members, stateChanged, next := warmUpPoolMembers("pool", members, poolMembers, time.Minute, warmups)
+	if !stateChanged {
+		t.Errorf("warmUpPoolMembers() didn't change the state of member warmed")
+	}
+	if next <= 0 || next > time.Minute {
+		t.Errorf("warmUpPoolMembers() returned the next warmup in %v, expected within a minute", next)
+	}
+	for i, up := range []bool{true, true, false} {
+		if members[i].AdminStateUp == nil || *members[i].AdminStateUp != up {
+			t.Errorf("member %s admin state is %v, expected %t", members[i].Address, members[i].AdminStateUp, up)
+		}
+	}
+	if _, ok := warmups.added["pool/10.0.0.3-30000"]; !ok {
+		t.Errorf("warmup of the new member 10.0.0.3 wasn't recorded")
+	}
+	if _, ok := warmups.added["pool/10.0.0.2-30000"]; ok {
+		t.Errorf("warmup of member warmed wasn't forgotten")
+	}
+	if _, ok := warmups.added["pool/10.0.0.4-30000"]; ok {
+		t.Errorf("warmup of the removed member 10.0.0.4 wasn't forgotten")
+	}
+
+	// Without initial delay only the members still warming up are brought up
+	poolMembers = append(poolMembers[:1], v2pools.Member{ID: "new", Address: "10.0.0.3", ProtocolPort: 30000, AdminStateUp: false})
+	members = []v2pools.BatchUpdateMemberOpts{
+		{Address: "10.0.0.1", ProtocolPort: 30000},
+		{Address: "10.0.0.3", ProtocolPort: 30000},
+	}
+	members, stateChanged, next = warmUpPoolMembers("pool", members, poolMembers, 0, warmups)
+	if !stateChanged || next != 0 {
+		t.Errorf("warmUpPoolMembers() without initial delay returned %v, %v, expected member new brought up", stateChanged, next)
+	}
+	if members[0].AdminStateUp != nil {
+		t.Errorf("member 10.0.0.1 admin state is %t, expected unchanged", *members[0].AdminStateUp)
+	}
+	if members[1].AdminStateUp == nil || !*members[1].AdminStateUp {
+		t.Errorf("member 10.0.0.3 wasn't brought up")
+	}
+	if len(warmups.added) != 0 {
+		t.Errorf("warmups %v weren't forgotten", warmups.added)
+	}
+}
+
+func TestGetMemberWeight(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    int
+	}{
+		{expected: 1},
+		{annotations: map[string]string{NodeAnnotationLoadBalancerMemberWeight: "10"}, expected: 10},
+		{annotations: map[string]string{NodeAnnotationLoadBalancerMemberWeight: "256"}, expected: 256},
+		{annotations: map[string]string{NodeAnnotationLoadBalancerMemberWeight: "0"}, expected: 1},
+		{annotations: map[string]string{NodeAnnotationLoadBalancerMemberWeight: "257"}, expected: 1},
+		{annotations: map[string]string{NodeAnnotationLoadBalancerMemberWeight: "heavy"}, expected: 1},
+	}
+
+	for _, test := range testCases {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: test.annotations}}
+		if weight := getMemberWeight(node); weight != test.expected {
+			t.Errorf("getMemberWeight(%v) = %d, expected %d", test.annotations, weight, test.expected)
+		}
+	}
+}
+
+func TestGetListenerProtocol(t *testing.T) {
+	testCases := []struct {
+		protocol        corev1.Protocol
+		keepClientIP    bool
+		tlsContainerRef string
+		expected        listeners.Protocol
+	}{
+		{protocol: corev1.ProtocolTCP, expected: listeners.ProtocolTCP},
+		{protocol: corev1.ProtocolUDP, expected: listeners.ProtocolUDP},
+		{protocol: corev1.ProtocolSCTP, expected: listeners.Protocol("SCTP")},
+		{protocol: corev1.ProtocolTCP, keepClientIP: true, expected: listeners.ProtocolHTTP},
+		{protocol: corev1.ProtocolUDP, keepClientIP: true, expected: listeners.ProtocolUDP},
+		{protocol: corev1.ProtocolSCTP, keepClientIP: true, expected: listeners.Protocol("SCTP")},
+		{protocol: corev1.ProtocolTCP, tlsContainerRef: "ref", expected: listeners.ProtocolTerminatedHTTPS},
+		{protocol: corev1.ProtocolTCP, keepClientIP: true, tlsContainerRef: "ref", expected: listeners.ProtocolTerminatedHTTPS},
+		{protocol: corev1.ProtocolUDP, tlsContainerRef: "ref", expected: listeners.ProtocolUDP},
+	}
+
+	for _, test := range testCases {
+		port := corev1.ServicePort{Port: 53, Protocol: test.protocol}
+		protocol := getListenerProtocol(port, &serviceConfig{keepClientIP: test.keepClientIP, tlsContainerRef: test.tlsContainerRef})
+		if protocol != test.expected {
+			t.Errorf("getListenerProtocol(%s, keepClientIP=%v, tlsContainerRef=%q) = %s, expected %s", test.protocol, test.keepClientIP, test.tlsContainerRef, protocol, test.expected)
+		}
+	}
+}
+
+func TestGetHealthMonitorType(t *testing.T) {
+	testCases := []struct {
+		protocol    corev1.Protocol
+		monitorType string
+		expected    string
+	}{
+		{protocol: corev1.ProtocolTCP, expected: "TCP"},
+		{protocol: corev1.ProtocolUDP, expected: "UDP-CONNECT"},
+		{protocol: corev1.ProtocolSCTP, expected: "SCTP"},
+		{protocol: corev1.ProtocolTCP, monitorType: "HTTP", expected: "HTTP"},
+		{protocol: corev1.ProtocolUDP, monitorType: "HTTP", expected: "HTTP"},
+		{protocol: corev1.ProtocolSCTP, monitorType: "HTTP", expected: "HTTP"},
+		{protocol: corev1.ProtocolTCP, monitorType: "UDP-CONNECT", expected: "TCP"},
+		{protocol: corev1.ProtocolUDP, monitorType: "TLS-HELLO", expected: "UDP-CONNECT"},
+		{protocol: corev1.ProtocolSCTP, monitorType: "HTTPS", expected: "SCTP"},
+	}
+
+	for _, test := range testCases {
+		port := corev1.ServicePort{Port: 53, Protocol: test.protocol}
+		monitorType := getHealthMonitorType(port, &serviceConfig{monitorType: test.monitorType})
+		if monitorType != test.expected {
+			t.Errorf("getHealthMonitorType(%s, %q) = %s, expected %s", test.protocol, test.monitorType, monitorType, test.expected)
+		}
+	}
+}
+
+func TestGetLBMethodFromServiceAnnotation(t *testing.T) {
+	testCases := []struct {
+		value    *string
+		provider string
+		expected v2pools.LBMethod
+		fail     bool
+	}{
+		{value: nil, provider: "amphora", expected: v2pools.LBMethodRoundRobin},
+		{value: strPtr("least_connections"), provider: "amphora", expected: v2pools.LBMethodLeastConnections},
+		{value: strPtr("SOURCE_IP"), provider: "octavia", expected: v2pools.LBMethodSourceIp},
+		{value: strPtr("SOURCE_IP_PORT"), provider: "ovn", expected: "SOURCE_IP_PORT"},
+		{value: strPtr("SOURCE_IP_PORT"), provider: "vendor", expected: "SOURCE_IP_PORT"},
+		{value: strPtr("SOURCE_IP_PORT"), provider: "amphora", fail: true},
+		{value: nil, provider: "ovn", fail: true},
+		{value: strPtr("RANDOM"), provider: "vendor", fail: true},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{}
+		if test.value != nil {
+			service.Annotations = map[string]string{ServiceAnnotationLoadBalancerLBMethod: *test.value}
+		}
+
+		lbMethod, err := getLBMethodFromServiceAnnotation(service, "ROUND_ROBIN", test.provider)
+		if (err != nil) != test.fail {
+			t.Errorf("getLBMethodFromServiceAnnotation(%v, %s) returned error %v", test.value, test.provider, err)
+		}
+		if lbMethod != test.expected {
+			t.Errorf("getLBMethodFromServiceAnnotation(%v, %s) = %q, expected %q", test.value, test.provider, lbMethod, test.expected)
+		}
+	}
+}
+
+func TestCleanupOrphanedLoadBalancers(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	type lb struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	lbs := []lb{
+		{ID: "orphan", Name: "kube_service_kubernetes_default_gone", Description: "Kubernetes external service default/gone from cluster kubernetes"},
+		{ID: "live", Name: "kube_service_kubernetes_default_web", Description: "Kubernetes external service default/web from cluster kubernetes"},
+		{ID: "other-cluster", Name: "kube_service_other_default_gone", Description: "Kubernetes external service default/gone from cluster other"},
+		{ID: "manual", Name: "kube_service_kubernetes_default_manual", Description: "Load balancer of the manual Service"},
+		{ID: "renamed", Name: "my-lb", Description: "Kubernetes external service default/renamed from cluster kubernetes"},
+		{ID: "retained", Name: "kube_retained_kubernetes_default_gone", Description: "Kubernetes retained load balancer of service default/gone from cluster kubernetes"},
+	}
+
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		var matched []lb
+		for _, l := range lbs {
+			if name := r.URL.Query().Get("name"); name == "" || name == l.Name {
+				matched = append(matched, l)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string][]lb{"loadbalancers": matched})
+	})
+	var deleted []string
+	th.Mux.HandleFunc("/lbaas/loadbalancers/", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "DELETE")
+		deleted = append(deleted, r.URL.Path[len("/lbaas/loadbalancers/"):])
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:   fake.ServiceClient(),
+		opts: LoadBalancerOpts{UseOctavia: true, CascadeDelete: true},
+	}}
+
+	err := lbaas.cleanupOrphanedLoadBalancers(context.TODO(), "kubernetes", corelisters.NewServiceLister(indexer))
+	if err != nil {
+		t.Fatalf("cleanupOrphanedLoadBalancers() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"orphan"}) {
+		t.Errorf("cleanupOrphanedLoadBalancers() deleted %v, expected [orphan]", deleted)
+	}
+}
+
+func TestRetainAndAdoptLoadBalancer(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	var updates []string
+	th.Mux.HandleFunc("/lbaas/loadbalancers/lb", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"loadbalancer": {"id": "lb", "description": "Kubernetes retained load balancer of service blue/web from cluster kubernetes", "tags": ["team"]}}`)
+		case "PUT":
+			var body map[string]map[string]interface{}
+			th.AssertNoErr(t, json.NewDecoder(r.Body).Decode(&body))
+			updates = append(updates, fmt.Sprintf("%v %v %v", body["loadbalancer"]["name"], body["loadbalancer"]["description"], body["loadbalancer"]["tags"]))
+			fmt.Fprint(w, `{"loadbalancer": {"id": "lb"}}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+	th.Mux.HandleFunc("/lbaas/loadbalancers/other", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancer": {"id": "other", "description": "Kubernetes external service blue/api from cluster kubernetes"}}`)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fake.ServiceClient()}}
+	blue := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "blue", Name: "web"}}
+	green := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "green", Name: "web", UID: "uid"}}
+
+	th.AssertNoErr(t, lbaas.retainLoadBalancer(blue, &loadbalancers.LoadBalancer{ID: "lb", Tags: []string{"team", "kube_service_uid_blue"}}, "kubernetes"))
+	_, err := lbaas.adoptLoadBalancer(green, "lb", "kube_service_kubernetes_green_web", "kubernetes", &serviceConfig{serviceTag: serviceTag(green)})
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{
+		"kube_retained_kubernetes_blue_web Kubernetes retained load balancer of service blue/web from cluster kubernetes [team]",
+		"kube_service_kubernetes_green_web Kubernetes external service green/web from cluster kubernetes [team kube_service_uid_uid]",
+	}, updates)
+
+	// only the retained load balancers of the cluster can be adopted
+	for _, test := range []struct{ id, cluster string }{{"other", "kubernetes"}, {"lb", "other"}} {
+		if _, err := lbaas.adoptLoadBalancer(green, test.id, "kube_service_other_green_web", test.cluster, &serviceConfig{}); err == nil {
+			t.Errorf("adoptLoadBalancer(%s) succeeded in cluster %s", test.id, test.cluster)
+		}
+	}
+	th.AssertEquals(t, 2, len(updates))
+}
+
+func TestGetServiceLoadbalancer(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	type lb struct {
+		ID                 string   `json:"id"`
+		Name               string   `json:"name"`
+		ProvisioningStatus string   `json:"provisioning_status"`
+		Tags               []string `json:"tags"`
+	}
+	lbs := []lb{
+		{ID: "creating", Name: "kube_service_kubernetes_default_web", ProvisioningStatus: "PENDING_CREATE", Tags: []string{"kube_service_uid_web-uid"}},
+		{ID: "deleting", Name: "kube_service_kubernetes_default_web", ProvisioningStatus: "PENDING_DELETE", Tags: []string{"kube_service_uid_web-uid"}},
+		{ID: "untagged", Name: "kube_service_kubernetes_default_api", ProvisioningStatus: "ACTIVE"},
+	}
+
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		var matched []lb
+		for _, l := range lbs {
+			name, tag := r.URL.Query().Get("name"), r.URL.Query().Get("tags")
+			if (name == "" || name == l.Name) && (tag == "" || (len(l.Tags) > 0 && tag == l.Tags[0])) {
+				matched = append(matched, l)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string][]lb{"loadbalancers": matched})
+	})
+
+	// the load balancer being created is found by its tag
+	svcConf := &serviceConfig{serviceTag: serviceTag(&corev1.Service{ObjectMeta: metav1.ObjectMeta{UID: "web-uid"}})}
+	loadbalancer, err := getServiceLoadbalancer(fake.ServiceClient(), svcConf, "kube_service_kubernetes_default_web", "")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "creating", loadbalancer.ID)
+
+	// the untagged load balancer is found by its name
+	svcConf = &serviceConfig{serviceTag: serviceTag(&corev1.Service{ObjectMeta: metav1.ObjectMeta{UID: "api-uid"}})}
+	loadbalancer, err = getServiceLoadbalancer(fake.ServiceClient(), svcConf, "kube_service_kubernetes_default_api", "")
+	th.AssertNoErr(t, err)
+	th.AssertEquals(t, "untagged", loadbalancer.ID)
+
+	if _, err := getServiceLoadbalancer(fake.ServiceClient(), &serviceConfig{}, "kube_service_kubernetes_default_gone", ""); err != ErrNotFound {
+		t.Errorf("getServiceLoadbalancer() returned %v, expected %v", err, ErrNotFound)
+	}
+}
+
+func TestGetLoadBalancerByTag(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"versions": [{"id": "v2.0", "status": "SUPPORTED"}, {"id": "v2.14", "status": "CURRENT"}]}`)
+	})
+	// The adopted load balancer is only found by the tag of the Service
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		if r.URL.Query().Get("tags") != "kube_service_uid_web-uid" {
+			fmt.Fprint(w, `{"loadbalancers": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"loadbalancers": [{"id": "adopted", "name": "retained", "provisioning_status": "ACTIVE", "tags": ["kube_service_uid_web-uid"]}]}`)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{
+		network: fake.ServiceClient(),
+		lb:      fake.ServiceClient(),
+		opts:    LoadBalancerOpts{UseOctavia: true},
+	}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "web-uid"}}
+
+	if _, exists, err := lbaas.GetLoadBalancer(context.TODO(), "kubernetes", service); err != nil || !exists {
+		t.Errorf("GetLoadBalancer() returned %v, %v, expected the tagged load balancer to exist", exists, err)
+	}
+
+	service = &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api", UID: "api-uid"}}
+	if _, exists, err := lbaas.GetLoadBalancer(context.TODO(), "kubernetes", service); err != nil || exists {
+		t.Errorf("GetLoadBalancer() returned %v, %v, expected no load balancer", exists, err)
+	}
+}
+
+func TestInternalLoadBalancerNoFloatingIP(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	// Only the Octavia API versions may be requested, any Neutron call fails the test
+	th.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"versions": [{"id": "v2.0", "status": "SUPPORTED"}, {"id": "v2.14", "status": "CURRENT"}]}`)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{
+		network: fake.ServiceClient(),
+		lb:      fake.ServiceClient(),
+		opts:    LoadBalancerOpts{UseOctavia: true, SubnetID: "subnet", LBMethod: "ROUND_ROBIN", LBProvider: "amphora"},
+	}}
+	nodes := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node"}}}
+	lb := &loadbalancers.LoadBalancer{ID: "lb", VipAddress: "10.0.0.10", VipPortID: "vip-port"}
+
+	for _, annotation := range []string{ServiceAnnotationLoadBalancerInternal, ServiceAnnotationLoadBalancerOpenStackInternal} {
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: map[string]string{annotation: "true"}},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}}},
+		}
+
+		svcConf := &serviceConfig{}
+		if err := lbaas.checkService(service, nodes, svcConf); err != nil {
+			t.Fatalf("checkService() with %s returned error: %v", annotation, err)
+		}
+		if !svcConf.internal || svcConf.lbPublicNetworkID != "" {
+			t.Errorf("checkService() with %s returned internal %v and floating network %q", annotation, svcConf.internal, svcConf.lbPublicNetworkID)
+		}
+
+		addr, err := lbaas.getServiceAddress("kubernetes", service, lb, svcConf)
+		if err != nil {
+			t.Fatalf("getServiceAddress() with %s returned error: %v", annotation, err)
+		}
+		if addr != lb.VipAddress {
+			t.Errorf("getServiceAddress() with %s = %s, expected the VIP address %s", annotation, addr, lb.VipAddress)
+		}
+	}
+
+	// The new annotation takes precedence over the legacy one
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		ServiceAnnotationLoadBalancerInternal:          "true",
+		ServiceAnnotationLoadBalancerOpenStackInternal: "false",
+	}}}
+	if internal, err := getInternalFromServiceAnnotation(service, false); err != nil || internal {
+		t.Errorf("getInternalFromServiceAnnotation() = %v, %v, expected false", internal, err)
+	}
+
+	// A floating IP can't be requested for an internal load balancer
+	service = &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: map[string]string{
+			ServiceAnnotationLoadBalancerOpenStackInternal: "true",
+			ServiceAnnotationLoadBalancerFloatingIP:        "172.24.4.10",
+		}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP}}},
+	}
+	if err := lbaas.checkService(service, nodes, &serviceConfig{}); err == nil {
+		t.Errorf("checkService() accepted a floating IP for an internal loadbalancer")
+	}
+}
+
+func TestGetInsertHeadersFromServiceAnnotation(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    map[string]string
+		fail        bool
+	}{
+		{annotations: nil, expected: nil},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerXForwardedFor: "true"},
+			expected:    map[string]string{"X-Forwarded-For": "true"},
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerXForwardedFor:   "true",
+				ServiceAnnotationLoadBalancerXForwardedPort:  "true",
+				ServiceAnnotationLoadBalancerXForwardedProto: "false",
+			},
+			expected: map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Port": "true"},
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerXForwardedFor:   "true",
+				ServiceAnnotationLoadBalancerXForwardedPort:  "true",
+				ServiceAnnotationLoadBalancerXForwardedProto: "true",
+			},
+			expected: map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Port": "true", "X-Forwarded-Proto": "true"},
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerXForwardedProto: "false"},
+			expected:    nil,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerXForwardedProto: "true"},
+			fail:        true,
+		},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerXForwardedFor:  "true",
+				ServiceAnnotationLoadBalancerXForwardedPort: "yes",
+			},
+			fail: true,
+		},
+	}
+
+	for _, test := range testCases {
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: test.annotations}}
+		keepClientIP := test.annotations[ServiceAnnotationLoadBalancerXForwardedFor] == "true"
+
+		headers, err := getInsertHeadersFromServiceAnnotation(service, keepClientIP)
+		if (err != nil) != test.fail {
+			t.Errorf("getInsertHeadersFromServiceAnnotation(%v) returned error %v", test.annotations, err)
+		}
+		if !reflect.DeepEqual(headers, test.expected) {
+			t.Errorf("getInsertHeadersFromServiceAnnotation(%v) = %v, expected %v", test.annotations, headers, test.expected)
+		}
+	}
+}
+
+func TestReconcileInsertHeaders(t *testing.T) {
+	testCases := []struct {
+		current  map[string]string
+		desired  map[string]string
+		expected map[string]string
+		changed  bool
+	}{
+		{current: nil, desired: nil, expected: map[string]string{}},
+		{
+			current:  map[string]string{},
+			desired:  map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Proto": "true"},
+			expected: map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Proto": "true"},
+			changed:  true,
+		},
+		{
+			current:  map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Proto": "true"},
+			desired:  map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Proto": "true"},
+			expected: map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Proto": "true"},
+		},
+		{
+			current:  map[string]string{"X-Forwarded-For": "true", "X-Forwarded-Port": "true", "X-SSL-Client-Verify": "true"},
+			desired:  map[string]string{"X-Forwarded-For": "true"},
+			expected: map[string]string{"X-Forwarded-For": "true", "X-SSL-Client-Verify": "true"},
+			changed:  true,
+		},
+		{
+			current:  map[string]string{"X-Forwarded-For": "true"},
+			desired:  nil,
+			expected: map[string]string{},
+			changed:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		headers, changed := reconcileInsertHeaders(test.current, test.desired)
+		if !reflect.DeepEqual(headers, test.expected) || changed != test.changed {
+			t.Errorf("reconcileInsertHeaders(%v, %v) = %v, %v, expected %v, %v", test.current, test.desired, headers, changed, test.expected, test.changed)
+		}
+	}
+}
+
+func TestGetAttachedPorts(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/ports", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		if deviceID := r.URL.Query().Get("device_id"); deviceID != "server-id" {
+			t.Errorf("expected the ports to be filtered by device_id server-id, got %q", deviceID)
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Query().Get("marker") {
+		case "":
+			fmt.Fprintf(w, `{"ports": [{"id": "port-1", "device_id": "server-id"}], "ports_links": [{"rel": "next", "href": "%sports?device_id=server-id&marker=port-1"}]}`, th.Endpoint())
+		case "port-1":
+			fmt.Fprintf(w, `{"ports": [{"id": "port-2", "device_id": "server-id"}], "ports_links": [{"rel": "next", "href": "%sports?device_id=server-id&marker=port-2"}]}`, th.Endpoint())
+		case "port-2":
+			fmt.Fprintf(w, `{"ports": []}`)
+		default:
+			t.Errorf("unexpected marker %q", r.URL.Query().Get("marker"))
+		}
+	})
+
+	ports, err := getAttachedPorts(fake.ServiceClient(), "server-id")
+	th.AssertNoErr(t, err)
+
+	var ids []string
+	for _, port := range ports {
+		ids = append(ids, port.ID)
+	}
+	th.AssertDeepEquals(t, []string{"port-1", "port-2"}, ids)
+}
+
+func TestCheckTLSContainerRefs(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/active":
+			fmt.Fprint(w, `{"status": "ACTIVE", "type": "certificate"}`)
+		case "/containers/pending":
+			fmt.Fprint(w, `{"status": "PENDING", "type": "certificate"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	th.Mux.HandleFunc("/secrets/pkcs12", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "ACTIVE", "secret_type": "opaque"}`)
+	})
+
+	const barbican = "https://barbican.example.com/v1/"
+	testCases := []struct {
+		annotations map[string]string
+		secret      bool
+		tlsRef      string
+		sniRefs     []string
+		event       bool
+	}{
+		{annotations: map[string]string{}},
+		{
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerDefaultTLSContainerRef: barbican + "containers/active",
+				ServiceAnnotationLoadBalancerSNIContainerRefs:       barbican + "secrets/pkcs12, " + barbican + "containers/active",
+			},
+			secret:  true,
+			tlsRef:  barbican + "containers/active",
+			sniRefs: []string{barbican + "secrets/pkcs12", barbican + "containers/active"},
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerDefaultTLSContainerRef: barbican + "containers/missing"},
+			secret:      true,
+			event:       true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerDefaultTLSContainerRef: barbican + "containers/pending"},
+			secret:      true,
+			event:       true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerDefaultTLSContainerRef: barbican + "containers/active"},
+			event:       true,
+		},
+		{
+			annotations: map[string]string{ServiceAnnotationLoadBalancerSNIContainerRefs: barbican + "containers/active"},
+			secret:      true,
+			event:       true,
+		},
+	}
+
+	for _, test := range testCases {
+		recorder := record.NewFakeRecorder(1)
+		lbaas := &LbaasV2{LoadBalancer{eventRecorder: recorder}}
+		if test.secret {
+			lbaas.secret = fake.ServiceClient()
+		}
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: test.annotations}}
+		svcConf := &serviceConfig{}
+
+		err := lbaas.checkTLSContainerRefs(service, svcConf)
+		if test.event {
+			if err == nil {
+				t.Errorf("checkTLSContainerRefs(%v) succeeded, expected an error", test.annotations)
+			}
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, EventReasonInvalidTLSContainerRef) {
+					t.Errorf("checkTLSContainerRefs(%v) recorded %q", test.annotations, event)
+				}
+			default:
+				t.Errorf("checkTLSContainerRefs(%v) recorded no event", test.annotations)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("checkTLSContainerRefs(%v) returned error: %v", test.annotations, err)
+		}
+		if svcConf.tlsContainerRef != test.tlsRef || !reflect.DeepEqual(svcConf.sniContainerRefs, test.sniRefs) {
+			t.Errorf("checkTLSContainerRefs(%v) read %q and %v, expected %q and %v", test.annotations, svcConf.tlsContainerRef, svcConf.sniContainerRefs, test.tlsRef, test.sniRefs)
+		}
+	}
+}
+
+func TestWaitLoadbalancerActiveTimeout(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	statuses := map[string]string{"pending": "PENDING_CREATE", "active": "ACTIVE"}
+	th.Mux.HandleFunc("/lbaas/loadbalancers/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/lbaas/loadbalancers/")
+		fmt.Fprintf(w, `{"loadbalancer": {"id": "%s", "provisioning_status": "%s"}}`, id, statuses[id])
+	})
+
+	client := fake.ServiceClient()
+
+	status, err := waitLoadbalancerActiveTimeout(client, "active", time.Second)
+	if err != nil || status != "ACTIVE" {
+		t.Errorf("waitLoadbalancerActiveTimeout() returned %q, %v, expected ACTIVE", status, err)
+	}
+
+	status, err = waitLoadbalancerActiveTimeout(client, "pending", time.Second)
+	if err == nil {
+		t.Errorf("waitLoadbalancerActiveTimeout() of a pending loadbalancer succeeded")
+	}
+	if !isPendingStatus(status) {
+		t.Errorf("waitLoadbalancerActiveTimeout() returned status %q, expected a pending status", status)
+	}
+}
+
+func TestEnsureOctaviaPoolUpdateFailure(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "lb", r.URL.Query().Get("loadbalancer_id"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pools": [{"id": "pool", "protocol": "TCP", "lb_algorithm": "ROUND_ROBIN", "listeners": [{"id": "listener"}]}]}`)
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "PUT")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	lbaas := &LbaasV2{LoadBalancer{lb: fake.ServiceClient()}}
+	listener := &listeners.Listener{ID: "listener", Protocol: "TCP"}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	port := corev1.ServicePort{Port: 80, Protocol: corev1.ProtocolTCP}
+	svcConf := &serviceConfig{lbMethod: v2pools.LBMethodLeastConnections}
+
+	_, err := lbaas.ensureOctaviaPool("lb", listener, service, port, nil, svcConf)
+	if err == nil || !strings.Contains(err.Error(), "error updating pool pool") {
+		t.Errorf("ensureOctaviaPool() with a failed pool update returned %v, expected an error updating pool pool", err)
+	}
+}
+
+func TestUpdateOctaviaLoadBalancerInitialDelay(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/lbaas/loadbalancers", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "kube_service_kubernetes_default_web", r.URL.Query().Get("name"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancers": [{"id": "lb", "provisioning_status": "ACTIVE"}]}`)
+	})
+	th.Mux.HandleFunc("/lbaas/loadbalancers/lb", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancer": {"id": "lb", "provisioning_status": "ACTIVE"}}`)
+	})
+	th.Mux.HandleFunc("/lbaas/listeners", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"listeners": [{"id": "listener", "protocol": "TCP", "protocol_port": 80, "loadbalancers": [{"id": "lb"}]}]}`)
+	})
+	th.Mux.HandleFunc("/lbaas/pools", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pools": [{"id": "update-pool", "protocol": "TCP", "lb_algorithm": "ROUND_ROBIN", "listeners": [{"id": "listener"}]}]}`)
+	})
+	var updated string
+	th.Mux.HandleFunc("/lbaas/pools/update-pool/members", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			body, _ := ioutil.ReadAll(r.Body)
+			updated = string(body)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"members": []}`)
+	})
+	defer func() {
+		memberWarmups.Lock()
+		defer memberWarmups.Unlock()
+		if timer, ok := memberWarmups.timers["update-pool"]; ok {
+			timer.Stop()
+			delete(memberWarmups.timers, "update-pool")
+		}
+		delete(memberWarmups.added, "update-pool/10.0.0.1-30000")
+	}()
+
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:   fake.ServiceClient(),
+		opts: LoadBalancerOpts{UseOctavia: true, SubnetID: "subnet", LBMethod: "ROUND_ROBIN", LBProvider: "amphora"},
+	}}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: map[string]string{
+			ServiceAnnotationLoadBalancerHealthMonitorInitialDelay: "60",
+		}},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, NodePort: 30000, Protocol: corev1.ProtocolTCP}}},
+	}
+	nodes := []*corev1.Node{{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+	}}
+
+	if err := lbaas.updateOctaviaLoadBalancer(context.TODO(), "kubernetes", service, nodes); err != nil {
+		t.Fatalf("updateOctaviaLoadBalancer() returned error: %v", err)
+	}
+	// The member of the node added by the update is down until its initial delay is over
+	if !strings.Contains(updated, `"admin_state_up":false`) {
+		t.Errorf("updateOctaviaLoadBalancer() updated the members with %s, expected the new member down", updated)
+	}
+}
+
+func TestEnsureSecurityGroupRules(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cidr    string
+		created []string
+		deleted []string
+	}{
+		{
+			name:    "IPv4 member subnet",
+			cidr:    "10.0.0.0/24",
+			created: []string{"IPv4 tcp 30443 10.0.0.0/24"},
+			deleted: []string{"stale"},
+		},
+		{
+			name:    "IPv6 member subnet",
+			cidr:    "fd00::/64",
+			created: []string{"IPv6 tcp 30080 fd00::/64", "IPv6 tcp 30443 fd00::/64"},
+			deleted: []string{"stale", "current"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			th.SetupHTTP()
+			defer th.TeardownHTTP()
+
+			var created, deleted []string
+			th.Mux.HandleFunc("/security-group-rules", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				switch r.Method {
+				case http.MethodGet:
+					fmt.Fprintf(w, `{"security_group_rules": [
+						{"id": "stale", "description": "%[1]s", "direction": "ingress", "ethertype": "IPv4", "protocol": "tcp", "port_range_min": 30001, "port_range_max": 30001, "remote_ip_prefix": "10.0.0.0/24", "security_group_id": "sg"},
+						{"id": "user", "direction": "ingress", "ethertype": "IPv4", "protocol": "tcp", "port_range_min": 22, "port_range_max": 22, "remote_ip_prefix": "0.0.0.0/0", "security_group_id": "sg"},
+						{"id": "current", "description": "%[1]s", "direction": "ingress", "ethertype": "IPv4", "protocol": "tcp", "port_range_min": 30080, "port_range_max": 30080, "remote_ip_prefix": "10.0.0.0/24", "security_group_id": "sg"}
+					]}`, securityGroupRuleDescription)
+				case http.MethodPost:
+					var req struct {
+						Rule struct {
+							Description    string `json:"description"`
+							EtherType      string `json:"ethertype"`
+							Protocol       string `json:"protocol"`
+							PortRangeMin   int    `json:"port_range_min"`
+							RemoteIPPrefix string `json:"remote_ip_prefix"`
+						} `json:"security_group_rule"`
+					}
+					if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+						t.Errorf("failed to decode the rule: %v", err)
+					}
+					if req.Rule.Description != securityGroupRuleDescription {
+						t.Errorf("rule created with description %q", req.Rule.Description)
+					}
+					created = append(created, fmt.Sprintf("%s %s %d %s", req.Rule.EtherType, req.Rule.Protocol, req.Rule.PortRangeMin, req.Rule.RemoteIPPrefix))
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprint(w, `{"security_group_rule": {"id": "new"}}`)
+				default:
+					t.Errorf("unexpected method %s", r.Method)
+				}
+			})
+			th.Mux.HandleFunc("/security-group-rules/", func(w http.ResponseWriter, r *http.Request) {
+				th.TestMethod(t, r, http.MethodDelete)
+				deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/security-group-rules/"))
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			ports := []corev1.ServicePort{
+				{Port: 80, NodePort: 30080, Protocol: corev1.ProtocolTCP},
+				{Port: 443, NodePort: 30443, Protocol: corev1.ProtocolTCP},
+			}
+			th.AssertNoErr(t, ensureSecurityGroupRules(fake.ServiceClient(), "sg", ports, test.cidr))
+			th.AssertDeepEquals(t, test.created, created)
+			th.AssertDeepEquals(t, test.deleted, deleted)
+		})
+	}
+}
+
+func TestRecordLoadBalancerHealth(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	memberStatus := "ERROR"
+	th.Mux.HandleFunc("/lbaas/loadbalancers/lb/status", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		lbStatus := "ONLINE"
+		if memberStatus != "ONLINE" {
+			lbStatus = "DEGRADED"
+		}
+		fmt.Fprintf(w, `{"statuses": {"loadbalancer": {"id": "lb", "provisioning_status": "ACTIVE", "operating_status": "%[1]s", "listeners": [
+			{"id": "listener", "provisioning_status": "ACTIVE", "operating_status": "%[1]s", "pools": [
+				{"id": "pool", "name": "http", "provisioning_status": "ACTIVE", "operating_status": "%[1]s", "members": [
+					{"id": "up", "address": "10.0.0.1", "protocol_port": 30080, "provisioning_status": "ACTIVE", "operating_status": "ONLINE"},
+					{"id": "down", "address": "10.0.0.2", "protocol_port": 30080, "provisioning_status": "ACTIVE", "operating_status": "%[2]s"}
+				]}
+			]}
+		]}}}`, lbStatus, memberStatus)
+	})
+
+	recorder := record.NewFakeRecorder(10)
+	lbaas := &LbaasV2{LoadBalancer{
+		lb:            fake.ServiceClient(),
+		eventRecorder: recorder,
+		health:        newLoadBalancerHealth(),
+	}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default"}}
+
+	expectEvents := func(expected ...string) {
+		t.Helper()
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		th.AssertDeepEquals(t, expected, events)
+	}
+
+	lbaas.recordLoadBalancerHealth(service, "lb")
+	expectEvents(
+		"Warning LoadBalancerUnhealthy Loadbalancer lb: the listener listener is in operating status DEGRADED",
+		"Warning LoadBalancerUnhealthy Loadbalancer lb: the loadbalancer is in operating status DEGRADED",
+		"Warning LoadBalancerUnhealthy Loadbalancer lb: the member 10.0.0.2:30080(down) of pool http(pool) is in operating status ERROR",
+		"Warning LoadBalancerUnhealthy Loadbalancer lb: the pool http(pool) is in operating status DEGRADED",
+	)
+
+	// the unchanged statuses are not recorded again
+	lbaas.recordLoadBalancerHealth(service, "lb")
+	expectEvents()
+
+	memberStatus = "ONLINE"
+	lbaas.recordLoadBalancerHealth(service, "lb")
+	expectEvents("Normal LoadBalancerHealthy Loadbalancer lb recovered")
+}
+
+func TestLoadBalancerNamespaceNotAllowed(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	recorder := record.NewFakeRecorder(10)
+	lbaas := &LbaasV2{LoadBalancer{
+		network:       fake.ServiceClient(),
+		compute:       fake.ServiceClient(),
+		lb:            fake.ServiceClient(),
+		eventRecorder: recorder,
+		opts:          LoadBalancerOpts{UseOctavia: true, AllowedNamespaces: []string{"team-a"}},
+	}}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "team-b"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 80, NodePort: 30080, Protocol: corev1.ProtocolTCP}},
+		},
+	}
+	nodes := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node"}}}
+	expectEvent := func() {
+		t.Helper()
+		select {
+		case event := <-recorder.Events:
+			th.AssertEquals(t, "Warning LoadBalancerNamespaceNotAllowed the Services of namespace team-b are not allowed to have a load balancer, see `allowed-namespace` in section [LoadBalancer]", event)
+		default:
+			t.Errorf("no event was recorded")
+		}
+	}
+
+	if _, err := lbaas.EnsureLoadBalancer(context.TODO(), "kubernetes", service, nodes); err == nil {
+		t.Errorf("EnsureLoadBalancer() succeeded in a disallowed namespace")
+	}
+	expectEvent()
+
+	if err := lbaas.UpdateLoadBalancer(context.TODO(), "kubernetes", service, nodes); err == nil {
+		t.Errorf("UpdateLoadBalancer() succeeded in a disallowed namespace")
+	}
+	expectEvent()
+
+	service.Namespace = "team-a"
+	th.AssertNoErr(t, lbaas.checkNamespaceAllowed(service))
+	lbaas.opts.AllowedNamespaces = nil
+	service.Namespace = "team-b"
+	th.AssertNoErr(t, lbaas.checkNamespaceAllowed(service))
+}
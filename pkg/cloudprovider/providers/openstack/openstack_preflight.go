@@ -0,0 +1,176 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/pagination"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+)
+
+// PreflightResult is the result of a preflight check
+type PreflightResult struct {
+	Check string
+	// Skipped is set when the check doesn't apply to the configuration
+	Skipped bool
+	// Err is set when the check failed, Hint then tells how to fix it
+	Err  error
+	Hint string
+}
+
+// preflightCheck lists the first page of a resource of a service, which
+// requires the service to be reachable and the user to be allowed to read it
+type preflightCheck struct {
+	name      string
+	resource  string
+	newClient func() (*gophercloud.ServiceClient, error)
+	list      func(client *gophercloud.ServiceClient) pagination.Pager
+	// skip is the reason why the check doesn't apply when set
+	skip string
+}
+
+func (c preflightCheck) run() PreflightResult {
+	result := PreflightResult{Check: c.name}
+	if c.skip != "" {
+		result.Skipped = true
+		result.Hint = c.skip
+		return result
+	}
+
+	client, err := c.newClient()
+	if err != nil {
+		result.Err = err
+		result.Hint = fmt.Sprintf("the %s service is missing from the catalog of the region, check `region` in section [Global]", c.name)
+		return result
+	}
+
+	mc := metrics.NewMetricContext(c.resource, "list")
+	err = c.list(client).EachPage(func(page pagination.Page) (bool, error) {
+		return false, nil
+	})
+	if mc.ObserveRequest(err) != nil {
+		result.Err = fmt.Errorf("failed to list the %ss: %v", c.resource, err)
+		result.Hint = preflightHint(c.name, c.resource, err)
+	}
+	return result
+}
+
+// preflightHint tells how to fix the failure of the request listing the
+// resources of a service
+func preflightHint(service, resource string, err error) string {
+	switch err.(type) {
+	case gophercloud.ErrDefault401, *gophercloud.ErrDefault401:
+		return "the token was rejected, check the credentials in section [Global]"
+	case gophercloud.ErrDefault403, *gophercloud.ErrDefault403:
+		return fmt.Sprintf("the user isn't allowed to list the %ss, grant it a role allowing it in the project, e.g. member", resource)
+	}
+	return fmt.Sprintf("check that the %s endpoint of the catalog is reachable from the controller and that the service is healthy", service)
+}
+
+// hasEndpoint returns whether the catalog has an endpoint of the service type
+// in the region
+func (os *OpenStack) hasEndpoint(service string) bool {
+	eo := gophercloud.EndpointOpts{
+		Type:   service,
+		Region: os.region,
+	}
+	eo.ApplyDefaults(service)
+	_, err := os.provider.EndpointLocator(eo)
+	return err == nil
+}
+
+func (os *OpenStack) preflightChecks() []preflightCheck {
+	checks := []preflightCheck{
+		{
+			name:      "compute",
+			resource:  "server",
+			newClient: os.NewComputeV2,
+			list: func(client *gophercloud.ServiceClient) pagination.Pager {
+				return servers.List(client, servers.ListOpts{Limit: 1})
+			},
+		},
+		{
+			name:      "network",
+			resource:  "network",
+			newClient: os.NewNetworkV2,
+			list: func(client *gophercloud.ServiceClient) pagination.Pager {
+				return networks.List(client, networks.ListOpts{Limit: 1})
+			},
+		},
+		{
+			name:      "load-balancer",
+			resource:  "loadbalancer",
+			newClient: os.NewLoadBalancerV2,
+			list: func(client *gophercloud.ServiceClient) pagination.Pager {
+				return loadbalancers.List(client, loadbalancers.ListOpts{Limit: 1})
+			},
+		},
+		{
+			name:      "block-storage",
+			resource:  "volume",
+			newClient: os.NewBlockStorageV3,
+			list: func(client *gophercloud.ServiceClient) pagination.Pager {
+				return volumes.List(client, volumes.ListOpts{Limit: 1})
+			},
+		},
+	}
+
+	if !os.lbOpts.UseOctavia {
+		checks[2].skip = "use-octavia is false"
+	}
+	if !os.hasEndpoint("volumev3") {
+		checks[3].skip = "no volumev3 endpoint in the catalog of the region"
+	}
+
+	return checks
+}
+
+// Preflight validates the cloud config files and checks that the OpenStack
+// services used by the controllers are reachable with the configured
+// credentials. Each service is checked by listing the first page of one of
+// its resources: compute and network always, load-balancer when Octavia is
+// used and block-storage when the catalog has its endpoint.
+func Preflight() []PreflightResult {
+	cfg, err := readCloudConfig()
+	if err != nil {
+		return []PreflightResult{{Check: "config", Err: err, Hint: "fix the cloud config file given by --cloud-config"}}
+	}
+	results := []PreflightResult{{Check: "config"}}
+
+	cloud, err := NewOpenStack(cfg)
+	if err != nil {
+		return append(results, PreflightResult{
+			Check: "authentication",
+			Err:   err,
+			Hint:  "check `auth-url` and the credentials in section [Global], and that Keystone is reachable from the controller",
+		})
+	}
+	results = append(results, PreflightResult{Check: "authentication"})
+
+	for _, check := range cloud.preflightChecks() {
+		results = append(results, check.run())
+	}
+
+	return results
+}
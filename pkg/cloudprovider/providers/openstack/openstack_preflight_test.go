@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/pagination"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+func TestPreflightCheck(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/servers/detail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	th.Mux.HandleFunc("/networks", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "1", r.URL.Query().Get("limit"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"networks": [{"id": "net"}]}`)
+	})
+
+	client := func() (*gophercloud.ServiceClient, error) { return fake.ServiceClient(), nil }
+	listServers := func(client *gophercloud.ServiceClient) pagination.Pager {
+		return servers.List(client, servers.ListOpts{Limit: 1})
+	}
+	listNetworks := func(client *gophercloud.ServiceClient) pagination.Pager {
+		return networks.List(client, networks.ListOpts{Limit: 1})
+	}
+
+	testCases := []struct {
+		check   preflightCheck
+		skipped bool
+		hint    string
+	}{
+		{
+			check: preflightCheck{name: "network", resource: "network", newClient: client, list: listNetworks},
+		},
+		{
+			check: preflightCheck{name: "compute", resource: "server", newClient: client, list: listServers},
+			hint:  "isn't allowed to list the servers",
+		},
+		{
+			check: preflightCheck{name: "compute", resource: "server", list: listServers, newClient: func() (*gophercloud.ServiceClient, error) {
+				return nil, errors.New("failed to find compute v2 endpoint for region RegionOne")
+			}},
+			hint: "`region` in section [Global]",
+		},
+		{
+			check:   preflightCheck{name: "load-balancer", skip: "use-octavia is false"},
+			skipped: true,
+			hint:    "use-octavia is false",
+		},
+	}
+
+	for _, test := range testCases {
+		result := test.check.run()
+		if result.Skipped != test.skipped || !strings.Contains(result.Hint, test.hint) || (result.Err != nil) != (test.hint != "" && !test.skipped) {
+			t.Errorf("%s check returned %+v, expected skipped %t and the hint %q", test.check.name, result, test.skipped, test.hint)
+		}
+	}
+}
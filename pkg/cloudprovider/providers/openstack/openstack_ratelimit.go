@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+)
+
+// rateLimitGlobal is the service label of the requests limited by the
+// global rate limiter
+const rateLimitGlobal = "global"
+
+// RateLimitOpts is used to limit the rate of the OpenStack API requests
+type RateLimitOpts struct {
+	// QPS is the sustained number of requests per second, the requests are
+	// not limited when it is not set
+	QPS float32 `gcfg:"qps"`
+	// Burst is the number of requests which may exceed QPS, it defaults to
+	// QPS rounded up
+	Burst int `gcfg:"burst"`
+}
+
+func (opts *RateLimitOpts) newRateLimiter() flowcontrol.RateLimiter {
+	if opts == nil || opts.QPS <= 0 {
+		return nil
+	}
+	burst := opts.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(float64(opts.QPS)))
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(opts.QPS, burst)
+}
+
+// endpointRateLimiter is the rate limiter of the requests sent to an endpoint
+type endpointRateLimiter struct {
+	endpoint string
+	service  string
+	limiter  flowcontrol.RateLimiter
+}
+
+// rateLimitedTransport waits for the rate limiter of the service of each
+// request before sending it
+type rateLimitedTransport struct {
+	rt http.RoundTripper
+	// endpoints are sorted by decreasing length so that the most specific
+	// endpoint matches first
+	endpoints []endpointRateLimiter
+	global    flowcontrol.RateLimiter
+}
+
+func (t *rateLimitedTransport) limiterFor(url string) (string, flowcontrol.RateLimiter) {
+	for _, e := range t.endpoints {
+		if strings.HasPrefix(url, e.endpoint) {
+			return e.service, e.limiter
+		}
+	}
+	return rateLimitGlobal, t.global
+}
+
+// RoundTrip waits until the request is allowed by the rate limiter, or
+// fails once the request context is done or would be done before then.
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	service, limiter := t.limiterFor(req.URL.String())
+	if limiter != nil {
+		err := metrics.ObserveRateLimiterWait(service, func() error {
+			return limiter.Wait(req.Context())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rate limit of the %s requests: %v", service, err)
+		}
+	}
+	return t.rt.RoundTrip(req)
+}
+
+// ApplyRateLimit makes all the service clients of the provider client share
+// the global rate limiter configured by opts. The services listed in
+// serviceOpts by their catalog type get their own rate limiter instead.
+func ApplyRateLimit(provider *gophercloud.ProviderClient, region string, opts RateLimitOpts, serviceOpts map[string]*RateLimitOpts) error {
+	t := &rateLimitedTransport{
+		global: opts.newRateLimiter(),
+	}
+
+	for service, o := range serviceOpts {
+		limiter := o.newRateLimiter()
+		if limiter == nil {
+			continue
+		}
+		eo := gophercloud.EndpointOpts{
+			Type:   service,
+			Region: region,
+		}
+		eo.ApplyDefaults(service)
+		endpoint, err := provider.EndpointLocator(eo)
+		if err != nil {
+			return fmt.Errorf("failed to find the %s endpoint to rate limit for region %s: %v", service, region, err)
+		}
+		t.endpoints = append(t.endpoints, endpointRateLimiter{
+			endpoint: gophercloud.NormalizeURL(endpoint),
+			service:  service,
+			limiter:  limiter,
+		})
+		klog.V(4).Infof("Limiting the %s requests sent to %s to %v per second", service, endpoint, o.QPS)
+	}
+
+	if t.global == nil && len(t.endpoints) == 0 {
+		return nil
+	}
+	if t.global != nil {
+		klog.V(4).Infof("Limiting the OpenStack requests to %v per second", opts.QPS)
+	}
+
+	sort.Slice(t.endpoints, func(i, j int) bool {
+		return len(t.endpoints[i].endpoint) > len(t.endpoints[j].endpoint)
+	})
+
+	t.rt = provider.HTTPClient.Transport
+	if t.rt == nil {
+		t.rt = http.DefaultTransport
+	}
+	provider.HTTPClient.Transport = t
+
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRoundTripper struct {
+	requests int
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+}
+
+func TestReadConfigRateLimit(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ region = RegionOne
+ [RateLimit]
+ qps = 10
+ burst = 20
+ [RateLimitService "compute"]
+ qps = 2.5
+ `))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	if cfg.RateLimit.QPS != 10 || cfg.RateLimit.Burst != 20 {
+		t.Errorf("incorrect rate limit: %+v", cfg.RateLimit)
+	}
+	compute, ok := cfg.RateLimitService["compute"]
+	if !ok {
+		t.Fatalf("missing compute rate limit: %+v", cfg.RateLimitService)
+	}
+	if compute.QPS != 2.5 || compute.Burst != 0 {
+		t.Errorf("incorrect compute rate limit: %+v", compute)
+	}
+}
+
+func TestRateLimitedTransport(t *testing.T) {
+	computeOpts := RateLimitOpts{QPS: 0.001, Burst: 1}
+	globalOpts := RateLimitOpts{QPS: 1000}
+	rt := &fakeRoundTripper{}
+	transport := &rateLimitedTransport{
+		rt: rt,
+		endpoints: []endpointRateLimiter{
+			{endpoint: "http://compute.example.com/v2.1/", service: "compute", limiter: computeOpts.newRateLimiter()},
+		},
+		global: globalOpts.newRateLimiter(),
+	}
+
+	service, _ := transport.limiterFor("http://compute.example.com/v2.1/servers/detail")
+	if service != "compute" {
+		t.Errorf("expected the compute rate limiter, got %s", service)
+	}
+	service, _ = transport.limiterFor("http://network.example.com/v2.0/ports")
+	if service != rateLimitGlobal {
+		t.Errorf("expected the global rate limiter, got %s", service)
+	}
+
+	send := func(url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = transport.RoundTrip(req)
+		return err
+	}
+
+	// the burst lets the first compute request through
+	if err := send("http://compute.example.com/v2.1/servers/detail"); err != nil {
+		t.Errorf("unexpected error on the first compute request: %v", err)
+	}
+	// the next compute request would wait beyond the request deadline
+	if err := send("http://compute.example.com/v2.1/servers/detail"); err == nil {
+		t.Errorf("expected the second compute request to fail on its deadline")
+	}
+	// the other services are not limited by the compute rate limiter
+	for i := 0; i < 5; i++ {
+		if err := send("http://network.example.com/v2.0/ports"); err != nil {
+			t.Errorf("unexpected error on network request %d: %v", i, err)
+		}
+	}
+
+	if rt.requests != 6 {
+		t.Errorf("expected 6 requests sent, got %d", rt.requests)
+	}
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	var nilOpts *RateLimitOpts
+	if nilOpts.newRateLimiter() != nil {
+		t.Errorf("expected no rate limiter without options")
+	}
+	if (&RateLimitOpts{Burst: 5}).newRateLimiter() != nil {
+		t.Errorf("expected no rate limiter without qps")
+	}
+	if (&RateLimitOpts{QPS: 1.5}).newRateLimiter() == nil {
+		t.Errorf("expected a rate limiter")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"sync"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+)
+
+const (
+	reauthReasonTokenExpired       = "token_expired"
+	reauthReasonCredentialReloaded = "credential_reloaded"
+)
+
+// AuthOptsLoader returns the current authentication options, e.g. read again
+// from the cloud config once an application credential was rotated
+type AuthOptsLoader func() (*AuthOpts, error)
+
+// usesApplicationCredential returns whether the options authenticate with an
+// application credential, trusts are consumed with the trustee credentials
+func usesApplicationCredential(cfg *AuthOpts) bool {
+	return cfg.TrustID == "" && (cfg.ApplicationCredentialID != "" || cfg.ApplicationCredentialName != "")
+}
+
+func sameApplicationCredential(a, b *AuthOpts) bool {
+	return a.ApplicationCredentialID == b.ApplicationCredentialID &&
+		a.ApplicationCredentialName == b.ApplicationCredentialName &&
+		a.ApplicationCredentialSecret == b.ApplicationCredentialSecret
+}
+
+func isUnauthorized(err error) bool {
+	switch err.(type) {
+	case gophercloud.ErrDefault401, *gophercloud.ErrDefault401:
+		return true
+	}
+	return false
+}
+
+// EnableApplicationCredentialReload makes the provider client load the
+// authentication options again when re-authenticating with its application
+// credential is rejected, e.g. because the credential was revoked or has
+// expired, and re-authenticate with the loaded application credential when
+// it differs. The clients authenticating with a password, a token or a trust
+// are left as is.
+func EnableApplicationCredentialReload(provider *gophercloud.ProviderClient, cfg *AuthOpts, load AuthOptsLoader) {
+	if provider.ReauthFunc == nil || load == nil || !usesApplicationCredential(cfg) {
+		return
+	}
+
+	// The re-authentication replaces the credential and the re-authentication
+	// it uses once the reloaded credential is accepted
+	var lock sync.Mutex
+	reauth := provider.ReauthFunc
+	provider.ReauthFunc = func() error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		err := reauth()
+		if !isUnauthorized(err) {
+			return metrics.ObserveReauthentication(reauthReasonTokenExpired, err)
+		}
+
+		loaded, loadErr := load()
+		if loadErr != nil {
+			klog.Errorf("Failed to load the OpenStack authentication options after the application credential was rejected: %v", loadErr)
+			return metrics.ObserveReauthentication(reauthReasonTokenExpired, err)
+		}
+		if !usesApplicationCredential(loaded) || sameApplicationCredential(cfg, loaded) {
+			klog.Errorf("The application credential was rejected and no other application credential is configured: %v", err)
+			return metrics.ObserveReauthentication(reauthReasonTokenExpired, err)
+		}
+
+		klog.Infof("The application credential was rejected, re-authenticating with the reloaded application credential")
+		if err := authenticateThrowaway(provider, loaded); err != nil {
+			return metrics.ObserveReauthentication(reauthReasonCredentialReloaded, err)
+		}
+
+		cfg = loaded
+		reauth = func() error {
+			return authenticateThrowaway(provider, loaded)
+		}
+		return metrics.ObserveReauthentication(reauthReasonCredentialReloaded, nil)
+	}
+}
+
+// authenticateThrowaway authenticates a throwaway copy of the provider client
+// with the application credential of the options and copies its token to the
+// provider client. The requests of the copy are not re-authenticated, so a
+// rejected credential fails instead of waiting on the ongoing
+// re-authentication of the provider client.
+func authenticateThrowaway(provider *gophercloud.ProviderClient, cfg *AuthOpts) error {
+	tac := *provider
+	tac.SetThrowaway(true)
+	tac.ReauthFunc = nil
+	if err := tac.SetTokenAndAuthResult(nil); err != nil {
+		return err
+	}
+
+	opts := cfg.ToAuth3Options()
+	if err := openstack.AuthenticateV3(&tac, &opts, gophercloud.EndpointOpts{}); err != nil {
+		return err
+	}
+	provider.CopyTokenFrom(&tac)
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	th "github.com/gophercloud/gophercloud/testhelper"
+)
+
+func TestEnableApplicationCredentialReload(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Auth struct {
+				Identity struct {
+					ApplicationCredential struct {
+						ID     string
+						Secret string
+					} `json:"application_credential"`
+				}
+			}
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the token request: %v", err)
+		}
+		if req.Auth.Identity.ApplicationCredential.Secret != "new-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Add("X-Subject-Token", "new-token")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": {"expires_at": "2030-01-01T00:00:00.000000Z", "catalog": []}}`)
+	})
+
+	newProvider := func() *gophercloud.ProviderClient {
+		provider, err := openstack.NewClient(th.Endpoint())
+		th.AssertNoErr(t, err)
+		provider.ReauthFunc = func() error {
+			return gophercloud.ErrDefault401{}
+		}
+		return provider
+	}
+
+	oldCfg := &AuthOpts{
+		AuthURL:                     th.Endpoint(),
+		ApplicationCredentialID:     "app-cred",
+		ApplicationCredentialSecret: "old-secret",
+	}
+	newCfg := *oldCfg
+	newCfg.ApplicationCredentialSecret = "new-secret"
+
+	// the rotated application credential is used to re-authenticate
+	provider := newProvider()
+	EnableApplicationCredentialReload(provider, oldCfg, func() (*AuthOpts, error) {
+		return &newCfg, nil
+	})
+	th.AssertNoErr(t, provider.ReauthFunc())
+	th.AssertEquals(t, "new-token", provider.Token())
+	// the next re-authentications use the rotated application credential
+	th.AssertNoErr(t, provider.Reauthenticate(provider.Token()))
+
+	// a rejected reloaded application credential fails the re-authentication
+	rejectedCfg := *oldCfg
+	rejectedCfg.ApplicationCredentialSecret = "rejected-secret"
+	provider = newProvider()
+	EnableApplicationCredentialReload(provider, oldCfg, func() (*AuthOpts, error) {
+		return &rejectedCfg, nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Reauthenticate(provider.Token())
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected the re-authentication with the rejected application credential to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the re-authentication with the rejected application credential didn't return")
+	}
+
+	// the same application credential is not used again
+	loads := 0
+	provider = newProvider()
+	EnableApplicationCredentialReload(provider, oldCfg, func() (*AuthOpts, error) {
+		loads++
+		return oldCfg, nil
+	})
+	if _, ok := provider.ReauthFunc().(gophercloud.ErrDefault401); !ok {
+		t.Errorf("expected the re-authentication to fail with the unchanged application credential")
+	}
+	th.AssertEquals(t, 1, loads)
+
+	// the password authentication is left as is
+	loads = 0
+	provider = newProvider()
+	EnableApplicationCredentialReload(provider, &AuthOpts{AuthURL: th.Endpoint(), Username: "user", Password: "pass"}, func() (*AuthOpts, error) {
+		loads++
+		return &newCfg, nil
+	})
+	if _, ok := provider.ReauthFunc().(gophercloud.ErrDefault401); !ok {
+		t.Errorf("expected the password re-authentication to be unchanged")
+	}
+	th.AssertEquals(t, 0, loads)
+}
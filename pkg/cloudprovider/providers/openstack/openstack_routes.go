@@ -58,7 +58,7 @@ func NewRoutes(compute *gophercloud.ServiceClient, network *gophercloud.ServiceC
 
 // ListRoutes lists all managed routes that belong to the specified clusterName
 func (r *Routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
-	klog.V(4).Infof("ListRoutes(%v)", clusterName)
+	klog.V(4).InfoS("ListRoutes() called", "cluster", clusterName)
 
 	nodeNamesByAddr := make(map[string]types.NodeName)
 	err := foreachServer(r.compute, servers.ListOpts{}, func(srv *servers.Server) (bool, error) {
@@ -119,7 +119,7 @@ func updateRoutes(network *gophercloud.ServiceClient, router *routers.Router, ne
 	}
 
 	unwinder := func() {
-		klog.V(4).Infof("Reverting routes change to router %v", router.ID)
+		klog.V(4).InfoS("Reverting routes change to router", "router", router.ID)
 		mc := metrics.NewMetricContext("router", "update")
 		_, err := routers.Update(network, router.ID, routers.UpdateOpts{
 			Routes: origRoutes,
@@ -144,7 +144,7 @@ func updateAllowedAddressPairs(network *gophercloud.ServiceClient, port *neutron
 	}
 
 	unwinder := func() {
-		klog.V(4).Infof("Reverting allowed-address-pairs change to port %v", port.ID)
+		klog.V(4).InfoS("Reverting allowed-address-pairs change to port", "port", port.ID)
 		mc := metrics.NewMetricContext("port", "update")
 		_, err := neutronports.Update(network, port.ID, neutronports.UpdateOpts{
 			AllowedAddressPairs: &origPairs,
@@ -159,7 +159,7 @@ func updateAllowedAddressPairs(network *gophercloud.ServiceClient, port *neutron
 
 // CreateRoute creates the described managed route
 func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint string, route *cloudprovider.Route) error {
-	klog.V(4).Infof("CreateRoute(%v, %v, %v)", clusterName, nameHint, route)
+	klog.V(4).InfoS("CreateRoute() called", "cluster", clusterName, "nameHint", nameHint, "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 
 	onFailure := newCaller()
 
@@ -171,7 +171,7 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 		return err
 	}
 
-	klog.V(4).Infof("Using nexthop %v for node %v", addr, route.TargetNode)
+	klog.V(4).InfoS("Using nexthop for node", "nexthop", addr, "node", route.TargetNode)
 
 	mc := metrics.NewMetricContext("router", "get")
 	router, err := routers.Get(r.network, r.opts.RouterID).Extract()
@@ -183,7 +183,7 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 
 	for _, item := range routes {
 		if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == addr {
-			klog.V(4).Infof("Skipping existing route: %v", route)
+			klog.V(4).InfoS("Skipping existing route", "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 			return nil
 		}
 	}
@@ -212,7 +212,7 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 	found := false
 	for _, item := range port.AllowedAddressPairs {
 		if item.IPAddress == route.DestinationCIDR {
-			klog.V(4).Infof("Found existing allowed-address-pair: %v", item)
+			klog.V(4).InfoS("Found existing allowed-address-pair", "port", port.ID, "address", item.IPAddress)
 			found = true
 			break
 		}
@@ -229,14 +229,14 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 		defer onFailure.call(unwind)
 	}
 
-	klog.V(4).Infof("Route created: %v", route)
+	klog.V(4).InfoS("Route created", "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 	onFailure.disarm()
 	return nil
 }
 
 // DeleteRoute deletes the specified managed route
 func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *cloudprovider.Route) error {
-	klog.V(4).Infof("DeleteRoute(%v, %v)", clusterName, route)
+	klog.V(4).InfoS("DeleteRoute() called", "cluster", clusterName, "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 
 	onFailure := newCaller()
 
@@ -269,7 +269,7 @@ func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *clo
 	}
 
 	if index == -1 {
-		klog.V(4).Infof("Skipping non-existent route: %v", route)
+		klog.V(4).InfoS("Skipping non-existent route", "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 		return nil
 	}
 
@@ -315,7 +315,7 @@ func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *clo
 		defer onFailure.call(unwind)
 	}
 
-	klog.V(4).Infof("Route deleted: %v", route)
+	klog.V(4).InfoS("Route deleted", "node", route.TargetNode, "destinationCIDR", route.DestinationCIDR)
 	onFailure.disarm()
 	return nil
 }
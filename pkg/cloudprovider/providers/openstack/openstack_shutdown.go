@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const defaultShutdownGracePeriod = 30 * time.Second
+
+// shutdownGracePeriod is how long the in-flight operations may run on shutdown
+var shutdownGracePeriod = defaultShutdownGracePeriod
+
+// shutdownCoordinator tracks the in-flight OpenStack operations, so that they
+// may finish on shutdown before their requests are canceled
+type shutdownCoordinator struct {
+	lock     sync.Mutex
+	inflight int
+	draining bool
+	// idle is closed once no operation is in flight after the shutdown started
+	idle   chan struct{}
+	isIdle bool
+
+	// stopping is canceled once the shutdown starts, it stops the background
+	// controllers and the reconciles starting from then
+	stopping context.Context
+	stop     context.CancelFunc
+	// requests is the parent context of the OpenStack requests, it's canceled
+	// once the grace period is over
+	requests context.Context
+	cancel   context.CancelFunc
+}
+
+var shutdown = newShutdownCoordinator()
+
+func newShutdownCoordinator() *shutdownCoordinator {
+	s := &shutdownCoordinator{idle: make(chan struct{})}
+	s.stopping, s.stop = context.WithCancel(context.Background())
+	s.requests, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// track returns the context of an operation bound to ctx, which is done once
+// the shutdown grace period is over. The operation is in flight until the
+// returned function is called.
+func (s *shutdownCoordinator) track(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-s.requests.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	s.lock.Lock()
+	s.inflight++
+	s.lock.Unlock()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+
+			s.lock.Lock()
+			s.inflight--
+			s.notifyIdle()
+			s.lock.Unlock()
+		})
+	}
+}
+
+// notifyIdle closes idle once the shutdown started and no operation is in
+// flight, s.lock must be held
+func (s *shutdownCoordinator) notifyIdle() {
+	if s.draining && s.inflight == 0 && !s.isIdle {
+		s.isIdle = true
+		close(s.idle)
+	}
+}
+
+// shutdown stops the reconciles, waits up to gracePeriod for the in-flight
+// operations to finish and then cancels their requests. It returns whether
+// all the operations finished in time.
+func (s *shutdownCoordinator) shutdown(gracePeriod time.Duration) bool {
+	s.stop()
+
+	s.lock.Lock()
+	s.draining = true
+	s.notifyIdle()
+	s.lock.Unlock()
+
+	drained := true
+	select {
+	case <-s.idle:
+	case <-time.After(gracePeriod):
+		drained = false
+	}
+	s.cancel()
+
+	return drained
+}
+
+// Shutdown is called by the main package on termination. The reconciles
+// starting from then fail, the in-flight OpenStack operations are given the
+// --shutdown-grace-period to finish before their requests are canceled and
+// the connections of the OpenStack clients are closed.
+func Shutdown() {
+	klog.Infof("Shutting down, waiting up to %v for the in-flight OpenStack operations", shutdownGracePeriod)
+	if shutdown.shutdown(shutdownGracePeriod) {
+		klog.Infof("The in-flight OpenStack operations are done")
+	} else {
+		klog.Warningf("Canceling the in-flight OpenStack operations still running after %v", shutdownGracePeriod)
+	}
+
+	DefaultProviderClientPool.Close()
+}
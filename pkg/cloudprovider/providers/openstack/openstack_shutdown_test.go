@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownCoordinator(t *testing.T) {
+	// the in-flight operation finishing within the grace period
+	s := newShutdownCoordinator()
+	ctx, done := s.track(context.Background())
+	go func() {
+		<-s.stopping.Done()
+		if ctx.Err() != nil {
+			t.Errorf("expected the in-flight operation to run until the grace period is over")
+		}
+		done()
+	}()
+	if !s.shutdown(time.Minute) {
+		t.Errorf("expected the in-flight operation to be done")
+	}
+
+	// the in-flight operation outliving the grace period
+	s = newShutdownCoordinator()
+	ctx, done = s.track(context.Background())
+	defer done()
+	if s.shutdown(10 * time.Millisecond) {
+		t.Errorf("expected the in-flight operation to be still running")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("expected the in-flight operation to be canceled")
+	}
+
+	// without any operation in flight
+	s = newShutdownCoordinator()
+	_, done = s.track(context.Background())
+	done()
+	if !s.shutdown(time.Minute) {
+		t.Errorf("expected no operation in flight")
+	}
+	if s.stopping.Err() == nil {
+		t.Errorf("expected the reconciles to be stopped")
+	}
+}
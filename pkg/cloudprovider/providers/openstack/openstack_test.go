@@ -18,8 +18,10 @@ package openstack
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -31,9 +33,13 @@ import (
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	tokens3 "github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
 	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	certutil "k8s.io/client-go/util/cert"
 
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 )
@@ -173,6 +179,150 @@ func TestReadConfig(t *testing.T) {
 	}
 }
 
+func TestReadConfigTrust(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ trust-id = mytrust
+ trustee-id = trustee
+ trustee-password = trusteepass
+ region = RegionOne
+ `))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	if cfg.Global.TrustID != "mytrust" {
+		t.Errorf("incorrect trust id: %s", cfg.Global.TrustID)
+	}
+
+	if cfg.Global.TrusteeID != "trustee" {
+		t.Errorf("incorrect trustee id: %s", cfg.Global.TrusteeID)
+	}
+
+	if cfg.Global.TrusteePassword != "trusteepass" {
+		t.Errorf("incorrect trustee password: %s", cfg.Global.TrusteePassword)
+	}
+}
+
+func TestReadConfigOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cloud-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secrets := filepath.Join(dir, "secrets.conf")
+	err = ioutil.WriteFile(secrets, []byte(`
+ [Global]
+ application-credential-id = app-id
+ application-credential-secret = app-secret
+ `), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb := filepath.Join(dir, "lb.conf")
+	err = ioutil.WriteFile(lb, []byte(`
+ [LoadBalancer]
+ lb-method = SOURCE_IP
+ `), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.conf")
+	err = ioutil.WriteFile(invalid, []byte(`
+ [LoadBalancer]
+ monitor-max-retries = many
+ `), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { configOverrides = nil }()
+	configOverrides = []string{secrets, lb}
+
+	cfg, err := ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ application-credential-secret = placeholder
+ region = RegionOne
+ [LoadBalancer]
+ lb-method = LEAST_CONNECTIONS
+ subnet-id = subnet
+ `))
+	if err != nil {
+		t.Fatalf("Should succeed when valid configs are provided: %s", err)
+	}
+
+	if cfg.Global.AuthURL != "http://auth.url" {
+		t.Errorf("incorrect authurl: %s", cfg.Global.AuthURL)
+	}
+	if cfg.Global.ApplicationCredentialID != "app-id" {
+		t.Errorf("incorrect application credential id: %s", cfg.Global.ApplicationCredentialID)
+	}
+	if cfg.Global.ApplicationCredentialSecret != "app-secret" {
+		t.Errorf("incorrect application credential secret: %s", cfg.Global.ApplicationCredentialSecret)
+	}
+	if cfg.LoadBalancer.LBMethod != "SOURCE_IP" {
+		t.Errorf("incorrect lb.lbmethod: %s", cfg.LoadBalancer.LBMethod)
+	}
+	if cfg.LoadBalancer.SubnetID != "subnet" {
+		t.Errorf("incorrect lb.subnetid: %s", cfg.LoadBalancer.SubnetID)
+	}
+
+	// the overrides may be used without the main config file
+	configOverrides = []string{secrets}
+	cfg, err = ReadConfig(nil)
+	if err != nil {
+		t.Fatalf("Should succeed when only an override is provided: %s", err)
+	}
+	if cfg.Global.ApplicationCredentialID != "app-id" {
+		t.Errorf("incorrect application credential id: %s", cfg.Global.ApplicationCredentialID)
+	}
+
+	configOverrides = []string{secrets, invalid}
+	_, err = ReadConfig(nil)
+	if err == nil || !strings.Contains(err.Error(), invalid) {
+		t.Errorf("expected an error naming %s, got %v", invalid, err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey("client", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	th.AssertNoErr(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	th.AssertNoErr(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	config, err := TLSConfig(&AuthOpts{CAFile: certFile, CertFile: certFile, KeyFile: keyFile})
+	th.AssertNoErr(t, err)
+	if config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Errorf("TLSConfig() didn't configure the CA bundle and the client certificate: %+v", config)
+	}
+
+	for _, test := range []struct {
+		opts     AuthOpts
+		expected string
+	}{
+		{AuthOpts{CAFile: filepath.Join(dir, "missing.crt")}, "ca-file"},
+		{AuthOpts{CAFile: keyFile}, "ca-file"},
+		{AuthOpts{CertFile: certFile}, "cert-file and key-file must be set together"},
+		{AuthOpts{CertFile: certFile, KeyFile: certFile}, "key-file"},
+	} {
+		if _, err := TLSConfig(&test.opts); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("TLSConfig(%+v) returned %v, expected an error about %s", test.opts, err, test.expected)
+		}
+	}
+}
+
 func TestReadClouds(t *testing.T) {
 
 	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
@@ -355,6 +505,93 @@ func TestCheckOpenStackOpts(t *testing.T) {
 			expectedError: fmt.Errorf("invalid element %q found in section [Metadata] with key `search-order`."+
 				"Supported elements include %q and %q", "value1", metadata.ConfigDriveID, metadata.MetadataID),
 		},
+		{
+			name: "ip-version-preference",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					IPVersionPreference: "ipv7",
+				},
+			},
+			expectedError: fmt.Errorf("invalid value %q in section [Networking] with key `ip-version-preference`. Supported values are %q and %q", "ipv7", "ipv4", "ipv6"),
+		},
+		{
+			name: "ip-version-preference-ipv6-disabled",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					IPv6SupportDisabled: true,
+					IPVersionPreference: "ipv6",
+				},
+			},
+			expectedError: fmt.Errorf("invalid value in section [Networking] with key `ip-version-preference`. Value cannot be ipv6 when IPv6 support is disabled"),
+		},
+		{
+			name: "external-ipv6-source",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					ExternalIPv4Source: "floating",
+					ExternalIPv6Source: "public",
+				},
+			},
+			expectedError: fmt.Errorf("invalid value %q in section [Networking] with key `external-ipv6-source`. Supported values are %q, %q and %q", "public", "any", "floating", "fixed"),
+		},
+		{
+			name: "address-translation",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					AddressTranslation: []string{"10.0.0.0/24->2001:db8::/120"},
+				},
+			},
+			expectedError: fmt.Errorf("invalid value in section [Networking] with key `address-translation`: %q translates between IP families", "10.0.0.0/24->2001:db8::/120"),
+		},
+		{
+			name: "external-ip-deny-cidr",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					ExternalIPDenyCIDR: []string{"50.56.176.0"},
+				},
+			},
+			expectedError: fmt.Errorf("invalid value %q in section [Networking] with key `external-ip-deny-cidr`: invalid CIDR address: 50.56.176.0", "50.56.176.0"),
+		},
+		{
+			name: "network-role",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					NetworkRole: []string{"management:internal", "data:public"},
+				},
+			},
+			expectedError: fmt.Errorf("invalid value in section [Networking] with key `network-role`: %q: unsupported role %q, supported roles are %q and %q", "data:public", "public", "internal", "external"),
+		},
+		{
+			name: "network-role with public-network-name",
+			openstackOpts: &OpenStack{
+				metadataOpts: MetadataOpts{
+					SearchOrder: metadata.ConfigDriveID,
+				},
+				networkingOpts: NetworkingOpts{
+					NetworkRole:       []string{"management:internal"},
+					PublicNetworkName: []string{"data"},
+				},
+			},
+			expectedError: fmt.Errorf("invalid value in section [Networking] with key `network-role`. It cannot be set with `public-network-name` or `internal-network-name`"),
+		},
 	}
 
 	for _, testcase := range tests {
@@ -483,15 +720,28 @@ func TestNodeAddresses(t *testing.T) {
 		{Type: v1.NodeInternalIP, Address: "10.0.0.31"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.99"},
 		{Type: v1.NodeExternalIP, Address: "2001:4800:790e:510:be76:4eff:fe04:82a8"},
-		{Type: v1.NodeHostName, Address: "a1-yinvcez57-0-bvynoyawrhcg-kube-minion-fg5i4jwcc2yy.novalocal"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.35"},
 		{Type: v1.NodeExternalIP, Address: "2001:4800:780e:510:be76:4eff:fe04:84a8"},
+		{Type: v1.NodeHostName, Address: "a1-yinvcez57-0-bvynoyawrhcg-kube-minion-fg5i4jwcc2yy.novalocal"},
 	}
 
 	if !reflect.DeepEqual(want, addrs) {
 		t.Errorf("nodeAddresses returned incorrect value, want %v", want)
 	}
+
+	// the addresses are listed in the same order on every sync
+	expected, err := json.Marshal(addrs)
+	th.AssertNoErr(t, err)
+	for i := 0; i < 20; i++ {
+		addrs, err := nodeAddresses(&srv, interfaces, networkingOpts)
+		th.AssertNoErr(t, err)
+		actual, err := json.Marshal(addrs)
+		th.AssertNoErr(t, err)
+		if string(actual) != string(expected) {
+			t.Fatalf("nodeAddresses returned %s, want %s", actual, expected)
+		}
+	}
 }
 
 func TestNodeAddressesCustomPublicNetwork(t *testing.T) {
@@ -721,9 +971,9 @@ func TestNodeAddressesMultipleCustomInternalNetworks(t *testing.T) {
 	want := []v1.NodeAddress{
 		{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
 		{Type: v1.NodeInternalIP, Address: "10.0.0.31"},
+		{Type: v1.NodeInternalIP, Address: "10.0.0.64"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.99"},
 		{Type: v1.NodeExternalIP, Address: "2001:4800:790e:510:be76:4eff:fe04:82a8"},
-		{Type: v1.NodeInternalIP, Address: "10.0.0.64"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
 	}
 
@@ -803,9 +1053,9 @@ func TestNodeAddressesOneInternalNetwork(t *testing.T) {
 	t.Logf("addresses are %v", addrs)
 
 	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.64"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.99"},
 		{Type: v1.NodeExternalIP, Address: "2001:4800:790e:510:be76:4eff:fe04:82a8"},
-		{Type: v1.NodeInternalIP, Address: "10.0.0.64"},
 		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
 	}
 
@@ -814,6 +1064,96 @@ func TestNodeAddressesOneInternalNetwork(t *testing.T) {
 	}
 }
 
+func TestNodeAddressesNetworkRole(t *testing.T) {
+	srv := servers.Server{
+		Status:     "ACTIVE",
+		AccessIPv4: "50.56.176.99",
+		Addresses: map[string]interface{}{
+			"data": []interface{}{
+				map[string]interface{}{"addr": "192.168.0.10", "OS-EXT-IPS:type": "fixed"},
+			},
+			"management": []interface{}{
+				map[string]interface{}{"addr": "10.0.0.10", "OS-EXT-IPS:type": "fixed"},
+				map[string]interface{}{"addr": "50.56.176.36", "OS-EXT-IPS:type": "floating"},
+			},
+			"storage": []interface{}{
+				map[string]interface{}{"addr": "172.16.0.10", "OS-EXT-IPS:type": "fixed"},
+			},
+		},
+	}
+
+	// the interfaces are ignored, the addresses are classified by network
+	interfaces := []attachinterfaces.Interface{
+		{
+			PortState: "ACTIVE",
+			FixedIPs:  []attachinterfaces.FixedIP{{IPAddress: "172.16.0.10"}},
+		},
+	}
+
+	networkingOpts := NetworkingOpts{
+		NetworkRole: []string{"management:internal", "data:external"},
+	}
+
+	addrs, err := nodeAddresses(&srv, interfaces, networkingOpts)
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+		{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+		{Type: v1.NodeExternalIP, Address: "192.168.0.10"},
+	}
+
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+}
+
+func TestNodeAddressesExternalIPCIDRs(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{"addr": "10.0.0.10", "OS-EXT-IPS:type": "fixed"},
+				map[string]interface{}{"addr": "50.56.176.36", "OS-EXT-IPS:type": "floating"},
+				map[string]interface{}{"addr": "203.0.113.5", "OS-EXT-IPS:type": "floating"},
+			},
+			"public": []interface{}{
+				map[string]interface{}{"addr": "50.56.10.5", "OS-EXT-IPS:type": "fixed"},
+				map[string]interface{}{"addr": "50.56.176.20", "OS-EXT-IPS:type": "fixed"},
+			},
+		},
+	}
+
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.10"},
+		{Type: v1.NodeInternalIP, Address: "50.56.176.20"},
+		{Type: v1.NodeExternalIP, Address: "50.56.10.5"},
+	}
+
+	// the denied range is part of the allowed range, the denied addresses
+	// are not ExternalIP addresses whatever the order of the ranges
+	for _, allow := range [][]string{
+		{"50.56.0.0/16", "50.56.176.0/24"},
+		{"50.56.176.0/24", "50.56.0.0/16"},
+	} {
+		networkingOpts := NetworkingOpts{
+			PublicNetworkName:   []string{"public"},
+			ExternalIPAllowCIDR: allow,
+			ExternalIPDenyCIDR:  []string{"50.56.176.0/24"},
+		}
+
+		addrs, err := nodeAddresses(&srv, nil, networkingOpts)
+		if err != nil {
+			t.Fatalf("nodeAddresses returned error: %v", err)
+		}
+		if !reflect.DeepEqual(want, addrs) {
+			t.Errorf("nodeAddresses with allowed CIDRs %v returned %v, want %v", allow, addrs, want)
+		}
+	}
+}
+
 func TestNodeAddressesIPv6Disabled(t *testing.T) {
 	srv := servers.Server{
 		Status:     "ACTIVE",
@@ -891,6 +1231,379 @@ func TestNodeAddressesIPv6Disabled(t *testing.T) {
 	}
 }
 
+func TestNodeAddressesInternalNetworkIDs(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "10.0.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+			},
+			"storage": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "10.1.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+			},
+		},
+	}
+
+	networkingOpts := NetworkingOpts{
+		InternalNetworkName: []string{"private"},
+		InternalNetworkIDs:  []string{"f3bc0e5c-0a2b-4a83-a5d4-6d0b3bd3a1c3"},
+	}
+
+	interfaces := []attachinterfaces.Interface{
+		{
+			PortState: "ACTIVE",
+			NetID:     "70a6e6a3-6c0b-4d51-a1d0-3c0b7d2a9d2f",
+			FixedIPs: []attachinterfaces.FixedIP{
+				{
+					IPAddress: "10.1.0.32",
+				},
+			},
+		},
+		{
+			PortState: "ACTIVE",
+			NetID:     "f3bc0e5c-0a2b-4a83-a5d4-6d0b3bd3a1c3",
+			FixedIPs: []attachinterfaces.FixedIP{
+				{
+					IPAddress: "10.0.0.32",
+				},
+			},
+		},
+	}
+
+	addrs, err := nodeAddresses(&srv, interfaces, networkingOpts)
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+	}
+
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+}
+
+func TestNodeAddressesIPVersionPreference(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "10.0.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(6),
+					"addr":            "2001:4800:780e:510:be76:4eff:fe04:84a8",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(6),
+					"addr":            "fe80::f816:3eff:fe7c:1b2b",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "50.56.176.36",
+					"OS-EXT-IPS:type": "floating",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		preference string
+		want       []v1.NodeAddress
+	}{
+		{
+			preference: "",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeInternalIP, Address: "2001:4800:780e:510:be76:4eff:fe04:84a8"},
+				{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+			},
+		},
+		{
+			preference: "ipv4",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+				{Type: v1.NodeInternalIP, Address: "2001:4800:780e:510:be76:4eff:fe04:84a8"},
+			},
+		},
+		{
+			preference: "ipv6",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "2001:4800:780e:510:be76:4eff:fe04:84a8"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeExternalIP, Address: "50.56.176.36"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		networkingOpts := NetworkingOpts{
+			IPVersionPreference: test.preference,
+		}
+
+		addrs, err := nodeAddresses(&srv, nil, networkingOpts)
+		if err != nil {
+			t.Fatalf("nodeAddresses returned error: %v", err)
+		}
+
+		if !reflect.DeepEqual(test.want, addrs) {
+			t.Errorf("nodeAddresses with preference %q returned %v, want %v", test.preference, addrs, test.want)
+		}
+	}
+}
+
+func TestNodeAddressesExternalIPSource(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "10.0.0.32",
+					"OS-EXT-IPS:type": "fixed",
+				},
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "198.51.100.5",
+					"OS-EXT-IPS:type": "floating",
+				},
+			},
+			"public": []interface{}{
+				map[string]interface{}{
+					"version":         float64(4),
+					"addr":            "203.0.113.10",
+					"OS-EXT-IPS:type": "fixed",
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		source string
+		want   []v1.NodeAddress
+	}{
+		{
+			source: "",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeExternalIP, Address: "198.51.100.5"},
+				{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+			},
+		},
+		{
+			source: "any",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeExternalIP, Address: "198.51.100.5"},
+				{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+			},
+		},
+		{
+			source: "floating",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeInternalIP, Address: "203.0.113.10"},
+				{Type: v1.NodeExternalIP, Address: "198.51.100.5"},
+			},
+		},
+		{
+			source: "fixed",
+			want: []v1.NodeAddress{
+				{Type: v1.NodeInternalIP, Address: "10.0.0.32"},
+				{Type: v1.NodeExternalIP, Address: "203.0.113.10"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		networkingOpts := NetworkingOpts{
+			PublicNetworkName: []string{"public"},
+			// IPv6 addresses are not affected by the IPv4 source
+			ExternalIPv4Source: test.source,
+			ExternalIPv6Source: "fixed",
+		}
+
+		addrs, err := nodeAddresses(&srv, nil, networkingOpts)
+		if err != nil {
+			t.Fatalf("nodeAddresses returned error: %v", err)
+		}
+
+		if !reflect.DeepEqual(test.want, addrs) {
+			t.Errorf("nodeAddresses with source %q returned %v, want %v", test.source, addrs, test.want)
+		}
+	}
+}
+
+func TestNodeAddressesIPv6Only(t *testing.T) {
+	srv := servers.Server{
+		Status: "ACTIVE",
+		Addresses: map[string]interface{}{
+			"private": []interface{}{
+				map[string]interface{}{
+					"version":         float64(6),
+					"addr":            "2001:4800:780e:510:be76:4eff:fe04:84a8",
+					"OS-EXT-IPS:type": "fixed",
+				},
+			},
+		},
+	}
+
+	interfaces := []attachinterfaces.Interface{
+		{
+			PortState: "ACTIVE",
+			FixedIPs: []attachinterfaces.FixedIP{
+				{
+					IPAddress: "fe80::f816:3eff:fe7c:1b2b",
+				},
+				{
+					IPAddress: "2001:4800:780e:510:be76:4eff:fe04:84a8",
+				},
+			},
+		},
+	}
+
+	addrs, err := nodeAddresses(&srv, interfaces, NetworkingOpts{IPVersionPreference: "ipv6"})
+	if err != nil {
+		t.Fatalf("nodeAddresses returned error: %v", err)
+	}
+
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "2001:4800:780e:510:be76:4eff:fe04:84a8"},
+	}
+
+	if !reflect.DeepEqual(want, addrs) {
+		t.Errorf("nodeAddresses returned %v, want %v", addrs, want)
+	}
+}
+
+func TestTranslateAddresses(t *testing.T) {
+	addrs := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: v1.NodeInternalIP, Address: "10.0.1.200"},
+		{Type: v1.NodeInternalIP, Address: "10.20.30.40"},
+		{Type: v1.NodeInternalIP, Address: "2001:db8::5"},
+		{Type: v1.NodeExternalIP, Address: "172.24.4.10"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+	}
+	networkingOpts := NetworkingOpts{
+		AddressTranslation: []string{
+			"10.0.0.0/23 -> 192.168.10.0/23",
+			"2001:db8::/64->2001:db8:1::/64",
+			"10.0.0.0/8->192.168.0.0/16",
+		},
+	}
+
+	want := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.10.5"},
+		{Type: v1.NodeInternalIP, Address: "192.168.11.200"},
+		{Type: v1.NodeInternalIP, Address: "192.168.30.40"},
+		{Type: v1.NodeInternalIP, Address: "2001:db8:1::5"},
+		{Type: v1.NodeExternalIP, Address: "172.24.4.10"},
+		{Type: v1.NodeHostName, Address: "node-1"},
+	}
+	if got := translateAddresses("node-1", addrs, networkingOpts); !reflect.DeepEqual(want, got) {
+		t.Errorf("translateAddresses returned %v, want %v", got, want)
+	}
+
+	// the addresses translated to the network or the broadcast address are dropped
+	addrs = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: v1.NodeInternalIP, Address: "10.0.1.0"},
+		{Type: v1.NodeInternalIP, Address: "10.0.1.255"},
+	}
+	networkingOpts.AddressTranslation = []string{"10.0.0.0/23->192.168.10.0/24"}
+	want = []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "192.168.10.5"},
+	}
+	if got := translateAddresses("node-1", addrs, networkingOpts); !reflect.DeepEqual(want, got) {
+		t.Errorf("translateAddresses returned %v, want %v", got, want)
+	}
+
+	// the addresses are kept without translations
+	if got := translateAddresses("node-1", addrs, NetworkingOpts{}); !reflect.DeepEqual(addrs, got) {
+		t.Errorf("translateAddresses returned %v, want %v", got, addrs)
+	}
+}
+
+func TestParseAddressTranslations(t *testing.T) {
+	for _, value := range []string{
+		"10.0.0.0/24",
+		"10.0.0.0/24->",
+		"10.0.0.0->192.168.0.0/24",
+		"10.0.0.0/24->192.168.0.0/24->172.16.0.0/24",
+		"10.0.0.0/24->2001:db8::/120",
+	} {
+		if _, err := parseAddressTranslations([]string{value}); err == nil {
+			t.Errorf("parseAddressTranslations(%q) succeeded, expected an error", value)
+		}
+	}
+
+	translations, err := parseAddressTranslations([]string{"10.0.0.0/24->192.168.0.0/24"})
+	if err != nil {
+		t.Fatalf("parseAddressTranslations returned error: %v", err)
+	}
+	if len(translations) != 1 || translations[0].from.String() != "10.0.0.0/24" || translations[0].to.String() != "192.168.0.0/24" {
+		t.Errorf("parseAddressTranslations returned %v", translations)
+	}
+}
+
+func TestGetTrunkSubportInterfaces(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/trunks", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		if r.URL.Query().Get("port_id") != "parent-port" {
+			fmt.Fprint(w, `{"trunks": []}`)
+			return
+		}
+		fmt.Fprint(w, `{"trunks": [{"id": "trunk", "port_id": "parent-port", "sub_ports": [{"port_id": "subport", "segmentation_type": "vlan", "segmentation_id": 100}]}]}`)
+	})
+	th.Mux.HandleFunc("/ports/subport", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"port": {"id": "subport", "network_id": "vlan-net", "status": "ACTIVE", "fixed_ips": [{"subnet_id": "vlan-subnet", "ip_address": "10.1.0.5"}]}}`)
+	})
+
+	interfaces := []attachinterfaces.Interface{
+		{PortID: "parent-port", NetID: "net", PortState: "ACTIVE"},
+		{PortID: "plain-port", NetID: "other-net", PortState: "ACTIVE"},
+	}
+
+	subports, err := getTrunkSubportInterfaces(fake.ServiceClient(), interfaces)
+	if err != nil {
+		t.Fatalf("getTrunkSubportInterfaces() returned error: %v", err)
+	}
+
+	expected := []attachinterfaces.Interface{
+		{
+			PortID:    "subport",
+			PortState: "ACTIVE",
+			NetID:     "vlan-net",
+			FixedIPs:  []attachinterfaces.FixedIP{{SubnetID: "vlan-subnet", IPAddress: "10.1.0.5"}},
+		},
+	}
+	if !reflect.DeepEqual(subports, expected) {
+		t.Errorf("getTrunkSubportInterfaces() = %+v, expected %+v", subports, expected)
+	}
+}
+
 func TestNewOpenStack(t *testing.T) {
 	cfg := ConfigFromEnv()
 	testConfigFromEnv(t, &cfg)
@@ -901,6 +1614,39 @@ func TestNewOpenStack(t *testing.T) {
 	}
 }
 
+func TestCatalogRegions(t *testing.T) {
+	catalog := &tokens3.ServiceCatalog{
+		Entries: []tokens3.CatalogEntry{
+			{
+				Type: "compute",
+				Endpoints: []tokens3.Endpoint{
+					{Region: "RegionOne", Interface: "public"},
+					{Region: "RegionOne", Interface: "internal"},
+				},
+			},
+			{
+				Type: "network",
+				Endpoints: []tokens3.Endpoint{
+					{Region: "RegionTwo", Interface: "public"},
+				},
+			},
+		},
+	}
+
+	if regions := catalogRegions(catalog, "compute"); !reflect.DeepEqual(regions, []string{"RegionOne"}) {
+		t.Errorf("catalogRegions returned %v, expected [RegionOne]", regions)
+	}
+
+	catalog.Entries[0].Endpoints = append(catalog.Entries[0].Endpoints, tokens3.Endpoint{Region: "RegionTwo", Interface: "public"})
+	if regions := catalogRegions(catalog, "compute"); !reflect.DeepEqual(regions, []string{"RegionOne", "RegionTwo"}) {
+		t.Errorf("catalogRegions returned %v, expected [RegionOne RegionTwo]", regions)
+	}
+
+	if regions := catalogRegions(catalog, "volumev3"); len(regions) != 0 {
+		t.Errorf("catalogRegions returned %v, expected no region", regions)
+	}
+}
+
 func TestLoadBalancer(t *testing.T) {
 	cfg := ConfigFromEnv()
 	testConfigFromEnv(t, &cfg)
@@ -967,6 +1713,42 @@ func TestZones(t *testing.T) {
 	}
 }
 
+func TestZonesMetadataKey(t *testing.T) {
+	md := FakeMetadata
+	md.Meta = map[string]string{"rack": "room 1/rack 2"}
+	metadata.Set(&md)
+	defer metadata.Clear()
+
+	os := OpenStack{
+		provider: &gophercloud.ProviderClient{
+			IdentityBase: "http://auth.url/",
+		},
+		region: "myRegion",
+		instancesOpts: InstancesOpts{
+			ZoneMetadataKey: "rack",
+		},
+	}
+
+	zone, err := os.GetZone(context.TODO())
+	if err != nil {
+		t.Fatalf("GetZone() returned error: %s", err)
+	}
+
+	if zone.FailureDomain != "room-1-rack-2" {
+		t.Fatalf("GetZone() returned wrong failure domain (%s)", zone.FailureDomain)
+	}
+
+	os.instancesOpts.ZoneMetadataKey = "room"
+	zone, err = os.GetZone(context.TODO())
+	if err != nil {
+		t.Fatalf("GetZone() returned error: %s", err)
+	}
+
+	if zone.FailureDomain != "nova" {
+		t.Fatalf("GetZone() returned wrong failure domain (%s)", zone.FailureDomain)
+	}
+}
+
 var diskPathRegexp = regexp.MustCompile("/dev/disk/(?:by-id|by-path)/")
 
 func TestInstanceIDFromProviderID(t *testing.T) {
@@ -986,11 +1768,21 @@ func TestInstanceIDFromProviderID(t *testing.T) {
 			instanceID: "7b9cf879-7146-417c-abfd-cb4272f0c935",
 			fail:       false,
 		},
+		{
+			providerID: ProviderName + "://" + "RegionOne" + "/" + "7b9cf879-7146-417c-abfd-cb4272f0c935",
+			instanceID: "7b9cf879-7146-417c-abfd-cb4272f0c935",
+			fail:       false,
+		},
 		{
 			providerID: "openstack://7b9cf879-7146-417c-abfd-cb4272f0c935",
 			instanceID: "",
 			fail:       true,
 		},
+		{
+			providerID: "openstack://RegionOne/7b9cf879-7146-417c-abfd-cb4272f0c935/extra",
+			instanceID: "",
+			fail:       true,
+		},
 		{
 			providerID: "7b9cf879-7146-417c-abfd-cb4272f0c935",
 			instanceID: "",
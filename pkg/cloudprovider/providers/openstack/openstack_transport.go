@@ -0,0 +1,175 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+)
+
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 20
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultDialKeepAlive       = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// TransportOpts is used to tune the connections of the OpenStack HTTP client
+type TransportOpts struct {
+	// MaxIdleConns is the number of idle connections kept to all the
+	// endpoints, it defaults to 100
+	MaxIdleConns int `gcfg:"max-idle-conns"`
+	// MaxIdleConnsPerHost is the number of idle connections kept to each
+	// endpoint, it defaults to 20
+	MaxIdleConnsPerHost int `gcfg:"max-idle-conns-per-host"`
+	// IdleConnTimeout is how long an idle connection is kept, it defaults
+	// to 90s
+	IdleConnTimeout MyDuration `gcfg:"idle-conn-timeout"`
+	// DialTimeout is how long the connection to an endpoint may take, it
+	// defaults to 30s
+	DialTimeout MyDuration `gcfg:"dial-timeout"`
+	// TLSHandshakeTimeout is how long the TLS handshake with an endpoint may
+	// take, it defaults to 10s
+	TLSHandshakeTimeout MyDuration `gcfg:"tls-handshake-timeout"`
+}
+
+func checkTransportOpts(opts TransportOpts) error {
+	for _, o := range []struct {
+		key      string
+		negative bool
+	}{
+		{"max-idle-conns", opts.MaxIdleConns < 0},
+		{"max-idle-conns-per-host", opts.MaxIdleConnsPerHost < 0},
+		{"idle-conn-timeout", opts.IdleConnTimeout.Duration < 0},
+		{"dial-timeout", opts.DialTimeout.Duration < 0},
+		{"tls-handshake-timeout", opts.TLSHandshakeTimeout.Duration < 0},
+	} {
+		if o.negative {
+			return fmt.Errorf("invalid value in section [Transport] with key `%s`. Value cannot be negative", o.key)
+		}
+	}
+	return nil
+}
+
+func intOrDefault(value, def int) int {
+	if value == 0 {
+		return def
+	}
+	return value
+}
+
+func durationOrDefault(value MyDuration, def time.Duration) time.Duration {
+	if value.Duration == 0 {
+		return def
+	}
+	return value.Duration
+}
+
+// newTransport creates the transport of the OpenStack HTTP client, whose
+// connections are observed by the connection metrics
+func newTransport(config *tls.Config, opts TransportOpts) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(opts.DialTimeout, defaultDialTimeout),
+		KeepAlive: defaultDialKeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         trackedDialer{dialer}.DialContext,
+		TLSClientConfig:     config,
+		TLSHandshakeTimeout: durationOrDefault(opts.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		MaxIdleConns:        intOrDefault(opts.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost: intOrDefault(opts.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		IdleConnTimeout:     durationOrDefault(opts.IdleConnTimeout, defaultIdleConnTimeout),
+		ForceAttemptHTTP2:   true,
+	}
+
+	return &connTracingTransport{rt: transport}
+}
+
+// trackedDialer counts the open connections to each endpoint
+type trackedDialer struct {
+	dialer *net.Dialer
+}
+
+func (d trackedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	metrics.ObserveConnectionDial(addr, err)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedConn{Conn: conn, addr: addr}, nil
+}
+
+// trackedConn stops counting the connection once it's closed
+type trackedConn struct {
+	net.Conn
+	addr  string
+	close sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.close.Do(func() {
+		metrics.ObserveConnectionClose(c.addr)
+	})
+	return c.Conn.Close()
+}
+
+// connTracingTransport counts whether the requests reused an idle connection
+type connTracingTransport struct {
+	rt http.RoundTripper
+}
+
+func (t *connTracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.ObserveConnectionUse(endpointAddr(req), info.Reused)
+		},
+	}
+	return t.rt.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// endpointAddr returns the address of the endpoint of the request, as dialed
+// by the transport
+func endpointAddr(req *http.Request) string {
+	if req.URL.Port() != "" {
+		return req.URL.Host
+	}
+	port := "80"
+	if req.URL.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport,
+// it's called by http.Client.CloseIdleConnections
+func (t *connTracingTransport) CloseIdleConnections() {
+	if c, ok := t.rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadConfigTransport(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ [Transport]
+ max-idle-conns-per-host = 50
+ dial-timeout = 5s
+ `))
+	if err != nil {
+		t.Fatalf("Should succeed when a valid config is provided: %s", err)
+	}
+
+	transport := newTransport(nil, cfg.Transport).(*connTracingTransport).rt.(*http.Transport)
+	if transport.MaxIdleConns != defaultMaxIdleConns || transport.MaxIdleConnsPerHost != 50 {
+		t.Errorf("incorrect idle connections: %d, %d per host", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != defaultIdleConnTimeout || transport.TLSHandshakeTimeout != defaultTLSHandshakeTimeout {
+		t.Errorf("incorrect timeouts: idle %v, TLS handshake %v", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+
+	_, err = ReadConfig(strings.NewReader(`
+ [Global]
+ auth-url = http://auth.url
+ [Transport]
+ idle-conn-timeout = -1s
+ `))
+	if err == nil || !strings.Contains(err.Error(), "idle-conn-timeout") {
+		t.Errorf("expected an error naming idle-conn-timeout, got %v", err)
+	}
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	rt := newTransport(nil, TransportOpts{DialTimeout: MyDuration{time.Second}})
+	transport := rt.(*connTracingTransport).rt.(*http.Transport)
+	dial := transport.DialContext
+	dials := 0
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		return dial(ctx, network, addr)
+	}
+	client := &http.Client{Transport: rt}
+	defer client.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		_, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if dials != 1 {
+		t.Errorf("expected the idle connection to be reused, got %d dials", dials)
+	}
+}
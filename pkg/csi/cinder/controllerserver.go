@@ -18,7 +18,9 @@ package cinder
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/protobuf/ptypes"
@@ -62,9 +64,6 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	}
 	volSizeGB := int(util.RoundUpSize(volSizeBytes, 1024*1024*1024))
 
-	// Volume Type
-	volType := req.GetParameters()["type"]
-
 	var volAvailability string
 	if req.GetAccessibilityRequirements() != nil {
 		volAvailability = getAZFromTopology(req.GetAccessibilityRequirements())
@@ -75,8 +74,31 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		volAvailability = req.GetParameters()["availability"]
 	}
 
+	// Volume Type
+	volType, err := getVolumeType(req.GetParameters(), volAvailability)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("[CreateVolume] %v", err))
+	}
+
 	cloud := cs.Cloud
 
+	multiattach, err := requiresMultiattach(volCapabilities)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("[CreateVolume] %v", err))
+	}
+	if multiattach {
+		if volType == "" {
+			return nil, status.Error(codes.InvalidArgument, "[CreateVolume] a multiattach volume type must be set in the type parameter")
+		}
+		ok, err := cloud.IsMultiattachVolumeType(volType)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "[CreateVolume] failed to get volume type %s: %v", volType, err)
+		}
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "[CreateVolume] volume type %s does not support multiattach", volType)
+		}
+	}
+
 	// Verify a volume with the provided name doesn't already exist for this tenant
 	volumes, err := cloud.GetVolumesByName(volName)
 	if err != nil {
@@ -87,6 +109,9 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		if volSizeGB != volumes[0].Size {
 			return nil, status.Error(codes.AlreadyExists, "Volume Already exists with same name and different capacity")
 		}
+		if multiattach && !volumes[0].Multiattach {
+			return nil, status.Error(codes.AlreadyExists, "Volume Already exists with same name and without multiattach")
+		}
 
 		klog.V(4).Infof("Volume %s already exists in Availability Zone: %s of size %d GiB", volumes[0].ID, volumes[0].AvailabilityZone, volumes[0].Size)
 		return getCreateVolumeResponse(&volumes[0]), nil
@@ -115,19 +140,25 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 	if content != nil && content.GetVolume() != nil {
 		sourcevolID = content.GetVolume().GetVolumeId()
-		_, err := cloud.GetVolume(sourcevolID)
+		sourceVol, err := cloud.GetVolume(sourcevolID)
 		if err != nil {
 			if cpoerrors.IsNotFound(err) {
 				return nil, status.Errorf(codes.NotFound, "Source Volume %s not found", sourcevolID)
 			}
 			return nil, status.Errorf(codes.Internal, "Failed to retrieve the source volume %s: %v", sourcevolID, err)
 		}
+		if err := validateCloneSource(sourceVol, volSizeGB, volType, volAvailability); err != nil {
+			return nil, err
+		}
 	}
 
-	vol, err := cloud.CreateVolume(volName, volSizeGB, volType, volAvailability, snapshotID, sourcevolID, &properties)
+	vol, err := cloud.CreateVolume(volName, volSizeGB, volType, volAvailability, snapshotID, sourcevolID, multiattach, &properties)
 
 	if err != nil {
 		klog.Errorf("Failed to CreateVolume: %v", err)
+		if sourcevolID != "" && cpoerrors.IsInvalidError(err) {
+			return nil, status.Errorf(codes.FailedPrecondition, "CreateVolume failed to clone source volume %s, the Cinder backend may not support volume cloning: %v", sourcevolID, err)
+		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("CreateVolume failed with error %v", err))
 
 	}
@@ -137,6 +168,22 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 	return getCreateVolumeResponse(vol), nil
 }
 
+// validateCloneSource checks that a volume of the given size, type and
+// availability zone can be cloned from the source volume. An empty type or
+// availability zone means the one of the source volume is used.
+func validateCloneSource(source *volumes.Volume, sizeGB int, volType, availability string) error {
+	if sizeGB < source.Size {
+		return status.Errorf(codes.OutOfRange, "Requested size %d GiB is smaller than the size %d GiB of the source volume %s", sizeGB, source.Size, source.ID)
+	}
+	if volType != "" && volType != source.VolumeType {
+		return status.Errorf(codes.InvalidArgument, "Volume type %q does not match the type %q of the source volume %s", volType, source.VolumeType, source.ID)
+	}
+	if availability != "" && availability != source.AvailabilityZone {
+		return status.Errorf(codes.InvalidArgument, "Availability zone %q does not match the availability zone %q of the source volume %s", availability, source.AvailabilityZone, source.ID)
+	}
+	return nil
+}
+
 func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
 	klog.V(4).Infof("DeleteVolume: called with args %+v", *req)
 
@@ -177,7 +224,7 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return nil, status.Error(codes.InvalidArgument, "[ControllerPublishVolume] Volume capability must be provided")
 	}
 
-	_, err := cs.Cloud.GetVolume(volumeID)
+	vol, err := cs.Cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
 			return nil, status.Errorf(codes.NotFound, "[ControllerPublishVolume] Volume %s not found", volumeID)
@@ -185,6 +232,21 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return nil, status.Error(codes.Internal, fmt.Sprintf("[ControllerPublishVolume] get volume failed with error %v", err))
 	}
 
+	// Only the multiattach volumes can be published on several nodes
+	if !vol.Multiattach {
+		for _, att := range vol.Attachments {
+			if att.ServerID != instanceID {
+				return nil, status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] Volume %s is not multiattach and is already attached to instance %s, publishing it on several nodes requires a multiattach volume type", volumeID, att.ServerID)
+			}
+		}
+	}
+
+	readOnly := req.GetReadonly() || isReadOnlyAccessMode(volumeCapability)
+	updateReadOnly, err := checkAttachMode(vol, readOnly)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "[ControllerPublishVolume] %v", err)
+	}
+
 	_, err = cs.Cloud.GetInstanceByID(instanceID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
@@ -193,6 +255,16 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 		return nil, status.Error(codes.Internal, fmt.Sprintf("[ControllerPublishVolume] GetInstanceByID failed with error %v", err))
 	}
 
+	if updateReadOnly {
+		err = cs.Cloud.SetVolumeReadOnly(volumeID, readOnly)
+		if err != nil && readOnly {
+			// The device is still mounted read-only by the nodes
+			klog.Warningf("[ControllerPublishVolume] Failed to set the read-only flag of volume %s, attaching it read-write: %v", volumeID, err)
+		} else if err != nil {
+			return nil, status.Error(codes.Internal, fmt.Sprintf("[ControllerPublishVolume] failed to clear the read-only flag of volume %s: %v", volumeID, err))
+		}
+	}
+
 	_, err = cs.Cloud.AttachVolume(instanceID, volumeID)
 	if err != nil {
 		klog.V(3).Infof("Failed to AttachVolume: %v", err)
@@ -217,12 +289,38 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	// Publish Volume Info
 	pvInfo := map[string]string{}
 	pvInfo["DevicePath"] = devicePath
+	if readOnly {
+		pvInfo[publishContextReadOnly] = "true"
+	}
 
 	return &csi.ControllerPublishVolumeResponse{
 		PublishContext: pvInfo,
 	}, nil
 }
 
+// checkAttachMode returns whether the read-only flag of the volume must be
+// changed to attach it in the requested mode. The flag of a Cinder volume
+// applies to all its attachments, so an attached volume can't be attached in
+// the other mode.
+func checkAttachMode(vol *volumes.Volume, readOnly bool) (bool, error) {
+	if isVolumeReadOnly(vol) == readOnly {
+		return false, nil
+	}
+	if len(vol.Attachments) > 0 {
+		current, requested := "read-write", "read-only"
+		if !readOnly {
+			current, requested = requested, current
+		}
+		return false, fmt.Errorf("volume %s is attached %s to instance %s, it can't be attached %s", vol.ID, current, vol.Attachments[0].ServerID, requested)
+	}
+	return true, nil
+}
+
+// isVolumeReadOnly returns whether the read-only flag of the volume is set
+func isVolumeReadOnly(vol *volumes.Volume) bool {
+	return strings.EqualFold(vol.Metadata["readonly"], "true")
+}
+
 func (cs *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
 
 	// Volume Detach
@@ -277,7 +375,8 @@ func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 	vlist, nextPageToken, err := cs.Cloud.ListVolumes(maxEntries, req.StartingToken)
 	if err != nil {
 		klog.V(3).Infof("Failed to ListVolumes: %v", err)
-		if cpoerrors.IsInvalidError(err) {
+		// Cinder rejects an unknown marker as not found
+		if cpoerrors.IsInvalidError(err) || (req.StartingToken != "" && cpoerrors.IsNotFound(err)) {
 			return nil, status.Errorf(codes.Aborted, "[ListVolumes] Invalid request: %v", err)
 		}
 		return nil, status.Error(codes.Internal, fmt.Sprintf("ListVolumes failed with error %v", err))
@@ -288,7 +387,7 @@ func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolume
 		ventry := csi.ListVolumesResponse_Entry{
 			Volume: &csi.Volume{
 				VolumeId:      v.ID,
-				CapacityBytes: int64(v.Size * 1024 * 1024 * 1024),
+				CapacityBytes: int64(v.Size) * 1024 * 1024 * 1024,
 			},
 		}
 
@@ -495,7 +594,7 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 		return nil, status.Error(codes.InvalidArgument, "ValidateVolumeCapabilities Volume ID must be provided")
 	}
 
-	_, err := cs.Cloud.GetVolume(volumeID)
+	vol, err := cs.Cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
 			return nil, status.Error(codes.NotFound, fmt.Sprintf("ValidateVolumeCapabiltites Volume %s not found", volumeID))
@@ -504,27 +603,53 @@ func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req
 	}
 
 	for _, cap := range reqVolCap {
-		if cap.GetAccessMode().GetMode() != cs.Driver.vcap[0].Mode {
+		if !cs.Driver.supportsAccessMode(cap.GetAccessMode().GetMode()) {
 			return &csi.ValidateVolumeCapabilitiesResponse{Message: "Requested Volume Capabilty not supported"}, nil
 		}
 	}
+	multiattach, err := requiresMultiattach(reqVolCap)
+	if err != nil || (multiattach && !vol.Multiattach) {
+		return &csi.ValidateVolumeCapabilitiesResponse{Message: "Requested Volume Capabilty not supported"}, nil
+	}
 
-	// Cinder CSI driver currently supports one mode only
 	resp := &csi.ValidateVolumeCapabilitiesResponse{
 		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
-			VolumeCapabilities: []*csi.VolumeCapability{
-				{
-					AccessMode: cs.Driver.vcap[0],
-				},
-			},
+			VolumeCapabilities: reqVolCap,
 		},
 	}
 
 	return resp, nil
 }
 
+// GetCapacity returns the capacity available for the volumes of the
+// parameters in the availability zone of the topology. It is bounded by the
+// gigabytes quotas of the project and by the free capacity of the largest
+// backend pool of the volume type, the largest volume that can be created.
 func (cs *controllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, fmt.Sprintf("GetCapacity is not yet implemented"))
+	klog.V(4).Infof("GetCapacity: called with args %+v", *req)
+
+	availability := req.GetAccessibleTopology().GetSegments()[topologyKey]
+	if len(availability) == 0 {
+		availability = req.GetParameters()["availability"]
+	}
+
+	volType, err := getVolumeType(req.GetParameters(), availability)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("[GetCapacity] %v", err))
+	}
+
+	availableGiB, limited, err := cs.Cloud.GetAvailableCapacity(volType, availability)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "[GetCapacity] failed to get the capacity of volume type %q in availability zone %q: %v", volType, availability, err)
+	}
+	if !limited {
+		// Neither the quotas nor the backends expose a bound, the capacity
+		// is reported as the largest one so that the zone isn't excluded
+		klog.V(4).Infof("GetCapacity: the capacity of volume type %q in availability zone %q isn't bounded", volType, availability)
+		return &csi.GetCapacityResponse{AvailableCapacity: math.MaxInt64}, nil
+	}
+
+	return &csi.GetCapacityResponse{AvailableCapacity: availableGiB * 1024 * 1024 * 1024}, nil
 }
 
 func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
@@ -568,6 +693,57 @@ func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi
 	}, nil
 }
 
+// requiresMultiattach returns whether the volume capabilities require a
+// multiattach volume, which Nova attaches to several nodes. Several nodes may
+// only write to block volumes, the filesystems of the driver can't be mounted
+// by several nodes safely unless they are read-only.
+func requiresMultiattach(caps []*csi.VolumeCapability) (bool, error) {
+	multiattach := false
+	for _, cap := range caps {
+		switch cap.GetAccessMode().GetMode() {
+		case csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+			multiattach = true
+		case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER:
+			if cap.GetBlock() == nil {
+				return false, fmt.Errorf("the %s access mode is only supported for block volumes", csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER)
+			}
+			multiattach = true
+		}
+	}
+	return multiattach, nil
+}
+
+// getVolumeType returns the volume type for a volume in the given
+// availability zone. The "zone-types" parameter maps availability zones to
+// volume types as a comma separated list of zone:type pairs, when the zone
+// isn't mapped (or no zone is requested) the "type" parameter is used.
+func getVolumeType(parameters map[string]string, availability string) (string, error) {
+	volType := parameters["type"]
+
+	zoneTypes, ok := parameters["zone-types"]
+	if !ok {
+		return volType, nil
+	}
+
+	types := make(map[string]string)
+	for _, pair := range strings.Split(zoneTypes, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return "", fmt.Errorf("invalid zone-types parameter %q, expected a comma separated list of zone:type pairs", zoneTypes)
+		}
+		types[kv[0]] = kv[1]
+	}
+
+	if t, ok := types[availability]; ok && availability != "" {
+		return t, nil
+	}
+	return volType, nil
+}
+
+// getAZFromTopology returns the zone of the first preferred topology, or of
+// the first requisite topology when no preferred topology has a zone. The
+// external-provisioner lists the zone of the node of the pod first in the
+// preferred topologies when the volume binding waits for the first consumer.
 func getAZFromTopology(requirement *csi.TopologyRequirement) string {
 	for _, topology := range requirement.GetPreferred() {
 		zone, exists := topology.GetSegments()[topologyKey]
@@ -613,14 +789,17 @@ func getCreateVolumeResponse(vol *volumes.Volume) *csi.CreateVolumeResponse {
 		Volume: &csi.Volume{
 			VolumeId:      vol.ID,
 			CapacityBytes: int64(vol.Size * 1024 * 1024 * 1024),
-			AccessibleTopology: []*csi.Topology{
-				{
-					Segments: map[string]string{topologyKey: vol.AvailabilityZone},
-				},
-			},
 			ContentSource: volsrc,
 		},
 	}
+	// A topology segment can't be empty
+	if vol.AvailabilityZone != "" {
+		resp.Volume.AccessibleTopology = []*csi.Topology{
+			{
+				Segments: map[string]string{topologyKey: vol.AvailabilityZone},
+			},
+		}
+	}
 
 	return resp
 
@@ -17,11 +17,16 @@ limitations under the License.
 package cinder
 
 import (
+	"math"
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 )
 
@@ -46,7 +51,7 @@ func TestCreateVolume(t *testing.T) {
 	// mock OpenStack
 	properties := map[string]string{"cinder.csi.openstack.org/cluster": FakeCluster}
 	// CreateVolume(name string, size int, vtype, availability string, snapshotID string, tags *map[string]string) (string, string, int, error)
-	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, FakeAvailability, "", "", &properties).Return(&FakeVol, nil)
+	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, FakeAvailability, "", "", false, &properties).Return(&FakeVol, nil)
 
 	osmock.On("GetVolumesByName", FakeVolName).Return(FakeVolListEmpty, nil)
 	// Init assert
@@ -87,11 +92,58 @@ func TestCreateVolume(t *testing.T) {
 
 }
 
+func TestCreateVolumeMultiattach(t *testing.T) {
+	properties := map[string]string{"cinder.csi.openstack.org/cluster": FakeCluster}
+	osmock.On("IsMultiattachVolumeType", "multiattach").Return(true, nil)
+	osmock.On("IsMultiattachVolumeType", "single").Return(false, nil)
+	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), "multiattach", "", "", "", true, &properties).Return(&FakeVol, nil)
+	osmock.On("GetVolumesByName", FakeVolName).Return(FakeVolListEmpty, nil)
+
+	assert := assert.New(t)
+
+	block := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}
+	mount := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+	}
+	readOnly := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+	}
+
+	testCases := []struct {
+		volType    string
+		capability *csi.VolumeCapability
+		code       codes.Code
+	}{
+		{volType: "multiattach", capability: block, code: codes.OK},
+		{volType: "multiattach", capability: mount, code: codes.InvalidArgument},
+		{volType: "single", capability: block, code: codes.InvalidArgument},
+		{capability: block, code: codes.InvalidArgument},
+		{volType: "multiattach", capability: readOnly, code: codes.OK},
+		{volType: "single", capability: readOnly, code: codes.InvalidArgument},
+	}
+
+	for _, test := range testCases {
+		fakeReq := &csi.CreateVolumeRequest{
+			Name:               FakeVolName,
+			Parameters:         map[string]string{"type": test.volType},
+			VolumeCapabilities: []*csi.VolumeCapability{test.capability},
+		}
+
+		_, err := fakeCs.CreateVolume(FakeCtx, fakeReq)
+		assert.Equal(test.code, status.Code(err), "CreateVolume with type %q and %v returned %v", test.volType, test.capability, err)
+	}
+}
+
 func TestCreateVolumeFromSnapshot(t *testing.T) {
 
 	properties := map[string]string{"cinder.csi.openstack.org/cluster": FakeCluster}
 	// CreateVolume(name string, size int, vtype, availability string, snapshotID string, tags *map[string]string) (string, string, int, error)
-	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, "", FakeSnapshotID, "", &properties).Return(&FakeVolFromSnapshot, nil)
+	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, "", FakeSnapshotID, "", false, &properties).Return(&FakeVolFromSnapshot, nil)
 	osmock.On("GetVolumesByName", FakeVolName).Return(FakeVolListEmpty, nil)
 
 	// Init assert
@@ -139,7 +191,7 @@ func TestCreateVolumeFromSourceVolume(t *testing.T) {
 
 	properties := map[string]string{"cinder.csi.openstack.org/cluster": FakeCluster}
 	// CreateVolume(name string, size int, vtype, availability string, snapshotID string, tags *map[string]string) (string, string, int, error)
-	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, "", "", FakeVolID, &properties).Return(&FakeVolFromSourceVolume, nil)
+	osmock.On("CreateVolume", FakeVolName, mock.AnythingOfType("int"), FakeVolType, "", "", FakeVolID, false, &properties).Return(&FakeVolFromSourceVolume, nil)
 	osmock.On("GetVolumesByName", FakeVolName).Return(FakeVolListEmpty, nil)
 
 	// Init assert
@@ -285,6 +337,113 @@ func TestControllerPublishVolume(t *testing.T) {
 	assert.Equal(expectedRes, actualRes)
 }
 
+func TestControllerPublishVolumeReadOnly(t *testing.T) {
+	osmock.On("AttachVolume", FakeNodeID, FakeVolID).Return(FakeVolID, nil)
+	osmock.On("WaitDiskAttached", FakeNodeID, FakeVolID).Return(nil)
+	osmock.On("GetAttachmentDiskPath", FakeNodeID, FakeVolID).Return(FakeDevicePath, nil)
+	// SetVolumeReadOnly(volumeID string, readOnly bool) error
+	osmock.On("SetVolumeReadOnly", FakeVolID, true).Return(nil)
+
+	assert := assert.New(t)
+
+	fakeReq := &csi.ControllerPublishVolumeRequest{
+		VolumeId: FakeVolID,
+		NodeId:   FakeNodeID,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			},
+		},
+	}
+
+	expectedRes := &csi.ControllerPublishVolumeResponse{
+		PublishContext: map[string]string{
+			"DevicePath": FakeDevicePath,
+			"readonly":   "true",
+		},
+	}
+
+	actualRes, err := fakeCs.ControllerPublishVolume(FakeCtx, fakeReq)
+	if err != nil {
+		t.Errorf("failed to ControllerPublishVolume: %v", err)
+	}
+
+	assert.Equal(expectedRes, actualRes)
+	osmock.AssertCalled(t, "SetVolumeReadOnly", FakeVolID, true)
+}
+
+func TestControllerPublishVolumeReadOnlyMultiNode(t *testing.T) {
+	osmock.On("AttachVolume", FakeNodeID, FakeMultiattachVolID).Return(FakeMultiattachVolID, nil)
+	osmock.On("WaitDiskAttached", FakeNodeID, FakeMultiattachVolID).Return(nil)
+	osmock.On("GetAttachmentDiskPath", FakeNodeID, FakeMultiattachVolID).Return(FakeDevicePath, nil)
+
+	assert := assert.New(t)
+
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
+	}
+
+	// The multiattach volume attached read-only to another node is published on a second node
+	actualRes, err := fakeCs.ControllerPublishVolume(FakeCtx, &csi.ControllerPublishVolumeRequest{
+		VolumeId:         FakeMultiattachVolID,
+		NodeId:           FakeNodeID,
+		VolumeCapability: capability,
+	})
+	if err != nil {
+		t.Errorf("failed to ControllerPublishVolume: %v", err)
+	}
+	assert.Equal(&csi.ControllerPublishVolumeResponse{
+		PublishContext: map[string]string{
+			"DevicePath": FakeDevicePath,
+			"readonly":   "true",
+		},
+	}, actualRes)
+	osmock.AssertCalled(t, "AttachVolume", FakeNodeID, FakeMultiattachVolID)
+
+	// Nova only attaches a volume which isn't multiattach to a single node
+	_, err = fakeCs.ControllerPublishVolume(FakeCtx, &csi.ControllerPublishVolumeRequest{
+		VolumeId:         FakeAttachedVolID,
+		NodeId:           FakeNodeID,
+		VolumeCapability: capability,
+	})
+	assert.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func TestCheckAttachMode(t *testing.T) {
+	readOnlyVol := volumes.Volume{ID: FakeVolID, Metadata: map[string]string{"readonly": "True"}}
+	attached := []volumes.Attachment{FakeAttachment}
+
+	tests := []struct {
+		name     string
+		vol      volumes.Volume
+		readOnly bool
+		update   bool
+		fail     bool
+	}{
+		{name: "read-write", vol: FakeVol},
+		{name: "read-only detached", vol: FakeVol, readOnly: true, update: true},
+		{name: "read-only already set", vol: readOnlyVol, readOnly: true},
+		{name: "read-write detached read-only volume", vol: readOnlyVol, update: true},
+		{name: "read-only attached read-write", vol: volumes.Volume{ID: FakeVolID, Attachments: attached}, readOnly: true, fail: true},
+		{name: "read-write attached read-only", vol: volumes.Volume{ID: FakeVolID, Metadata: readOnlyVol.Metadata, Attachments: attached}, fail: true},
+	}
+
+	for _, test := range tests {
+		update, err := checkAttachMode(&test.vol, test.readOnly)
+		if (err != nil) != test.fail || update != test.update {
+			t.Errorf("%s: checkAttachMode() = %v, %v, expected the update %v and a failure %v", test.name, update, err, test.update, test.fail)
+		}
+	}
+}
+
 // Test ControllerUnpublishVolume
 func TestControllerUnpublishVolume(t *testing.T) {
 
@@ -357,6 +516,15 @@ func TestListVolumes(t *testing.T) {
 	assert.Equal(expectedRes, actualRes)
 }
 
+func TestListVolumesInvalidToken(t *testing.T) {
+	osmock.On("ListVolumes", 2, "unknown").Return(nil, "", gophercloud.ErrDefault404{})
+
+	_, err := fakeCs.ListVolumes(FakeCtx, &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: "unknown"})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("ListVolumes with an invalid starting token returned %v, expected %v", err, codes.Aborted)
+	}
+}
+
 // Test CreateSnapshot
 func TestCreateSnapshot(t *testing.T) {
 
@@ -462,6 +630,33 @@ func TestControllerExpandVolume(t *testing.T) {
 
 }
 
+func TestGetCapacity(t *testing.T) {
+	osmock.On("GetAvailableCapacity", "ssd", "az1").Return(int64(19), true, nil)
+	osmock.On("GetAvailableCapacity", "", "nova").Return(int64(0), false, nil)
+
+	assert := assert.New(t)
+
+	// the zone of the topology takes precedence over the availability parameter
+	actualRes, err := fakeCs.GetCapacity(FakeCtx, &csi.GetCapacityRequest{
+		Parameters:         map[string]string{"type": "ssd", "availability": "nova"},
+		AccessibleTopology: &csi.Topology{Segments: map[string]string{topologyKey: "az1"}},
+	})
+	assert.NoError(err)
+	assert.Equal(int64(19*1024*1024*1024), actualRes.AvailableCapacity)
+
+	// an unbounded capacity is reported as the largest one
+	actualRes, err = fakeCs.GetCapacity(FakeCtx, &csi.GetCapacityRequest{
+		Parameters: map[string]string{"availability": "nova"},
+	})
+	assert.NoError(err)
+	assert.Equal(int64(math.MaxInt64), actualRes.AvailableCapacity)
+
+	_, err = fakeCs.GetCapacity(FakeCtx, &csi.GetCapacityRequest{
+		Parameters: map[string]string{"zone-types": "az1"},
+	})
+	assert.Equal(codes.InvalidArgument, status.Code(err))
+}
+
 func TestValidateVolumeCapabilities(t *testing.T) {
 
 	// GetVolume(volumeID string)
@@ -522,3 +717,95 @@ func TestValidateVolumeCapabilities(t *testing.T) {
 	assert.Equal(expectedRes2, actualRes2)
 
 }
+
+// Test validateCloneSource
+func TestValidateCloneSource(t *testing.T) {
+	source := &volumes.Volume{
+		ID:               FakeVolID,
+		Size:             2,
+		VolumeType:       "ssd",
+		AvailabilityZone: FakeAvailability,
+	}
+
+	testCases := []struct {
+		name         string
+		sizeGB       int
+		volType      string
+		availability string
+		code         codes.Code
+	}{
+		{name: "same size", sizeGB: 2, code: codes.OK},
+		{name: "bigger size with matching type and zone", sizeGB: 3, volType: "ssd", availability: FakeAvailability, code: codes.OK},
+		{name: "smaller size", sizeGB: 1, code: codes.OutOfRange},
+		{name: "different type", sizeGB: 2, volType: "hdd", code: codes.InvalidArgument},
+		{name: "different zone", sizeGB: 2, availability: "az2", code: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		err := validateCloneSource(source, tc.sizeGB, tc.volType, tc.availability)
+		assert.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+}
+
+// Test getVolumeType
+func TestGetVolumeType(t *testing.T) {
+	testCases := []struct {
+		name         string
+		parameters   map[string]string
+		availability string
+		expected     string
+		fail         bool
+	}{
+		{name: "no type", parameters: map[string]string{}, availability: "az1", expected: ""},
+		{name: "type only", parameters: map[string]string{"type": "ssd"}, availability: "az1", expected: "ssd"},
+		{name: "mapped zone", parameters: map[string]string{"type": "ssd", "zone-types": "az1:fast, az2:slow"}, availability: "az2", expected: "slow"},
+		{name: "unmapped zone", parameters: map[string]string{"type": "ssd", "zone-types": "az1:fast"}, availability: "az3", expected: "ssd"},
+		{name: "no zone", parameters: map[string]string{"zone-types": "az1:fast"}, availability: "", expected: ""},
+		{name: "invalid mapping", parameters: map[string]string{"zone-types": "az1"}, availability: "az1", fail: true},
+		{name: "empty type", parameters: map[string]string{"zone-types": "az1:"}, availability: "az1", fail: true},
+	}
+
+	for _, tc := range testCases {
+		volType, err := getVolumeType(tc.parameters, tc.availability)
+		if tc.fail {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, volType, tc.name)
+	}
+}
+
+// Test getAZFromTopology
+func TestGetAZFromTopology(t *testing.T) {
+	zone := func(az string) *csi.Topology {
+		return &csi.Topology{Segments: map[string]string{topologyKey: az}}
+	}
+
+	testCases := []struct {
+		name        string
+		requirement *csi.TopologyRequirement
+		expected    string
+	}{
+		{name: "no requirement", requirement: nil, expected: ""},
+		{name: "single requisite zone", requirement: &csi.TopologyRequirement{Requisite: []*csi.Topology{zone("az1")}}, expected: "az1"},
+		{
+			name: "preferred zone among requisite zones",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{zone("az1"), zone("az2")},
+				Preferred: []*csi.Topology{zone("az2"), zone("az1")},
+			},
+			expected: "az2",
+		},
+		{name: "first requisite zone", requirement: &csi.TopologyRequirement{Requisite: []*csi.Topology{zone("az1"), zone("az2")}}, expected: "az1"},
+		{
+			name:        "no zone segment",
+			requirement: &csi.TopologyRequirement{Requisite: []*csi.Topology{{Segments: map[string]string{"other": "az1"}}}},
+			expected:    "",
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, getAZFromTopology(tc.requirement), tc.name)
+	}
+}
@@ -32,6 +32,9 @@ import (
 const (
 	driverName  = "cinder.csi.openstack.org"
 	topologyKey = "topology." + driverName + "/zone"
+	// publishContextReadOnly is the publish context key telling the nodes
+	// that the volume is attached read-only
+	publishContextReadOnly = "readonly"
 )
 
 var (
@@ -80,8 +83,17 @@ func NewDriver(nodeID, endpoint, cluster string) *CinderDriver {
 			csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 			csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+			csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		})
+	d.AddVolumeCapabilityAccessModes(
+		[]csi.VolumeCapability_AccessMode_Mode{
+			csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+			// Only for the volumes of a multiattach volume type
+			csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			// Only for the block volumes of a multiattach volume type
+			csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 		})
-	d.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER})
 
 	d.AddNodeServiceCapabilities(
 		[]csi.NodeServiceCapability_RPC_Type{
@@ -143,6 +155,15 @@ func (d *CinderDriver) GetVolumeCapabilityAccessModes() []*csi.VolumeCapability_
 	return d.vcap
 }
 
+func (d *CinderDriver) supportsAccessMode(mode csi.VolumeCapability_AccessMode_Mode) bool {
+	for _, vcap := range d.vcap {
+		if vcap.GetMode() == mode {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *CinderDriver) SetupDriver(cloud openstack.IOpenStack, mount mount.IMount, metadata metadata.IMetadata) {
 
 	d.ids = NewIdentityServer(d)
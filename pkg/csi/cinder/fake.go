@@ -30,6 +30,8 @@ var FakeConfig = "/etc/cloud.conf"
 var FakeCtx = context.Background()
 var FakeVolName = "CSIVolumeName"
 var FakeVolID = "CSIVolumeID"
+var FakeMultiattachVolID = "multiattach-volume-id"
+var FakeAttachedVolID = "attached-volume-id"
 var FakeSnapshotName = "CSISnapshotName"
 var FakeSnapshotID = "261a8b81-3660-43e5-bab8-6470b65ee4e8"
 var FakeCapacityGiB = 1
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
@@ -39,11 +40,43 @@ import (
 	"k8s.io/cloud-provider-openstack/pkg/util/mount"
 )
 
+// defaultNodeMaxConcurrentOperations is the default number of
+// NodeStageVolume and NodeUnstageVolume calls allowed to run at once.
+const defaultNodeMaxConcurrentOperations = 4
+
+// defaultNodeDeviceDiscoveryTimeout is the default time allowed to find the
+// device of an attached volume on the node.
+const defaultNodeDeviceDiscoveryTimeout = 30 * time.Second
+
 type nodeServer struct {
 	Driver   *CinderDriver
 	Mount    mount.IMount
 	Metadata metadata.IMetadata
 	Cloud    openstack.IOpenStack
+
+	// operations limits the concurrent device operations on the node.
+	operations chan struct{}
+	// deviceDiscoveryTimeout bounds the search of the device of a volume.
+	deviceDiscoveryTimeout time.Duration
+}
+
+// acquireOperation waits for a free device operation slot, queuing the
+// caller until one is released or the request context is done.
+func (ns *nodeServer) acquireOperation(ctx context.Context) error {
+	select {
+	case ns.operations <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return status.Error(codes.DeadlineExceeded, "timed out waiting for a free device operation slot")
+		}
+		return status.Error(codes.Canceled, "canceled while waiting for a free device operation slot")
+	}
+}
+
+// releaseOperation frees a device operation slot taken by acquireOperation.
+func (ns *nodeServer) releaseOperation() {
+	<-ns.operations
 }
 
 func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
@@ -148,7 +181,7 @@ func nodePublishEphermeral(req *csi.NodePublishVolumeRequest, ns *nodeServer) (*
 		volumeType = ""
 	}
 
-	evol, err := ns.Cloud.CreateVolume(volName, size, volumeType, volAvailability, "", "", &properties)
+	evol, err := ns.Cloud.CreateVolume(volName, size, volumeType, volAvailability, "", "", false, &properties)
 
 	if err != nil {
 		klog.V(3).Infof("Failed to Create Ephermal Volume: %v", err)
@@ -179,7 +212,7 @@ func nodePublishEphermeral(req *csi.NodePublishVolumeRequest, ns *nodeServer) (*
 
 	m := ns.Mount
 
-	devicePath, err := getDevicePath(evol.ID, m)
+	devicePath, err := ns.getDevicePath(evol.ID)
 	if devicePath == "" {
 		return nil, status.Error(codes.Internal, "Unable to find Device path for volume")
 	}
@@ -225,7 +258,7 @@ func nodePublishVolumeForBlock(req *csi.NodePublishVolumeRequest, ns *nodeServer
 	m := ns.Mount
 
 	// Do not trust the path provided by cinder, get the real path on node
-	source, err := getDevicePath(volumeID, m)
+	source, err := ns.getDevicePath(volumeID)
 	if source == "" {
 		return nil, status.Error(codes.Internal, "Unable to find Device path for volume")
 	}
@@ -355,6 +388,11 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 		return nil, status.Error(codes.InvalidArgument, "NodeStageVolume Volume Capability must be provided")
 	}
 
+	if err := ns.acquireOperation(ctx); err != nil {
+		return nil, err
+	}
+	defer ns.releaseOperation()
+
 	_, err := ns.Cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
@@ -365,10 +403,15 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 
 	m := ns.Mount
 	// Do not trust the path provided by cinder, get the real path on node
-	devicePath, err := getDevicePath(volumeID, m)
+	devicePath, err := ns.getDevicePath(volumeID)
 	if devicePath == "" {
 		return nil, status.Error(codes.Internal, "Unable to find Device path for volume")
 	}
+	if novaPath := req.GetPublishContext()["DevicePath"]; novaPath != "" {
+		if device, err := filepath.EvalSymlinks(devicePath); err == nil && device != novaPath {
+			klog.V(2).Infof("NodeStageVolume: volume %s is attached as %s, not as %s reported by Nova", volumeID, device, novaPath)
+		}
+	}
 
 	if blk := volumeCapability.GetBlock(); blk != nil {
 		// If block volume, do nothing
@@ -393,6 +436,11 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 			mountFlags := mnt.GetMountFlags()
 			options = append(options, mountFlags...)
 		}
+		// The device of a read-only volume is mounted read-only, so it can't
+		// be formatted and must already have a filesystem
+		if req.GetPublishContext()[publishContextReadOnly] == "true" || isReadOnlyAccessMode(volumeCapability) {
+			options = append(options, "ro")
+		}
 		// Mount
 		err = m.Mounter().FormatAndMount(devicePath, stagingTarget, fsType, options)
 		if err != nil {
@@ -416,6 +464,11 @@ func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return nil, status.Error(codes.InvalidArgument, "NodeUnstageVolume Staging Target Path must be provided")
 	}
 
+	if err := ns.acquireOperation(ctx); err != nil {
+		return nil, err
+	}
+	defer ns.releaseOperation()
+
 	_, err := ns.Cloud.GetVolume(volumeID)
 	if err != nil {
 		if cpoerrors.IsNotFound(err) {
@@ -444,7 +497,11 @@ func (ns *nodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoReque
 	if err != nil {
 		return nil, status.Error(codes.Internal, fmt.Sprintf("[NodeGetInfo] Unable to retrieve availability zone of node %v", err))
 	}
-	topology := &csi.Topology{Segments: map[string]string{topologyKey: zone}}
+	// A topology segment can't be empty, the node is then reachable from any zone
+	var topology *csi.Topology
+	if zone != "" {
+		topology = &csi.Topology{Segments: map[string]string{topologyKey: zone}}
+	}
 
 	maxVolume := ns.Cloud.GetMaxVolLimit()
 
@@ -516,6 +573,21 @@ func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 	}
 	volumePath := req.GetVolumePath()
 
+	// Raw block volumes have no filesystem to resize, the device only needs
+	// to be rescanned to reflect the size of the extended Cinder volume.
+	if req.GetVolumeCapability().GetBlock() != nil {
+		devicePath, _ := ns.getDevicePath(volumeID)
+		if devicePath == "" {
+			return nil, status.Error(codes.Internal, "Unable to find Device path for volume")
+		}
+
+		newSize := req.GetCapacityRange().GetRequiredBytes()
+		if err := blockdevice.RescanBlockDeviceGeometry(devicePath, volumePath, newSize); err != nil {
+			return nil, status.Errorf(codes.Internal, "Could not verify %q volume size: %v", volumeID, err)
+		}
+		return &csi.NodeExpandVolumeResponse{}, nil
+	}
+
 	args := []string{"-o", "source", "--noheadings", "--target", volumePath}
 	output, err := ns.Mount.Mounter().Exec.Command("findmnt", args...).CombinedOutput()
 	if err != nil {
@@ -543,10 +615,13 @@ func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 	return &csi.NodeExpandVolumeResponse{}, nil
 }
 
-func getDevicePath(volumeID string, m mount.IMount) (string, error) {
-	var devicePath string
-	devicePath, _ = m.GetDevicePath(volumeID)
+// getDevicePath returns the device of an attached volume, matched by its
+// serial or WWN rather than the device path reported by Nova, which may not
+// be the name the kernel gave to the disk.
+func (ns *nodeServer) getDevicePath(volumeID string) (string, error) {
+	devicePath, err := ns.Mount.GetDevicePath(volumeID, ns.deviceDiscoveryTimeout)
 	if devicePath == "" {
+		klog.V(3).Infof("Failed to find the device of volume %s by its serial: %v", volumeID, err)
 		// try to get from metadata service
 		devicePath = metadata.GetDevicePath(volumeID)
 	}
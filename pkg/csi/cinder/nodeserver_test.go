@@ -17,14 +17,18 @@ limitations under the License.
 package cinder
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder/openstack"
 	"k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/cloud-provider-openstack/pkg/util/mount"
@@ -129,11 +133,11 @@ func TestNodePublishVolumeEphermeral(t *testing.T) {
 	properties := map[string]string{"cinder.csi.openstack.org/cluster": FakeCluster}
 	fvolName := fmt.Sprintf("ephemeral-%s", FakeVolID)
 
-	omock.On("CreateVolume", fvolName, 2, "test", "nova", "", "", &properties).Return(&FakeVol, nil)
+	omock.On("CreateVolume", fvolName, 2, "test", "nova", "", "", false, &properties).Return(&FakeVol, nil)
 
 	omock.On("AttachVolume", FakeNodeID, FakeVolID).Return(FakeVolID, nil)
 	omock.On("WaitDiskAttached", FakeNodeID, FakeVolID).Return(nil)
-	mmock.On("GetDevicePath", FakeVolID).Return(FakeDevicePath, nil)
+	mmock.On("GetDevicePath", FakeVolID, defaultNodeDeviceDiscoveryTimeout).Return(FakeDevicePath, nil)
 	mmock.On("IsLikelyNotMountPointAttach", FakeTargetPath).Return(true, nil)
 	metamock.On("GetAvailabilityZone").Return(FakeAvailability, nil)
 
@@ -181,7 +185,7 @@ func TestNodePublishVolumeEphermeral(t *testing.T) {
 // Test NodeStageVolume
 func TestNodeStageVolume(t *testing.T) {
 
-	mmock.On("GetDevicePath", FakeVolID).Return(FakeDevicePath, nil)
+	mmock.On("GetDevicePath", FakeVolID, defaultNodeDeviceDiscoveryTimeout).Return(FakeDevicePath, nil)
 	mmock.On("IsLikelyNotMountPointAttach", FakeStagingTargetPath).Return(true, nil)
 	omock.On("GetVolume", FakeVolID).Return(FakeVol, nil)
 
@@ -370,6 +374,38 @@ func TestNodeExpandVolume(t *testing.T) {
 
 }
 
+func TestNodeExpandVolumeBlock(t *testing.T) {
+
+	// Init assert
+	assert := assert.New(t)
+	mmock.ExpectedCalls = nil
+
+	mmock.On("GetDevicePath", FakeVolID, defaultNodeDeviceDiscoveryTimeout).Return(FakeDevicePath, nil)
+
+	// Fake request
+	fakeReq := &csi.NodeExpandVolumeRequest{
+		VolumeId:   FakeVolID,
+		VolumePath: FakeTargetPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+		},
+	}
+
+	// Expected Result
+	expectedRes := &csi.NodeExpandVolumeResponse{}
+
+	// Invoke NodeExpandVolume
+	actualRes, err := fakeNs.NodeExpandVolume(FakeCtx, fakeReq)
+
+	// Assert
+	assert.NoError(err)
+	assert.Equal(expectedRes, actualRes)
+	mmock.AssertCalled(t, "GetDevicePath", FakeVolID, defaultNodeDeviceDiscoveryTimeout)
+
+}
+
 func TestNodeGetVolumeStatsBlock(t *testing.T) {
 
 	// Init assert
@@ -446,3 +482,30 @@ func TestNodeGetVolumeStatsFs(t *testing.T) {
 	assert.Equal(expectedFsRes, fsRes)
 
 }
+
+func TestNodeOperationLimit(t *testing.T) {
+
+	// Init assert
+	assert := assert.New(t)
+
+	ns := &nodeServer{operations: make(chan struct{}, 1)}
+
+	assert.NoError(ns.acquireOperation(FakeCtx))
+
+	// Operations over the limit queue until the deadline of the request
+	ctx, cancel := context.WithTimeout(FakeCtx, 10*time.Millisecond)
+	defer cancel()
+	err := ns.acquireOperation(ctx)
+	assert.Equal(codes.DeadlineExceeded, status.Code(err))
+
+	// A queued operation proceeds once a slot is released
+	done := make(chan error)
+	go func() {
+		done <- ns.acquireOperation(FakeCtx)
+	}()
+	ns.releaseOperation()
+	assert.NoError(<-done)
+	ns.releaseOperation()
+
+	assert.Equal(defaultNodeMaxConcurrentOperations, cap(fakeNs.operations))
+}
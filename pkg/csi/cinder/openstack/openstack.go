@@ -25,8 +25,10 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/snapshots"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	tokens3 "github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
 	"github.com/spf13/pflag"
 	gcfg "gopkg.in/gcfg.v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	openstack_provider "k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack"
 	md "k8s.io/cloud-provider-openstack/pkg/util/metadata"
 	"k8s.io/klog/v2"
@@ -42,7 +44,7 @@ func AddExtraFlags(fs *pflag.FlagSet) {
 
 type IOpenStack interface {
 	CheckBlockStorageAPI() error
-	CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourcevolID string, tags *map[string]string) (*volumes.Volume, error)
+	CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourcevolID string, multiattach bool, tags *map[string]string) (*volumes.Volume, error)
 	DeleteVolume(volumeID string) error
 	AttachVolume(instanceID, volumeID string) (string, error)
 	ListVolumes(limit int, startingToken string) ([]volumes.Volume, string, error)
@@ -56,9 +58,12 @@ type IOpenStack interface {
 	ListSnapshots(filters map[string]string) ([]snapshots.Snapshot, string, error)
 	DeleteSnapshot(snapID string) error
 	GetSnapshotByID(snapshotID string) (*snapshots.Snapshot, error)
+	IsMultiattachVolumeType(volumeType string) (bool, error)
 	WaitSnapshotReady(snapshotID string) error
 	GetInstanceByID(instanceID string) (*servers.Server, error)
 	ExpandVolume(volumeID string, size int) error
+	SetVolumeReadOnly(volumeID string, readOnly bool) error
+	GetAvailableCapacity(volumeType, availability string) (int64, bool, error)
 	GetMaxVolLimit() int64
 	GetMetadataOpts() openstack_provider.MetadataOpts
 	GetBlockStorageOpts() BlockStorageOpts
@@ -70,11 +75,18 @@ type OpenStack struct {
 	bsOpts       BlockStorageOpts
 	epOpts       gophercloud.EndpointOpts
 	metadataOpts openstack_provider.MetadataOpts
+	// projectID is the project of the token, whose quotas bound the
+	// available capacity
+	projectID string
 }
 
 type BlockStorageOpts struct {
-	NodeVolumeAttachLimit int64 `gcfg:"node-volume-attach-limit"`
-	RescanOnResize        bool  `gcfg:"rescan-on-resize"`
+	NodeVolumeAttachLimit       int64 `gcfg:"node-volume-attach-limit"`
+	RescanOnResize              bool  `gcfg:"rescan-on-resize"`
+	NodeMaxConcurrentOperations int   `gcfg:"node-max-concurrent-operations"`
+	// NodeDeviceDiscoveryTimeout is how long the node plugin looks for the
+	// device of an attached volume
+	NodeDeviceDiscoveryTimeout openstack_provider.MyDuration `gcfg:"node-device-discovery-timeout"`
 }
 
 type Config struct {
@@ -103,6 +115,11 @@ func GetConfigFromFile(configFilePath string) (Config, error) {
 		return cfg, err
 	}
 
+	if err := openstack_provider.ApplyAuthEnv(&cfg.Global); err != nil {
+		klog.V(3).Infof("Failed to read the OpenStack options from the environment: %v", err)
+		return cfg, err
+	}
+
 	return cfg, nil
 }
 
@@ -127,10 +144,31 @@ func CreateOpenStackProvider() (IOpenStack, error) {
 	}
 	logcfg(cfg)
 
-	provider, err := openstack_provider.NewOpenStackClient(&cfg.Config.Global, "cinder-csi-plugin", userAgentData...)
+	clientOpts := openstack_provider.ClientOptionsKey("cinder-csi-plugin", userAgentData, configFile, cfg.Config.Transport, cfg.Config.RateLimit, cfg.Config.RateLimitService)
+	provider, err := openstack_provider.DefaultProviderClientPool.Get(&cfg.Config.Global, clientOpts, func() (*gophercloud.ProviderClient, error) {
+		provider, err := openstack_provider.NewOpenStackClientWithTransport(&cfg.Config.Global, cfg.Config.Transport, "cinder-csi-plugin", userAgentData...)
+		if err != nil {
+			return nil, err
+		}
+
+		openstack_provider.EnableApplicationCredentialReload(provider, &cfg.Config.Global, func() (*openstack_provider.AuthOpts, error) {
+			cfg, err := GetConfigFromFile(configFile)
+			if err != nil {
+				return nil, err
+			}
+			return &cfg.Config.Global, nil
+		})
+
+		err = openstack_provider.ApplyRateLimit(provider, cfg.Global.Region, cfg.Config.RateLimit, cfg.Config.RateLimitService)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	openstack_provider.DefaultProviderClientPool.Start(wait.NeverStop)
 
 	epOpts := gophercloud.EndpointOpts{
 		Region: cfg.Global.Region,
@@ -153,6 +191,15 @@ func CreateOpenStackProvider() (IOpenStack, error) {
 		cfg.Config.Metadata.SearchOrder = fmt.Sprintf("%s,%s", md.ConfigDriveID, md.MetadataID)
 	}
 
+	// The project of the token is also known when only its name, or an
+	// application credential, is configured
+	projectID := cfg.Global.TenantID
+	if result, ok := provider.GetAuthResult().(tokens3.CreateResult); ok {
+		if project, err := result.ExtractProject(); err == nil && project != nil {
+			projectID = project.ID
+		}
+	}
+
 	// Init OpenStack
 	OsInstance = &OpenStack{
 		compute:      computeclient,
@@ -160,6 +207,7 @@ func CreateOpenStackProvider() (IOpenStack, error) {
 		bsOpts:       cfg.BlockStorage,
 		epOpts:       epOpts,
 		metadataOpts: cfg.Config.Metadata,
+		projectID:    projectID,
 	}
 
 	return OsInstance, nil
@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+// quotaUsage is the usage of a quota of the project, a negative limit is
+// unlimited
+type quotaUsage struct {
+	InUse    int64 `json:"in_use"`
+	Reserved int64 `json:"reserved"`
+	Limit    int64 `json:"limit"`
+}
+
+// storagePool is a backend pool of the scheduler stats, its name is
+// host@backend#pool
+type storagePool struct {
+	Name         string `json:"name"`
+	Capabilities struct {
+		VolumeBackendName string `json:"volume_backend_name"`
+		// FreeCapacityGB is a number, or "infinite" or "unknown" when the
+		// backend doesn't expose its capacity
+		FreeCapacityGB interface{} `json:"free_capacity_gb"`
+	} `json:"capabilities"`
+}
+
+// volumeService is a cinder-volume service, its host is host@backend
+type volumeService struct {
+	Host   string `json:"host"`
+	Zone   string `json:"zone"`
+	State  string `json:"state"`
+	Status string `json:"status"`
+}
+
+// GetAvailableCapacity returns the GiB available for the new volumes of the
+// volume type, given by its name or ID, in the availability zone. It is the
+// lowest of the gigabytes quotas of the project, overall and of the volume
+// type, and of the free capacity of the largest backend pool of the volume
+// type in the zone. The pools are only listed by the admins, they are ignored when
+// the user isn't allowed to list them. The returned bool is false when
+// neither the quotas nor the pools bound the capacity.
+func (os *OpenStack) GetAvailableCapacity(volumeType, availability string) (int64, bool, error) {
+	typeName, backendName, err := os.getVolumeTypeBackend(volumeType)
+	if err != nil {
+		return 0, false, err
+	}
+
+	available, limited, err := os.getQuotaCapacity(typeName)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get the quota usage of project %s: %v", os.projectID, err)
+	}
+
+	free, bounded, err := os.getPoolsCapacity(backendName, availability)
+	if cpoerrors.IsForbidden(err) {
+		klog.V(4).Infof("The backend pools can't be listed, the capacity is only bounded by the quotas: %v", err)
+		return available, limited, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if bounded && (!limited || free < available) {
+		return free, true, nil
+	}
+	return available, limited, nil
+}
+
+// getVolumeTypeBackend returns the name and the volume_backend_name extra spec
+// of the volume type. Both are empty for the default volume type.
+func (os *OpenStack) getVolumeTypeBackend(volumeType string) (string, string, error) {
+	if volumeType == "" {
+		return "", "", nil
+	}
+
+	pages, err := volumetypes.List(os.blockstorage, volumetypes.ListOpts{}).AllPages()
+	if err != nil {
+		return "", "", err
+	}
+	types, err := volumetypes.ExtractVolumeTypes(pages)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, t := range types {
+		if t.ID == volumeType || t.Name == volumeType {
+			return t.Name, t.ExtraSpecs["volume_backend_name"], nil
+		}
+	}
+	return "", "", fmt.Errorf("volume type %s not found", volumeType)
+}
+
+// getQuotaCapacity returns the GiB left by the gigabytes quotas of the
+// project, overall and of the volume type when it is set
+func (os *OpenStack) getQuotaCapacity(typeName string) (int64, bool, error) {
+	if os.projectID == "" {
+		klog.V(4).Infof("The project of the token is unknown, the capacity isn't bounded by the quotas")
+		return 0, false, nil
+	}
+
+	var body struct {
+		QuotaSet map[string]json.RawMessage `json:"quota_set"`
+	}
+	_, err := os.blockstorage.Get(os.blockstorage.ServiceURL("os-quota-sets", os.projectID)+"?usage=true", &body, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	keys := []string{"gigabytes"}
+	if typeName != "" {
+		keys = append(keys, "gigabytes_"+typeName)
+	}
+
+	var available int64
+	limited := false
+	for _, key := range keys {
+		raw, ok := body.QuotaSet[key]
+		if !ok {
+			continue
+		}
+		var usage quotaUsage
+		if err := json.Unmarshal(raw, &usage); err != nil {
+			return 0, false, fmt.Errorf("failed to read the %s quota: %v", key, err)
+		}
+		if usage.Limit < 0 {
+			continue
+		}
+
+		left := usage.Limit - usage.InUse - usage.Reserved
+		if left < 0 {
+			left = 0
+		}
+		if !limited || left < available {
+			available = left
+		}
+		limited = true
+	}
+	return available, limited, nil
+}
+
+// getPoolsCapacity returns the free GiB of the largest pool of the backend, or
+// of all the backends when it isn't set, whose cinder-volume service is up and
+// enabled in the availability zone. A volume is created in a single pool, the
+// free GiB of the pools aren't summed. The returned bool is false when a pool
+// doesn't expose its capacity.
+func (os *OpenStack) getPoolsCapacity(backendName, availability string) (int64, bool, error) {
+	var services struct {
+		Services []volumeService `json:"services"`
+	}
+	_, err := os.blockstorage.Get(os.blockstorage.ServiceURL("os-services")+"?binary=cinder-volume", &services, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	hosts := map[string]bool{}
+	for _, s := range services.Services {
+		if s.State == "up" && s.Status == "enabled" && (availability == "" || s.Zone == availability) {
+			hosts[s.Host] = true
+		}
+	}
+
+	var pools struct {
+		Pools []storagePool `json:"pools"`
+	}
+	_, err = os.blockstorage.Get(os.blockstorage.ServiceURL("scheduler-stats", "get_pools")+"?detail=true", &pools, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var free int64
+	for _, pool := range pools.Pools {
+		host := strings.SplitN(pool.Name, "#", 2)[0]
+		if !hosts[host] || (backendName != "" && pool.Capabilities.VolumeBackendName != backendName) {
+			continue
+		}
+
+		gb, ok := pool.Capabilities.FreeCapacityGB.(float64)
+		if !ok {
+			klog.V(4).Infof("Pool %s doesn't expose its free capacity (%v), the capacity isn't bounded by the pools", pool.Name, pool.Capabilities.FreeCapacityGB)
+			return 0, false, nil
+		}
+		if int64(gb) > free {
+			free = int64(gb)
+		}
+	}
+	return free, true, nil
+}
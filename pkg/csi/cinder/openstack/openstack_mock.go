@@ -40,6 +40,23 @@ var fakeVol2 = volumes.Volume{
 	Size:             1,
 }
 
+// fakeAttachedVols are volumes attached read-only to another node
+var fakeAttachedVols = map[string]volumes.Volume{
+	"multiattach-volume-id": {
+		ID:          "multiattach-volume-id",
+		Status:      "in-use",
+		Multiattach: true,
+		Metadata:    map[string]string{"readonly": "True"},
+		Attachments: []volumes.Attachment{{ServerID: "other-node-id"}},
+	},
+	"attached-volume-id": {
+		ID:          "attached-volume-id",
+		Status:      "in-use",
+		Metadata:    map[string]string{"readonly": "True"},
+		Attachments: []volumes.Attachment{{ServerID: "other-node-id"}},
+	},
+}
+
 var fakeSnapshot = snapshots.Snapshot{
 	ID:       "261a8b81-3660-43e5-bab8-6470b65ee4e8",
 	Name:     "fake-snapshot",
@@ -76,20 +93,20 @@ func (_m *OpenStackMock) AttachVolume(instanceID string, volumeID string) (strin
 	return r0, r1
 }
 
-// CreateVolume provides a mock function with given fields: name, size, vtype, availability, tags
-func (_m *OpenStackMock) CreateVolume(name string, size int, vtype string, availability string, snapshotID string, sourceVolID string, tags *map[string]string) (*volumes.Volume, error) {
-	ret := _m.Called(name, size, vtype, availability, snapshotID, sourceVolID, tags)
+// CreateVolume provides a mock function with given fields: name, size, vtype, availability, snapshotID, sourceVolID, multiattach, tags
+func (_m *OpenStackMock) CreateVolume(name string, size int, vtype string, availability string, snapshotID string, sourceVolID string, multiattach bool, tags *map[string]string) (*volumes.Volume, error) {
+	ret := _m.Called(name, size, vtype, availability, snapshotID, sourceVolID, multiattach, tags)
 
 	var r0 *volumes.Volume
-	if rf, ok := ret.Get(0).(func(string, int, string, string, string, string, *map[string]string) *volumes.Volume); ok {
-		r0 = rf(name, size, vtype, availability, snapshotID, sourceVolID, tags)
+	if rf, ok := ret.Get(0).(func(string, int, string, string, string, string, bool, *map[string]string) *volumes.Volume); ok {
+		r0 = rf(name, size, vtype, availability, snapshotID, sourceVolID, multiattach, tags)
 	} else {
 		r0 = ret.Get(0).(*volumes.Volume)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, int, string, string, string, string, *map[string]string) error); ok {
-		r1 = rf(name, size, vtype, availability, snapshotID, sourceVolID, tags)
+	if rf, ok := ret.Get(1).(func(string, int, string, string, string, string, bool, *map[string]string) error); ok {
+		r1 = rf(name, size, vtype, availability, snapshotID, sourceVolID, multiattach, tags)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -113,6 +130,9 @@ func (_m *OpenStackMock) DeleteVolume(volumeID string) error {
 
 // GetVolume provides a mock function with given fields: volumeID
 func (_m *OpenStackMock) GetVolume(volumeID string) (*volumes.Volume, error) {
+	if vol, ok := fakeAttachedVols[volumeID]; ok {
+		return &vol, nil
+	}
 	return &fakeVol1, nil
 }
 
@@ -359,6 +379,27 @@ func (_m *OpenStackMock) GetInstanceByID(instanceID string) (*servers.Server, er
 	return nil, nil
 }
 
+// IsMultiattachVolumeType provides a mock function with given fields: volumeType
+func (_m *OpenStackMock) IsMultiattachVolumeType(volumeType string) (bool, error) {
+	ret := _m.Called(volumeType)
+
+	return ret.Bool(0), ret.Error(1)
+}
+
+// SetVolumeReadOnly provides a mock function with given fields: volumeID, readOnly
+func (_m *OpenStackMock) SetVolumeReadOnly(volumeID string, readOnly bool) error {
+	ret := _m.Called(volumeID, readOnly)
+
+	return ret.Error(0)
+}
+
+// GetAvailableCapacity provides a mock function with given fields: volumeType, availability
+func (_m *OpenStackMock) GetAvailableCapacity(volumeType string, availability string) (int64, bool, error) {
+	ret := _m.Called(volumeType, availability)
+
+	return ret.Get(0).(int64), ret.Bool(1), ret.Error(2)
+}
+
 // ExpandVolume provides a mock function with given fields: instanceID, volumeID
 func (_m *OpenStackMock) ExpandVolume(volumeID string, size int) error {
 	ret := _m.Called(volumeID, size)
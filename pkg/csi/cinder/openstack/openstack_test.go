@@ -17,11 +17,16 @@ limitations under the License.
 package openstack
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,7 +53,8 @@ domain-id=` + fakeDomainID + `
 ca-file=` + fakeCAfile + `
 region=` + fakeRegion + `
 [BlockStorage]
-rescan-on-resize=true`
+rescan-on-resize=true
+node-max-concurrent-operations=8`
 
 	f, err := os.Create(fakeFileName)
 	if err != nil {
@@ -73,6 +79,7 @@ rescan-on-resize=true`
 	expectedOpts.Global.TenantID = fakeTenantID
 	expectedOpts.Global.Region = fakeRegion
 	expectedOpts.BlockStorage.RescanOnResize = true
+	expectedOpts.BlockStorage.NodeMaxConcurrentOperations = 8
 
 	// Invoke GetConfigFromFile
 	actualAuthOpts, err := GetConfigFromFile(fakeFileName)
@@ -141,3 +148,124 @@ func resetEnviron(t *testing.T, items []string) {
 		}
 	}
 }
+
+func TestListVolumes(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	th.Mux.HandleFunc("/volumes/detail", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		switch r.URL.Query().Get("marker") {
+		case "":
+			fmt.Fprintf(w, `{"volumes": [{"id": "vol-1"}, {"id": "vol-2"}], "volumes_links": [{"rel": "next", "href": "%svolumes/detail?limit=2&marker=vol-2"}]}`, th.Endpoint())
+		case "vol-2":
+			fmt.Fprintf(w, `{"volumes": [{"id": "vol-3"}]}`)
+		default:
+			t.Errorf("unexpected marker %q", r.URL.Query().Get("marker"))
+		}
+	})
+
+	cloud := &OpenStack{blockstorage: fake.ServiceClient()}
+	ids := func(vols []volumes.Volume) []string {
+		var ids []string
+		for _, v := range vols {
+			ids = append(ids, v.ID)
+		}
+		return ids
+	}
+
+	// a single page is listed with a limit
+	vols, token, err := cloud.ListVolumes(2, "")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{"vol-1", "vol-2"}, ids(vols))
+	th.AssertEquals(t, "vol-2", token)
+
+	vols, token, err = cloud.ListVolumes(2, token)
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{"vol-3"}, ids(vols))
+	th.AssertEquals(t, "", token)
+
+	// all the pages are listed without a limit
+	vols, token, err = cloud.ListVolumes(0, "")
+	th.AssertNoErr(t, err)
+	th.AssertDeepEquals(t, []string{"vol-1", "vol-2", "vol-3"}, ids(vols))
+	th.AssertEquals(t, "", token)
+}
+
+func TestGetAvailableCapacity(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+
+	gigabytesLimit := 100
+	poolsForbidden := false
+
+	th.Mux.HandleFunc("/types", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"volume_types": [{"id": "type-1", "name": "ssd", "extra_specs": {"volume_backend_name": "ssd-backend"}}]}`)
+	})
+	th.Mux.HandleFunc("/os-quota-sets/project-1", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "true", r.URL.Query().Get("usage"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"quota_set": {"id": "project-1", "gigabytes": {"in_use": 60, "reserved": 10, "limit": %d}, "gigabytes_ssd": {"in_use": 20, "reserved": 0, "limit": -1}}}`, gigabytesLimit)
+	})
+	th.Mux.HandleFunc("/os-services", func(w http.ResponseWriter, r *http.Request) {
+		th.AssertEquals(t, "cinder-volume", r.URL.Query().Get("binary"))
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"services": [
+			{"host": "cinder-1@ssd", "zone": "az-1", "state": "up", "status": "enabled"},
+			{"host": "cinder-2@ssd", "zone": "az-2", "state": "up", "status": "enabled"},
+			{"host": "cinder-3@hdd", "zone": "az-1", "state": "up", "status": "enabled"},
+			{"host": "cinder-4@ssd", "zone": "az-1", "state": "down", "status": "enabled"}
+		]}`)
+	})
+	th.Mux.HandleFunc("/scheduler-stats/get_pools", func(w http.ResponseWriter, r *http.Request) {
+		if poolsForbidden {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"pools": [
+			{"name": "cinder-1@ssd#pool-1", "capabilities": {"volume_backend_name": "ssd-backend", "free_capacity_gb": 12.5}},
+			{"name": "cinder-1@ssd#pool-2", "capabilities": {"volume_backend_name": "ssd-backend", "free_capacity_gb": 7}},
+			{"name": "cinder-2@ssd#pool", "capabilities": {"volume_backend_name": "ssd-backend", "free_capacity_gb": 500}},
+			{"name": "cinder-3@hdd#pool", "capabilities": {"volume_backend_name": "hdd-backend", "free_capacity_gb": "infinite"}},
+			{"name": "cinder-4@ssd#pool", "capabilities": {"volume_backend_name": "ssd-backend", "free_capacity_gb": 1000}}
+		]}`)
+	})
+
+	cloud := &OpenStack{blockstorage: fake.ServiceClient(), projectID: "project-1"}
+
+	testCases := []struct {
+		volumeType     string
+		availability   string
+		gigabytesLimit int
+		poolsForbidden bool
+		available      int64
+		limited        bool
+	}{
+		// the largest pool of the backend of the type whose service is up in the zone
+		{volumeType: "ssd", availability: "az-1", gigabytesLimit: 100, available: 12, limited: true},
+		// the quota left: 100 - 60 in use - 10 reserved
+		{volumeType: "type-1", availability: "az-2", gigabytesLimit: 100, available: 30, limited: true},
+		// a pool of the zone doesn't expose its capacity
+		{availability: "az-1", gigabytesLimit: 100, available: 30, limited: true},
+		{volumeType: "ssd", availability: "az-1", gigabytesLimit: 100, poolsForbidden: true, available: 30, limited: true},
+		{volumeType: "ssd", availability: "az-1", gigabytesLimit: -1, poolsForbidden: true},
+		{volumeType: "ssd", availability: "az-3", gigabytesLimit: -1, limited: true},
+	}
+
+	for _, test := range testCases {
+		gigabytesLimit, poolsForbidden = test.gigabytesLimit, test.poolsForbidden
+		available, limited, err := cloud.GetAvailableCapacity(test.volumeType, test.availability)
+		th.AssertNoErr(t, err)
+		if available != test.available || limited != test.limited {
+			t.Errorf("GetAvailableCapacity(%q, %q) with %+v returned %d, %t, expected %d, %t",
+				test.volumeType, test.availability, test, available, limited, test.available, test.limited)
+		}
+	}
+
+	if _, _, err := cloud.GetAvailableCapacity("missing", "az-1"); err == nil {
+		t.Errorf("GetAvailableCapacity() with a missing volume type didn't fail")
+	}
+}
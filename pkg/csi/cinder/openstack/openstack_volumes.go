@@ -19,13 +19,17 @@ package openstack
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/apiversions"
 	volumeexpand "github.com/gophercloud/gophercloud/openstack/blockstorage/extensions/volumeactions"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumes"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v3/volumetypes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
+	"github.com/gophercloud/gophercloud/pagination"
 	"k8s.io/apimachinery/pkg/util/wait"
 	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 
@@ -58,7 +62,7 @@ func (os *OpenStack) CheckBlockStorageAPI() error {
 }
 
 // CreateVolume creates a volume of given size
-func (os *OpenStack) CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourcevolID string, tags *map[string]string) (*volumes.Volume, error) {
+func (os *OpenStack) CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourcevolID string, multiattach bool, tags *map[string]string) (*volumes.Volume, error) {
 
 	opts := &volumes.CreateOpts{
 		Name:             name,
@@ -73,7 +77,12 @@ func (os *OpenStack) CreateVolume(name string, size int, vtype, availability str
 		opts.Metadata = *tags
 	}
 
-	vol, err := volumes.Create(os.blockstorage, opts).Extract()
+	var createOpts volumes.CreateOptsBuilder = opts
+	if multiattach {
+		createOpts = multiattachCreateOpts{opts}
+	}
+
+	vol, err := volumes.Create(os.blockstorage, createOpts).Extract()
 	if err != nil {
 		return nil, err
 	}
@@ -81,24 +90,75 @@ func (os *OpenStack) CreateVolume(name string, size int, vtype, availability str
 	return vol, nil
 }
 
-// ListVolumes list all the volumes
-func (os *OpenStack) ListVolumes(limit int, startingToken string) ([]volumes.Volume, string, error) {
-	nextPageToken := ""
-	opts := volumes.ListOpts{Limit: limit, Marker: startingToken}
-	pages, err := volumes.List(os.blockstorage, opts).AllPages()
+// multiattachCreateOpts requests a volume which can be attached to several
+// instances, the volume type must support it too
+type multiattachCreateOpts struct {
+	*volumes.CreateOpts
+}
+
+func (opts multiattachCreateOpts) ToVolumeCreateMap() (map[string]interface{}, error) {
+	b, err := opts.CreateOpts.ToVolumeCreateMap()
 	if err != nil {
-		return nil, nextPageToken, err
+		return nil, err
 	}
-	vols, err := volumes.ExtractVolumes(pages)
+	b["volume"].(map[string]interface{})["multiattach"] = true
+	return b, nil
+}
+
+// IsMultiattachVolumeType returns whether the volume type, given by its name
+// or ID, has the multiattach extra spec
+func (os *OpenStack) IsMultiattachVolumeType(volumeType string) (bool, error) {
+	pages, err := volumetypes.List(os.blockstorage, volumetypes.ListOpts{}).AllPages()
 	if err != nil {
-		return nil, nextPageToken, err
+		return false, err
+	}
+	types, err := volumetypes.ExtractVolumeTypes(pages)
+	if err != nil {
+		return false, err
 	}
-	nextPageURL, err := pages.NextPageURL()
-	if err != nil && nextPageURL != "" {
-		if queryParams, nerr := url.ParseQuery(nextPageURL); nerr != nil {
-			nextPageToken = queryParams.Get("marker")
+
+	for _, t := range types {
+		if t.ID == volumeType || t.Name == volumeType {
+			return strings.EqualFold(strings.TrimSpace(t.ExtraSpecs["multiattach"]), "<is> True"), nil
 		}
 	}
+	return false, fmt.Errorf("volume type %s not found", volumeType)
+}
+
+// ListVolumes lists the volumes after the startingToken marker. When limit
+// is set, only the first page of at most limit volumes is listed and the
+// returned token is the marker of the next page, empty on the last page.
+// Otherwise all the volumes are listed.
+func (os *OpenStack) ListVolumes(limit int, startingToken string) ([]volumes.Volume, string, error) {
+	var vols []volumes.Volume
+	nextPageToken := ""
+	opts := volumes.ListOpts{Limit: limit, Marker: startingToken}
+	err := volumes.List(os.blockstorage, opts).EachPage(func(page pagination.Page) (bool, error) {
+		pageVols, err := volumes.ExtractVolumes(page)
+		if err != nil {
+			return false, err
+		}
+		vols = append(vols, pageVols...)
+		if limit == 0 {
+			return true, nil
+		}
+
+		nextPageURL, err := page.NextPageURL()
+		if err != nil {
+			return false, err
+		}
+		if nextPageURL != "" {
+			u, err := url.Parse(nextPageURL)
+			if err != nil {
+				return false, err
+			}
+			nextPageToken = u.Query().Get("marker")
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
 	return vols, nextPageToken, nil
 }
 
@@ -312,6 +372,21 @@ func (os *OpenStack) ExpandVolume(volumeID string, newSize int) error {
 	return fmt.Errorf("volume cannot be resized, when status is %s", volume.Status)
 }
 
+// SetVolumeReadOnly sets the read-only flag of the volume, the volumes whose
+// flag is set are attached read-only by Nova. The flag can't be changed while
+// the volume is attached.
+func (os *OpenStack) SetVolumeReadOnly(volumeID string, readOnly bool) error {
+	body := map[string]interface{}{
+		"os-update_readonly_flag": map[string]interface{}{
+			"readonly": readOnly,
+		},
+	}
+	_, err := os.blockstorage.Post(os.blockstorage.ServiceURL("volumes", volumeID, "action"), body, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{202},
+	})
+	return err
+}
+
 //GetMaxVolLimit returns max vol limit
 func (os *OpenStack) GetMaxVolLimit() int64 {
 	if os.bsOpts.NodeVolumeAttachLimit > 0 && os.bsOpts.NodeVolumeAttachLimit <= 256 {
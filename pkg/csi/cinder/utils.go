@@ -37,6 +37,16 @@ func NewVolumeCapabilityAccessMode(mode csi.VolumeCapability_AccessMode_Mode) *c
 	return &csi.VolumeCapability_AccessMode{Mode: mode}
 }
 
+// isReadOnlyAccessMode returns whether the access mode of the volume
+// capability only allows reading the volume
+func isReadOnlyAccessMode(volumeCapability *csi.VolumeCapability) bool {
+	switch volumeCapability.GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY, csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return true
+	}
+	return false
+}
+
 func NewControllerServer(d *CinderDriver, cloud openstack.IOpenStack) *controllerServer {
 	return &controllerServer{
 		Driver: d,
@@ -51,11 +61,23 @@ func NewIdentityServer(d *CinderDriver) *identityServer {
 }
 
 func NewNodeServer(d *CinderDriver, mount mount.IMount, metadata metadata.IMetadata, cloud openstack.IOpenStack) *nodeServer {
+	maxOperations := cloud.GetBlockStorageOpts().NodeMaxConcurrentOperations
+	if maxOperations <= 0 {
+		maxOperations = defaultNodeMaxConcurrentOperations
+	}
+
+	deviceDiscoveryTimeout := cloud.GetBlockStorageOpts().NodeDeviceDiscoveryTimeout.Duration
+	if deviceDiscoveryTimeout <= 0 {
+		deviceDiscoveryTimeout = defaultNodeDeviceDiscoveryTimeout
+	}
+
 	return &nodeServer{
-		Driver:   d,
-		Mount:    mount,
-		Metadata: metadata,
-		Cloud:    cloud,
+		Driver:                 d,
+		Mount:                  mount,
+		Metadata:               metadata,
+		Cloud:                  cloud,
+		operations:             make(chan struct{}, maxOperations),
+		deviceDiscoveryTimeout: deviceDiscoveryTimeout,
 	}
 }
 
@@ -98,6 +98,25 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return nil, status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
 	}
 
+	if shareOpts.ShareNetworkNeutronNetID != "" {
+		// Retrieve the share network of the Neutron subnet or create a new one
+
+		shareNetworkName := getShareNetworkName(shareOpts)
+
+		if _, isPending := pendingShareNetworks.LoadOrStore(shareNetworkName, true); isPending {
+			return nil, status.Errorf(codes.Aborted, "a share network named %s is already being created or deleted", shareNetworkName)
+		}
+		// The share network is only released once the share is created or its creation failed
+		defer pendingShareNetworks.Delete(shareNetworkName)
+
+		shareNetwork, err := getOrCreateShareNetwork(shareNetworkName, shareOpts, manilaClient)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to get or create a share network (%s): %v", shareNetworkName, err)
+		}
+
+		shareOpts.ShareNetworkID = shareNetwork.ID
+	}
+
 	shareTypeCaps, err := capabilities.GetManilaCapabilities(shareOpts.Type, manilaClient)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get Manila capabilities for share type %s: %v", shareOpts.Type, err)
@@ -158,17 +177,23 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		accessibleTopology = req.GetAccessibilityRequirements().GetPreferred()
 	}
 
+	volumeCtx := map[string]string{
+		"shareID":        share.ID,
+		"shareAccessID":  accessRight.ID,
+		"cephfs-mounter": shareOpts.CephfsMounter,
+	}
+
+	if shareOpts.CephfsMatchExportLocationAddress != "" {
+		volumeCtx["cephfs-matchExportLocationAddress"] = shareOpts.CephfsMatchExportLocationAddress
+	}
+
 	return &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:           share.ID,
 			ContentSource:      req.GetVolumeContentSource(),
 			AccessibleTopology: accessibleTopology,
 			CapacityBytes:      int64(sizeInGiB) * bytesInGiB,
-			VolumeContext: map[string]string{
-				"shareID":        share.ID,
-				"shareAccessID":  accessRight.ID,
-				"cephfs-mounter": shareOpts.CephfsMounter,
-			},
+			VolumeContext:      volumeCtx,
 		},
 	}, nil
 }
@@ -188,10 +213,25 @@ func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 		return nil, status.Errorf(codes.Unauthenticated, "failed to create Manila v2 client: %v", err)
 	}
 
+	// Remember the share network of the share so that it can be deleted along with its last share
+
+	var shareNetworkID string
+	if share, err := manilaClient.GetShareByID(req.GetVolumeId()); err != nil {
+		if !clouderrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "failed to retrieve share %s: %v", req.GetVolumeId(), err)
+		}
+	} else {
+		shareNetworkID = share.ShareNetworkID
+	}
+
 	if err := deleteShare(req.GetVolumeId(), manilaClient); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to delete share %s: %v", req.GetVolumeId(), err)
 	}
 
+	if shareNetworkID != "" {
+		tryDeleteShareNetwork(req.GetVolumeId(), shareNetworkID, manilaClient)
+	}
+
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
@@ -327,6 +367,17 @@ func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 		return nil, status.Errorf(codes.Internal, "failed to delete snapshot %s: %v", req.GetSnapshotId(), err)
 	}
 
+	// Wait for the snapshot to be gone. Manila moves snapshots that
+	// couldn't be deleted into error_deleting state.
+
+	if _, manilaErrCode, err := waitForSnapshotStatus(req.GetSnapshotId(), snapshotDeleting, "", true, manilaClient); err != nil {
+		if err == wait.ErrWaitTimeout {
+			return nil, status.Errorf(codes.DeadlineExceeded, "deadline exceeded while waiting for snapshot %s to be deleted", req.GetSnapshotId())
+		}
+
+		return nil, status.Errorf(manilaErrCode.toRpcErrorCode(), "failed to delete snapshot %s: %v", req.GetSnapshotId(), err)
+	}
+
 	return &csi.DeleteSnapshotResponse{}, nil
 }
 
@@ -412,6 +463,8 @@ func (cs *controllerServer) GetCapacity(context.Context, *csi.GetCapacityRequest
 }
 
 func (cs *controllerServer) ListSnapshots(context.Context, *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	// ListSnapshotsRequest doesn't carry any secrets,
+	// so there are no credentials to build a Manila client with.
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
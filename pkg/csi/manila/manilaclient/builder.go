@@ -25,6 +25,7 @@ import (
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/apiversions"
+	"k8s.io/apimachinery/pkg/util/wait"
 	openstack_provider "k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack"
 )
 
@@ -99,10 +100,15 @@ func validateManilaClient(c *gophercloud.ServiceClient) error {
 
 func New(o *openstack_provider.AuthOpts, userAgent string, extraUserAgentData []string) (*Client, error) {
 	// Authenticate and create Manila v2 client
-	provider, err := openstack_provider.NewOpenStackClient(o, userAgent, extraUserAgentData...)
+	// The clients of the same credentials are shared across the requests
+	clientOpts := openstack_provider.ClientOptionsKey(userAgent, extraUserAgentData)
+	provider, err := openstack_provider.DefaultProviderClientPool.Get(o, clientOpts, func() (*gophercloud.ProviderClient, error) {
+		return openstack_provider.NewOpenStackClient(o, userAgent, extraUserAgentData...)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to authenticate: %v", err)
 	}
+	openstack_provider.DefaultProviderClientPool.Start(wait.NeverStop)
 
 	client, err := openstack.NewSharedFileSystemV2(provider, gophercloud.EndpointOpts{Region: o.Region})
 	if err != nil {
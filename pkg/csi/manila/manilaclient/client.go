@@ -19,6 +19,7 @@ package manilaclient
 import (
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharenetworks"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharetypes"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/snapshots"
@@ -44,6 +45,15 @@ func (c Client) GetShareByName(shareName string) (*shares.Share, error) {
 	return shares.Get(c.c, shareID).Extract()
 }
 
+func (c Client) GetShares(opts shares.ListOptsBuilder) ([]shares.Share, error) {
+	allPages, err := shares.ListDetail(c.c, opts).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	return shares.ExtractShares(allPages)
+}
+
 func (c Client) CreateShare(opts shares.CreateOptsBuilder) (*shares.Share, error) {
 	return shares.Create(c.c, opts).Extract()
 }
@@ -106,6 +116,39 @@ func (c Client) GetShareTypeIDFromName(shareTypeName string) (string, error) {
 	return sharetypes_utils.IDFromName(c.c, shareTypeName)
 }
 
+func (c Client) GetShareNetworkByID(shareNetworkID string) (*sharenetworks.ShareNetwork, error) {
+	return sharenetworks.Get(c.c, shareNetworkID).Extract()
+}
+
+func (c Client) GetShareNetworkByName(shareNetworkName string) (*sharenetworks.ShareNetwork, error) {
+	allPages, err := sharenetworks.ListDetail(c.c, sharenetworks.ListOpts{Name: shareNetworkName}).AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	shareNetworks, err := sharenetworks.ExtractShareNetworks(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(shareNetworks) {
+	case 0:
+		return nil, gophercloud.ErrResourceNotFound{Name: shareNetworkName, ResourceType: "share network"}
+	case 1:
+		return &shareNetworks[0], nil
+	default:
+		return nil, gophercloud.ErrMultipleResourcesFound{Name: shareNetworkName, Count: len(shareNetworks), ResourceType: "share network"}
+	}
+}
+
+func (c Client) CreateShareNetwork(opts sharenetworks.CreateOptsBuilder) (*sharenetworks.ShareNetwork, error) {
+	return sharenetworks.Create(c.c, opts).Extract()
+}
+
+func (c Client) DeleteShareNetwork(shareNetworkID string) error {
+	return sharenetworks.Delete(c.c, shareNetworkID).ExtractErr()
+}
+
 func (c Client) GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Message, error) {
 	allPages, err := messages.List(c.c, opts).AllPages()
 	if err != nil {
@@ -18,6 +18,7 @@ package manilaclient
 
 import (
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharenetworks"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharetypes"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/snapshots"
@@ -27,6 +28,7 @@ import (
 type Interface interface {
 	GetShareByID(shareID string) (*shares.Share, error)
 	GetShareByName(shareName string) (*shares.Share, error)
+	GetShares(opts shares.ListOptsBuilder) ([]shares.Share, error)
 	CreateShare(opts shares.CreateOptsBuilder) (*shares.Share, error)
 	DeleteShare(shareID string) error
 
@@ -46,6 +48,11 @@ type Interface interface {
 	GetShareTypes() ([]sharetypes.ShareType, error)
 	GetShareTypeIDFromName(shareTypeName string) (string, error)
 
+	GetShareNetworkByID(shareNetworkID string) (*sharenetworks.ShareNetwork, error)
+	GetShareNetworkByName(shareNetworkName string) (*sharenetworks.ShareNetwork, error)
+	CreateShareNetwork(opts sharenetworks.CreateOptsBuilder) (*sharenetworks.ShareNetwork, error)
+	DeleteShareNetwork(shareNetworkID string) error
+
 	GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Message, error)
 }
 
@@ -26,10 +26,17 @@ type ControllerVolumeContext struct {
 	ShareNetworkID   string `name:"shareNetworkID" value:"optional"`
 	AvailabilityZone string `name:"availability" value:"optional"`
 
+	// Share network options
+
+	ShareNetworkNeutronNetID    string `name:"shareNetworkNeutronNetID" value:"optional" dependsOn:"shareNetworkNeutronSubnetID" precludes:"shareNetworkID"`
+	ShareNetworkNeutronSubnetID string `name:"shareNetworkNeutronSubnetID" value:"optional" dependsOn:"shareNetworkNeutronNetID"`
+	PVCNamespace                string `name:"csi.storage.k8s.io/pvc/namespace" value:"optional"`
+
 	// Adapter options
 
-	CephfsMounter  string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
-	NFSShareClient string `name:"nfs-shareClient" value:"default:0.0.0.0/0"`
+	CephfsMounter                    string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
+	CephfsMatchExportLocationAddress string `name:"cephfs-matchExportLocationAddress" value:"optional"`
+	NFSShareClient                   string `name:"nfs-shareClient" value:"default:0.0.0.0/0"`
 }
 
 type NodeVolumeContext struct {
@@ -39,7 +46,8 @@ type NodeVolumeContext struct {
 
 	// Adapter options
 
-	CephfsMounter string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
+	CephfsMounter                    string `name:"cephfs-mounter" value:"default:fuse" matches:"^kernel|fuse$"`
+	CephfsMatchExportLocationAddress string `name:"cephfs-matchExportLocationAddress" value:"optional"`
 }
 
 var (
@@ -18,6 +18,8 @@ package shareadapters
 
 import (
 	"fmt"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
@@ -105,7 +107,7 @@ func (Cephfs) GetOrGrantAccess(args *GrantAccessArgs) (accessRight *shares.Acces
 }
 
 func (Cephfs) BuildVolumeContext(args *VolumeContextArgs) (volumeContext map[string]string, err error) {
-	chosenExportLocationIdx, err := manilautil.FindExportLocation(args.Locations, manilautil.AnyExportLocation)
+	chosenExportLocationIdx, err := cephfsChooseExportLocation(args.Locations, args.Options.CephfsMatchExportLocationAddress)
 	if err != nil {
 		return nil, fmt.Errorf("failed to choose an export location: %v", err)
 	}
@@ -130,3 +132,67 @@ func (Cephfs) BuildNodeStageSecret(args *SecretArgs) (secret map[string]string,
 func (Cephfs) BuildNodePublishSecret(args *SecretArgs) (secret map[string]string, err error) {
 	return nil, nil
 }
+
+// Tries to choose a suitable export location from the given list.
+// Returns index into `locs`.
+// If `matchAddress` is set, an export location with a monitor address matching it
+// is selected. If there's no such location, or `matchAddress` is empty, the function
+// falls back to using manilautil.AnyExportLocation filter, choosing the preferred location.
+func cephfsChooseExportLocation(locs []shares.ExportLocation, matchAddress string) (chosenExportLocationIdx int, err error) {
+	if matchAddress != "" {
+		if chosenExportLocationIdx, err = cephfsMatchExportLocationAddress(locs, matchAddress); err != nil {
+			return -1, err
+		}
+
+		if chosenExportLocationIdx != -1 {
+			klog.V(4).Infof("chose export location %s matching address %s", locs[chosenExportLocationIdx].Path, matchAddress)
+			return chosenExportLocationIdx, nil
+		}
+	}
+
+	if chosenExportLocationIdx, err = manilautil.FindExportLocation(locs, manilautil.AnyExportLocation); err != nil {
+		return -1, err
+	}
+
+	if matchAddress != "" && chosenExportLocationIdx != -1 {
+		klog.Infof("no export location matches address %s, falling back to export location %s", matchAddress, locs[chosenExportLocationIdx].Path)
+	}
+
+	return chosenExportLocationIdx, nil
+}
+
+// Selects an export location with at least one monitor address matching `matchAddress`.
+// CephFS export location paths are in the form of "mon1:port,mon2:port,...:/path".
+func cephfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress string) (idx int, err error) {
+	netIP, err := parseMatchExportLocationAddress(matchAddress)
+	if err != nil {
+		return -1, err
+	}
+
+	idx, err = manilautil.FindExportLocation(locs, func(i int) (bool, error) {
+		monitors, _, err := splitExportLocationPath(locs[i].Path)
+		if err != nil {
+			return false, err
+		}
+
+		for _, mon := range strings.Split(monitors, ",") {
+			host, _, err := net.SplitHostPort(mon)
+			if err != nil {
+				// Monitor address without a port
+				host = mon
+			}
+
+			if hostIP := net.ParseIP(host); hostIP != nil && netIP.Contains(hostIP) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+
+	if err != nil {
+		return -1, fmt.Errorf("matchExportLocationAddress filter '%s': %v", matchAddress, err)
+	}
+
+	return idx, nil
+}
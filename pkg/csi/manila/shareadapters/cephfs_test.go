@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shareadapters
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+)
+
+func TestCephfsChooseExportLocation(t *testing.T) {
+	locs := []shares.ExportLocation{
+		{
+			Path:        "192.168.1.10:6789,192.168.1.11:6789:/volumes/_nogroup/share",
+			IsAdminOnly: false,
+			Preferred:   false,
+		},
+		{
+			Path:        "10.0.0.10:6789,10.0.0.11:6789:/volumes/_nogroup/share",
+			IsAdminOnly: false,
+			Preferred:   true,
+		},
+		{
+			Path:        "[fd00::10]:6789,[fd00::11]:6789:/volumes/_nogroup/share",
+			IsAdminOnly: false,
+			Preferred:   false,
+		},
+		{
+			Path:        "172.16.0.10:6789:/volumes/_nogroup/share",
+			IsAdminOnly: true,
+			Preferred:   false,
+		},
+	}
+
+	ts := []struct {
+		matchAddress     string
+		expectedMatchIdx int
+		expectErr        bool
+	}{
+		{
+			// No filter, the preferred location is chosen
+			matchAddress:     "",
+			expectedMatchIdx: 1,
+		},
+		{
+			matchAddress:     "192.168.1.0/24",
+			expectedMatchIdx: 0,
+		},
+		{
+			// Matches the second monitor of the location
+			matchAddress:     "192.168.1.11",
+			expectedMatchIdx: 0,
+		},
+		{
+			matchAddress:     "fd00::/64",
+			expectedMatchIdx: 2,
+		},
+		{
+			// Admin-only locations are never chosen, fall back to the preferred location
+			matchAddress:     "172.16.0.0/16",
+			expectedMatchIdx: 1,
+		},
+		{
+			// No match, fall back to the preferred location
+			matchAddress:     "10.10.0.0/16",
+			expectedMatchIdx: 1,
+		},
+		{
+			matchAddress: "not-an-address",
+			expectErr:    true,
+		},
+	}
+
+	for i := range ts {
+		idx, err := cephfsChooseExportLocation(locs, ts[i].matchAddress)
+
+		if ts[i].expectErr {
+			if err == nil {
+				t.Errorf("test %d: expected error, got none", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+
+		if idx != ts[i].expectedMatchIdx {
+			t.Errorf("test %d: expected match index %d, got %d", i, ts[i].expectedMatchIdx, idx)
+		}
+	}
+}
@@ -19,7 +19,6 @@ package shareadapters
 import (
 	"fmt"
 	"net"
-	"strings"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
@@ -124,23 +123,9 @@ func nfsMatchExportLocationFromConfig(locs []shares.ExportLocation, conf *runtim
 
 // Selects an export location with a matching address
 func nfsMatchExportLocationAddress(locs []shares.ExportLocation, matchAddress string) (idx int, err error) {
-	if ip := net.ParseIP(matchAddress); ip != nil {
-		// `matchAddress` is a valid IP, but does not have a prefix.
-		// This means we're looking for an exact match in export location addresses.
-
-		// Heuristic to check whether this is an IPv4 or IPv6 address
-		if strings.Contains(matchAddress, ".") {
-			// IPv4
-			matchAddress += "/32"
-		} else {
-			// IPv6
-			matchAddress += "/128"
-		}
-	}
-
-	_, netIP, err := net.ParseCIDR(matchAddress)
+	netIP, err := parseMatchExportLocationAddress(matchAddress)
 	if err != nil {
-		return -1, fmt.Errorf("matchExportLocationAddress filter '%s' is not a CIDR-formatted IP address", matchAddress)
+		return -1, err
 	}
 
 	idx, err = manilautil.FindExportLocation(locs, func(i int) (bool, error) {
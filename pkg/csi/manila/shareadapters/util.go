@@ -18,6 +18,7 @@ package shareadapters
 
 import (
 	"fmt"
+	"net"
 	"strings"
 )
 
@@ -33,3 +34,28 @@ func splitExportLocationPath(exportLocationPath string) (address, location strin
 
 	return
 }
+
+// Parses a CIDR-formatted address used to match export locations.
+// If prefix is not provided, /32 or /128 prefix is assumed for IPv4 and IPv6 respectively.
+func parseMatchExportLocationAddress(matchAddress string) (*net.IPNet, error) {
+	if ip := net.ParseIP(matchAddress); ip != nil {
+		// `matchAddress` is a valid IP, but does not have a prefix.
+		// This means we're looking for an exact match in export location addresses.
+
+		// Heuristic to check whether this is an IPv4 or IPv6 address
+		if strings.Contains(matchAddress, ".") {
+			// IPv4
+			matchAddress += "/32"
+		} else {
+			// IPv6
+			matchAddress += "/128"
+		}
+	}
+
+	_, netIP, err := net.ParseCIDR(matchAddress)
+	if err != nil {
+		return nil, fmt.Errorf("matchExportLocationAddress filter '%s' is not a CIDR-formatted IP address", matchAddress)
+	}
+
+	return netIP, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharenetworks"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/options"
+	clouderrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const shareNetworkNamePrefix = "manila-csi-"
+
+// pendingShareNetworks holds the names of the share networks being created or deleted,
+// so that a share network is never deleted while a share is being provisioned in it
+var pendingShareNetworks = sync.Map{}
+
+// getShareNetworkName returns the name of the share network managed for the Neutron subnet,
+// one share network is created per namespace when the namespace of the PVC is known
+func getShareNetworkName(shareOpts *options.ControllerVolumeContext) string {
+	if shareOpts.PVCNamespace == "" {
+		return shareNetworkNamePrefix + shareOpts.ShareNetworkNeutronSubnetID
+	}
+
+	return fmt.Sprintf("%s%s-%s", shareNetworkNamePrefix, shareOpts.PVCNamespace, shareOpts.ShareNetworkNeutronSubnetID)
+}
+
+// getOrCreateShareNetwork first retrieves an existing share network with name=shareNetworkName, or creates a new one if it doesn't exist yet.
+// The share networks created by the driver are marked with shareDescription, only those are deleted once they're not used anymore.
+func getOrCreateShareNetwork(shareNetworkName string, shareOpts *options.ControllerVolumeContext, manilaClient manilaclient.Interface) (*sharenetworks.ShareNetwork, error) {
+	shareNetwork, err := manilaClient.GetShareNetworkByName(shareNetworkName)
+	if err == nil {
+		klog.V(4).Infof("a share network named %s already exists", shareNetworkName)
+
+		if shareNetwork.NeutronNetID != shareOpts.ShareNetworkNeutronNetID || shareNetwork.NeutronSubnetID != shareOpts.ShareNetworkNeutronSubnetID {
+			return nil, fmt.Errorf("share network %s is attached to Neutron network %s and subnet %s, wanted network %s and subnet %s", shareNetwork.ID,
+				shareNetwork.NeutronNetID, shareNetwork.NeutronSubnetID, shareOpts.ShareNetworkNeutronNetID, shareOpts.ShareNetworkNeutronSubnetID)
+		}
+
+		return shareNetwork, nil
+	}
+
+	if !clouderrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to probe for a share network named %s: %v", shareNetworkName, err)
+	}
+
+	klog.V(4).Infof("creating a new share network (%s) in Neutron subnet %s", shareNetworkName, shareOpts.ShareNetworkNeutronSubnetID)
+
+	return manilaClient.CreateShareNetwork(sharenetworks.CreateOpts{
+		NeutronNetID:    shareOpts.ShareNetworkNeutronNetID,
+		NeutronSubnetID: shareOpts.ShareNetworkNeutronSubnetID,
+		Name:            shareNetworkName,
+		Description:     shareDescription,
+	})
+}
+
+// tryDeleteShareNetwork deletes the share network of a deleted share once no other share uses it,
+// provided the share network was created by the driver. Failures are only logged, the share network
+// is then deleted along with the next share using it.
+func tryDeleteShareNetwork(shareID, shareNetworkID string, manilaClient manilaclient.Interface) {
+	shareNetwork, err := manilaClient.GetShareNetworkByID(shareNetworkID)
+	if err != nil {
+		if !clouderrors.IsNotFound(err) {
+			klog.Errorf("couldn't retrieve share network %s of deleted share %s: %v", shareNetworkID, shareID, err)
+		}
+		return
+	}
+
+	if shareNetwork.Description != shareDescription {
+		return
+	}
+
+	// A share might be being created in this share network, it's still in use then
+	if _, isPending := pendingShareNetworks.LoadOrStore(shareNetwork.Name, true); isPending {
+		return
+	}
+	defer pendingShareNetworks.Delete(shareNetwork.Name)
+
+	// The share network can't be deleted before the share is gone
+	if _, _, err = waitForShareStatus(shareID, shareDeleting, "", true, manilaClient); err != nil {
+		klog.Errorf("couldn't wait for share %s to be deleted before deleting its share network %s: %v", shareID, shareNetworkID, err)
+		return
+	}
+
+	inUse, err := manilaClient.GetShares(shares.ListOpts{ShareNetworkID: shareNetworkID})
+	if err != nil {
+		klog.Errorf("couldn't list the shares in share network %s: %v", shareNetworkID, err)
+		return
+	}
+
+	if len(inUse) > 0 {
+		klog.V(4).Infof("share network %s is still used by %d share(s)", shareNetworkID, len(inUse))
+		return
+	}
+
+	klog.V(4).Infof("deleting share network %s as its last share %s was deleted", shareNetworkID, shareID)
+
+	if err = manilaClient.DeleteShareNetwork(shareNetworkID); err != nil && !clouderrors.IsNotFound(err) {
+		klog.Errorf("couldn't delete share network %s: %v", shareNetworkID, err)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manila
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharenetworks"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
+	"k8s.io/cloud-provider-openstack/pkg/csi/manila/manilaclient"
+)
+
+// shareNetworkClient is a Manila client of a share network without shares
+type shareNetworkClient struct {
+	manilaclient.Interface
+	shareNetwork *sharenetworks.ShareNetwork
+	deleted      []string
+}
+
+func (c *shareNetworkClient) GetShareNetworkByID(shareNetworkID string) (*sharenetworks.ShareNetwork, error) {
+	return c.shareNetwork, nil
+}
+
+func (c *shareNetworkClient) GetShareByID(shareID string) (*shares.Share, error) {
+	return nil, gophercloud.ErrDefault404{}
+}
+
+func (c *shareNetworkClient) GetShares(opts shares.ListOptsBuilder) ([]shares.Share, error) {
+	return nil, nil
+}
+
+func (c *shareNetworkClient) DeleteShareNetwork(shareNetworkID string) error {
+	c.deleted = append(c.deleted, shareNetworkID)
+	return nil
+}
+
+func TestTryDeleteShareNetworkPending(t *testing.T) {
+	client := &shareNetworkClient{
+		shareNetwork: &sharenetworks.ShareNetwork{ID: "sn-id", Name: shareNetworkNamePrefix + "subnet-id", Description: shareDescription},
+	}
+
+	// A CreateVolume holds the share network until its share is created
+	pendingShareNetworks.Store(client.shareNetwork.Name, true)
+	tryDeleteShareNetwork("share-id", client.shareNetwork.ID, client)
+	if len(client.deleted) != 0 {
+		t.Errorf("share network deleted while a share is being created in it")
+	}
+	if _, isPending := pendingShareNetworks.Load(client.shareNetwork.Name); !isPending {
+		t.Errorf("the share network of the pending CreateVolume was released")
+	}
+
+	pendingShareNetworks.Delete(client.shareNetwork.Name)
+	tryDeleteShareNetwork("share-id", client.shareNetwork.ID, client)
+	if len(client.deleted) != 1 || client.deleted[0] != client.shareNetwork.ID {
+		t.Errorf("unused share network wasn't deleted, deleted %v", client.deleted)
+	}
+}
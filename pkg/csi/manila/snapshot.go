@@ -133,7 +133,7 @@ func waitForSnapshotStatus(snapshotID, currentStatus, desiredStatus string, succ
 			isAvailable = false
 		case desiredStatus:
 			isAvailable = true
-		case shareError:
+		case snapshotError:
 			manilaErrMsg, err := lastResourceError(snapshotID, manilaClient)
 			if err != nil {
 				return false, fmt.Errorf("snapshot %s is in error state, error description could not be retrieved: %v", snapshotID, err)
@@ -17,15 +17,24 @@ limitations under the License.
 package keystone
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/groups"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/users"
+	"k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
+const (
+	// tokenCacheSize is the maximum number of tokens kept in the token cache
+	tokenCacheSize = 4096
+)
+
 type tokenInfo struct {
 	userName    string
 	userID      string
@@ -34,6 +43,7 @@ type tokenInfo struct {
 	projectID   string
 	domainName  string
 	domainID    string
+	expiresAt   time.Time
 }
 
 type IKeystone interface {
@@ -75,6 +85,11 @@ func (k *Keystoner) GetTokenInfo(token string) (*tokenInfo, error) {
 		userRoles = append(userRoles, role.Name)
 	}
 
+	t, err := ret.ExtractToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract token information from Keystone response: %v", err)
+	}
+
 	return &tokenInfo{
 		userName:    tokenUser.Name,
 		userID:      tokenUser.ID,
@@ -83,6 +98,7 @@ func (k *Keystoner) GetTokenInfo(token string) (*tokenInfo, error) {
 		roles:       userRoles,
 		domainID:    tokenUser.Domain.ID,
 		domainName:  tokenUser.Domain.Name,
+		expiresAt:   t.ExpiresAt,
 	}, nil
 }
 
@@ -110,20 +126,56 @@ func (k *Keystoner) GetGroups(token string, userID string) ([]string, error) {
 // Authenticator contacts openstack keystone to validate user's token passed in the request.
 type Authenticator struct {
 	keystoner IKeystone
+	// groupPrefix is prepended to the names of the user's Keystone groups
+	groupPrefix string
+	// tokenCache keeps the users of validated tokens, keyed by the hash of
+	// the token, for at most tokenCacheTTL or until the token expires
+	tokenCache    *cache.LRUExpireCache
+	tokenCacheTTL time.Duration
+}
+
+// NewAuthenticator returns an Authenticator caching validated tokens for
+// tokenCacheTTL, a zero tokenCacheTTL disables the cache.
+func NewAuthenticator(keystoner IKeystone, groupPrefix string, tokenCacheTTL time.Duration) *Authenticator {
+	a := &Authenticator{
+		keystoner:     keystoner,
+		groupPrefix:   groupPrefix,
+		tokenCacheTTL: tokenCacheTTL,
+	}
+	if tokenCacheTTL > 0 {
+		a.tokenCache = cache.NewLRUExpireCache(tokenCacheSize)
+	}
+	return a
 }
 
 // AuthenticateToken checks the token via Keystone call
 func (a *Authenticator) AuthenticateToken(token string) (user.Info, bool, error) {
+	var cacheKey string
+	if a.tokenCache != nil {
+		sum := sha256.Sum256([]byte(token))
+		cacheKey = hex.EncodeToString(sum[:])
+		if u, ok := a.tokenCache.Get(cacheKey); ok {
+			return u.(user.Info), true, nil
+		}
+	}
+
 	tokenInfo, err := a.keystoner.GetTokenInfo(token)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to authenticate: %v", err)
 	}
 
-	userGroups, err := a.keystoner.GetGroups(token, tokenInfo.userID)
+	keystoneGroups, err := a.keystoner.GetGroups(token, tokenInfo.userID)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to authenticate: %v", err)
 	}
 
+	// The user may not be a member of any Keystone group, in which case
+	// only the project ID is included in the groups.
+	userGroups := make([]string, 0, len(keystoneGroups)+1)
+	for _, g := range keystoneGroups {
+		userGroups = append(userGroups, a.groupPrefix+g)
+	}
+
 	extra := map[string][]string{
 		Roles:       tokenInfo.roles,
 		ProjectID:   {tokenInfo.projectID},
@@ -140,5 +192,16 @@ func (a *Authenticator) AuthenticateToken(token string) (user.Info, bool, error)
 		Extra:  extra,
 	}
 
+	if a.tokenCache != nil {
+		// Never keep a token in the cache beyond its expiry
+		ttl := a.tokenCacheTTL
+		if untilExpiry := time.Until(tokenInfo.expiresAt); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+		if ttl > 0 {
+			a.tokenCache.Add(cacheKey, authenticatedUser, ttl)
+		}
+	}
+
 	return authenticatedUser, true, nil
 }
@@ -15,6 +15,7 @@ package keystone
 
 import (
 	"testing"
+	"time"
 
 	th "github.com/gophercloud/gophercloud/testhelper"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -50,7 +51,7 @@ func TestAuthenticateToken(t *testing.T) {
 	expectedUserInfo := &user.DefaultInfo{
 		Name:   "user-name",
 		UID:    "user-id",
-		Groups: []string{"group1", "group2"},
+		Groups: []string{"group1", "group2", "project-id"},
 		Extra: map[string][]string{
 			Roles:       {"role1", "role2"},
 			ProjectID:   {"project-id"},
@@ -63,3 +64,88 @@ func TestAuthenticateToken(t *testing.T) {
 
 	keystone.AssertExpectations(t)
 }
+
+func TestAuthenticateTokenGroupPrefix(t *testing.T) {
+	info := &tokenInfo{
+		userName:    "user-name",
+		userID:      "user-id",
+		projectID:   "project-id",
+		projectName: "project-name",
+		domainName:  "domain-name",
+		domainID:    "domain-id",
+		roles:       []string{"role1"},
+	}
+
+	testCases := []struct {
+		name           string
+		keystoneGroups []string
+		expectedGroups []string
+	}{
+		{
+			name:           "prefixed groups",
+			keystoneGroups: []string{"admins", "developers"},
+			expectedGroups: []string{"keystone:admins", "keystone:developers", "project-id"},
+		},
+		{
+			name:           "no groups",
+			keystoneGroups: nil,
+			expectedGroups: []string{"project-id"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keystone := &MockIKeystone{}
+			keystone.On("GetTokenInfo", "token").Return(info, nil).Once()
+			keystone.On("GetGroups", "token", "user-id").Return(tc.keystoneGroups, nil).Once()
+
+			a := &Authenticator{
+				keystoner:   keystone,
+				groupPrefix: "keystone:",
+			}
+			userInfo, allowed, err := a.AuthenticateToken("token")
+
+			th.AssertNoErr(t, err)
+			th.AssertEquals(t, true, allowed)
+			th.AssertDeepEquals(t, tc.expectedGroups, userInfo.GetGroups())
+
+			keystone.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthenticateTokenCache(t *testing.T) {
+	newTokenInfo := func(expiresAt time.Time) *tokenInfo {
+		return &tokenInfo{
+			userName:  "user-name",
+			userID:    "user-id",
+			projectID: "project-id",
+			expiresAt: expiresAt,
+		}
+	}
+
+	keystone := &MockIKeystone{}
+	keystone.On("GetTokenInfo", "token").Return(newTokenInfo(time.Now().Add(time.Hour)), nil).Once()
+	keystone.On("GetGroups", "token", "user-id").Return([]string{"group1"}, nil).Once()
+	keystone.On("GetTokenInfo", "expired").Return(newTokenInfo(time.Now().Add(-time.Minute)), nil).Twice()
+	keystone.On("GetGroups", "expired", "user-id").Return([]string{"group1"}, nil).Twice()
+
+	a := NewAuthenticator(keystone, "", time.Minute)
+
+	// The second call is served from the cache
+	for i := 0; i < 2; i++ {
+		userInfo, allowed, err := a.AuthenticateToken("token")
+		th.AssertNoErr(t, err)
+		th.AssertEquals(t, true, allowed)
+		th.AssertEquals(t, "user-id", userInfo.GetUID())
+	}
+
+	// Tokens past their expiry are never cached
+	for i := 0; i < 2; i++ {
+		_, allowed, err := a.AuthenticateToken("expired")
+		th.AssertNoErr(t, err)
+		th.AssertEquals(t, true, allowed)
+	}
+
+	keystone.AssertExpectations(t)
+}
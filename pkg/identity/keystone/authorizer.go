@@ -104,7 +104,9 @@ func resourcePermissionAllowed(permissionSpec map[string][]string, attr authoriz
 	verb := attr.GetVerb()
 	klog.V(4).Infof("Request namespace: %s, resource: %s, verb: %s", ns, res, verb)
 
-	for key, value := range permissionSpec {
+	// Evaluate the permissions in a stable order so the logs are deterministic
+	for _, key := range sets.StringKeySet(permissionSpec).List() {
+		value := permissionSpec[key]
 		klog.V(4).Infof("Evaluating %s: %s", key, value)
 
 		allowedVerbs := sets.NewString()
@@ -137,6 +139,7 @@ func resourcePermissionAllowed(permissionSpec map[string][]string, attr authoriz
 		klog.V(4).Infof("allowedNamespaces: %s, allowedResources: %s, allowedVerbs: %s", allowedNamespaces.List(), allowedResources.List(), allowedVerbs.List())
 
 		if allowedNamespaces.Has(ns) && allowedResources.Has(res) && allowedVerbs.Has(verb) {
+			klog.V(4).Infof("Request matched the permission definition %s", key)
 			return true
 		}
 	}
@@ -148,9 +151,9 @@ func nonResourcePermissionAllowed(permissionSpec map[string][]string, attr autho
 	path := attr.GetPath()
 	verb := attr.GetVerb()
 
-	for key, value := range permissionSpec {
+	for _, key := range sets.StringKeySet(permissionSpec).List() {
 		allowedVerbs := sets.NewString()
-		for _, val := range value {
+		for _, val := range permissionSpec[key] {
 			allowedVerbs.Insert(val)
 		}
 		if allowedVerbs.Has("*") {
@@ -336,7 +339,8 @@ func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized aut
 
 	// The permission is whitelist. Make sure we go through all the policies that match the user roles and projects. If
 	// the operation is allowed explicitly, stop the loop and return "allowed".
-	for _, p := range a.pl {
+	var matchedPolicies []int
+	for i, p := range a.pl {
 		policyRoles := sets.NewString()
 		policyProjects := sets.NewString()
 
@@ -358,31 +362,46 @@ func (a *Authorizer) Authorize(attributes authorizer.Attributes) (authorized aut
 				continue
 			}
 		}
+		matchedPolicies = append(matchedPolicies, i)
 
-		// ResourcePermissionsSpec and NonResourcePermissionsSpec take precedence over ResourceSpec and NonResourceSpec
-		if attributes.IsResourceRequest() {
-			if p.ResourcePermissionsSpec != nil {
-				if resourcePermissionAllowed(p.ResourcePermissionsSpec, attributes) {
-					return authorizer.DecisionAllow, "", nil
-				}
-			} else if p.ResourceSpec != nil {
-				if resourceMatches(*p, attributes) {
-					return authorizer.DecisionAllow, "", nil
-				}
-			}
-		} else {
-			if p.NonResourcePermissionsSpec != nil {
-				if nonResourcePermissionAllowed(p.NonResourcePermissionsSpec, attributes) {
-					return authorizer.DecisionAllow, "", nil
-				}
-			} else if p.NonResourceSpec != nil {
-				if nonResourceMatches(*p, attributes) {
-					return authorizer.DecisionAllow, "", nil
-				}
-			}
+		if policyAllows(p, attributes) {
+			klog.V(4).Infof("Request of user %s allowed by policy %d", user.GetName(), i)
+			return authorizer.DecisionAllow, "", nil
 		}
 	}
 
+	reason := "No policy matched."
+	if len(matchedPolicies) > 0 {
+		reason = fmt.Sprintf("Policies %v matched the user but none of them allows the request.", matchedPolicies)
+	}
+
+	if attributes.IsResourceRequest() {
+		klog.Infof("Denied %s on %s in namespace %q to user %s (projects: %s, roles: %s): %s", attributes.GetVerb(), attributes.GetResource(), attributes.GetNamespace(),
+			user.GetName(), userProjects.List(), userRoles.List(), reason)
+	} else {
+		klog.Infof("Denied %s on %s to user %s (projects: %s, roles: %s): %s", attributes.GetVerb(), attributes.GetPath(),
+			user.GetName(), userProjects.List(), userRoles.List(), reason)
+	}
 	klog.V(4).Infof("Authorization failed, user: %#v, attributes: %#v\n", attributes.GetUser(), attributes)
-	return authorizer.DecisionDeny, "No policy matched.", nil
+	return authorizer.DecisionDeny, reason, nil
+}
+
+// policyAllows checks whether the policy allows the request. ResourcePermissionsSpec and NonResourcePermissionsSpec
+// take precedence over ResourceSpec and NonResourceSpec.
+func policyAllows(p *policy, attributes authorizer.Attributes) bool {
+	if attributes.IsResourceRequest() {
+		if p.ResourcePermissionsSpec != nil {
+			return resourcePermissionAllowed(p.ResourcePermissionsSpec, attributes)
+		} else if p.ResourceSpec != nil {
+			return resourceMatches(*p, attributes)
+		}
+		return false
+	}
+
+	if p.NonResourcePermissionsSpec != nil {
+		return nonResourcePermissionAllowed(p.NonResourcePermissionsSpec, attributes)
+	} else if p.NonResourceSpec != nil {
+		return nonResourceMatches(*p, attributes)
+	}
+	return false
 }
@@ -378,3 +378,68 @@ func TestAuthorizerVersion2(t *testing.T) {
 	decision, _, _ = a.Authorize(attrs)
 	th.AssertEquals(t, authorizer.DecisionAllow, decision)
 }
+
+func TestAuthorizerDenyReason(t *testing.T) {
+	path, err := os.Getwd()
+	th.AssertNoErr(t, err)
+	path += "/authorizer_test_policy_version2.json"
+	policy, err := newFromFile(path)
+	th.AssertNoErr(t, err)
+
+	a := &Authorizer{authURL: "127.0.0.1", pl: policy}
+
+	viewer := &user.DefaultInfo{
+		Name: "viewer",
+		Extra: map[string][]string{
+			ProjectName: {"demo"},
+			Roles:       {"viewer"},
+		},
+	}
+	stranger := &user.DefaultInfo{
+		Name: "stranger",
+		Extra: map[string][]string{
+			ProjectName: {"other"},
+			Roles:       {"viewer"},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		attrs    authorizer.AttributesRecord
+		decision authorizer.Decision
+		reason   string
+	}{
+		{
+			name:     "allowed by the viewer policy",
+			attrs:    authorizer.AttributesRecord{User: viewer, ResourceRequest: true, Verb: "list", Namespace: "default", Resource: "pods"},
+			decision: authorizer.DecisionAllow,
+			reason:   "",
+		},
+		{
+			name:     "verb not allowed by the viewer policy",
+			attrs:    authorizer.AttributesRecord{User: viewer, ResourceRequest: true, Verb: "delete", Namespace: "default", Resource: "pods"},
+			decision: authorizer.DecisionDeny,
+			reason:   "Policies [1] matched the user but none of them allows the request.",
+		},
+		{
+			name:     "non-resource request not allowed by the viewer policy",
+			attrs:    authorizer.AttributesRecord{User: viewer, ResourceRequest: false, Verb: "get", Path: "/healthz"},
+			decision: authorizer.DecisionDeny,
+			reason:   "Policies [1] matched the user but none of them allows the request.",
+		},
+		{
+			name:     "project not in any policy",
+			attrs:    authorizer.AttributesRecord{User: stranger, ResourceRequest: true, Verb: "get", Namespace: "default", Resource: "pods"},
+			decision: authorizer.DecisionDeny,
+			reason:   "No policy matched.",
+		},
+	}
+
+	for _, tc := range testCases {
+		decision, reason, err := a.Authorize(tc.attrs)
+		th.AssertNoErr(t, err)
+		if decision != tc.decision || reason != tc.reason {
+			t.Errorf("%s: got decision %v with reason %q, expected %v with reason %q", tc.name, decision, reason, tc.decision, tc.reason)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package keystone
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -36,6 +37,8 @@ type Config struct {
 	SyncConfigFile      string
 	SyncConfigMapName   string
 	Kubeconfig          string
+	GroupPrefix         string
+	TokenCacheTTL       time.Duration
 }
 
 // NewConfig returns a Config
@@ -51,6 +54,8 @@ func NewConfig() *Config {
 		SyncConfigFile:      os.Getenv("KEYSTONE_SYNC_CONFIG_FILE"),
 		SyncConfigMapName:   os.Getenv("KEYSTONE_SYNC_CONFIGMAP_NAME"),
 		Kubeconfig:          os.Getenv("KEYSTONE_KUBECONFIG_FILE"),
+		GroupPrefix:         os.Getenv("KEYSTONE_GROUP_PREFIX"),
+		TokenCacheTTL:       30 * time.Second,
 	}
 }
 
@@ -91,4 +96,6 @@ func (c *Config) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&c.SyncConfigFile, "sync-config-file", c.SyncConfigFile, "File containing config values for data synchronization beetween Keystone and Kubernetes.")
 	fs.StringVar(&c.SyncConfigMapName, "sync-configmap-name", "", "ConfigMap in kube-system namespace containing config values for data synchronization beetween Keystone and Kubernetes.")
 	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "Kubeconfig file used to connect to Kubernetes API to get policy configmap. If the service is running inside the pod, this option is not necessary, will use in-cluster config instead.")
+	fs.StringVar(&c.GroupPrefix, "keystone-group-prefix", c.GroupPrefix, "Prefix prepended to the names of the user's Keystone groups in the authenticated user's groups, e.g. 'keystone:' maps the Keystone group 'admins' to 'keystone:admins'.")
+	fs.DurationVar(&c.TokenCacheTTL, "token-cache-ttl", c.TokenCacheTTL, "How long validated tokens are cached, the cache entry never outlives the token itself. Revoked tokens are still accepted until their cache entry expires, so keep it short. Set to 0 to disable the cache.")
 }
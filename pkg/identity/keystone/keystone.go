@@ -474,7 +474,7 @@ func NewKeystoneAuth(c *Config) (*KeystoneAuth, error) {
 	}
 
 	keystoneAuth := &KeystoneAuth{
-		authn:     &Authenticator{keystoner: NewKeystoner(keystoneClient)},
+		authn:     NewAuthenticator(NewKeystoner(keystoneClient), c.GroupPrefix, c.TokenCacheTTL),
 		authz:     &Authorizer{authURL: c.KeystoneURL, client: keystoneClient, pl: policy},
 		syncer:    &Syncer{k8sClient: k8sClient, syncConfig: sc},
 		k8sClient: k8sClient,
@@ -12,7 +12,9 @@ type BarbicanService interface {
 }
 
 type KMSOpts struct {
-	KeyID string `gcfg:"key-id"`
+	KeyID        string                        `gcfg:"key-id"`
+	KeyCacheTTL  openstack_provider.MyDuration `gcfg:"key-cache-ttl"`
+	KeyCacheSize int                           `gcfg:"key-cache-size"`
 }
 
 //Config to read config options
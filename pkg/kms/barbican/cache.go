@@ -0,0 +1,112 @@
+package barbican
+
+import (
+	"sync"
+	"time"
+)
+
+// CachedBarbican keeps the secrets retrieved from Barbican in memory for a
+// limited time, so repeated requests for the same key don't hit Barbican.
+// Secrets are never written to disk.
+type CachedBarbican struct {
+	Service BarbicanService
+
+	ttl     time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	payload   []byte
+	expiresAt time.Time
+}
+
+// NewCachedBarbican returns a BarbicanService caching at most size secrets of
+// service for ttl.
+func NewCachedBarbican(service BarbicanService, ttl time.Duration, size int) *CachedBarbican {
+	return &CachedBarbican{
+		Service: service,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]cachedSecret),
+	}
+}
+
+// GetSecret gets unencrypted secret, from the cache if it's still valid
+func (c *CachedBarbican) GetSecret(keyID string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := c.entries[keyID]; ok {
+		if now.Before(e.expiresAt) {
+			// Hand out a copy, the cached payload is wiped on removal
+			return append([]byte(nil), e.payload...), nil
+		}
+		c.remove(keyID)
+	}
+
+	key, err := c.Service.GetSecret(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[keyID] = cachedSecret{payload: append([]byte(nil), key...), expiresAt: now.Add(c.ttl)}
+
+	return key, nil
+}
+
+// Invalidate drops the cached secret, e.g. when it doesn't decrypt the data
+// any longer after a key rotation.
+func (c *CachedBarbican) Invalidate(keyID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(keyID)
+}
+
+// Clear wipes and drops all the cached secrets.
+func (c *CachedBarbican) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for keyID := range c.entries {
+		c.remove(keyID)
+	}
+}
+
+// evict drops the expired secrets, or the one expiring first if none has
+// expired yet. It must be called with the lock held.
+func (c *CachedBarbican) evict(now time.Time) {
+	var oldest string
+	for keyID, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			c.remove(keyID)
+			continue
+		}
+		if oldest == "" || e.expiresAt.Before(c.entries[oldest].expiresAt) {
+			oldest = keyID
+		}
+	}
+
+	if len(c.entries) >= c.size && oldest != "" {
+		c.remove(oldest)
+	}
+}
+
+// remove wipes and drops a cached secret. It must be called with the lock held.
+func (c *CachedBarbican) remove(keyID string) {
+	e, ok := c.entries[keyID]
+	if !ok {
+		return
+	}
+
+	for i := range e.payload {
+		e.payload[i] = 0
+	}
+	delete(c.entries, keyID)
+}
@@ -0,0 +1,98 @@
+package barbican
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type countingBarbican struct {
+	FakeBarbican
+	calls int
+}
+
+func (c *countingBarbican) GetSecret(keyID string) ([]byte, error) {
+	c.calls++
+	return c.FakeBarbican.GetSecret(keyID)
+}
+
+func TestCachedBarbican(t *testing.T) {
+	service := &countingBarbican{}
+	c := NewCachedBarbican(service, time.Hour, 1)
+
+	expected, _ := (&FakeBarbican{}).GetSecret("key1")
+
+	// The second request is served from the cache
+	for i := 0; i < 2; i++ {
+		key, err := c.GetSecret("key1")
+		if err != nil || !bytes.Equal(key, expected) {
+			t.Fatalf("GetSecret(key1) = %v, %v, expected %v", key, err, expected)
+		}
+	}
+	if service.calls != 1 {
+		t.Errorf("expected 1 call to Barbican, got %d", service.calls)
+	}
+
+	// The cache is bounded, key2 evicts key1
+	if _, err := c.GetSecret("key2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSecret("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if service.calls != 3 {
+		t.Errorf("expected 3 calls to Barbican, got %d", service.calls)
+	}
+
+	c.Invalidate("key1")
+	if _, err := c.GetSecret("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if service.calls != 4 {
+		t.Errorf("expected 4 calls to Barbican after invalidation, got %d", service.calls)
+	}
+
+	c.Clear()
+	if len(c.entries) != 0 {
+		t.Errorf("expected an empty cache after Clear, got %d entries", len(c.entries))
+	}
+}
+
+func TestCachedBarbicanExpiry(t *testing.T) {
+	service := &countingBarbican{}
+	c := NewCachedBarbican(service, time.Millisecond, 1)
+
+	if _, err := c.GetSecret("key1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, err := c.GetSecret("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if service.calls != 2 {
+		t.Errorf("expected 2 calls to Barbican, got %d", service.calls)
+	}
+}
+
+func TestCachedBarbicanWipe(t *testing.T) {
+	c := NewCachedBarbican(&FakeBarbican{}, time.Hour, 1)
+
+	key, err := c.GetSecret("key1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := c.entries["key1"].payload
+
+	c.Clear()
+	for _, b := range cached {
+		if b != 0 {
+			t.Fatalf("cached key was not wiped: %v", cached)
+		}
+	}
+
+	// Keys handed out are copies and stay usable
+	expected, _ := (&FakeBarbican{}).GetSecret("key1")
+	if !bytes.Equal(key, expected) {
+		t.Errorf("key handed out was modified: %v", key)
+	}
+}
@@ -20,6 +20,9 @@ const (
 	version        = "v1beta1"
 	runtimename    = "barbican"
 	runtimeversion = "0.0.1"
+
+	// defaultKeyCacheSize is the default maximum number of keys kept in the key cache
+	defaultKeyCacheSize = 16
 )
 
 // KMSserver struct
@@ -58,6 +61,17 @@ func Run(configFilePath string, socketpath string, sigchan <-chan os.Signal) (er
 	}
 	s.barbican = &barbican.Barbican{Client: client}
 
+	if ttl := s.cfg.KeyManager.KeyCacheTTL.Duration; ttl > 0 {
+		size := s.cfg.KeyManager.KeyCacheSize
+		if size <= 0 {
+			size = defaultKeyCacheSize
+		}
+		keyCache := barbican.NewCachedBarbican(s.barbican, ttl, size)
+		// Wipe the cached keys on shutdown
+		defer keyCache.Clear()
+		s.barbican = keyCache
+	}
+
 	// unlink the unix socket
 	if err = unix.Unlink(socketpath); err != nil {
 		klog.V(4).Infof("Error to unlink unix socket: %v", err)
@@ -109,8 +123,22 @@ func (s *KMSserver) Decrypt(ctx context.Context, req *pb.DecryptRequest) (*pb.De
 
 	plain, err := aescbc.Decrypt(req.Cipher, key)
 	if err != nil {
-		klog.V(4).Infof("Failed to decrypt data %v: ", err)
-		return nil, err
+		keyCache, ok := s.barbican.(*barbican.CachedBarbican)
+		if !ok {
+			klog.V(4).Infof("Failed to decrypt data %v: ", err)
+			return nil, err
+		}
+
+		// The cached key may be stale after a key rotation, retry with the key from Barbican
+		keyCache.Invalidate(s.cfg.KeyManager.KeyID)
+		if key, err = keyCache.GetSecret(s.cfg.KeyManager.KeyID); err != nil {
+			klog.V(4).Infof("Failed to get key %v: ", err)
+			return nil, err
+		}
+		if plain, err = aescbc.Decrypt(req.Cipher, key); err != nil {
+			klog.V(4).Infof("Failed to decrypt data %v: ", err)
+			return nil, err
+		}
 	}
 
 	return &pb.DecryptResponse{Plain: plain}, nil
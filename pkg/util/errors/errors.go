@@ -17,6 +17,7 @@ limitations under the License.
 package errors
 
 import (
+	"net"
 	"net/http"
 
 	"github.com/gophercloud/gophercloud"
@@ -40,6 +41,43 @@ func IsNotFound(err error) bool {
 	return false
 }
 
+// IsTransient returns true if the error is a transient failure of the OpenStack
+// API, such as rate limiting, a server side error or a connection error, so
+// that the request may succeed when retried.
+func IsTransient(err error) bool {
+	switch e := err.(type) {
+	case gophercloud.ErrDefault429, gophercloud.ErrDefault500, gophercloud.ErrDefault503:
+		return true
+	case gophercloud.ErrUnexpectedResponseCode:
+		switch e.Actual {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+// IsForbidden returns true if the error is a 403 response, e.g. of a request
+// only allowed to the admins.
+func IsForbidden(err error) bool {
+	if _, ok := err.(gophercloud.ErrDefault403); ok {
+		return true
+	}
+
+	if errCode, ok := err.(gophercloud.ErrUnexpectedResponseCode); ok {
+		if errCode.Actual == http.StatusForbidden {
+			return true
+		}
+	}
+
+	return false
+}
+
 func IsInvalidError(err error) bool {
 	if _, ok := err.(gophercloud.ErrDefault400); ok {
 		return true
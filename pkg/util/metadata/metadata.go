@@ -75,10 +75,11 @@ type DeviceMetadata struct {
 // Metadata has the information fetched from OpenStack metadata service or
 // config drives. Assumes the "latest" meta_data.json format.
 type Metadata struct {
-	UUID             string           `json:"uuid"`
-	Name             string           `json:"name"`
-	AvailabilityZone string           `json:"availability_zone"`
-	Devices          []DeviceMetadata `json:"devices,omitempty"`
+	UUID             string            `json:"uuid"`
+	Name             string            `json:"name"`
+	AvailabilityZone string            `json:"availability_zone"`
+	Meta             map[string]string `json:"meta,omitempty"`
+	Devices          []DeviceMetadata  `json:"devices,omitempty"`
 	// .. and other fields we don't care about.  Expand as necessary.
 }
 
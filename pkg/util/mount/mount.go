@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -34,17 +35,18 @@ import (
 )
 
 const (
-	probeVolumeDuration      = 1 * time.Second
-	probeVolumeTimeout       = 60 * time.Second
-	operationFinishInitDelay = 1 * time.Second
-	operationFinishFactor    = 1.1
-	operationFinishSteps     = 15
+	probeVolumeDuration = 1 * time.Second
+	probeVolumeTimeout  = 60 * time.Second
 )
 
+// sysBlockPath is the sysfs directory of the block devices, whose serial and
+// WWID are read when udev didn't create the /dev/disk/by-id links
+var sysBlockPath = "/sys/block"
+
 type IMount interface {
 	Mounter() *mount.SafeFormatAndMount
 	ScanForAttach(devicePath string) error
-	GetDevicePath(volumeID string) (string, error)
+	GetDevicePath(volumeID string, timeout time.Duration) (string, error)
 	IsLikelyNotMountPointAttach(targetpath string) (bool, error)
 	UnmountPath(mountPath string) error
 	MakeFile(pathname string) error
@@ -117,16 +119,15 @@ func probeVolume() error {
 }
 
 // GetDevicePath returns the path of an attached block storage volume, specified by its id.
-func (m *Mount) GetDevicePath(volumeID string) (string, error) {
-	backoff := wait.Backoff{
-		Duration: operationFinishInitDelay,
-		Factor:   operationFinishFactor,
-		Steps:    operationFinishSteps,
-	}
-
+// The device is matched by the serial or the WWN of the disk, the volumes are
+// probed until it is found or the timeout expires.
+func (m *Mount) GetDevicePath(volumeID string, timeout time.Duration) (string, error) {
 	var devicePath string
-	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+	err := wait.PollImmediate(probeVolumeDuration, timeout, func() (bool, error) {
 		devicePath = m.getDevicePathBySerialID(volumeID)
+		if devicePath == "" {
+			devicePath = getDevicePathBySysfs(volumeID)
+		}
 		if devicePath != "" {
 			return true, nil
 		}
@@ -136,24 +137,91 @@ func (m *Mount) GetDevicePath(volumeID string) (string, error) {
 	})
 
 	if err == wait.ErrWaitTimeout {
-		return "", fmt.Errorf("Failed to find device for the volumeID: %q within the alloted time", volumeID)
+		return "", fmt.Errorf("Failed to find device for the volumeID: %q within %v", volumeID, timeout)
 	} else if devicePath == "" {
 		return "", fmt.Errorf("Device path was empty for volumeID: %q", volumeID)
 	}
+
+	device, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the device %s of volumeID %q: %v", devicePath, volumeID, err)
+	}
+	klog.V(2).Infof("Found the device of volumeID %q: %s (%s)", volumeID, devicePath, device)
 	return devicePath, nil
 }
 
+// maxSerialLength is the length the hypervisors may truncate the disk serials to
+const maxSerialLength = 20
+
+// truncatedSerial returns the volume id truncated to the serial length of
+// the hypervisors, the shorter ids are returned as is.
+func truncatedSerial(volumeID string) string {
+	if len(volumeID) > maxSerialLength {
+		return volumeID[:maxSerialLength]
+	}
+	return volumeID
+}
+
+// serialCandidates returns the serials and WWNs set by the Nova drivers on
+// the disk of a volume. They include the Cinder volume id, which the
+// hypervisors may truncate to 20 characters.
+func serialCandidates(volumeID string) []string {
+	return []string{
+		truncatedSerial(volumeID),
+		volumeID,
+		strings.Replace(volumeID, "-", "", -1),
+	}
+}
+
+// getDevicePathBySysfs returns the path of an attached block storage volume by
+// matching the serial of the virtio disks and the WWID of the SCSI disks read
+// from sysfs.
+func getDevicePathBySysfs(volumeID string) string {
+	dirs, err := ioutil.ReadDir(sysBlockPath)
+	if err != nil {
+		klog.V(4).Infof("ReadDir failed with error %v", err)
+		return ""
+	}
+
+	candidates := serialCandidates(volumeID)
+	for _, d := range dirs {
+		for _, file := range []string{"serial", "device/wwid"} {
+			data, err := ioutil.ReadFile(path.Join(sysBlockPath, d.Name(), file))
+			if err != nil {
+				continue
+			}
+			// The WWID of a SCSI disk ends with its serial, prefixed with
+			// the vendor and the model, e.g. "t10.QEMU QEMU HARDDISK <serial>"
+			// or "naa.<wwn>"
+			fields := strings.Fields(string(data))
+			if len(fields) == 0 {
+				continue
+			}
+			value := strings.TrimPrefix(fields[len(fields)-1], "naa.")
+			for _, c := range candidates {
+				if strings.EqualFold(value, c) {
+					klog.V(4).Infof("Found disk attached as %q with %s %q", d.Name(), file, value)
+					return path.Join("/dev", d.Name())
+				}
+			}
+		}
+	}
+
+	klog.V(4).Infof("Failed to find device for the volumeID: %q in %s", volumeID, sysBlockPath)
+	return ""
+}
+
 // GetDevicePathBySerialID returns the path of an attached block storage volume, specified by its id.
 func (m *Mount) getDevicePathBySerialID(volumeID string) string {
 	// Build a list of candidate device paths.
 	// Certain Nova drivers will set the disk serial ID, including the Cinder volume id.
 	candidateDeviceNodes := []string{
 		// KVM
-		fmt.Sprintf("virtio-%s", volumeID[:20]),
+		fmt.Sprintf("virtio-%s", truncatedSerial(volumeID)),
 		// KVM #852
 		fmt.Sprintf("virtio-%s", volumeID),
 		// KVM virtio-scsi
-		fmt.Sprintf("scsi-0QEMU_QEMU_HARDDISK_%s", volumeID[:20]),
+		fmt.Sprintf("scsi-0QEMU_QEMU_HARDDISK_%s", truncatedSerial(volumeID)),
 		// KVM virtio-scsi #852
 		fmt.Sprintf("scsi-0QEMU_QEMU_HARDDISK_%s", volumeID),
 		// ESXi
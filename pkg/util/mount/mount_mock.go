@@ -17,6 +17,8 @@ limitations under the License.
 package mount
 
 import (
+	"time"
+
 	mock "github.com/stretchr/testify/mock"
 	utilsexec "k8s.io/utils/exec"
 	exec "k8s.io/utils/exec/testing"
@@ -106,20 +108,20 @@ func (_m *MountMock) ScanForAttach(devicePath string) error {
 	return r0
 }
 
-// GetDevicePath provides a mock function with given fields: volumeID
-func (_m *MountMock) GetDevicePath(volumeID string) (string, error) {
-	ret := _m.Called(volumeID)
+// GetDevicePath provides a mock function with given fields: volumeID, timeout
+func (_m *MountMock) GetDevicePath(volumeID string, timeout time.Duration) (string, error) {
+	ret := _m.Called(volumeID, timeout)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(volumeID)
+	if rf, ok := ret.Get(0).(func(string, time.Duration) string); ok {
+		r0 = rf(volumeID, timeout)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(volumeID)
+	if rf, ok := ret.Get(1).(func(string, time.Duration) error); ok {
+		r1 = rf(volumeID, timeout)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,48 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mount
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSerialCandidates(t *testing.T) {
+	testCases := []struct {
+		volumeID string
+		expected []string
+	}{
+		{
+			volumeID: "4d2dd372-c8e0-4bd5-b9d4-1a9b4c7d5ad1",
+			expected: []string{"4d2dd372-c8e0-4bd5-b", "4d2dd372-c8e0-4bd5-b9d4-1a9b4c7d5ad1", "4d2dd372c8e04bd5b9d41a9b4c7d5ad1"},
+		},
+		{
+			volumeID: "vol-1",
+			expected: []string{"vol-1", "vol-1", "vol1"},
+		},
+		{
+			volumeID: "",
+			expected: []string{"", "", ""},
+		},
+	}
+
+	for _, test := range testCases {
+		if candidates := serialCandidates(test.volumeID); !reflect.DeepEqual(candidates, test.expected) {
+			t.Errorf("serialCandidates(%q) = %v, expected %v", test.volumeID, candidates, test.expected)
+		}
+	}
+}
@@ -21,8 +21,11 @@ import (
 	"strings"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/keymanager/v1/containers"
 	"github.com/gophercloud/gophercloud/openstack/keymanager/v1/secrets"
 	"k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack/metrics"
+
+	cpoerrors "k8s.io/cloud-provider-openstack/pkg/util/errors"
 )
 
 // EnsureSecret creates a secret if it doesn't exist.
@@ -95,6 +98,37 @@ func ParseSecretID(ref string) (string, error) {
 	return parts[len(parts)-1], nil
 }
 
+// GetContainerRefStatus returns the status of the certificate container, or of
+// the secret, e.g. a PKCS12 bundle, of the given Barbican reference.
+func GetContainerRefStatus(client *gophercloud.ServiceClient, ref string) (string, error) {
+	id, err := ParseSecretID(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.Contains(ref, "/containers/") {
+		mc := metrics.NewMetricContext("container", "get")
+		container, err := containers.Get(client, id).Extract()
+		if mc.ObserveRequest(err) != nil {
+			if cpoerrors.IsNotFound(err) {
+				return "", ErrNotFound
+			}
+			return "", err
+		}
+		return container.Status, nil
+	}
+
+	mc := metrics.NewMetricContext("secret", "get")
+	secret, err := secrets.Get(client, id).Extract()
+	if mc.ObserveRequest(err) != nil {
+		if cpoerrors.IsNotFound(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return secret.Status, nil
+}
+
 // DeleteSecrets deletes all the secrets that including the name string.
 func DeleteSecrets(client *gophercloud.ServiceClient, partName string) error {
 	listOpts := secrets.ListOpts{
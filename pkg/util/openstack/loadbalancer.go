@@ -19,6 +19,7 @@ package openstack
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
@@ -26,6 +27,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
 	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
 	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/pools"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/providers"
 	"github.com/gophercloud/gophercloud/pagination"
 	version "github.com/hashicorp/go-version"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -41,6 +43,7 @@ const (
 	OctaviaFeatureFlavors           = 2
 	OctaviaFeatureTimeout           = 3
 	OctaviaFeatureAvailabilityZones = 4
+	OctaviaFeatureSCTP              = 5
 
 	loadbalancerActiveInitDelay = 1 * time.Second
 	loadbalancerActiveFactor    = 1.2
@@ -53,6 +56,9 @@ const (
 var (
 	octaviaVersion string
 
+	// batchUpdateUnsupported is set once Octavia rejected a batch member update
+	batchUpdateUnsupported bool
+
 	// ErrNotFound is used to inform that the object is missing
 	ErrNotFound = errors.New("failed to find object")
 
@@ -125,6 +131,11 @@ func IsOctaviaFeatureSupported(client *gophercloud.ServiceClient, feature int) b
 		if currentVer.GreaterThanOrEqual(verAvailabilityZones) {
 			return true
 		}
+	case OctaviaFeatureSCTP:
+		verSCTP, _ := version.NewVersion("v2.23")
+		if currentVer.GreaterThanOrEqual(verSCTP) {
+			return true
+		}
 	default:
 		klog.Warningf("Feature %d not recognized", feature)
 	}
@@ -213,6 +224,52 @@ func GetLoadbalancerByName(client *gophercloud.ServiceClient, name string) (*loa
 	return &loadbalancerList[0], nil
 }
 
+// GetProvider returns the enabled Octavia provider with the given name, raise ErrNotFound if the provider isn't enabled.
+func GetProvider(client *gophercloud.ServiceClient, name string) (*providers.Provider, error) {
+	mc := metrics.NewMetricContext("loadbalancer_provider", "list")
+	allPages, err := providers.List(client, providers.ListOpts{}).AllPages()
+	if mc.ObserveRequest(err) != nil {
+		return nil, err
+	}
+	providerList, err := providers.ExtractProviders(allPages)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range providerList {
+		if providerList[i].Name == name {
+			return &providerList[i], nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetFlavorIDByName returns the ID of the Octavia flavor with the given name, raise error if not found or get multiple ones.
+func GetFlavorIDByName(client *gophercloud.ServiceClient, name string) (string, error) {
+	var body struct {
+		Flavors []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"flavors"`
+	}
+
+	mc := metrics.NewMetricContext("loadbalancer_flavor", "list")
+	_, err := client.Get(client.ServiceURL("lbaas", "flavors")+"?name="+url.QueryEscape(name), &body, nil)
+	if mc.ObserveRequest(err) != nil {
+		return "", err
+	}
+
+	if len(body.Flavors) > 1 {
+		return "", ErrMultipleResults
+	}
+	if len(body.Flavors) == 0 {
+		return "", ErrNotFound
+	}
+
+	return body.Flavors[0].ID, nil
+}
+
 // GetListenerByName gets a listener by its name, raise error if not found or get multiple ones.
 func GetListenerByName(client *gophercloud.ServiceClient, name string, lbID string) (*listeners.Listener, error) {
 	opts := listeners.ListOpts{
@@ -352,14 +409,91 @@ func GetMembersbyPool(client *gophercloud.ServiceClient, poolID string) ([]pools
 	return members, nil
 }
 
+// BatchUpdatePoolMembers replaces the members of the pool in a single call, falling back to per-member calls if the
+// Octavia batch member update API isn't supported.
 func BatchUpdatePoolMembers(client *gophercloud.ServiceClient, lbID, poolID string, opts []pools.BatchUpdateMemberOpts) error {
-	err := pools.BatchUpdateMembers(client, poolID, opts).ExtractErr()
+	if !batchUpdateUnsupported {
+		mc := metrics.NewMetricContext("loadbalancer_member", "batch_update")
+		err := pools.BatchUpdateMembers(client, poolID, opts).ExtractErr()
+		if _, ok := err.(gophercloud.ErrDefault405); !ok {
+			if mc.ObserveRequest(err) != nil {
+				return err
+			}
+			if err := waitLoadbalancerActive(client, lbID); err != nil {
+				return fmt.Errorf("failed to wait for load balancer ACTIVE after updating pool members for %s: %v", poolID, err)
+			}
+			return nil
+		}
+
+		klog.Warningf("The batch member update is not supported by Octavia, updating the members one by one")
+		batchUpdateUnsupported = true
+	}
+
+	return updatePoolMembers(client, lbID, poolID, opts)
+}
+
+// updatePoolMembers replaces the members of the pool by creating, updating and deleting the members one by one.
+func updatePoolMembers(client *gophercloud.ServiceClient, lbID, poolID string, opts []pools.BatchUpdateMemberOpts) error {
+	poolMembers, err := GetMembersbyPool(client, poolID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get members in the pool %s: %v", poolID, err)
+	}
+	curMembers := make(map[string]pools.Member)
+	for _, m := range poolMembers {
+		curMembers[fmt.Sprintf("%s-%d", m.Address, m.ProtocolPort)] = m
 	}
 
-	if err := waitLoadbalancerActive(client, lbID); err != nil {
-		return fmt.Errorf("failed to wait for load balancer ACTIVE after updating pool members for %s: %v", poolID, err)
+	for _, opt := range opts {
+		key := fmt.Sprintf("%s-%d", opt.Address, opt.ProtocolPort)
+		member, ok := curMembers[key]
+		delete(curMembers, key)
+
+		if !ok {
+			createOpts := pools.CreateMemberOpts{
+				Address:      opt.Address,
+				ProtocolPort: opt.ProtocolPort,
+				Weight:       opt.Weight,
+				AdminStateUp: opt.AdminStateUp,
+			}
+			if opt.Name != nil {
+				createOpts.Name = *opt.Name
+			}
+			if opt.SubnetID != nil {
+				createOpts.SubnetID = *opt.SubnetID
+			}
+			mc := metrics.NewMetricContext("loadbalancer_member", "create")
+			_, err := pools.CreateMember(client, poolID, createOpts).Extract()
+			if mc.ObserveRequest(err) != nil {
+				return fmt.Errorf("error creating member %s for pool %s: %v", key, poolID, err)
+			}
+		} else if (opt.Weight != nil && *opt.Weight != member.Weight) || (opt.Name != nil && *opt.Name != member.Name) ||
+			(opt.AdminStateUp != nil && *opt.AdminStateUp != member.AdminStateUp) {
+			mc := metrics.NewMetricContext("loadbalancer_member", "update")
+			_, err := pools.UpdateMember(client, poolID, member.ID, pools.UpdateMemberOpts{Name: opt.Name, Weight: opt.Weight, AdminStateUp: opt.AdminStateUp}).Extract()
+			if mc.ObserveRequest(err) != nil {
+				return fmt.Errorf("error updating member %s for pool %s: %v", member.ID, poolID, err)
+			}
+		} else {
+			continue
+		}
+
+		if err := waitLoadbalancerActive(client, lbID); err != nil {
+			return fmt.Errorf("failed to wait for load balancer ACTIVE after updating member %s for pool %s: %v", key, poolID, err)
+		}
+	}
+
+	for _, member := range curMembers {
+		mc := metrics.NewMetricContext("loadbalancer_member", "delete")
+		err := pools.DeleteMember(client, poolID, member.ID).ExtractErr()
+		if err != nil && !cpoerrors.IsNotFound(err) {
+			mc.ObserveRequest(err)
+			return fmt.Errorf("error deleting member %s for pool %s: %v", member.ID, poolID, err)
+		}
+		mc.ObserveRequest(nil)
+
+		if err := waitLoadbalancerActive(client, lbID); err != nil {
+			return fmt.Errorf("failed to wait for load balancer ACTIVE after deleting member %s for pool %s: %v", member.ID, poolID, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/pools"
+	th "github.com/gophercloud/gophercloud/testhelper"
+	fake "github.com/gophercloud/gophercloud/testhelper/client"
+)
+
+func TestBatchUpdatePoolMembersFallback(t *testing.T) {
+	th.SetupHTTP()
+	defer th.TeardownHTTP()
+	defer func() { batchUpdateUnsupported = false }()
+
+	var requests []string
+	th.Mux.HandleFunc("/lbaas/loadbalancers/lb", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "GET")
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprint(w, `{"loadbalancer": {"id": "lb", "provisioning_status": "ACTIVE"}}`)
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool/members", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"members": [
+				{"id": "m1", "address": "10.0.0.1", "protocol_port": 30000, "weight": 1, "name": "node-1"},
+				{"id": "m2", "address": "10.0.0.2", "protocol_port": 30000, "weight": 1, "name": "node-2"}
+			]}`)
+		case "PUT":
+			requests = append(requests, "batch")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case "POST":
+			body, _ := ioutil.ReadAll(r.Body)
+			th.AssertJSONEquals(t, `{"member": {"address": "10.0.0.3", "protocol_port": 30000, "name": "node-3", "subnet_id": "subnet"}}`, string(body))
+			requests = append(requests, "create")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"member": {"id": "m3", "address": "10.0.0.3", "protocol_port": 30000}}`)
+		}
+	})
+	th.Mux.HandleFunc("/lbaas/pools/pool/members/", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Path[len("/lbaas/pools/pool/members/"):]
+		switch r.Method {
+		case "PUT":
+			requests = append(requests, "update "+id)
+			w.Header().Add("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"member": {"id": "%s"}}`, id)
+		case "DELETE":
+			requests = append(requests, "delete "+id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	name1, name3, subnet, weight := "node-1", "node-3", "subnet", 0
+	opts := []pools.BatchUpdateMemberOpts{
+		{Address: "10.0.0.1", ProtocolPort: 30000, Name: &name1, Weight: &weight},
+		{Address: "10.0.0.3", ProtocolPort: 30000, Name: &name3, SubnetID: &subnet},
+	}
+
+	if err := BatchUpdatePoolMembers(fake.ServiceClient(), "lb", "pool", opts); err != nil {
+		t.Fatalf("BatchUpdatePoolMembers() returned error: %v", err)
+	}
+	expected := []string{"batch", "update m1", "create", "delete m2"}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("BatchUpdatePoolMembers() made requests %v, expected %v", requests, expected)
+	}
+
+	// The batch member update isn't tried again once it is known to be unsupported
+	requests = nil
+	if err := BatchUpdatePoolMembers(fake.ServiceClient(), "lb", "pool", opts); err != nil {
+		t.Fatalf("BatchUpdatePoolMembers() returned error: %v", err)
+	}
+	expected = []string{"update m1", "create", "delete m2"}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("BatchUpdatePoolMembers() made requests %v, expected %v", requests, expected)
+	}
+}
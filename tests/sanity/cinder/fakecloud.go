@@ -32,7 +32,7 @@ func getfakecloud() *cloud {
 var _ cco.IOpenStack = &cloud{}
 
 // Fake Cloud
-func (cloud *cloud) CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourceVolID string, tags *map[string]string) (*volumes.Volume, error) {
+func (cloud *cloud) CreateVolume(name string, size int, vtype, availability string, snapshotID string, sourceVolID string, multiattach bool, tags *map[string]string) (*volumes.Volume, error) {
 
 	vol := &volumes.Volume{
 		ID:               randString(10),
@@ -43,6 +43,7 @@ func (cloud *cloud) CreateVolume(name string, size int, vtype, availability stri
 		AvailabilityZone: availability,
 		SnapshotID:       snapshotID,
 		SourceVolID:      sourceVolID,
+		Multiattach:      multiattach,
 	}
 
 	cloud.volumes[vol.ID] = vol
@@ -254,10 +255,22 @@ func (cloud *cloud) GetInstanceByID(instanceID string) (*servers.Server, error)
 	return inst, nil
 }
 
+func (cloud *cloud) IsMultiattachVolumeType(volumeType string) (bool, error) {
+	return true, nil
+}
+
 func (cloud *cloud) ExpandVolume(volumeID string, size int) error {
 	return nil
 }
 
+func (cloud *cloud) SetVolumeReadOnly(volumeID string, readOnly bool) error {
+	return nil
+}
+
+func (cloud *cloud) GetAvailableCapacity(volumeType, availability string) (int64, bool, error) {
+	return 0, false, nil
+}
+
 func (cloud *cloud) GetMaxVolLimit() int64 {
 	return 256
 }
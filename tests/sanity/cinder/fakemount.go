@@ -1,6 +1,8 @@
 package sanity
 
 import (
+	"time"
+
 	"k8s.io/cloud-provider-openstack/pkg/csi/cinder"
 	cpomount "k8s.io/cloud-provider-openstack/pkg/util/mount"
 	exec "k8s.io/utils/exec/testing"
@@ -51,7 +53,7 @@ func (m *fakemount) GetInstanceID() (string, error) {
 	return cinder.FakeInstanceID, nil
 }
 
-func (m *fakemount) GetDevicePath(volumeID string) (string, error) {
+func (m *fakemount) GetDevicePath(volumeID string, timeout time.Duration) (string, error) {
 	return cinder.FakeDevicePath, nil
 }
 
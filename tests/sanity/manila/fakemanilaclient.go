@@ -22,6 +22,7 @@ import (
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/messages"
+	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/sharenetworks"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/shares"
 	"github.com/gophercloud/gophercloud/openstack/sharedfilesystems/v2/snapshots"
 	openstack_provider "k8s.io/cloud-provider-openstack/pkg/cloudprovider/providers/openstack"
@@ -32,10 +33,12 @@ var (
 	fakeShareID       = 1
 	fakeAccessRightID = 1
 	fakeSnapshotID    = 1
+	fakeShareNetID    = 1
 
 	fakeShares       = make(map[int]*shares.Share)
 	fakeAccessRights = make(map[int]*shares.AccessRight)
 	fakeSnapshots    = make(map[int]*snapshots.Snapshot)
+	fakeShareNets    = make(map[int]*sharenetworks.ShareNetwork)
 )
 
 type fakeManilaClientBuilder struct{}
@@ -90,6 +93,22 @@ func (c fakeManilaClient) GetShareByName(shareName string) (*shares.Share, error
 	return c.GetShareByID(shareID)
 }
 
+func (c fakeManilaClient) GetShares(opts shares.ListOptsBuilder) ([]shares.Share, error) {
+	var shareNetworkID string
+	if listOpts, ok := opts.(shares.ListOpts); ok {
+		shareNetworkID = listOpts.ShareNetworkID
+	}
+
+	var ss []shares.Share
+	for _, share := range fakeShares {
+		if shareNetworkID == "" || share.ShareNetworkID == shareNetworkID {
+			ss = append(ss, *share)
+		}
+	}
+
+	return ss, nil
+}
+
 func (c fakeManilaClient) CreateShare(opts shares.CreateOptsBuilder) (*shares.Share, error) {
 	var res shares.CreateResult
 	res.Body = opts
@@ -244,6 +263,51 @@ func (c fakeManilaClient) DeleteSnapshot(snapID string) error {
 	return nil
 }
 
+func (c fakeManilaClient) GetShareNetworkByID(shareNetworkID string) (*sharenetworks.ShareNetwork, error) {
+	sn, ok := fakeShareNets[strToInt(shareNetworkID)]
+	if !ok {
+		return nil, gophercloud.ErrResourceNotFound{}
+	}
+
+	return sn, nil
+}
+
+func (c fakeManilaClient) GetShareNetworkByName(shareNetworkName string) (*sharenetworks.ShareNetwork, error) {
+	for _, sn := range fakeShareNets {
+		if sn.Name == shareNetworkName {
+			return sn, nil
+		}
+	}
+
+	return nil, gophercloud.ErrResourceNotFound{}
+}
+
+func (c fakeManilaClient) CreateShareNetwork(opts sharenetworks.CreateOptsBuilder) (*sharenetworks.ShareNetwork, error) {
+	var res sharenetworks.CreateResult
+	res.Body = opts
+
+	sn := &sharenetworks.ShareNetwork{}
+	if err := res.ExtractInto(sn); err != nil {
+		return nil, err
+	}
+
+	sn.ID = intToStr(fakeShareNetID)
+	fakeShareNets[fakeShareNetID] = sn
+	fakeShareNetID++
+
+	return sn, nil
+}
+
+func (c fakeManilaClient) DeleteShareNetwork(shareNetworkID string) error {
+	id := strToInt(shareNetworkID)
+	if _, ok := fakeShareNets[id]; !ok {
+		return gophercloud.ErrResourceNotFound{}
+	}
+
+	delete(fakeShareNets, id)
+	return nil
+}
+
 func (c fakeManilaClient) GetUserMessages(opts messages.ListOptsBuilder) ([]messages.Message, error) {
 	return nil, nil
 }